@@ -0,0 +1,58 @@
+package check
+
+import (
+	"log"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/autoupdate"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "auto_update",
+	}, autoUpdateCheck)
+}
+
+// updateTimer returns the timer that runs the automatic updates on the host
+func updateTimer(config *buildconfig.BuildConfig) string {
+	switch {
+	case config.Options.Bootc != nil:
+		return autoupdate.BootcUpdateTimer
+	case Exists("/run/ostree-booted"):
+		return autoupdate.RpmOstreedAutomaticTimer
+	default:
+		return autoupdate.DNFAutomaticTimer
+	}
+}
+
+func autoUpdateCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.AutoUpdate == nil {
+		return Skip("no auto_update customizations")
+	}
+	expected := config.Options.AutoUpdate
+	timer := updateTimer(config)
+
+	state, _, _, err := ExecString("systemctl", "is-enabled", timer)
+	if err != nil {
+		return Fail("update timer", timer, "is not enabled, error:", err)
+	}
+	if state != "enabled" {
+		return Fail("update timer", timer, "is not enabled, state:", state)
+	}
+	log.Printf("update timer %s is enabled\n", timer)
+
+	if expected.RebootWindow != "" {
+		path := "/etc/systemd/system/" + timer + ".d/50-auto-update.conf"
+		data, err := ReadFile(path)
+		if err != nil {
+			return Fail("failed to read", path, "error:", err)
+		}
+		if !strings.Contains(string(data), "OnCalendar="+expected.RebootWindow+"\n") {
+			return Fail("update timer", timer, "is not moved to the reboot window", expected.RebootWindow)
+		}
+		log.Printf("update timer %s runs at %s\n", timer, expected.RebootWindow)
+	}
+
+	return Pass()
+}
@@ -0,0 +1,105 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/autoupdate"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoUpdateCheck(t *testing.T) {
+	enabled := ExecResult{Stdout: []byte("enabled\n")}
+	windowDropIn := ReadFileResult{Data: []byte("[Timer]\nOnCalendar=\nOnCalendar=Sun 03:00\nRandomizedDelaySec=0\n")}
+
+	tests := []struct {
+		name         string
+		config       *autoupdate.Options
+		bootc        bool
+		mockExec     map[string]ExecResult
+		mockExists   map[string]bool
+		mockReadFile map[string]ReadFileResult
+		wantErr      error
+	}{
+		{
+			name:    "skip when no auto_update customizations",
+			config:  nil,
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name:   "pass when dnf-automatic is enabled in the reboot window",
+			config: &autoupdate.Options{Policy: "apply", RebootWindow: "Sun 03:00"},
+			mockExec: map[string]ExecResult{
+				"systemctl is-enabled dnf-automatic.timer": enabled,
+			},
+			mockReadFile: map[string]ReadFileResult{
+				"/etc/systemd/system/dnf-automatic.timer.d/50-auto-update.conf": windowDropIn,
+			},
+		},
+		{
+			name:   "pass when rpm-ostreed automatic updates are enabled",
+			config: &autoupdate.Options{Policy: "download-only"},
+			mockExec: map[string]ExecResult{
+				"systemctl is-enabled rpm-ostreed-automatic.timer": enabled,
+			},
+			mockExists: map[string]bool{"/run/ostree-booted": true},
+		},
+		{
+			name:   "pass when the bootc update timer is enabled",
+			config: &autoupdate.Options{Policy: "apply"},
+			bootc:  true,
+			mockExec: map[string]ExecResult{
+				"systemctl is-enabled bootc-fetch-apply-updates.timer": enabled,
+			},
+			mockExists: map[string]bool{"/run/ostree-booted": true},
+		},
+		{
+			name:   "fail when the timer is disabled",
+			config: &autoupdate.Options{Policy: "security"},
+			mockExec: map[string]ExecResult{
+				"systemctl is-enabled dnf-automatic.timer": {Stdout: []byte("disabled\n"), Code: 1, Err: errors.New("exit status 1")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when the timer is not in the reboot window",
+			config: &autoupdate.Options{Policy: "apply", RebootWindow: "Sat 02:00"},
+			mockExec: map[string]ExecResult{
+				"systemctl is-enabled dnf-automatic.timer": enabled,
+			},
+			mockReadFile: map[string]ReadFileResult{
+				"/etc/systemd/system/dnf-automatic.timer.d/50-auto-update.conf": windowDropIn,
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExec(t, tt.mockExec)
+			installMockExists(t, tt.mockExists)
+			installMockReadFile(t, tt.mockReadFile)
+
+			chk, found := check.FindCheckByName("auto_update")
+			require.True(t, found, "auto_update check not found")
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{AutoUpdate: tt.config},
+			}
+			if tt.bootc {
+				config.Options.Bootc = &distro.BootcImageOptions{}
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
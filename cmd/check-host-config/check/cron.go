@@ -0,0 +1,30 @@
+package check
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "cron",
+	}, cronCheck)
+}
+
+func cronCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.Cron == nil {
+		return Skip("no cron customizations")
+	}
+
+	for _, j := range config.Options.Cron.Jobs {
+		path := filepath.Join("/etc/cron.d", j.Name)
+		if !Exists(path) {
+			return Fail("cron job does not exist:", path)
+		}
+		log.Printf("cron job %s exists\n", path)
+	}
+
+	return Pass()
+}
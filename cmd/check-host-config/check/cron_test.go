@@ -0,0 +1,69 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/cron"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronCheck(t *testing.T) {
+	jobs := &cron.Options{Jobs: []cron.Job{
+		{Name: "backup", Schedule: "@daily", Command: "/usr/local/bin/backup"},
+		{Name: "report", Schedule: "0 6 * * mon", Command: "/usr/local/bin/report"},
+	}}
+
+	tests := []struct {
+		name       string
+		config     *cron.Options
+		mockExists map[string]bool
+		wantErr    error
+	}{
+		{
+			name:    "skip when no cron customizations",
+			config:  nil,
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name:   "pass when all jobs exist",
+			config: jobs,
+			mockExists: map[string]bool{
+				"/etc/cron.d/backup": true,
+				"/etc/cron.d/report": true,
+			},
+		},
+		{
+			name:   "fail when a job is missing",
+			config: jobs,
+			mockExists: map[string]bool{
+				"/etc/cron.d/backup": true,
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExists(t, tt.mockExists)
+
+			chk, found := check.FindCheckByName("cron")
+			require.True(t, found, "cron check not found")
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{Cron: tt.config},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
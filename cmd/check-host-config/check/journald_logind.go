@@ -0,0 +1,93 @@
+package check
+
+import (
+	"log"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "journald",
+	}, journaldCheck)
+	RegisterCheck(Metadata{
+		Name: "logind",
+	}, logindCheck)
+}
+
+// effectiveSettings parses the output of "systemd-analyze cat-config", the
+// configuration file followed by its drop-ins, and returns the value each
+// setting ends up with
+func effectiveSettings(config string) map[string]string {
+	settings := map[string]string{}
+	for _, line := range strings.Split(config, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return settings
+}
+
+// checkSettings compares the expected settings of a systemd daemon with
+// the effective ones, empty values are not checked
+func checkSettings(configFile string, expected map[string]string) error {
+	out, stderr, _, err := ExecString("systemd-analyze", "cat-config", configFile)
+	if err != nil {
+		return Fail("failed to read the configuration of", configFile, "error:", err, stderr)
+	}
+	settings := effectiveSettings(out)
+	for key, value := range expected {
+		if value == "" {
+			continue
+		}
+		if settings[key] != value {
+			return Fail(configFile, "setting", key, "is", settings[key], "expected", value)
+		}
+		log.Printf("%s setting %s=%s is set\n", configFile, key, value)
+	}
+	return nil
+}
+
+func journaldCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.Systemd == nil || config.Options.Systemd.Journald == nil {
+		return Skip("no journald customizations")
+	}
+	j := config.Options.Systemd.Journald
+
+	err := checkSettings("systemd/journald.conf", map[string]string{
+		"Storage":           j.Storage,
+		"SystemMaxUse":      j.SystemMaxUse,
+		"SystemKeepFree":    j.SystemKeepFree,
+		"SystemMaxFileSize": j.SystemMaxFileSize,
+		"RuntimeMaxUse":     j.RuntimeMaxUse,
+		"MaxRetentionSec":   j.MaxRetentionSec,
+	})
+	if err != nil {
+		return err
+	}
+
+	return Pass()
+}
+
+func logindCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.Systemd == nil || config.Options.Systemd.Logind == nil {
+		return Skip("no logind customizations")
+	}
+	l := config.Options.Systemd.Logind
+
+	err := checkSettings("systemd/logind.conf", map[string]string{
+		"IdleAction":         l.IdleAction,
+		"IdleActionSec":      l.IdleActionSec,
+		"StopIdleSessionSec": l.StopIdleSessionSec,
+	})
+	if err != nil {
+		return err
+	}
+
+	return Pass()
+}
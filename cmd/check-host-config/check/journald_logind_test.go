@@ -0,0 +1,120 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/systemd"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const journaldCatConfig = `# /etc/systemd/journald.conf
+[Journal]
+#Storage=auto
+SystemMaxUse=4G
+
+# /etc/systemd/journald.conf.d/10-persistent.conf
+[Journal]
+Storage=persistent
+
+# /etc/systemd/journald.conf.d/50-customizations.conf
+[Journal]
+Storage=volatile
+SystemMaxUse=1G
+`
+
+const logindCatConfig = `# /etc/systemd/logind.conf
+[Login]
+#IdleAction=ignore
+
+# /etc/systemd/logind.conf.d/50-customizations.conf
+[Login]
+IdleAction=lock
+IdleActionSec=15min
+`
+
+func TestJournaldLogindCheck(t *testing.T) {
+	catConfig := map[string]ExecResult{
+		"systemd-analyze cat-config systemd/journald.conf": {Stdout: []byte(journaldCatConfig)},
+		"systemd-analyze cat-config systemd/logind.conf":   {Stdout: []byte(logindCatConfig)},
+	}
+
+	tests := []struct {
+		name     string
+		check    string
+		config   *systemd.Options
+		mockExec map[string]ExecResult
+		wantErr  error
+	}{
+		{
+			name:    "journald skip when no systemd customizations",
+			check:   "journald",
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name:    "journald skip when no journald customizations",
+			check:   "journald",
+			config:  &systemd.Options{Logind: &systemd.Logind{IdleAction: "lock"}},
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name:     "journald pass when the last drop-in wins",
+			check:    "journald",
+			config:   &systemd.Options{Journald: &systemd.Journald{Storage: "volatile", SystemMaxUse: "1G"}},
+			mockExec: catConfig,
+		},
+		{
+			name:     "journald fail when a setting is overridden",
+			check:    "journald",
+			config:   &systemd.Options{Journald: &systemd.Journald{Storage: "persistent"}},
+			mockExec: catConfig,
+			wantErr:  check.ErrCheckFailed,
+		},
+		{
+			name:   "journald fail when systemd-analyze fails",
+			check:  "journald",
+			config: &systemd.Options{Journald: &systemd.Journald{Storage: "volatile"}},
+			mockExec: map[string]ExecResult{
+				"systemd-analyze cat-config systemd/journald.conf": {Code: 1, Err: errors.New("exit status 1")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:     "logind pass",
+			check:    "logind",
+			config:   &systemd.Options{Logind: &systemd.Logind{IdleAction: "lock", IdleActionSec: "15min"}},
+			mockExec: catConfig,
+		},
+		{
+			name:     "logind fail when a setting is missing",
+			check:    "logind",
+			config:   &systemd.Options{Logind: &systemd.Logind{StopIdleSessionSec: "1h"}},
+			mockExec: catConfig,
+			wantErr:  check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExec(t, tt.mockExec)
+
+			chk, found := check.FindCheckByName(tt.check)
+			require.True(t, found, "%s check not found", tt.check)
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{Systemd: tt.config},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package check
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "kdump",
+	}, kdumpCheck)
+}
+
+// kdumpCheck checks that kdump is enabled and configured. The crashkernel=
+// reservation is not checked, /proc/cmdline is not reliable when testing in
+// a container.
+func kdumpCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.Kdump == nil {
+		return Skip("no kdump customizations")
+	}
+	expected := config.Options.Kdump
+
+	state, _, _, err := ExecString("systemctl", "is-enabled", "kdump.service")
+	if err != nil {
+		return Fail("kdump service is not enabled, error:", err)
+	}
+	if state != "enabled" {
+		return Fail("kdump service is not enabled, state:", state)
+	}
+	log.Println("kdump service is enabled")
+
+	conf, err := ReadFile("/etc/kdump.conf")
+	if err != nil {
+		return Fail("failed to read /etc/kdump.conf:", err)
+	}
+	lines := strings.Split(string(conf), "\n")
+	if expected.Target != nil {
+		target := expected.Target.Type + " " + expected.Target.Location
+		if !slices.Contains(lines, target) {
+			return Fail("kdump target is not configured:", target)
+		}
+		log.Printf("kdump target %s is configured\n", target)
+	}
+	if expected.Path != "" && !slices.Contains(lines, "path "+expected.Path) {
+		return Fail("kdump path is not configured:", expected.Path)
+	}
+
+	return Pass()
+}
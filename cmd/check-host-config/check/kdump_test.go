@@ -0,0 +1,97 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/kdump"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const kdumpIsEnabledCmd = "systemctl is-enabled kdump.service"
+
+func TestKdumpCheck(t *testing.T) {
+	nfsConf := []byte("nfs dump.example.com:/export/crash\npath /var/crash\ncore_collector makedumpfile -l --message-level 7 -d 31\n")
+
+	tests := []struct {
+		name         string
+		config       *kdump.Options
+		mockExec     map[string]ExecResult
+		mockReadFile map[string]ReadFileResult
+		wantErr      error
+	}{
+		{
+			name:    "skip when no kdump customizations",
+			config:  nil,
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name: "pass when enabled and configured",
+			config: &kdump.Options{
+				Target: &kdump.Target{Type: "nfs", Location: "dump.example.com:/export/crash"},
+				Path:   "/var/crash",
+			},
+			mockExec: map[string]ExecResult{
+				kdumpIsEnabledCmd: {Stdout: []byte("enabled\n")},
+			},
+			mockReadFile: map[string]ReadFileResult{
+				"/etc/kdump.conf": {Data: nfsConf},
+			},
+		},
+		{
+			name:   "fail when service is disabled",
+			config: &kdump.Options{},
+			mockExec: map[string]ExecResult{
+				kdumpIsEnabledCmd: {Stdout: []byte("disabled\n"), Code: 1, Err: errors.New("exit status 1")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when target is not configured",
+			config: &kdump.Options{Target: &kdump.Target{Type: "ssh", Location: "kdump@dump.example.com"}},
+			mockExec: map[string]ExecResult{
+				kdumpIsEnabledCmd: {Stdout: []byte("enabled\n")},
+			},
+			mockReadFile: map[string]ReadFileResult{
+				"/etc/kdump.conf": {Data: nfsConf},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when config is missing",
+			config: &kdump.Options{},
+			mockExec: map[string]ExecResult{
+				kdumpIsEnabledCmd: {Stdout: []byte("enabled\n")},
+			},
+			mockReadFile: map[string]ReadFileResult{
+				"/etc/kdump.conf": {Err: errors.New("no such file or directory")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExec(t, tt.mockExec)
+			installMockReadFile(t, tt.mockReadFile)
+
+			chk, found := check.FindCheckByName("kdump")
+			require.True(t, found, "kdump check not found")
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{Kdump: tt.config},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
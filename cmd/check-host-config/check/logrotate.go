@@ -0,0 +1,33 @@
+package check
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "logrotate",
+	}, logrotateCheck)
+}
+
+func logrotateCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.Logrotate == nil {
+		return Skip("no logrotate customizations")
+	}
+
+	for _, d := range config.Options.Logrotate.DropIns {
+		path := filepath.Join("/etc/logrotate.d", d.Name)
+		// in debug mode logrotate parses the configuration without rotating
+		// any logs
+		_, stderr, _, err := ExecString("logrotate", "--debug", path)
+		if err != nil {
+			return Fail("logrotate drop-in is not valid:", path, "error:", err, stderr)
+		}
+		log.Printf("logrotate drop-in %s is valid\n", path)
+	}
+
+	return Pass()
+}
@@ -0,0 +1,65 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/logrotate"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogrotateCheck(t *testing.T) {
+	dropIns := &logrotate.Options{DropIns: []logrotate.DropIn{
+		{Name: "myapp", Paths: []string{"/var/log/myapp/*.log"}},
+		{Name: "audit", Paths: []string{"/var/log/myapp/audit"}},
+	}}
+
+	tests := []struct {
+		name     string
+		config   *logrotate.Options
+		mockExec map[string]ExecResult
+		wantErr  error
+	}{
+		{
+			name:    "skip when no logrotate customizations",
+			config:  nil,
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name:   "pass when all drop-ins are valid",
+			config: dropIns,
+		},
+		{
+			name:   "fail when a drop-in is invalid",
+			config: dropIns,
+			mockExec: map[string]ExecResult{
+				"logrotate --debug /etc/logrotate.d/audit": {Stderr: []byte("error: /etc/logrotate.d/audit:2 unknown option 'fortnightly'\n"), Code: 1, Err: errors.New("exit status 1")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExec(t, tt.mockExec)
+
+			chk, found := check.FindCheckByName("logrotate")
+			require.True(t, found, "logrotate check not found")
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{Logrotate: tt.config},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package check
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "selinux",
+	}, selinuxCheck)
+}
+
+// localLines returns the fields of the non-empty lines of the output of a
+// "semanage ... -l -C" command, skipping the header line
+func localLines(out string) [][]string {
+	var lines [][]string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "SELinux" {
+			continue
+		}
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+func selinuxCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.SELinux == nil {
+		return Skip("no selinux customizations")
+	}
+	expected := config.Options.SELinux
+
+	for _, name := range slices.Sorted(maps.Keys(expected.Booleans)) {
+		want := "off"
+		if expected.Booleans[name] {
+			want = "on"
+		}
+		out, _, _, err := ExecString("getsebool", name)
+		if err != nil {
+			return Fail("failed to get SELinux boolean:", name, "error:", err)
+		}
+		_, got, _ := strings.Cut(out, "-->")
+		if strings.TrimSpace(got) != want {
+			return Fail("SELinux boolean", name, "is", strings.TrimSpace(got), "expected", want)
+		}
+		log.Printf("SELinux boolean %s is %s\n", name, want)
+	}
+
+	if len(expected.Modules) > 0 {
+		out, _, _, err := ExecString("semodule", "-l")
+		if err != nil {
+			return Fail("failed to list SELinux modules:", err)
+		}
+		var modules []string
+		for _, line := range strings.Split(out, "\n") {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				modules = append(modules, fields[0])
+			}
+		}
+		for _, m := range expected.Modules {
+			if !slices.Contains(modules, m.Name) {
+				return Fail("SELinux module is not installed:", m.Name)
+			}
+			log.Printf("SELinux module %s is installed\n", m.Name)
+		}
+	}
+
+	if len(expected.FileContexts) > 0 {
+		out, _, _, err := ExecString("semanage", "fcontext", "-l", "-C")
+		if err != nil {
+			return Fail("failed to list SELinux file contexts:", err)
+		}
+		lines := localLines(out)
+		for _, fc := range expected.FileContexts {
+			found := slices.ContainsFunc(lines, func(fields []string) bool {
+				return fields[0] == fc.Path && strings.Contains(fields[len(fields)-1], ":"+fc.Type+":")
+			})
+			if !found {
+				return Fail("SELinux file context not found:", fc.Path, fc.Type)
+			}
+			log.Printf("SELinux file context %s is %s\n", fc.Path, fc.Type)
+		}
+	}
+
+	if len(expected.Ports) > 0 {
+		out, _, _, err := ExecString("semanage", "port", "-l", "-C")
+		if err != nil {
+			return Fail("failed to list SELinux ports:", err)
+		}
+		lines := localLines(out)
+		for _, p := range expected.Ports {
+			found := slices.ContainsFunc(lines, func(fields []string) bool {
+				if len(fields) < 3 || fields[0] != p.Type || fields[1] != p.Protocol {
+					return false
+				}
+				for _, port := range fields[2:] {
+					if strings.TrimSuffix(port, ",") == p.Port {
+						return true
+					}
+				}
+				return false
+			})
+			if !found {
+				return Fail("SELinux port label not found:", p.Protocol, p.Port, p.Type)
+			}
+			log.Printf("SELinux port %s/%s is %s\n", p.Protocol, p.Port, p.Type)
+		}
+	}
+
+	return Pass()
+}
@@ -0,0 +1,114 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/selinux"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const semanageFcontextOutput = `SELinux fcontext                                   type               Context
+
+/srv/web(/.*)?                                     all files          system_u:object_r:httpd_sys_content_t:s0
+`
+
+const semanagePortOutput = `SELinux Port Type              Proto    Port Number
+
+http_port_t                    tcp      8080, 8443
+`
+
+func TestSELinuxCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *selinux.Options
+		mockExec map[string]ExecResult
+		wantErr  error
+	}{
+		{
+			name:    "skip when no selinux customizations",
+			config:  nil,
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name: "pass when everything is applied",
+			config: &selinux.Options{
+				Booleans:     map[string]bool{"httpd_can_network_connect": true, "deny_ptrace": false},
+				Modules:      []selinux.Module{{Name: "mywebapp", Type: selinux.ModuleTypeCIL}},
+				FileContexts: []selinux.FileContext{{Path: "/srv/web(/.*)?", Type: "httpd_sys_content_t"}},
+				Ports:        []selinux.Port{{Protocol: "tcp", Port: "8443", Type: "http_port_t"}},
+			},
+			mockExec: map[string]ExecResult{
+				"getsebool httpd_can_network_connect": {Stdout: []byte("httpd_can_network_connect --> on\n")},
+				"getsebool deny_ptrace":               {Stdout: []byte("deny_ptrace --> off\n")},
+				"semodule -l":                         {Stdout: []byte("abrt\nmywebapp\nzosremote\n")},
+				"semanage fcontext -l -C":             {Stdout: []byte(semanageFcontextOutput)},
+				"semanage port -l -C":                 {Stdout: []byte(semanagePortOutput)},
+			},
+		},
+		{
+			name:   "fail when boolean does not match",
+			config: &selinux.Options{Booleans: map[string]bool{"httpd_can_network_connect": true}},
+			mockExec: map[string]ExecResult{
+				"getsebool httpd_can_network_connect": {Stdout: []byte("httpd_can_network_connect --> off\n")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when module is missing",
+			config: &selinux.Options{Modules: []selinux.Module{{Name: "mywebapp", Type: selinux.ModuleTypePP}}},
+			mockExec: map[string]ExecResult{
+				"semodule -l": {Stdout: []byte("abrt\nzosremote\n")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when file context has a different type",
+			config: &selinux.Options{FileContexts: []selinux.FileContext{{Path: "/srv/web(/.*)?", Type: "public_content_t"}}},
+			mockExec: map[string]ExecResult{
+				"semanage fcontext -l -C": {Stdout: []byte(semanageFcontextOutput)},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when port is missing",
+			config: &selinux.Options{Ports: []selinux.Port{{Protocol: "udp", Port: "8443", Type: "http_port_t"}}},
+			mockExec: map[string]ExecResult{
+				"semanage port -l -C": {Stdout: []byte(semanagePortOutput)},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when semanage errors",
+			config: &selinux.Options{Ports: []selinux.Port{{Protocol: "tcp", Port: "8443", Type: "http_port_t"}}},
+			mockExec: map[string]ExecResult{
+				"semanage port -l -C": {Code: 127, Err: errors.New("not found")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExec(t, tt.mockExec)
+
+			chk, found := check.FindCheckByName("selinux")
+			require.True(t, found, "selinux check not found")
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{SELinux: tt.config},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package check
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/sshauth"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "ssh_auth",
+	}, sshAuthCheck)
+}
+
+func sshAuthCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.SSHAuth == nil {
+		return Skip("no ssh_auth customizations")
+	}
+	expected := config.Options.SSHAuth
+
+	// sshd -T prints the effective configuration with lowercase keywords
+	out, _, _, err := ExecString("sshd", "-T")
+	if err != nil {
+		return Fail("failed to read the sshd configuration:", err)
+	}
+	lines := strings.Split(out, "\n")
+
+	var want []string
+	if len(expected.TrustedUserCAKeys) > 0 {
+		want = append(want, "trustedusercakeys "+sshauth.TrustedUserCAKeysPath)
+	}
+	if len(expected.AuthorizedPrincipals) > 0 {
+		want = append(want, "authorizedprincipalsfile "+sshauth.AuthorizedPrincipalsFile)
+	}
+	if expected.AuthorizedKeysCommand != "" {
+		want = append(want,
+			"authorizedkeyscommand "+expected.AuthorizedKeysCommand,
+			"authorizedkeyscommanduser "+expected.AuthorizedKeysCommandUser,
+		)
+	}
+	for _, line := range want {
+		if !slices.Contains(lines, line) {
+			return Fail("sshd setting not found:", line)
+		}
+		log.Printf("sshd setting %q found\n", line)
+	}
+
+	return Pass()
+}
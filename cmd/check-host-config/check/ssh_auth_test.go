@@ -0,0 +1,78 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/sshauth"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHAuthCheck(t *testing.T) {
+	options := &sshauth.Options{
+		TrustedUserCAKeys:         []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMZ4b0kJvIYSyqRhzD0y3WjKUpN1HhkJTjbIBtUc6mhN"},
+		AuthorizedPrincipals:      map[string][]string{"root": {"admins"}},
+		AuthorizedKeysCommand:     "/usr/bin/sss_ssh_authorizedkeys",
+		AuthorizedKeysCommandUser: "nobody",
+	}
+
+	tests := []struct {
+		name     string
+		config   *sshauth.Options
+		mockExec map[string]ExecResult
+		wantErr  error
+	}{
+		{
+			name:    "skip when no ssh_auth customizations",
+			config:  nil,
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name:   "pass when all settings are active",
+			config: options,
+			mockExec: map[string]ExecResult{
+				"sshd -T": {Stdout: []byte("port 22\ntrustedusercakeys /etc/ssh/trusted_user_ca_keys\nauthorizedprincipalsfile /etc/ssh/auth_principals/%u\nauthorizedkeyscommand /usr/bin/sss_ssh_authorizedkeys\nauthorizedkeyscommanduser nobody\n")},
+			},
+		},
+		{
+			name:   "fail when a setting is missing",
+			config: options,
+			mockExec: map[string]ExecResult{
+				"sshd -T": {Stdout: []byte("port 22\ntrustedusercakeys none\n")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when sshd errors",
+			config: options,
+			mockExec: map[string]ExecResult{
+				"sshd -T": {Code: 255, Err: errors.New("exit status 255")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExec(t, tt.mockExec)
+
+			chk, found := check.FindCheckByName("ssh_auth")
+			require.True(t, found, "ssh_auth check not found")
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{SSHAuth: tt.config},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
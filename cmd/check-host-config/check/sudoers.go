@@ -0,0 +1,32 @@
+package check
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "sudoers",
+	}, sudoersCheck)
+}
+
+func sudoersCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.Sudoers == nil {
+		return Skip("no sudoers customizations")
+	}
+
+	for _, d := range config.Options.Sudoers.DropIns {
+		path := filepath.Join("/etc/sudoers.d", d.Name)
+		// visudo also checks the owner and the mode of the file
+		_, stderr, _, err := ExecString("visudo", "-c", "-f", path)
+		if err != nil {
+			return Fail("sudoers drop-in is not valid:", path, "error:", err, stderr)
+		}
+		log.Printf("sudoers drop-in %s is valid\n", path)
+	}
+
+	return Pass()
+}
@@ -0,0 +1,69 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/sudoers"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSudoersCheck(t *testing.T) {
+	dropIns := &sudoers.Options{DropIns: []sudoers.DropIn{
+		{Name: "ops", Contents: "%ops ALL=(ALL) ALL"},
+		{Name: "deploy", Contents: "deploy ALL=(ALL) NOPASSWD: /usr/bin/rsync"},
+	}}
+
+	tests := []struct {
+		name     string
+		config   *sudoers.Options
+		mockExec map[string]ExecResult
+		wantErr  error
+	}{
+		{
+			name:    "skip when no sudoers customizations",
+			config:  nil,
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name:   "pass when all drop-ins are valid",
+			config: dropIns,
+			mockExec: map[string]ExecResult{
+				"visudo -c -f /etc/sudoers.d/ops":    {Stdout: []byte("/etc/sudoers.d/ops: parsed OK\n")},
+				"visudo -c -f /etc/sudoers.d/deploy": {Stdout: []byte("/etc/sudoers.d/deploy: parsed OK\n")},
+			},
+		},
+		{
+			name:   "fail when a drop-in is invalid",
+			config: dropIns,
+			mockExec: map[string]ExecResult{
+				"visudo -c -f /etc/sudoers.d/deploy": {Stderr: []byte("/etc/sudoers.d/deploy: bad permissions, should be mode 0440\n"), Code: 1, Err: errors.New("exit status 1")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExec(t, tt.mockExec)
+
+			chk, found := check.FindCheckByName("sudoers")
+			require.True(t, found, "sudoers check not found")
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{Sudoers: tt.config},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
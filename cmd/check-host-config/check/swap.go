@@ -0,0 +1,72 @@
+package check
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "swap",
+	}, swapCheck)
+}
+
+// swapArea is an active swap area as listed by swapon
+type swapArea struct {
+	name string
+	typ  string
+}
+
+func (a swapArea) isZram() bool {
+	return strings.HasPrefix(a.name, "/dev/zram")
+}
+
+func swapCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.Swap == nil {
+		return Skip("no swap customizations")
+	}
+	expected := config.Options.Swap
+
+	out, _, _, err := ExecString("swapon", "--show=NAME,TYPE", "--noheadings", "--raw")
+	if err != nil {
+		return Fail("failed to list swap areas:", err)
+	}
+	var areas []swapArea
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			areas = append(areas, swapArea{name: fields[0], typ: fields[1]})
+		}
+	}
+
+	if expected.Partition != nil {
+		found := slices.ContainsFunc(areas, func(a swapArea) bool {
+			return a.typ == "partition" && !a.isZram()
+		})
+		if !found {
+			return Fail("no active swap partition")
+		}
+		log.Println("Swap partition is active")
+	}
+
+	if expected.File != nil {
+		found := slices.ContainsFunc(areas, func(a swapArea) bool {
+			return a.typ == "file" && a.name == expected.File.Path
+		})
+		if !found {
+			return Fail("swap file is not active:", expected.File.Path)
+		}
+		log.Printf("Swap file %s is active\n", expected.File.Path)
+	}
+
+	if expected.Zram != nil {
+		if !slices.ContainsFunc(areas, swapArea.isZram) {
+			return Fail("no active zram swap device")
+		}
+		log.Println("zram swap device is active")
+	}
+
+	return Pass()
+}
@@ -0,0 +1,94 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
+	"github.com/osbuild/image-builder/pkg/datasizes"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const swaponCmd = "swapon --show=NAME,TYPE --noheadings --raw"
+
+func TestSwapCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *swap.Options
+		mockExec map[string]ExecResult
+		wantErr  error
+	}{
+		{
+			name:    "skip when no swap customizations",
+			config:  nil,
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name: "pass when all swap areas are active",
+			config: &swap.Options{
+				Partition: &swap.Partition{Size: 2 * datasizes.GiB},
+				File:      &swap.File{Path: "/var/swapfile", Size: 1 * datasizes.GiB},
+				Zram:      &swap.Zram{},
+			},
+			mockExec: map[string]ExecResult{
+				swaponCmd: {Stdout: []byte("/dev/vda3 partition\n/var/swapfile file\n/dev/zram0 partition\n")},
+			},
+		},
+		{
+			name:   "fail when only zram is active",
+			config: &swap.Options{Partition: &swap.Partition{Size: 2 * datasizes.GiB}},
+			mockExec: map[string]ExecResult{
+				swaponCmd: {Stdout: []byte("/dev/zram0 partition\n")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when swap file is not active",
+			config: &swap.Options{File: &swap.File{Path: "/var/swapfile", Size: 1 * datasizes.GiB}},
+			mockExec: map[string]ExecResult{
+				swaponCmd: {Stdout: []byte("/dev/vda3 partition\n")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when zram is not active",
+			config: &swap.Options{Zram: &swap.Zram{}},
+			mockExec: map[string]ExecResult{
+				swaponCmd: {Stdout: []byte("")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when swapon errors",
+			config: &swap.Options{Zram: &swap.Zram{}},
+			mockExec: map[string]ExecResult{
+				swaponCmd: {Code: 127, Err: errors.New("not found")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExec(t, tt.mockExec)
+
+			chk, found := check.FindCheckByName("swap")
+			require.True(t, found, "swap check not found")
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{Swap: tt.config},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
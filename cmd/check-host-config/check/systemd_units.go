@@ -0,0 +1,45 @@
+package check
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/osbuild/image-builder/internal/buildconfig"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "systemd_units",
+	}, systemdUnitsCheck)
+}
+
+func systemdUnitsCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if config == nil || config.Options.Systemd == nil {
+		return Skip("no systemd customizations")
+	}
+	expected := config.Options.Systemd
+
+	for _, unit := range expected.Units {
+		path := filepath.Join("/etc/systemd/system", unit.Name)
+		if !Exists(path) {
+			return Fail("systemd unit file not found:", path)
+		}
+		if unit.Enabled {
+			state, _, _, err := ExecString("systemctl", "is-enabled", unit.Name)
+			if err != nil || state != "enabled" {
+				return Fail("systemd unit is not enabled:", unit.Name, "state:", state)
+			}
+			log.Printf("systemd unit %s is enabled\n", unit.Name)
+		}
+	}
+
+	for _, d := range expected.DropIns {
+		path := filepath.Join("/etc/systemd/system", d.Unit+".d", d.Name)
+		if !Exists(path) {
+			return Fail("systemd drop-in not found:", path)
+		}
+		log.Printf("systemd drop-in %s found\n", path)
+	}
+
+	return Pass()
+}
@@ -0,0 +1,101 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	check "github.com/osbuild/image-builder/cmd/check-host-config/check"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/customizations/systemd"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemdUnitsCheck(t *testing.T) {
+	options := &systemd.Options{
+		Units: []systemd.Unit{
+			{Name: "cleanup.service"},
+			{Name: "cleanup.timer", Enabled: true},
+		},
+		DropIns: []systemd.DropIn{{Unit: "httpd.service", Name: "10-env.conf"}},
+	}
+	allFiles := map[string]bool{
+		"/etc/systemd/system/cleanup.service":             true,
+		"/etc/systemd/system/cleanup.timer":               true,
+		"/etc/systemd/system/httpd.service.d/10-env.conf": true,
+	}
+
+	tests := []struct {
+		name       string
+		config     *systemd.Options
+		mockExists map[string]bool
+		mockExec   map[string]ExecResult
+		wantErr    error
+	}{
+		{
+			name:    "skip when no systemd customizations",
+			config:  nil,
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name:       "pass when units exist and are enabled",
+			config:     options,
+			mockExists: allFiles,
+			mockExec: map[string]ExecResult{
+				"systemctl is-enabled cleanup.timer": {Stdout: []byte("enabled\n")},
+			},
+		},
+		{
+			name:       "fail when unit is missing",
+			config:     options,
+			mockExists: map[string]bool{"/etc/systemd/system/cleanup.timer": true},
+			mockExec: map[string]ExecResult{
+				"systemctl is-enabled cleanup.timer": {Stdout: []byte("enabled\n")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:       "fail when unit is disabled",
+			config:     options,
+			mockExists: allFiles,
+			mockExec: map[string]ExecResult{
+				"systemctl is-enabled cleanup.timer": {Stdout: []byte("disabled\n"), Code: 1, Err: errors.New("exit status 1")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:   "fail when drop-in is missing",
+			config: options,
+			mockExists: map[string]bool{
+				"/etc/systemd/system/cleanup.service": true,
+				"/etc/systemd/system/cleanup.timer":   true,
+			},
+			mockExec: map[string]ExecResult{
+				"systemctl is-enabled cleanup.timer": {Stdout: []byte("enabled\n")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockExists(t, tt.mockExists)
+			installMockExec(t, tt.mockExec)
+
+			chk, found := check.FindCheckByName("systemd_units")
+			require.True(t, found, "systemd_units check not found")
+			config := &buildconfig.BuildConfig{
+				Options: distro.ImageOptions{Systemd: tt.config},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
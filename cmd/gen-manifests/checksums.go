@@ -2,14 +2,13 @@ package main
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 
+	"github.com/osbuild/image-builder/internal/cmdutil"
 	"github.com/osbuild/image-builder/pkg/container"
 	"github.com/osbuild/image-builder/pkg/depsolvednf"
 	"github.com/osbuild/image-builder/pkg/flatpak"
@@ -17,12 +16,6 @@ import (
 	"github.com/osbuild/image-builder/pkg/ostree"
 )
 
-// manifestChecksum is the method reponsible for doing the hard work
-func manifestChecksum(b []byte) string {
-	sum := sha256.Sum256(b)
-	return hex.EncodeToString(sum[:])
-}
-
 // Checksums records manifest digests and writes them under dir.
 type Checksums struct {
 	dir       string
@@ -52,7 +45,7 @@ func (c *Checksums) recordManifestChecksum(ms manifest.OSBuildManifest, depsolve
 		return err
 	}
 	name := checksumBasename(filename)
-	digest := manifestChecksum(buf.Bytes())
+	digest := cmdutil.ManifestChecksum(buf.Bytes())
 	path := filepath.Join(c.dir, name)
 	if err := writeChecksumFileIfChanged(path, digest); err != nil {
 		return fmt.Errorf("failed to write checksum %q: %w", path, err)
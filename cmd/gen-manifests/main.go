@@ -225,8 +225,6 @@ func loadImgConfig(configPath string, opts *buildconfig.Options) *BuildConfigs {
 	return cm
 }
 
-type manifestJob func(chan string) error
-
 func makeManifestJob(
 	bc *buildconfig.BuildConfig,
 	imgType distro.ImageType,
@@ -241,7 +239,7 @@ func makeManifestJob(
 	bootcRemote bool,
 	bootcInstallerRef string,
 	cs *Checksums,
-) manifestJob {
+) cmdutil.Job {
 	name := bc.Name
 	distroName := distribution.Name()
 	filename := fmt.Sprintf("%s-%s-%s-%s.json", u(distroName), u(archName), u(imgType.Name()), u(name))
@@ -500,7 +498,7 @@ func main() {
 	}
 
 	distroFac := distrofactory.NewDefault()
-	jobs := make([]manifestJob, 0)
+	jobs := make([]cmdutil.Job, 0)
 
 	contentResolve := map[string]bool{
 		"packages":   packages,
@@ -768,15 +766,16 @@ func main() {
 	fmt.Fprintf(os.Stderr, "Collected %d jobs\n", nJobs)
 
 	// nolint:gosec
-	wq := newWorkerQueue(uint32(nWorkers), uint32(nJobs))
-	wq.start()
+	wq := cmdutil.NewWorkerQueue(uint32(nWorkers), uint32(nJobs))
+	wq.ShowStatus = true
+	wq.Start()
 	fmt.Fprintf(os.Stderr, "Initialised %d workers\n", nWorkers)
 	fmt.Fprintf(os.Stderr, "Submitting %d jobs... ", nJobs)
 	for _, j := range jobs {
-		wq.submitJob(j)
+		wq.SubmitJob(j)
 	}
 	fmt.Fprintln(os.Stderr, "done")
-	errs := wq.wait()
+	errs := wq.Wait()
 	exit := 0
 	if nErrs := len(errs); nErrs > 0 {
 		fmt.Fprintf(os.Stderr, "Encountered %d errors:\n", nErrs)
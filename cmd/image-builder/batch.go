@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/osbuild/image-builder/internal/blueprintload"
+	"github.com/osbuild/image-builder/internal/cmdutil"
+	"github.com/osbuild/image-builder/pkg/arch"
+	"github.com/osbuild/image-builder/pkg/hashutil"
+	"github.com/osbuild/image-builder/pkg/imagefilter"
+	"github.com/osbuild/image-builder/pkg/manifestgen"
+	"github.com/osbuild/image-builder/pkg/progress"
+	"github.com/osbuild/image-builder/pkg/setup"
+)
+
+// batchMatrix describes a batch of builds, every combination of
+// distro, arch, image type and blueprint is built
+type batchMatrix struct {
+	Distros    []string `yaml:"distros"`
+	Arches     []string `yaml:"arches"`
+	ImageTypes []string `yaml:"image_types"`
+	// Blueprints are paths to blueprint files, relative paths are
+	// relative to the matrix file
+	Blueprints []string `yaml:"blueprints"`
+}
+
+func loadBatchMatrix(path string) (*batchMatrix, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open build matrix: %w", err)
+	}
+	defer f.Close()
+
+	var matrix batchMatrix
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&matrix); err != nil {
+		return nil, fmt.Errorf("cannot parse build matrix %q: %w", path, err)
+	}
+	if len(matrix.Distros) == 0 {
+		return nil, fmt.Errorf("build matrix %q has no distros", path)
+	}
+	if len(matrix.ImageTypes) == 0 {
+		return nil, fmt.Errorf("build matrix %q has no image_types", path)
+	}
+	if len(matrix.Arches) == 0 {
+		matrix.Arches = []string{arch.Current().String()}
+	}
+	for i, bp := range matrix.Blueprints {
+		if !filepath.IsAbs(bp) {
+			matrix.Blueprints[i] = filepath.Join(filepath.Dir(path), bp)
+		}
+	}
+
+	return &matrix, nil
+}
+
+// blueprintName returns the name of a blueprint in the matrix as used
+// in the output names, i.e. the filename without extension
+func blueprintName(blueprintPath string) string {
+	base := filepath.Base(blueprintPath)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// batchJob is a single build of the batch matrix
+type batchJob struct {
+	Distro    string
+	Arch      string
+	ImageType string
+	Blueprint string
+
+	// set during the manifest generation
+	img       *imagefilter.Result
+	blueprint *blueprint.Blueprint
+	manifest  []byte
+	result    *batchResult
+}
+
+// jobs returns all jobs of the matrix in a stable order
+func (m *batchMatrix) jobs() []*batchJob {
+	blueprints := m.Blueprints
+	if len(blueprints) == 0 {
+		blueprints = []string{""}
+	}
+
+	var jobs []*batchJob
+	for _, distroName := range m.Distros {
+		for _, archName := range m.Arches {
+			for _, imgTypeName := range m.ImageTypes {
+				for _, bp := range blueprints {
+					jobs = append(jobs, &batchJob{
+						Distro:    distroName,
+						Arch:      archName,
+						ImageType: imgTypeName,
+						Blueprint: bp,
+					})
+				}
+			}
+		}
+	}
+	return jobs
+}
+
+func (job *batchJob) String() string {
+	s := fmt.Sprintf("%s-%s-%s", job.Distro, job.ImageType, job.Arch)
+	if job.Blueprint != "" {
+		s += "-" + blueprintName(job.Blueprint)
+	}
+	return s
+}
+
+// basename is the name of the output directory and the prefix of the
+// files of the job
+func (job *batchJob) basename() string {
+	basename := basenameFor(job.img, "")
+	if job.Blueprint != "" {
+		basename += "-" + blueprintName(job.Blueprint)
+	}
+	return basename
+}
+
+const (
+	batchStatusSuccess = "success"
+	batchStatusFailure = "failure"
+	batchStatusSkipped = "skipped"
+)
+
+// batchResult is the report entry for a single job of the batch
+type batchResult struct {
+	Distro    string `json:"distro"`
+	Arch      string `json:"arch"`
+	ImageType string `json:"image_type"`
+	Blueprint string `json:"blueprint,omitempty"`
+
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	ManifestDuration float64 `json:"manifest_duration_seconds"`
+	BuildDuration    float64 `json:"build_duration_seconds"`
+
+	Artifact string `json:"artifact,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+
+	// Warnings of the manifest generation
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type batchReport struct {
+	Started  time.Time      `json:"started"`
+	Duration float64        `json:"duration_seconds"`
+	Builds   []*batchResult `json:"builds"`
+}
+
+type batchOptions struct {
+	OutputDir      string
+	StoreDir       string
+	Parallel       int
+	ManifestJobs   int
+	ManifestOpts   manifestOptions
+	RepoOpts       repoOptions
+	WithManifest   bool
+	WithBuildlog   bool
+	ContinueOnFail bool
+}
+
+// syncWriter serializes the writes of the two worker queues of a batch
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.w.Write(p)
+}
+
+// runBatch generates the manifests of all jobs concurrently and builds
+// them with at most opts.Parallel builds running at the same time.
+func runBatch(ctx context.Context, jobs []*batchJob, opts *batchOptions, out io.Writer) *batchReport {
+	report := &batchReport{
+		Started: time.Now(),
+	}
+
+	var mu sync.Mutex
+	var done int
+	var failed bool
+	finish := func(msgq chan string, job *batchJob, status string, err error) {
+		job.result.Status = status
+		if err != nil {
+			job.result.Error = err.Error()
+		}
+		mu.Lock()
+		done++
+		n := done
+		if status == batchStatusFailure {
+			failed = true
+		}
+		mu.Unlock()
+		msg := fmt.Sprintf("[%d/%d] %s: %s", n, len(jobs), job, status)
+		if err != nil {
+			msg += fmt.Sprintf("\n    %v", err)
+		}
+		msgq <- msg
+	}
+	skip := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return ctx.Err() != nil || (failed && !opts.ContinueOnFail)
+	}
+
+	out = &syncWriter{w: out}
+	// nolint:gosec
+	manifestQueue := cmdutil.NewWorkerQueue(uint32(opts.ManifestJobs), uint32(len(jobs)))
+	manifestQueue.Output = out
+	// nolint:gosec
+	buildQueue := cmdutil.NewWorkerQueue(uint32(opts.Parallel), uint32(len(jobs)))
+	buildQueue.Output = out
+	manifestQueue.Start()
+	buildQueue.Start()
+
+	for _, job := range jobs {
+		job.result = &batchResult{
+			Distro:    job.Distro,
+			Arch:      job.Arch,
+			ImageType: job.ImageType,
+			Blueprint: job.Blueprint,
+		}
+		report.Builds = append(report.Builds, job.result)
+		manifestQueue.SubmitJob(func(msgq chan string) error {
+			if skip() {
+				finish(msgq, job, batchStatusSkipped, nil)
+				return nil
+			}
+			start := time.Now()
+			err := generateBatchManifest(job, opts)
+			job.result.ManifestDuration = time.Since(start).Seconds()
+			for _, warning := range job.result.Warnings {
+				msgq <- fmt.Sprintf("%s: warning: %s", job, warning)
+			}
+			if err != nil {
+				finish(msgq, job, batchStatusFailure, fmt.Errorf("cannot generate manifest: %w", err))
+				return nil
+			}
+			buildQueue.SubmitJob(func(msgq chan string) error {
+				if skip() {
+					finish(msgq, job, batchStatusSkipped, nil)
+					return nil
+				}
+				msgq <- fmt.Sprintf("building %s", job)
+				start := time.Now()
+				err := buildBatchJob(ctx, job, opts)
+				job.result.BuildDuration = time.Since(start).Seconds()
+				if err != nil {
+					finish(msgq, job, batchStatusFailure, err)
+					return nil
+				}
+				finish(msgq, job, batchStatusSuccess, nil)
+				return nil
+			})
+			return nil
+		})
+	}
+	// all builds are submitted once the manifests are generated
+	manifestQueue.Wait()
+	buildQueue.Wait()
+
+	report.Duration = time.Since(report.Started).Seconds()
+	return report
+}
+
+func generateBatchManifest(job *batchJob, opts *batchOptions) error {
+	img, err := getOneImage(job.Distro, job.ImageType, job.Arch, &opts.RepoOpts)
+	if err != nil {
+		return err
+	}
+	if len(img.ImgType.Exports()) > 1 {
+		return fmt.Errorf("image %q has multiple exports: this is current unsupport: please report this as a bug", basenameFor(img, ""))
+	}
+	job.img = img
+	bp, err := blueprintload.Load(job.Blueprint)
+	if err != nil {
+		return err
+	}
+	job.blueprint = bp
+
+	// every job needs its own copy of the options as the
+	// manifestgen options get modified during the generation
+	manifestOpts := opts.ManifestOpts
+	manifestOpts.BlueprintPath = job.Blueprint
+	manifestOpts.Blueprint = bp
+	manifestOpts.OutputDir = filepath.Join(opts.OutputDir, job.basename())
+	manifestOpts.OutputFilename = job.basename()
+	manifestOpts.ManifestgenOptions.UseBootstrapContainer = img.ImgType.Arch().Name() != arch.Current().String()
+	// the warnings of the jobs are reported per job
+	var warnings bytes.Buffer
+	manifestOpts.ManifestgenOptions.WarningsOutput = &warnings
+
+	var mf bytes.Buffer
+	err = generateManifest(opts.RepoOpts.RepoDir, opts.RepoOpts.ExtraRepos, img, &mf, &manifestOpts)
+	for _, line := range strings.Split(strings.TrimSpace(warnings.String()), "\n") {
+		if line != "" {
+			job.result.Warnings = append(job.result.Warnings, line)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	job.manifest = mf.Bytes()
+	return nil
+}
+
+func buildBatchJob(ctx context.Context, job *batchJob, opts *batchOptions) error {
+	outputDir := filepath.Join(opts.OutputDir, job.basename())
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("cannot create output directory %s: %w", outputDir, err)
+	}
+	// builds run concurrently so the progress of each build goes
+	// into its own file next to the artifact
+	pbar, err := progress.New("file", progress.ProgressConfig{
+		FilePath: filepath.Join(outputDir, fmt.Sprintf("%s.progress", job.basename())),
+	})
+	if err != nil {
+		return err
+	}
+	pbar.Start()
+	defer pbar.Stop()
+
+	buildOpts := &buildOptions{
+		OutputDir:      outputDir,
+		OutputBasename: job.basename(),
+		StoreDir:       opts.StoreDir,
+		WriteManifest:  opts.WithManifest,
+		WriteBuildlog:  opts.WithBuildlog,
+		Blueprint:      job.blueprint,
+	}
+	imagePaths, err := buildImages(ctx, pbar, []*imageBuild{{img: job.img, manifest: job.manifest}}, buildOpts)
+	if err != nil {
+		return err
+	}
+	job.result.Artifact = imagePaths[0]
+
+	checksum, err := hashutil.Sha256sum(imagePaths[0])
+	if err != nil {
+		return fmt.Errorf("cannot checksum %s: %w", imagePaths[0], err)
+	}
+	job.result.SHA256 = checksum
+	return nil
+}
+
+func cmdBatch(cmd *cobra.Command, args []string) error {
+	ctx, stop := signalContext(cmd)
+	defer stop()
+
+	repoDir, err := cmd.Flags().GetString("force-repo-dir")
+	if err != nil {
+		return err
+	}
+	extraRepos, err := cmd.Flags().GetStringArray("extra-repo")
+	if err != nil {
+		return err
+	}
+	forceRepos, err := cmd.Flags().GetStringArray("force-repo")
+	if err != nil {
+		return err
+	}
+	forceDefsDir, err := cmd.Flags().GetString("force-defs-dir")
+	if err != nil {
+		return err
+	}
+	outputDir, err := cmd.Flags().GetString("output-dir")
+	if err != nil {
+		return err
+	}
+	cacheDir, err := cmd.Flags().GetString("cache")
+	if err != nil {
+		return err
+	}
+	rpmmdCacheDir, err := cmd.Flags().GetString("rpmmd-cache")
+	if err != nil {
+		return err
+	}
+	parallel, err := cmd.Flags().GetInt("parallel")
+	if err != nil {
+		return err
+	}
+	manifestJobs, err := cmd.Flags().GetInt("manifest-jobs")
+	if err != nil {
+		return err
+	}
+	reportPath, err := cmd.Flags().GetString("report")
+	if err != nil {
+		return err
+	}
+	withManifest, err := cmd.Flags().GetBool("with-manifest")
+	if err != nil {
+		return err
+	}
+	withBuildlog, err := cmd.Flags().GetBool("with-buildlog")
+	if err != nil {
+		return err
+	}
+	keepGoing, err := cmd.Flags().GetBool("keep-going")
+	if err != nil {
+		return err
+	}
+	if parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1, got %d", parallel)
+	}
+	if manifestJobs < 1 {
+		return fmt.Errorf("--manifest-jobs must be at least 1, got %d", manifestJobs)
+	}
+
+	matrix, err := loadBatchMatrix(args[0])
+	if err != nil {
+		return err
+	}
+	if outputDir == "" {
+		outputDir = "batch"
+	}
+	if reportPath == "" {
+		reportPath = filepath.Join(outputDir, "batch-report.json")
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return fmt.Errorf("cannot create cache directory %q: %w\nHint: use --cache to specify a writable path", cacheDir, err)
+	}
+	if setup.IsContainer() {
+		if err := setup.EnsureEnvironment(cacheDir, false); err != nil {
+			return fmt.Errorf("entrypoint setup failed: %w", err)
+		}
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("cannot create output base directory %s: %w", outputDir, err)
+	}
+
+	// depsolving and container resolving are cancelled with the batch
+	// on SIGINT/SIGTERM
+	depsolver := manifestgenDepsolver
+	if depsolver == nil {
+		depsolver = manifestgen.DepsolveWithContext(ctx)
+	}
+	containerResolver := manifestgenContainerResolver
+	if containerResolver == nil {
+		containerResolver = manifestgen.ContainerResolverWithContext(ctx)
+	}
+
+	opts := &batchOptions{
+		OutputDir:    outputDir,
+		StoreDir:     cacheDir,
+		Parallel:     parallel,
+		ManifestJobs: manifestJobs,
+		ManifestOpts: manifestOptions{
+			ManifestgenOptions: manifestgen.Options{
+				Cachedir:               rpmmdCacheDir,
+				DepsolveWarningsOutput: io.Discard,
+				Depsolve:               depsolver,
+				ContainerResolver:      containerResolver,
+			},
+			ForceRepos: forceRepos,
+		},
+		RepoOpts: repoOptions{
+			RepoDir:      repoDir,
+			ExtraRepos:   extraRepos,
+			ForceRepos:   forceRepos,
+			ForceDefsDir: forceDefsDir,
+		},
+		WithManifest:   withManifest,
+		WithBuildlog:   withBuildlog,
+		ContinueOnFail: keepGoing,
+	}
+	jobs := matrix.jobs()
+	fmt.Fprintf(osStdout, "Building %d images\n", len(jobs))
+	report := runBatch(ctx, jobs, opts, osStdout)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	// #nosec: G306
+	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+		return fmt.Errorf("cannot write batch report: %w", err)
+	}
+	fmt.Fprintf(osStdout, "Batch report written to %s\n", reportPath)
+
+	var nfailed int
+	for _, res := range report.Builds {
+		if res.Status != batchStatusSuccess {
+			nfailed++
+		}
+	}
+	if nfailed > 0 {
+		return fmt.Errorf("%d of %d builds did not succeed, see %s", nfailed, len(report.Builds), reportPath)
+	}
+	return nil
+}
+
+func setupBatchCmd() *cobra.Command {
+	batchCmd := &cobra.Command{
+		Use:   "batch <matrix.yaml>",
+		Short: "Build all combinations of distros, arches, image types and blueprints of the given build matrix",
+		Long: `Build all combinations of distros, arches, image types and blueprints of the given build matrix.
+
+The build matrix is a YAML file, e.g.:
+
+  distros: [centos-9, fedora-43]
+  arches: [x86_64, aarch64]
+  image_types: [qcow2, ami]
+  blueprints: [base.toml, web.toml]
+
+Manifests are generated concurrently, builds run with at most --parallel
+builds at the same time using a shared store. A JSON summary report is
+written at the end.`,
+		RunE:         cmdBatch,
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+	}
+	batchCmd.Flags().String("cache", defaultCacheDir(), `osbuild directory to cache intermediate build artifacts"`)
+	batchCmd.Flags().String("rpmmd-cache", "", `osbuild directory to cache rpm metadata`)
+	batchCmd.Flags().Int("parallel", 1, `maximum number of builds that run at the same time`)
+	batchCmd.Flags().Int("manifest-jobs", runtime.NumCPU(), `maximum number of manifests that are generated at the same time`)
+	batchCmd.Flags().String("report", "", `write the JSON report to the given path (default "<output-dir>/batch-report.json")`)
+	batchCmd.Flags().Bool("with-manifest", false, `export osbuild manifests`)
+	batchCmd.Flags().Bool("with-buildlog", false, `export osbuild buildlogs`)
+	batchCmd.Flags().Bool("keep-going", false, `continue with the remaining builds if a build fails`)
+
+	return batchCmd
+}
@@ -0,0 +1,219 @@
+package main_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testrepos "github.com/osbuild/image-builder/test/data/repositories"
+
+	main "github.com/osbuild/image-builder/cmd/image-builder"
+	"github.com/osbuild/image-builder/internal/testutil"
+	"github.com/osbuild/image-builder/pkg/arch"
+)
+
+type batchReportJSON struct {
+	Builds []struct {
+		Distro    string   `json:"distro"`
+		Arch      string   `json:"arch"`
+		ImageType string   `json:"image_type"`
+		Blueprint string   `json:"blueprint"`
+		Status    string   `json:"status"`
+		Error     string   `json:"error"`
+		Artifact  string   `json:"artifact"`
+		SHA256    string   `json:"sha256"`
+		Warnings  []string `json:"warnings"`
+	} `json:"builds"`
+}
+
+func makeTestBatchMatrix(t *testing.T, matrix string) string {
+	tmpdir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tmpdir, "blueprint.toml"), []byte(testBlueprint), 0600)
+	require.NoError(t, err)
+	matrixPath := filepath.Join(tmpdir, "matrix.yaml")
+	err = os.WriteFile(matrixPath, []byte(matrix), 0600)
+	require.NoError(t, err)
+	return matrixPath
+}
+
+func readBatchReport(t *testing.T, path string) *batchReportJSON {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var report batchReportJSON
+	require.NoError(t, json.Unmarshal(data, &report))
+	return &report
+}
+
+func TestBatchIntegrationHappy(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	currentArch := arch.Current().String()
+	matrixPath := makeTestBatchMatrix(t, fmt.Sprintf(`
+distros: [centos-9]
+arches: [%s]
+image_types: [qcow2, ami]
+blueprints: [blueprint.toml]
+`, currentArch))
+	outputDir := filepath.Join(t.TempDir(), "output")
+	restore = main.MockOsArgs([]string{
+		"batch", matrixPath,
+		"--cache", t.TempDir(),
+		"--output-dir", outputDir,
+		"--parallel", "2",
+	})
+	defer restore()
+
+	fakeOsbuildCmd := testutil.MockCommand(t, "osbuild", makeFakeOsbuildScript())
+
+	err := main.Run()
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(fakeOsbuildCmd.CallArgsList()))
+	assert.Contains(t, fakeStdout.String(), "Building 2 images\n")
+
+	report := readBatchReport(t, filepath.Join(outputDir, "batch-report.json"))
+	require.Len(t, report.Builds, 2)
+	for i, imgType := range []string{"qcow2", "ami"} {
+		res := report.Builds[i]
+		assert.Equal(t, "centos-9", res.Distro)
+		assert.Equal(t, currentArch, res.Arch)
+		assert.Equal(t, imgType, res.ImageType)
+		assert.Equal(t, filepath.Join(filepath.Dir(matrixPath), "blueprint.toml"), res.Blueprint)
+		assert.Equal(t, "success", res.Status)
+		assert.Equal(t, "", res.Error)
+
+		basename := fmt.Sprintf("centos-9-%s-%s-blueprint", imgType, currentArch)
+		assert.Equal(t, filepath.Join(outputDir, basename), filepath.Dir(res.Artifact))
+		data, err := os.ReadFile(res.Artifact)
+		require.NoError(t, err)
+		checksum := sha256.Sum256(data)
+		assert.Equal(t, hex.EncodeToString(checksum[:]), res.SHA256)
+	}
+}
+
+func TestBatchIntegrationFailure(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	matrixPath := makeTestBatchMatrix(t, `
+distros: [centos-9]
+image_types: [qcow2, no-such-type]
+`)
+	outputDir := filepath.Join(t.TempDir(), "output")
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	restore = main.MockOsArgs([]string{
+		"batch", matrixPath,
+		"--cache", t.TempDir(),
+		"--output-dir", outputDir,
+		"--report", reportPath,
+		"--manifest-jobs", "1",
+		"--keep-going",
+	})
+	defer restore()
+
+	testutil.MockCommand(t, "osbuild", makeFakeOsbuildScript())
+
+	err := main.Run()
+	assert.EqualError(t, err, fmt.Sprintf("1 of 2 builds did not succeed, see %s", reportPath))
+
+	report := readBatchReport(t, reportPath)
+	require.Len(t, report.Builds, 2)
+	assert.Equal(t, "success", report.Builds[0].Status)
+	assert.Equal(t, "failure", report.Builds[1].Status)
+	assert.Contains(t, report.Builds[1].Error, "cannot generate manifest: ")
+	assert.Equal(t, "", report.Builds[1].Artifact)
+}
+
+func TestBatchIntegrationWarnings(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	matrixPath := makeTestBatchMatrix(t, `
+distros: [centos-9]
+image_types: [qcow2]
+blueprints: [fips.toml]
+`)
+	// FIPS images built on a non-FIPS host generate a warning
+	err := os.WriteFile(filepath.Join(filepath.Dir(matrixPath), "fips.toml"), []byte("[customizations]\nfips = true\n"), 0600)
+	require.NoError(t, err)
+	outputDir := filepath.Join(t.TempDir(), "output")
+	restore = main.MockOsArgs([]string{
+		"batch", matrixPath,
+		"--cache", t.TempDir(),
+		"--output-dir", outputDir,
+	})
+	defer restore()
+
+	testutil.MockCommand(t, "osbuild", makeFakeOsbuildScript())
+
+	err = main.Run()
+	require.NoError(t, err)
+
+	report := readBatchReport(t, filepath.Join(outputDir, "batch-report.json"))
+	require.Len(t, report.Builds, 1)
+	assert.Equal(t, "success", report.Builds[0].Status)
+	require.Len(t, report.Builds[0].Warnings, 1)
+	assert.Contains(t, report.Builds[0].Warnings[0], "not running in FIPS mode")
+	assert.Contains(t, fakeStdout.String(), "warning: The host building this image is not running in FIPS mode")
+}
+
+func TestBatchMatrixErrors(t *testing.T) {
+	for _, tc := range []struct {
+		matrix      string
+		expectedErr string
+	}{
+		{"image_types: [qcow2]", "has no distros"},
+		{"distros: [centos-9]", "has no image_types"},
+		{"distros: [centos-9]\nimage_types: [qcow2]\nunknown: 1", "field unknown not found"},
+	} {
+		t.Run(tc.expectedErr, func(t *testing.T) {
+			restore := main.MockOsArgs([]string{
+				"batch", makeTestBatchMatrix(t, tc.matrix),
+				"--cache", t.TempDir(),
+				"--output-dir", t.TempDir(),
+			})
+			defer restore()
+
+			err := main.Run()
+			assert.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -11,7 +12,7 @@ import (
 	"github.com/osbuild/image-builder/pkg/cloud"
 )
 
-func bibUpload(uploader cloud.Uploader, path string, flags *pflag.FlagSet) error {
+func bibUpload(ctx context.Context, uploader cloud.Uploader, path string, flags *pflag.FlagSet) error {
 	progress, err := flags.GetString("progress")
 	if err != nil {
 		return err
@@ -53,6 +54,6 @@ func bibUpload(uploader cloud.Uploader, path string, flags *pflag.FlagSet) error
 		size = 0
 	}
 	// #nosec G115
-	_, err = uploader.UploadAndRegister(r, uint64(size), osStderr)
+	_, err = uploader.UploadAndRegister(ctx, r, uint64(size), osStderr)
 	return err
 }
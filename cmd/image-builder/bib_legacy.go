@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 
@@ -61,7 +62,7 @@ type ManifestConfig struct {
 	UseLibrepo bool
 }
 
-func manifestFromCobraForLegacyISO(imgref, buildImgref, imgTypeStr, rootFs, rpmCacheRoot string, config *blueprint.Blueprint, useLibrepo bool, cntArch arch.Arch) ([]byte, *mTLSConfig, error) {
+func manifestFromCobraForLegacyISO(ctx context.Context, imgref, buildImgref, imgTypeStr, rootFs, rpmCacheRoot string, config *blueprint.Blueprint, useLibrepo bool, cntArch arch.Arch) ([]byte, *mTLSConfig, error) {
 	container, err := podman_container.NewContainer(imgref)
 	if err != nil {
 		return nil, nil, err
@@ -128,6 +129,7 @@ func manifestFromCobraForLegacyISO(imgref, buildImgref, imgTypeStr, rootFs, rpmC
 	if err != nil {
 		return nil, nil, err
 	}
+	solver.SetContext(ctx)
 
 	manifestConfig := &ManifestConfig{
 		Architecture:    cntArch,
@@ -141,7 +143,7 @@ func manifestFromCobraForLegacyISO(imgref, buildImgref, imgTypeStr, rootFs, rpmC
 		UseLibrepo:      useLibrepo,
 	}
 
-	manifest, repos, err := makeISOManifest(manifestConfig, solver, rpmCacheRoot)
+	manifest, repos, err := makeISOManifest(ctx, manifestConfig, solver, rpmCacheRoot)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -154,7 +156,7 @@ func manifestFromCobraForLegacyISO(imgref, buildImgref, imgTypeStr, rootFs, rpmC
 	return manifest, mTLS, nil
 }
 
-func makeISOManifest(c *ManifestConfig, solver *depsolvednf.Solver, cacheRoot string) (manifest.OSBuildManifest, map[string][]rpmmd.RepoConfig, error) {
+func makeISOManifest(ctx context.Context, c *ManifestConfig, solver *depsolvednf.Solver, cacheRoot string) (manifest.OSBuildManifest, map[string][]rpmmd.RepoConfig, error) {
 	seed, err := cmdutil.NewRNGSeed()
 	if err != nil {
 		return nil, nil, err
@@ -191,7 +193,7 @@ func makeISOManifest(c *ManifestConfig, solver *depsolvednf.Solver, cacheRoot st
 	// run naively via syscall translation)
 
 	// XXX: should NewResolver() take "arch.Arch"?
-	resolver := container.NewResolver(c.Architecture.String())
+	resolver := container.NewResolverWithContext(ctx, c.Architecture.String())
 
 	containerSpecs := make(map[string][]container.Spec)
 	for plName, sourceSpecs := range mani.GetContainerSourceSpecs() {
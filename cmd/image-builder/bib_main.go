@@ -137,7 +137,7 @@ func bibManifestFromCobra(cmd *cobra.Command, args []string, pbar progress.Progr
 
 	// The anaconda-iso code is different enough for a separate function
 	if imgTypeStr == "anaconda-iso" || imgTypeStr == "iso" {
-		return manifestFromCobraForLegacyISO(imgref, buildImgref, imgTypeStr, rootFs, rpmCacheRoot, config, useLibrepo, cntArch)
+		return manifestFromCobraForLegacyISO(cmd.Context(), imgref, buildImgref, imgTypeStr, rootFs, rpmCacheRoot, config, useLibrepo, cntArch)
 	}
 
 	bootcInfo, err := bootc.ResolveBootcInfo(imgref)
@@ -191,7 +191,10 @@ func bibManifestFromCobra(cmd *cobra.Command, args []string, pbar progress.Progr
 		},
 		RpmDownloader: rpmDownloader,
 		Depsolve: func(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error) {
-			depsolveResult, err = manifestgen.DefaultDepsolve(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch)
+			depsolveResult, err = manifestgen.DepsolveWithContext(cmd.Context())(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch)
+			if err != nil {
+				return nil, err
+			}
 			// extracting needs to happen while container is mounted
 			depsolvedRepos := make(map[string][]rpmmd.RepoConfig)
 			for k, v := range depsolveResult {
@@ -203,6 +206,7 @@ func bibManifestFromCobra(cmd *cobra.Command, args []string, pbar progress.Progr
 			}
 			return depsolveResult, err
 		},
+		ContainerResolver: manifestgen.ContainerResolverWithContext(cmd.Context()),
 		// this turns (blueprint validation) warnings into
 		// warnings as they are visible to the user
 		WarningsOutput: os.Stderr,
@@ -291,6 +295,11 @@ func handleAWSFlags(cmd *cobra.Command) (cloud.Uploader, error) {
 //     intead (but we cannot change the output of bib becaue e.g. podman desktop depends
 //     on it)
 func bibCmdBuild(cmd *cobra.Command, args []string) error {
+	// when interrupted the bootc containers get stopped and osbuild
+	// cleans up before exiting
+	ctx, stop := signalContext(cmd)
+	defer stop()
+
 	chown, _ := cmd.Flags().GetString("chown")
 	imgTypes, _ := cmd.Flags().GetStringArray("type")
 	osbuildStore, _ := cmd.Flags().GetString("store")
@@ -382,7 +391,7 @@ func bibCmdBuild(cmd *cobra.Command, args []string) error {
 		OutputDir: outputDir,
 		ExtraEnv:  osbuildEnv,
 	}
-	if err = progress.RunOSBuild(pbar, mf, exports, &osbuildOpts); err != nil {
+	if err = progress.RunOSBuild(ctx, pbar, mf, exports, &osbuildOpts); err != nil {
 		return fmt.Errorf("cannot run osbuild: %w", err)
 	}
 
@@ -397,7 +406,7 @@ func bibCmdBuild(cmd *cobra.Command, args []string) error {
 			switch imgType {
 			case "ami":
 				diskpath := filepath.Join(outputDir, exports[idx], "disk.raw")
-				if err := bibUpload(uploader, diskpath, cmd.Flags()); err != nil {
+				if err := bibUpload(ctx, uploader, diskpath, cmd.Flags()); err != nil {
 					return fmt.Errorf("cannot upload AMI: %w", err)
 				}
 			default:
@@ -2,6 +2,7 @@ package main_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -184,7 +185,7 @@ func (fa *fakeAwsUploader) Check(status io.Writer) error {
 	return nil
 }
 
-func (fa *fakeAwsUploader) UploadAndRegister(r io.Reader, size uint64, status io.Writer) (*cloud.UploadResult, error) {
+func (fa *fakeAwsUploader) UploadAndRegister(ctx context.Context, r io.Reader, size uint64, status io.Writer) (*cloud.UploadResult, error) {
 	fa.uploadAndRegisterCalls++
 	_, err := io.Copy(&fa.uploadAndRegisterRead, r)
 	if err != nil {
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/osbuild/blueprint/pkg/blueprint"
+
 	"github.com/osbuild/image-builder/pkg/imagefilter"
 	"github.com/osbuild/image-builder/pkg/progress"
 )
@@ -20,60 +24,146 @@ type buildOptions struct {
 	WriteManifest bool
 	WriteBuildlog bool
 	Metrics       bool
+	MetricsReport string
+
+	// Blueprint is only used for the diagnostics bundle that is
+	// written when the build fails
+	Blueprint *blueprint.Blueprint
 }
 
-func buildImage(pbar progress.ProgressBar, res *imagefilter.Result, osbuildManifest []byte, opts *buildOptions) (string, error) {
+// buildBasenameFor returns the basename for the files that belong to
+// the build as a whole (manifest, buildlog, progress), for a single
+// image this is just the image basename
+func buildBasenameFor(builds []*imageBuild, userBasename string) string {
+	if len(builds) == 1 || userBasename != "" {
+		return basenameFor(builds[0].img, userBasename)
+	}
+	arch := builds[0].img.ImgType.Arch()
+	return fmt.Sprintf("%s-%s", arch.Distro().Name(), arch.Name())
+}
+
+// imageFilenameFor returns the final path of the image artifact
+func imageFilenameFor(outputDir string, res *imagefilter.Result, userBasename string) string {
+	imgExt := strings.SplitN(res.ImgType.Filename(), ".", 2)[1]
+	return filepath.Join(outputDir, fmt.Sprintf("%s.%v", basenameFor(res, userBasename), imgExt))
+}
+
+// buildImages builds all the given images in a single osbuild run and
+// returns the paths of the resulting artifacts (in the same order)
+func buildImages(ctx context.Context, pbar progress.ProgressBar, builds []*imageBuild, opts *buildOptions) ([]string, error) {
 	if opts == nil {
 		opts = &buildOptions{}
 	}
 
-	basename := basenameFor(res, opts.OutputBasename)
+	dstNames := map[string]string{}
+	for _, ib := range builds {
+		dstName := imageFilenameFor(opts.OutputDir, ib.img, opts.OutputBasename)
+		if other, ok := dstNames[dstName]; ok {
+			return nil, fmt.Errorf("image types %q and %q would both be written to %q, please use different output names", other, ib.img.ImgType.Name(), dstName)
+		}
+		dstNames[dstName] = ib.img.ImgType.Name()
+	}
+
+	osbuildManifest, err := combineManifests(builds)
+	if err != nil {
+		return nil, err
+	}
+
+	basename := buildBasenameFor(builds, opts.OutputBasename)
 	if opts.WriteManifest {
 		p := filepath.Join(opts.OutputDir, fmt.Sprintf("%s.osbuild-manifest.json", basename))
 		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
-			return "", err
+			return nil, err
 		}
 		// #nosec: G306
 		if err := os.WriteFile(p, osbuildManifest, 0644); err != nil {
-			return "", err
+			return nil, err
+		}
+	}
+
+	var exports, inVm []string
+	for _, ib := range builds {
+		for _, export := range ib.exports() {
+			if !slices.Contains(exports, export) {
+				exports = append(exports, export)
+			}
+		}
+		for _, pipeline := range opts.InVm {
+			if name := ib.pipelineName(pipeline); !slices.Contains(inVm, name) {
+				inVm = append(inVm, name)
+			}
 		}
 	}
 
 	osbuildOpts := &progress.OSBuildOptions{
-		StoreDir:   opts.StoreDir,
-		OutputDir:  opts.OutputDir,
-		Metrics:    opts.Metrics,
-		InVm:       opts.InVm,
-		JSONOutput: opts.JSONOutput,
+		StoreDir:      opts.StoreDir,
+		OutputDir:     opts.OutputDir,
+		Metrics:       opts.Metrics,
+		MetricsReport: opts.MetricsReport,
+		InVm:          inVm,
+		JSONOutput:    opts.JSONOutput,
 	}
 	if opts.WriteBuildlog {
 		if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
-			return "", fmt.Errorf("cannot create buildlog base directory: %w", err)
+			return nil, fmt.Errorf("cannot create buildlog base directory: %w", err)
 		}
 		p := filepath.Join(opts.OutputDir, fmt.Sprintf("%s.buildlog", basename))
 		f, err := os.Create(p)
 		if err != nil {
-			return "", fmt.Errorf("cannot create buildlog: %w", err)
+			return nil, fmt.Errorf("cannot create buildlog: %w", err)
 		}
 		defer f.Close()
 
 		osbuildOpts.BuildLog = f
 	}
-	if err := progress.RunOSBuild(pbar, osbuildManifest, res.ImgType.Exports(), osbuildOpts); err != nil {
-		return "", err
+	// keep the osbuild monitor log for the diagnostics bundle
+	monitorLog, err := os.CreateTemp("", "osbuild-monitor-*.jsonseq")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create osbuild monitor log: %w", err)
 	}
-	// Rename *sigh*, see https://github.com/osbuild/image-builder/pull/1039
-	// for my preferred way. Every frontend to images has to duplicate
-	// similar code like this.
-	pipelineDir := filepath.Join(opts.OutputDir, res.ImgType.Exports()[0])
-	srcName := filepath.Join(pipelineDir, res.ImgType.Filename())
-	imgExt := strings.SplitN(res.ImgType.Filename(), ".", 2)[1]
-	dstName := filepath.Join(opts.OutputDir, fmt.Sprintf("%s.%v", basename, imgExt))
-	if err := os.Rename(srcName, dstName); err != nil {
-		return "", fmt.Errorf("cannot rename artifact to final name: %w", err)
+	defer os.Remove(monitorLog.Name())
+	defer monitorLog.Close()
+	osbuildOpts.MonitorLog = monitorLog
+
+	if err := progress.RunOSBuild(ctx, pbar, osbuildManifest, exports, osbuildOpts); err != nil {
+		if ctx.Err() != nil {
+			// an interrupted build is not a bug, just remove
+			// what osbuild exported so far
+			for _, export := range exports {
+				_ = os.RemoveAll(filepath.Join(opts.OutputDir, export))
+			}
+			return nil, err
+		}
+		p := filepath.Join(opts.OutputDir, fmt.Sprintf("%s.diagnostics.tar.gz", basename))
+		return nil, withDiagnosticsBundle(p, err, &diagnosticsOptions{
+			Manifest:   osbuildManifest,
+			MonitorLog: monitorLog.Name(),
+			Blueprint:  opts.Blueprint,
+		})
+	}
+
+	var imagePaths []string
+	for _, ib := range builds {
+		// Rename *sigh*, see https://github.com/osbuild/image-builder/pull/1039
+		// for my preferred way. Every frontend to images has to duplicate
+		// similar code like this.
+		pipelineDir := filepath.Join(opts.OutputDir, ib.exports()[0])
+		srcName := filepath.Join(pipelineDir, ib.img.ImgType.Filename())
+		dstName := imageFilenameFor(opts.OutputDir, ib.img, opts.OutputBasename)
+		if err := os.Rename(srcName, dstName); err != nil {
+			return nil, fmt.Errorf("cannot rename artifact to final name: %w", err)
+		}
+		// image types with a block map export it next to the image
+		if _, err := os.Stat(srcName + ".bmap"); err == nil {
+			if err := os.Rename(srcName+".bmap", dstName+".bmap"); err != nil {
+				return nil, fmt.Errorf("cannot rename block map to final name: %w", err)
+			}
+		}
+		// best effort, remove the now empty pipeline export dir from osbuild
+		_ = os.Remove(pipelineDir)
+
+		imagePaths = append(imagePaths, dstName)
 	}
-	// best effort, remove the now empty pipeline export dir from osbuild
-	_ = os.Remove(pipelineDir)
 
-	return dstName, nil
+	return imagePaths, nil
 }
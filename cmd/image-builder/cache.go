@@ -0,0 +1,610 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+	"golang.org/x/sys/unix"
+
+	"github.com/osbuild/image-builder/pkg/datasizes"
+	"github.com/osbuild/image-builder/pkg/manifestgen"
+)
+
+// The kinds of cached data that the cache commands manage
+const (
+	cacheKindRpmmd      = "rpmmd"
+	cacheKindStore      = "store"
+	cacheKindContainers = "containers"
+)
+
+var cacheKinds = []string{cacheKindRpmmd, cacheKindStore, cacheKindContainers}
+
+// containerSourcesDir is the directory in the osbuild store sources
+// that contains the downloaded container images
+const containerSourcesDir = "org.osbuild.containers"
+
+// storeDirs are the directories of the osbuild store that can be
+// removed safely, osbuild recreates them as needed
+var storeDirs = []string{"objects", "refs", "sources", "stage", "tmp"}
+
+// cacheEntry is a single unit of cached data that can be pruned,
+// e.g. the metadata of a single repository or a single store object
+type cacheEntry struct {
+	Kind string
+	// Distro is only known for the rpmmd cache
+	Distro string
+	Paths  []string
+	Size   int64
+	// LastModified is the latest modification time of the paths,
+	// the caches do not record when an entry was last used
+	LastModified time.Time
+}
+
+func (e *cacheEntry) add(path string) error {
+	size, err := dirSize(path)
+	if err != nil {
+		return err
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	e.Paths = append(e.Paths, path)
+	e.Size += size
+	if st.ModTime().After(e.LastModified) {
+		e.LastModified = st.ModTime()
+	}
+	return nil
+}
+
+func (e *cacheEntry) remove() error {
+	for _, path := range e.Paths {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheDirs contains the locations of the caches
+type cacheDirs struct {
+	Rpmmd string
+	Store string
+}
+
+func cacheDirsFromFlags(cmd *cobra.Command) (*cacheDirs, error) {
+	storeDir, err := cmd.Flags().GetString("cache")
+	if err != nil {
+		return nil, err
+	}
+	rpmmdDir, err := cmd.Flags().GetString("rpmmd-cache")
+	if err != nil {
+		return nil, err
+	}
+	if rpmmdDir == "" {
+		rpmmdDir, err = manifestgen.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &cacheDirs{Rpmmd: rpmmdDir, Store: storeDir}, nil
+}
+
+// readDirIfExists is like os.ReadDir but a missing directory is
+// not an error
+func readDirIfExists(path string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return entries, err
+}
+
+// rpmmdCacheEntries returns the cached metadata of all repositories
+// for all distros in the rpmmd cache, the cache is organized as
+// <root>/<distro>/<repo-id><suffix>
+func rpmmdCacheEntries(root string) ([]*cacheEntry, error) {
+	distros, err := readDirIfExists(root)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*cacheEntry
+	for _, d := range distros {
+		if !d.IsDir() {
+			continue
+		}
+		repoEntries, err := readDirIfExists(filepath.Join(root, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		repos := map[string]*cacheEntry{}
+		for _, re := range repoEntries {
+			// see depsolvednf.rpmCache, repo IDs are sha256 hashes
+			repoID := re.Name()
+			if len(repoID) > 64 {
+				repoID = repoID[:64]
+			}
+			repo, ok := repos[repoID]
+			if !ok {
+				repo = &cacheEntry{Kind: cacheKindRpmmd, Distro: d.Name()}
+				repos[repoID] = repo
+				entries = append(entries, repo)
+			}
+			if err := repo.add(filepath.Join(root, d.Name(), re.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// storeCacheEntries returns the objects and sources in the osbuild
+// store, downloaded container images are of kind "containers"
+func storeCacheEntries(root string) (objects, sources, containers []*cacheEntry, err error) {
+	addAll := func(dir, kind string) ([]*cacheEntry, error) {
+		dirEntries, err := readDirIfExists(dir)
+		if err != nil {
+			return nil, err
+		}
+		var entries []*cacheEntry
+		for _, de := range dirEntries {
+			entry := &cacheEntry{Kind: kind}
+			if err := entry.add(filepath.Join(dir, de.Name())); err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	}
+
+	objects, err = addAll(filepath.Join(root, "objects"), cacheKindStore)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sourceTypes, err := readDirIfExists(filepath.Join(root, "sources"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, st := range sourceTypes {
+		dir := filepath.Join(root, "sources", st.Name())
+		if st.Name() == containerSourcesDir {
+			containers, err = addAll(dir, cacheKindContainers)
+		} else {
+			var more []*cacheEntry
+			more, err = addAll(dir, cacheKindStore)
+			sources = append(sources, more...)
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return objects, sources, containers, nil
+}
+
+// removeDanglingRefs removes all refs in the osbuild store that point
+// to objects that got pruned
+func removeDanglingRefs(root string) error {
+	refsDir := filepath.Join(root, "refs")
+	refs, err := readDirIfExists(refsDir)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		path := filepath.Join(refsDir, ref.Name())
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cacheUsage summarizes a set of cache entries
+type cacheUsage struct {
+	Count        int        `yaml:"count" json:"count"`
+	Size         int64      `yaml:"size" json:"size"`
+	LastModified *time.Time `yaml:"last-modified,omitempty" json:"last-modified,omitempty"`
+}
+
+func newCacheUsage(entries []*cacheEntry) cacheUsage {
+	var usage cacheUsage
+	for _, e := range entries {
+		usage.Count++
+		usage.Size += e.Size
+		if usage.LastModified == nil || e.LastModified.After(*usage.LastModified) {
+			lastUsed := e.LastModified.UTC()
+			usage.LastModified = &lastUsed
+		}
+	}
+	return usage
+}
+
+type cacheInfo struct {
+	Rpmmd struct {
+		Path    string                `yaml:"path" json:"path"`
+		Size    int64                 `yaml:"size" json:"size"`
+		Distros map[string]cacheUsage `yaml:"distros" json:"distros"`
+	} `yaml:"rpmmd" json:"rpmmd"`
+	Store struct {
+		Path       string       `yaml:"path" json:"path"`
+		Size       int64        `yaml:"size" json:"size"`
+		MaxSize    cacheMaxSize `yaml:"max-size" json:"max-size"`
+		Objects    cacheUsage   `yaml:"objects" json:"objects"`
+		Sources    cacheUsage   `yaml:"sources" json:"sources"`
+		Containers cacheUsage   `yaml:"containers" json:"containers"`
+	} `yaml:"store" json:"store"`
+}
+
+func readCacheInfo(dirs *cacheDirs) (*cacheInfo, error) {
+	info := &cacheInfo{}
+
+	info.Rpmmd.Path = dirs.Rpmmd
+	rpmmd, err := rpmmdCacheEntries(dirs.Rpmmd)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rpmmd cache: %w", err)
+	}
+	byDistro := map[string][]*cacheEntry{}
+	for _, e := range rpmmd {
+		byDistro[e.Distro] = append(byDistro[e.Distro], e)
+		info.Rpmmd.Size += e.Size
+	}
+	info.Rpmmd.Distros = map[string]cacheUsage{}
+	for distro, entries := range byDistro {
+		info.Rpmmd.Distros[distro] = newCacheUsage(entries)
+	}
+
+	info.Store.Path = dirs.Store
+	info.Store.MaxSize = readCacheMaxSize(dirs.Store)
+	objects, sources, containers, err := storeCacheEntries(dirs.Store)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read osbuild store: %w", err)
+	}
+	info.Store.Objects = newCacheUsage(objects)
+	info.Store.Sources = newCacheUsage(sources)
+	info.Store.Containers = newCacheUsage(containers)
+	size, err := dirSize(dirs.Store)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("cannot calculate osbuild store size: %w", err)
+	}
+	info.Store.Size = size
+
+	return info, nil
+}
+
+func cmdCacheInfo(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	dirs, err := cacheDirsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	info, err := readCacheInfo(dirs)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "", "yaml":
+		enc := yaml.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent(2)
+		return enc.Encode(info)
+	case "json":
+		b, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\n", b)
+		return nil
+	default:
+		return fmt.Errorf("unsupported format %q, supported formats: yaml, json", format)
+	}
+}
+
+// parseAge parses a duration like time.ParseDuration but also
+// supports days, e.g. "30d"
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseUint(days, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q: %w", s, err)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q: %w", s, err)
+	}
+	return d, nil
+}
+
+func kindsFromFlags(cmd *cobra.Command) ([]string, error) {
+	kinds, err := cmd.Flags().GetStringSlice("kind")
+	if err != nil {
+		return nil, err
+	}
+	if len(kinds) == 0 {
+		return cacheKinds, nil
+	}
+	for _, kind := range kinds {
+		if !slices.Contains(cacheKinds, kind) {
+			return nil, fmt.Errorf("unsupported cache kind %q, supported kinds: %s", kind, strings.Join(cacheKinds, ", "))
+		}
+	}
+	return kinds, nil
+}
+
+type pruneOptions struct {
+	Kinds     []string
+	OlderThan time.Duration
+	MaxSize   uint64
+	Distros   []string
+	DryRun    bool
+}
+
+// selectPrunable returns the entries that should be pruned: all
+// entries for the given distros, all entries that were not modified
+// for longer than OlderThan and then the least recently modified
+// entries until the remaining entries fit into MaxSize
+func selectPrunable(entries []*cacheEntry, opts *pruneOptions, now time.Time) []*cacheEntry {
+	var prune, keep []*cacheEntry
+	for _, e := range entries {
+		switch {
+		case !slices.Contains(opts.Kinds, e.Kind):
+			continue
+		case e.Distro != "" && slices.Contains(opts.Distros, e.Distro):
+			prune = append(prune, e)
+		case opts.OlderThan > 0 && now.Sub(e.LastModified) > opts.OlderThan:
+			prune = append(prune, e)
+		default:
+			keep = append(keep, e)
+		}
+	}
+	if opts.MaxSize == 0 {
+		return prune
+	}
+
+	var size uint64
+	for _, e := range keep {
+		size += uint64(e.Size)
+	}
+	sort.SliceStable(keep, func(i, j int) bool {
+		return keep[i].LastModified.Before(keep[j].LastModified)
+	})
+	for _, e := range keep {
+		if size <= opts.MaxSize {
+			break
+		}
+		prune = append(prune, e)
+		size -= uint64(e.Size)
+	}
+	return prune
+}
+
+// validateRpmmdDistros checks that the given distros are entries of
+// the rpmmd cache, they are used to build the paths that get removed
+func validateRpmmdDistros(root string, distros []string) error {
+	for _, distro := range distros {
+		if distro == "" || distro == "." || strings.Contains(distro, "..") || strings.ContainsRune(distro, filepath.Separator) {
+			return fmt.Errorf("invalid distro %q", distro)
+		}
+		st, err := os.Stat(filepath.Join(root, distro))
+		if errors.Is(err, os.ErrNotExist) || (err == nil && !st.IsDir()) {
+			return fmt.Errorf("distro %q not found in the rpmmd cache %s", distro, root)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// osbuildStoreLock is the lock file of the osbuild store, osbuild
+// holds a shared lock on it while it uses the store
+const osbuildStoreLock = "cache.lock"
+
+// lockStore takes an exclusive lock on the osbuild store so that no
+// build can use the store while it gets modified, a store that is in
+// use by a build is an error. The store is only locked if one of the
+// given kinds lives in the store.
+func lockStore(storeDir string, kinds []string) (unlock func(), err error) {
+	if !slices.Contains(kinds, cacheKindStore) && !slices.Contains(kinds, cacheKindContainers) {
+		return func() {}, nil
+	}
+	f, err := os.OpenFile(filepath.Join(storeDir, osbuildStoreLock), os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		// osbuild never used this store
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open osbuild store lock: %w", err)
+	}
+	lk := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_OFD_SETLK, &lk); err != nil {
+		f.Close()
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EACCES) {
+			return nil, fmt.Errorf("the osbuild store %s is in use by a build, try again once it is finished", storeDir)
+		}
+		return nil, fmt.Errorf("cannot lock osbuild store: %w", err)
+	}
+	// closing the file releases the lock
+	return func() { f.Close() }, nil
+}
+
+func pruneCache(w io.Writer, dirs *cacheDirs, opts *pruneOptions) error {
+	if err := validateRpmmdDistros(dirs.Rpmmd, opts.Distros); err != nil {
+		return err
+	}
+	unlock, err := lockStore(dirs.Store, opts.Kinds)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	rpmmd, err := rpmmdCacheEntries(dirs.Rpmmd)
+	if err != nil {
+		return fmt.Errorf("cannot read rpmmd cache: %w", err)
+	}
+	objects, sources, containers, err := storeCacheEntries(dirs.Store)
+	if err != nil {
+		return fmt.Errorf("cannot read osbuild store: %w", err)
+	}
+	var entries []*cacheEntry
+	entries = append(entries, rpmmd...)
+	entries = append(entries, objects...)
+	entries = append(entries, sources...)
+	entries = append(entries, containers...)
+
+	var freed int64
+	for _, e := range selectPrunable(entries, opts, time.Now()) {
+		for _, path := range e.Paths {
+			fmt.Fprintf(w, "removing %s\n", path)
+		}
+		if !opts.DryRun {
+			if err := e.remove(); err != nil {
+				return err
+			}
+		}
+		freed += e.Size
+	}
+	if !opts.DryRun {
+		if err := removeDanglingRefs(dirs.Store); err != nil {
+			return err
+		}
+		for _, distro := range opts.Distros {
+			if err := os.RemoveAll(filepath.Join(dirs.Rpmmd, distro)); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintf(w, "freed %d bytes\n", freed)
+	return nil
+}
+
+func cmdCachePrune(cmd *cobra.Command, args []string) error {
+	dirs, err := cacheDirsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	kinds, err := kindsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	olderThanStr, err := cmd.Flags().GetString("older-than")
+	if err != nil {
+		return err
+	}
+	maxSizeStr, err := cmd.Flags().GetString("max-size")
+	if err != nil {
+		return err
+	}
+	distros, err := cmd.Flags().GetStringArray("distro")
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	if olderThanStr == "" && maxSizeStr == "" && len(distros) == 0 {
+		return fmt.Errorf("nothing to prune, use --older-than, --max-size or --distro")
+	}
+
+	opts := &pruneOptions{
+		Kinds:   kinds,
+		Distros: distros,
+		DryRun:  dryRun,
+	}
+	if olderThanStr != "" {
+		opts.OlderThan, err = parseAge(olderThanStr)
+		if err != nil {
+			return err
+		}
+	}
+	if maxSizeStr != "" {
+		opts.MaxSize, err = datasizes.Parse(maxSizeStr)
+		if err != nil {
+			return fmt.Errorf("invalid max size %q: %w", maxSizeStr, err)
+		}
+	}
+	if len(distros) > 0 && !slices.Contains(kinds, cacheKindRpmmd) {
+		return fmt.Errorf("--distro can only be used to prune the rpmmd cache")
+	}
+
+	return pruneCache(cmd.OutOrStdout(), dirs, opts)
+}
+
+func cleanCache(w io.Writer, dirs *cacheDirs, kinds []string) error {
+	unlock, err := lockStore(dirs.Store, kinds)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var paths []string
+	if slices.Contains(kinds, cacheKindRpmmd) {
+		distros, err := readDirIfExists(dirs.Rpmmd)
+		if err != nil {
+			return fmt.Errorf("cannot read rpmmd cache: %w", err)
+		}
+		for _, d := range distros {
+			paths = append(paths, filepath.Join(dirs.Rpmmd, d.Name()))
+		}
+	}
+	if slices.Contains(kinds, cacheKindStore) {
+		for _, dir := range storeDirs {
+			// containers are cleaned separately below
+			if dir == "sources" && !slices.Contains(kinds, cacheKindContainers) {
+				sourceTypes, err := readDirIfExists(filepath.Join(dirs.Store, dir))
+				if err != nil {
+					return fmt.Errorf("cannot read osbuild store: %w", err)
+				}
+				for _, st := range sourceTypes {
+					if st.Name() != containerSourcesDir {
+						paths = append(paths, filepath.Join(dirs.Store, dir, st.Name()))
+					}
+				}
+				continue
+			}
+			paths = append(paths, filepath.Join(dirs.Store, dir))
+		}
+	} else if slices.Contains(kinds, cacheKindContainers) {
+		paths = append(paths, filepath.Join(dirs.Store, "sources", containerSourcesDir))
+	}
+
+	for _, path := range paths {
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		fmt.Fprintf(w, "removing %s\n", path)
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cmdCacheClean(cmd *cobra.Command, args []string) error {
+	dirs, err := cacheDirsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	kinds, err := kindsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	return cleanCache(cmd.OutOrStdout(), dirs, kinds)
+}
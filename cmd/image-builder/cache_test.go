@@ -0,0 +1,260 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	main "github.com/osbuild/image-builder/cmd/image-builder"
+)
+
+const (
+	fakeRepoID1 = "1111111111111111111111111111111111111111111111111111111111111111"
+	fakeRepoID2 = "2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// makeFakeCaches creates a rpmmd cache and an osbuild store with the
+// given paths, each file is 100 bytes and was last modified "age" ago
+func makeFakeCaches(t *testing.T, files map[string]time.Duration) (rpmmdDir, storeDir string) {
+	t.Helper()
+	tmpdir := t.TempDir()
+	for path, age := range files {
+		full := filepath.Join(tmpdir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, bytes.Repeat([]byte("x"), 100), 0644))
+		mtime := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(full, mtime, mtime))
+		require.NoError(t, os.Chtimes(filepath.Dir(full), mtime, mtime))
+	}
+	return filepath.Join(tmpdir, "rpmmd"), filepath.Join(tmpdir, "store")
+}
+
+func fakeCacheFiles() map[string]time.Duration {
+	day := 24 * time.Hour
+	return map[string]time.Duration{
+		"rpmmd/fedora-42/" + fakeRepoID1 + "-filenames.solv":   40 * day,
+		"rpmmd/fedora-42/" + fakeRepoID1 + ".solv":             40 * day,
+		"rpmmd/fedora-43/" + fakeRepoID2 + ".solv":             1 * day,
+		"store/objects/obj1/data":                              50 * day,
+		"store/objects/obj2/data":                              2 * day,
+		"store/sources/org.osbuild.files/sha256:aaa":           35 * day,
+		"store/sources/org.osbuild.containers/sha256:bbb/data": 3 * day,
+		"store/sources/org.osbuild.containers/sha256:ccc/data": 60 * day,
+	}
+}
+
+func runCacheCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	restore := main.MockOsArgs(append([]string{"cache"}, args...))
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	err := main.Run()
+	return fakeStdout.String(), err
+}
+
+func TestCacheInfo(t *testing.T) {
+	rpmmdDir, storeDir := makeFakeCaches(t, fakeCacheFiles())
+
+	out, err := runCacheCmd(t, "info", "--format=json", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	require.NoError(t, err)
+
+	type usage struct {
+		Count        int        `json:"count"`
+		Size         int64      `json:"size"`
+		LastModified *time.Time `json:"last-modified"`
+	}
+	var info struct {
+		Rpmmd struct {
+			Path    string           `json:"path"`
+			Size    int64            `json:"size"`
+			Distros map[string]usage `json:"distros"`
+		} `json:"rpmmd"`
+		Store struct {
+			Path       string `json:"path"`
+			Size       int64  `json:"size"`
+			MaxSize    any    `json:"max-size"`
+			Objects    usage  `json:"objects"`
+			Sources    usage  `json:"sources"`
+			Containers usage  `json:"containers"`
+		} `json:"store"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &info))
+
+	assert.Equal(t, rpmmdDir, info.Rpmmd.Path)
+	assert.Equal(t, int64(300), info.Rpmmd.Size)
+	require.Len(t, info.Rpmmd.Distros, 2)
+	assert.Equal(t, 1, info.Rpmmd.Distros["fedora-42"].Count)
+	assert.Equal(t, int64(200), info.Rpmmd.Distros["fedora-42"].Size)
+	assert.Equal(t, 1, info.Rpmmd.Distros["fedora-43"].Count)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), *info.Rpmmd.Distros["fedora-43"].LastModified, time.Minute)
+
+	assert.Equal(t, storeDir, info.Store.Path)
+	assert.Equal(t, int64(500), info.Store.Size)
+	assert.Equal(t, "unknown", info.Store.MaxSize)
+	assert.Equal(t, 2, info.Store.Objects.Count)
+	assert.Equal(t, int64(200), info.Store.Objects.Size)
+	assert.WithinDuration(t, time.Now().Add(-48*time.Hour), *info.Store.Objects.LastModified, time.Minute)
+	assert.Equal(t, 1, info.Store.Sources.Count)
+	assert.Equal(t, 2, info.Store.Containers.Count)
+}
+
+func TestCacheInfoEmpty(t *testing.T) {
+	tmpdir := t.TempDir()
+	out, err := runCacheCmd(t, "info", "--rpmmd-cache", filepath.Join(tmpdir, "rpmmd"), "--cache", filepath.Join(tmpdir, "store"))
+	require.NoError(t, err)
+	assert.Contains(t, out, "distros: {}\n")
+	assert.Contains(t, out, "objects:\n    count: 0\n    size: 0\n")
+}
+
+func TestCachePruneOlderThan(t *testing.T) {
+	rpmmdDir, storeDir := makeFakeCaches(t, fakeCacheFiles())
+
+	out, err := runCacheCmd(t, "prune", "--older-than", "30d", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "freed 500 bytes\n")
+
+	assert.NoFileExists(t, filepath.Join(rpmmdDir, "fedora-42", fakeRepoID1+".solv"))
+	assert.NoFileExists(t, filepath.Join(rpmmdDir, "fedora-42", fakeRepoID1+"-filenames.solv"))
+	assert.FileExists(t, filepath.Join(rpmmdDir, "fedora-43", fakeRepoID2+".solv"))
+	assert.NoDirExists(t, filepath.Join(storeDir, "objects/obj1"))
+	assert.DirExists(t, filepath.Join(storeDir, "objects/obj2"))
+	assert.NoFileExists(t, filepath.Join(storeDir, "sources/org.osbuild.files/sha256:aaa"))
+	assert.DirExists(t, filepath.Join(storeDir, "sources/org.osbuild.containers/sha256:bbb"))
+	assert.NoDirExists(t, filepath.Join(storeDir, "sources/org.osbuild.containers/sha256:ccc"))
+}
+
+func TestCachePruneMaxSize(t *testing.T) {
+	rpmmdDir, storeDir := makeFakeCaches(t, fakeCacheFiles())
+
+	out, err := runCacheCmd(t, "prune", "--max-size", "300", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "freed 500 bytes\n")
+
+	// the three most recently used entries are kept
+	assert.FileExists(t, filepath.Join(rpmmdDir, "fedora-43", fakeRepoID2+".solv"))
+	assert.DirExists(t, filepath.Join(storeDir, "objects/obj2"))
+	assert.DirExists(t, filepath.Join(storeDir, "sources/org.osbuild.containers/sha256:bbb"))
+	assert.NoDirExists(t, filepath.Join(storeDir, "objects/obj1"))
+	assert.NoFileExists(t, filepath.Join(rpmmdDir, "fedora-42", fakeRepoID1+".solv"))
+}
+
+func TestCachePruneDistro(t *testing.T) {
+	rpmmdDir, storeDir := makeFakeCaches(t, fakeCacheFiles())
+
+	out, err := runCacheCmd(t, "prune", "--distro", "fedora-43", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	require.NoError(t, err)
+	assert.Equal(t, "removing "+filepath.Join(rpmmdDir, "fedora-43", fakeRepoID2+".solv")+"\nfreed 100 bytes\n", out)
+	assert.NoDirExists(t, filepath.Join(rpmmdDir, "fedora-43"))
+	assert.DirExists(t, filepath.Join(rpmmdDir, "fedora-42"))
+}
+
+func TestCachePruneKindDryRun(t *testing.T) {
+	rpmmdDir, storeDir := makeFakeCaches(t, fakeCacheFiles())
+
+	out, err := runCacheCmd(t, "prune", "--older-than", "1h", "--kind", "containers", "--dry-run", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(out, "removing "+filepath.Join(storeDir, "sources/org.osbuild.containers")))
+	assert.Contains(t, out, "freed 200 bytes\n")
+	assert.DirExists(t, filepath.Join(storeDir, "sources/org.osbuild.containers/sha256:bbb"))
+	assert.DirExists(t, filepath.Join(storeDir, "sources/org.osbuild.containers/sha256:ccc"))
+}
+
+func TestCachePruneErrors(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		expected string
+	}{
+		{nil, "nothing to prune, use --older-than, --max-size or --distro"},
+		{[]string{"--older-than", "forever"}, `invalid age "forever": time: invalid duration "forever"`},
+		{[]string{"--max-size", "lots"}, `invalid max size "lots": the size string is not a valid positive float number: lots`},
+		{[]string{"--distro", "fedora-42", "--kind", "store"}, "--distro can only be used to prune the rpmmd cache"},
+		{[]string{"--older-than", "1d", "--kind", "foo"}, "unsupported cache kind \"foo\", supported kinds: rpmmd, store, containers"},
+		{[]string{"--distro", "../../etc"}, `invalid distro "../../etc"`},
+		{[]string{"--distro", "fedora/../.."}, `invalid distro "fedora/../.."`},
+		{[]string{"--distro", ".."}, `invalid distro ".."`},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			tmpdir := t.TempDir()
+			args := append([]string{"prune", "--rpmmd-cache", tmpdir, "--cache", tmpdir}, tc.args...)
+			_, err := runCacheCmd(t, args...)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestCachePruneUnknownDistro(t *testing.T) {
+	rpmmdDir, storeDir := makeFakeCaches(t, fakeCacheFiles())
+
+	_, err := runCacheCmd(t, "prune", "--distro", "fedora-99", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	assert.EqualError(t, err, fmt.Sprintf(`distro "fedora-99" not found in the rpmmd cache %s`, rpmmdDir))
+	assert.DirExists(t, filepath.Join(rpmmdDir, "fedora-42"))
+}
+
+func TestCachePruneStoreInUse(t *testing.T) {
+	rpmmdDir, storeDir := makeFakeCaches(t, fakeCacheFiles())
+
+	// osbuild holds a shared lock on the store while building
+	f, err := os.Create(filepath.Join(storeDir, "cache.lock"))
+	require.NoError(t, err)
+	defer f.Close()
+	lk := unix.Flock_t{Type: unix.F_RDLCK, Whence: io.SeekStart}
+	require.NoError(t, unix.FcntlFlock(f.Fd(), unix.F_OFD_SETLK, &lk))
+
+	_, err = runCacheCmd(t, "prune", "--older-than", "1h", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	assert.EqualError(t, err, fmt.Sprintf("the osbuild store %s is in use by a build, try again once it is finished", storeDir))
+	_, err = runCacheCmd(t, "clean", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	assert.ErrorContains(t, err, "is in use by a build")
+	assert.DirExists(t, filepath.Join(storeDir, "objects/obj1"))
+
+	// the rpmmd cache is not part of the store
+	_, err = runCacheCmd(t, "prune", "--older-than", "1h", "--kind", "rpmmd", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	assert.NoError(t, err)
+
+	// once the build is done the store can be pruned
+	require.NoError(t, f.Close())
+	_, err = runCacheCmd(t, "prune", "--older-than", "1h", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(storeDir, "objects/obj1"))
+}
+
+func TestCacheClean(t *testing.T) {
+	rpmmdDir, storeDir := makeFakeCaches(t, fakeCacheFiles())
+	require.NoError(t, os.WriteFile(filepath.Join(storeDir, "cache.size"), []byte("1000"), 0644))
+
+	_, err := runCacheCmd(t, "clean", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(rpmmdDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.NoDirExists(t, filepath.Join(storeDir, "objects"))
+	assert.NoDirExists(t, filepath.Join(storeDir, "sources"))
+	// the store configuration is kept
+	assert.FileExists(t, filepath.Join(storeDir, "cache.size"))
+}
+
+func TestCacheCleanKeepsContainers(t *testing.T) {
+	rpmmdDir, storeDir := makeFakeCaches(t, fakeCacheFiles())
+
+	_, err := runCacheCmd(t, "clean", "--kind", "store", "--rpmmd-cache", rpmmdDir, "--cache", storeDir)
+	require.NoError(t, err)
+
+	assert.DirExists(t, filepath.Join(rpmmdDir, "fedora-42"))
+	assert.NoDirExists(t, filepath.Join(storeDir, "objects"))
+	assert.NoDirExists(t, filepath.Join(storeDir, "sources/org.osbuild.files"))
+	assert.DirExists(t, filepath.Join(storeDir, "sources/org.osbuild.containers/sha256:bbb"))
+}
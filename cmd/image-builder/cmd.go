@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/osbuild/image-builder/internal/olog"
 	ilog "github.com/osbuild/image-builder/pkg/olog"
@@ -59,8 +60,10 @@ operating systems like Fedora, CentOS and RHEL with easy customizations support.
 	rootCmd.PersistentFlags().StringArray("force-repo", nil, `Override the base repositories during build (these will not be part of the final image)`)
 	rootCmd.PersistentFlags().String("output-dir", "", `Put output into the specified directory`)
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, `Switch to verbose mode (more logging on stderr and verbose progress)`)
+	rootCmd.PersistentFlags().String("config", "", fmt.Sprintf(`Use the given config file instead of %s and the user config file (can also be set with $%s)`, systemConfigPath, configEnvVar))
+	rootCmd.PersistentFlags().String("profile", "", `Use the named profile from the config file`)
 	registerMemProfileFlags(rootCmd)
-	rootCmd.PersistentPreRun = memProfilePersistentPreRun
+	rootCmd.PersistentPreRunE = rootPersistentPreRunE
 
 	rootCmd.SetOut(osStdout)
 	rootCmd.SetErr(osStderr)
@@ -80,6 +83,12 @@ operating systems like Fedora, CentOS and RHEL with easy customizations support.
 	systemCmd := setupSystemCmd()
 	rootCmd.AddCommand(systemCmd)
 
+	cacheCmd := setupCacheCmd()
+	rootCmd.AddCommand(cacheCmd)
+
+	configCmd := setupConfigCmd()
+	rootCmd.AddCommand(configCmd)
+
 	manifestCmd, err := setupManifestCmd()
 	if err != nil {
 		return nil, err
@@ -104,6 +113,12 @@ operating systems like Fedora, CentOS and RHEL with easy customizations support.
 	// that build gets a "--to" parameter
 	uploadCmd.Flags().String("to", "", "upload to the given cloud")
 
+	runCmd := setupRunCmd()
+	rootCmd.AddCommand(runCmd)
+
+	batchCmd := setupBatchCmd()
+	rootCmd.AddCommand(batchCmd)
+
 	describeCmd := setupDescribeCmd()
 	rootCmd.AddCommand(describeCmd)
 
@@ -187,6 +202,72 @@ func setupSystemCmd() *cobra.Command {
 	return systemCmd
 }
 
+func setupCacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and clean up the rpm metadata cache and the osbuild store",
+		Args:  cobra.NoArgs,
+	}
+	cacheCmd.PersistentFlags().String("cache", defaultCacheDir(), `osbuild directory to cache intermediate build artifacts`)
+	cacheCmd.PersistentFlags().String("rpmmd-cache", "", `osbuild directory to cache rpm metadata`)
+
+	cacheInfoCmd := &cobra.Command{
+		Use:          "info",
+		Short:        "Show the size and last use of the cached data",
+		RunE:         cmdCacheInfo,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+	}
+	cacheInfoCmd.Flags().String("format", "", "Output in a specific format (yaml, json)")
+	cacheCmd.AddCommand(cacheInfoCmd)
+
+	cachePruneCmd := &cobra.Command{
+		Use:          "prune",
+		Short:        "Remove cached data by age, size budget or distro",
+		RunE:         cmdCachePrune,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+	}
+	cachePruneCmd.Flags().String("older-than", "", `Remove cached data that was not modified for the given time (e.g. 72h, 30d)`)
+	cachePruneCmd.Flags().String("max-size", "", `Remove the least recently modified data until the cache fits the given size (e.g. 20 GiB)`)
+	cachePruneCmd.Flags().StringArray("distro", nil, `Remove the cached rpm metadata for the given distro (e.g. fedora-42)`)
+	cachePruneCmd.Flags().StringSlice("kind", nil, `Only prune the given kind of cached data (rpmmd, store, containers)`)
+	cachePruneCmd.Flags().Bool("dry-run", false, `Only show what would be removed`)
+	cacheCmd.AddCommand(cachePruneCmd)
+
+	cacheCleanCmd := &cobra.Command{
+		Use:          "clean",
+		Short:        "Remove all cached data",
+		RunE:         cmdCacheClean,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+	}
+	cacheCleanCmd.Flags().StringSlice("kind", nil, `Only clean the given kind of cached data (rpmmd, store, containers)`)
+	cacheCmd.AddCommand(cacheCleanCmd)
+
+	return cacheCmd
+}
+
+func setupConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show the image-builder configuration",
+		Args:  cobra.NoArgs,
+	}
+
+	configShowCmd := &cobra.Command{
+		Use:          "show",
+		Short:        "Show the effective configuration from the config files and the selected profile",
+		RunE:         cmdConfigShow,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+	}
+	configShowCmd.Flags().String("format", "", "Output in a specific format (yaml, json)")
+	configCmd.AddCommand(configShowCmd)
+
+	return configCmd
+}
+
 func setupManifestCmd() (*cobra.Command, error) {
 	manifestCmd := &cobra.Command{
 		Use:          "manifest <image-type>",
@@ -266,21 +347,25 @@ func setupUploadCmd() *cobra.Command {
 
 func setupBuildCmd() (*cobra.Command, error) {
 	buildCmd := &cobra.Command{
-		Use:          "build <image-type>",
-		Short:        "Build the given image-type, e.g. qcow2 (tip: combine with --distro, --arch)",
-		RunE:         cmdBuild,
+		Use:   "build <image-type> [<image-type>...]",
+		Short: "Build the given image-types, e.g. qcow2 (tip: combine with --distro, --arch)",
+		RunE:  cmdBuild,
+		// image types can also come from the config file
 		SilenceUsage: true,
-		Args:         cobra.ExactArgs(1),
+		Args:         cobra.ArbitraryArgs,
 	}
 	buildCmd.Flags().Bool("with-manifest", false, `export osbuild manifest`)
 	buildCmd.Flags().Bool("with-buildlog", false, `export osbuild buildlog`)
 	buildCmd.Flags().String("cache", defaultCacheDir(), `osbuild directory to cache intermediate build artifacts"`)
 	// XXX: add "--verbose" here, similar to how bib is doing this
 	// (see https://github.com/osbuild/bootc-image-builder/pull/790/commits/5cec7ffd8a526e2ca1e8ada0ea18f927695dfe43)
-	buildCmd.Flags().String("progress", "auto", "type of progress bar to use (e.g. verbose,term)")
+	buildCmd.Flags().String("progress", "auto", "type of progress bar to use (e.g. verbose,term,jsonl)")
+	buildCmd.Flags().Int("progress-fd", 1, "write the jsonl progress events to the given file descriptor")
 	buildCmd.Flags().Bool("with-metrics", false, `print timing information at the end of the build`)
+	buildCmd.Flags().String("metrics-report", "", `write per-pipeline and per-stage metrics of the build to the given path (CSV for *.csv, JSON otherwise)`)
 	buildCmd.Flags().String("output-name", "", "set specific output basename")
 	buildCmd.Flags().Bool("in-vm", false, `run the osbuild pipeline in a virtual machine`)
+	buildCmd.Flags().Bool("watch", false, `rebuild whenever the blueprint or the local files and repositories it uses change`)
 	buildCmd.Flags().String("format", "", "Output in a specific format (json)")
 	// hide this flag for now, this is only relevant for cockpit-image-builder
 	buildCmd.Flags().Bool("with-upload-result", false, `export upload result`)
@@ -291,6 +376,29 @@ func setupBuildCmd() (*cobra.Command, error) {
 	return buildCmd, nil
 }
 
+func setupRunCmd() *cobra.Command {
+	runCmd := &cobra.Command{
+		Use:          "run <image>",
+		Short:        "Boot the given disk image in QEMU and check that it is healthy",
+		RunE:         cmdRun,
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+	}
+	runCmd.Flags().String("arch", "", `architecture of the image (default: the host architecture)`)
+	runCmd.Flags().Int("memory", 2048, `memory of the virtual machine in MiB`)
+	runCmd.Flags().Int("cpus", 2, `number of CPUs of the virtual machine`)
+	runCmd.Flags().Bool("uefi", false, `boot with UEFI firmware instead of BIOS (always used for aarch64)`)
+	runCmd.Flags().Duration("timeout", 10*time.Minute, `fail if the image did not finish booting within the given time`)
+	runCmd.Flags().String("ssh-key", "", `private SSH key to log into the image (default: a generated key)`)
+	runCmd.Flags().String("console-log", "", `write the serial console output to the given file`)
+	runCmd.Flags().String("check-host-config", "", `run the given check-host-config binary (built for the --arch of the image) in the image to validate the blueprint customizations`)
+	runCmd.Flags().String("blueprint", "", `blueprint the image was built from, used by --check-host-config`)
+	runCmd.Flags().Bool("keep-running", false, `keep the image running after the checks and print how to log in`)
+	runCmd.Flags().String("format", "", "Output in a specific format (text, json)")
+
+	return runCmd
+}
+
 func setupDescribeCmd() *cobra.Command {
 	// XXX: add --format=json too?
 	describeCmd := &cobra.Command{
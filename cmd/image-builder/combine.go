@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/osbuild/image-builder/pkg/imagefilter"
+)
+
+// imageBuild is a single image type that is part of a (possibly
+// combined) osbuild run
+type imageBuild struct {
+	img      *imagefilter.Result
+	manifest []byte
+
+	// pipelines maps the pipeline names of the image manifest to
+	// the names used in the combined manifest, filled in by
+	// combineManifests()
+	pipelines map[string]string
+}
+
+// exports returns the exports of the image in the combined manifest
+func (ib *imageBuild) exports() []string {
+	var exports []string
+	for _, export := range ib.img.ImgType.Exports() {
+		exports = append(exports, ib.pipelineName(export))
+	}
+	return exports
+}
+
+// pipelineName returns the name of the given image pipeline in the
+// combined manifest
+func (ib *imageBuild) pipelineName(name string) string {
+	if newName, ok := ib.pipelines[name]; ok {
+		return newName
+	}
+	return name
+}
+
+type osbuildManifestJSON struct {
+	Version   string                   `json:"version"`
+	Pipelines []map[string]interface{} `json:"pipelines"`
+	Sources   map[string]interface{}   `json:"sources,omitempty"`
+}
+
+// combineManifests merges the osbuild manifests of the given image
+// builds into a single manifest so that all images can be built in a
+// single osbuild run.
+//
+// Pipelines that are identical between the images (e.g. the "build"
+// pipeline or the "os" pipeline when the package sets match) are only
+// included once. Pipelines with the same name but different content
+// are prefixed with the image type name and all references to them
+// are updated.
+func combineManifests(builds []*imageBuild) ([]byte, error) {
+	if len(builds) == 1 {
+		return builds[0].manifest, nil
+	}
+
+	var combined osbuildManifestJSON
+	byName := map[string]map[string]interface{}{}
+	for _, ib := range builds {
+		var mf osbuildManifestJSON
+		dec := json.NewDecoder(bytes.NewReader(ib.manifest))
+		// keep large integers (sizes, offsets) intact
+		dec.UseNumber()
+		if err := dec.Decode(&mf); err != nil {
+			return nil, fmt.Errorf("cannot parse manifest for %q: %w", ib.img.ImgType.Name(), err)
+		}
+		if combined.Version == "" {
+			combined.Version = mf.Version
+		}
+		if mf.Version != combined.Version {
+			return nil, fmt.Errorf("cannot combine manifests with versions %q and %q", combined.Version, mf.Version)
+		}
+
+		ib.pipelines = map[string]string{}
+		for _, pipeline := range mf.Pipelines {
+			name, ok := pipeline["name"].(string)
+			if !ok {
+				return nil, fmt.Errorf("pipeline without name in manifest for %q", ib.img.ImgType.Name())
+			}
+			pipeline = renamePipelineRefs(pipeline, ib.pipelines).(map[string]interface{})
+
+			if existing, ok := byName[name]; ok {
+				if reflect.DeepEqual(existing, pipeline) {
+					// shared with a previous image
+					ib.pipelines[name] = name
+					continue
+				}
+				newName := fmt.Sprintf("%s-%s", ib.img.ImgType.Name(), name)
+				if _, ok := byName[newName]; ok {
+					return nil, fmt.Errorf("cannot combine manifests: duplicated pipeline %q", newName)
+				}
+				pipeline["name"] = newName
+				ib.pipelines[name] = newName
+				name = newName
+			} else {
+				ib.pipelines[name] = name
+			}
+			byName[name] = pipeline
+			combined.Pipelines = append(combined.Pipelines, pipeline)
+		}
+
+		if len(mf.Sources) > 0 && combined.Sources == nil {
+			combined.Sources = map[string]interface{}{}
+		}
+		for k, v := range mf.Sources {
+			combined.Sources[k] = mergeSources(combined.Sources[k], v)
+		}
+	}
+
+	return json.MarshalIndent(combined, "", "    ")
+}
+
+// renamePipelineRefs rewrites all "name:<pipeline>" references in the
+// given value according to the renames map
+func renamePipelineRefs(v interface{}, renames map[string]string) interface{} {
+	rename := func(s string) string {
+		ref, ok := strings.CutPrefix(s, "name:")
+		if !ok {
+			return s
+		}
+		if newName, ok := renames[ref]; ok {
+			return "name:" + newName
+		}
+		return s
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			res[rename(k)] = renamePipelineRefs(val, renames)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, val := range v {
+			res[i] = renamePipelineRefs(val, renames)
+		}
+		return res
+	case string:
+		return rename(v)
+	default:
+		return v
+	}
+}
+
+// mergeSources merges the source items of b into a, sources are
+// content addressed so for duplicated keys the first one wins
+func mergeSources(a, b interface{}) interface{} {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if a == nil {
+			return b
+		}
+		return a
+	}
+	for k, v := range bm {
+		am[k] = mergeSources(am[k], v)
+	}
+	return am
+}
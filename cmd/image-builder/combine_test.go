@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/distro/test_distro"
+	"github.com/osbuild/image-builder/pkg/imagefilter"
+)
+
+func testImageBuild(t *testing.T, imgTypeName, manifest string) *imageBuild {
+	d := test_distro.DistroFactory(test_distro.TestDistro1Name)
+	a, err := d.GetArch(test_distro.TestArch3Name)
+	require.NoError(t, err)
+	imgType, err := a.GetImageType(imgTypeName)
+	require.NoError(t, err)
+	return &imageBuild{
+		img:      &imagefilter.Result{ImgType: imgType},
+		manifest: []byte(manifest),
+	}
+}
+
+func TestCombineManifestsSingle(t *testing.T) {
+	mf := `{"version":"2","pipelines":[]}`
+	ib := testImageBuild(t, "qcow2", mf)
+
+	combined, err := combineManifests([]*imageBuild{ib})
+	require.NoError(t, err)
+	assert.Equal(t, mf, string(combined))
+	assert.Equal(t, "assembler", ib.pipelineName("assembler"))
+}
+
+func TestCombineManifestsSharesIdenticalPipelines(t *testing.T) {
+	ib1 := testImageBuild(t, "qcow2", `{
+  "version": "2",
+  "pipelines": [
+    {"name": "build", "stages": [{"type": "org.osbuild.rpm"}]},
+    {"name": "os", "build": "name:build", "stages": [{"type": "org.osbuild.rpm"}]},
+    {"name": "assembler", "build": "name:build", "stages": [{"type": "org.osbuild.truncate", "options": {"size": "10737418240"}}], "inputs": {"tree": {"references": ["name:os"]}}}
+  ],
+  "sources": {"org.osbuild.curl": {"items": {"sha256:1": {"url": "https://example.com/1"}}}}
+}`)
+	ib2 := testImageBuild(t, "ami", `{
+  "version": "2",
+  "pipelines": [
+    {"name": "build", "stages": [{"type": "org.osbuild.rpm"}]},
+    {"name": "os", "build": "name:build", "stages": [{"type": "org.osbuild.rpm"}]},
+    {"name": "assembler", "build": "name:build", "stages": [{"type": "org.osbuild.truncate", "options": {"size": "5368709120"}}], "inputs": {"tree": {"references": ["name:os"]}}}
+  ],
+  "sources": {"org.osbuild.curl": {"items": {"sha256:2": {"url": "https://example.com/2"}}}}
+}`)
+
+	combined, err := combineManifests([]*imageBuild{ib1, ib2})
+	require.NoError(t, err)
+
+	var mf struct {
+		Version   string `json:"version"`
+		Pipelines []struct {
+			Name  string `json:"name"`
+			Build string `json:"build"`
+		} `json:"pipelines"`
+		Sources map[string]struct {
+			Items map[string]interface{} `json:"items"`
+		} `json:"sources"`
+	}
+	require.NoError(t, json.Unmarshal(combined, &mf))
+	assert.Equal(t, "2", mf.Version)
+
+	var names []string
+	for _, p := range mf.Pipelines {
+		names = append(names, p.Name)
+		assert.Equal(t, map[string]string{"build": "", "os": "name:build", "assembler": "name:build", "ami-assembler": "name:build"}[p.Name], p.Build)
+	}
+	assert.Equal(t, []string{"build", "os", "assembler", "ami-assembler"}, names)
+	assert.Len(t, mf.Sources["org.osbuild.curl"].Items, 2)
+	// large numbers are kept as-is
+	assert.Contains(t, string(combined), `"size": "10737418240"`)
+
+	assert.Equal(t, []string{"assembler"}, ib1.exports())
+	assert.Equal(t, []string{"ami-assembler"}, ib2.exports())
+	assert.Equal(t, "os", ib2.pipelineName("os"))
+}
+
+func TestCombineManifestsRenamesReferences(t *testing.T) {
+	ib1 := testImageBuild(t, "qcow2", `{
+  "version": "2",
+  "pipelines": [
+    {"name": "build", "stages": []},
+    {"name": "os", "build": "name:build", "stages": [{"type": "org.osbuild.users"}]},
+    {"name": "assembler", "build": "name:build", "inputs": {"tree": {"references": ["name:os"]}}}
+  ]
+}`)
+	ib2 := testImageBuild(t, "ami", `{
+  "version": "2",
+  "pipelines": [
+    {"name": "build", "stages": []},
+    {"name": "os", "build": "name:build", "stages": [{"type": "org.osbuild.cloud-init"}]},
+    {"name": "assembler", "build": "name:build", "inputs": {"tree": {"references": ["name:os"]}}}
+  ]
+}`)
+
+	combined, err := combineManifests([]*imageBuild{ib1, ib2})
+	require.NoError(t, err)
+	assert.Contains(t, string(combined), `"name:ami-os"`)
+	assert.Equal(t, "ami-os", ib2.pipelineName("os"))
+	assert.Equal(t, []string{"ami-assembler"}, ib2.exports())
+}
+
+func TestCombineManifestsVersionMismatch(t *testing.T) {
+	ib1 := testImageBuild(t, "qcow2", `{"version":"2","pipelines":[]}`)
+	ib2 := testImageBuild(t, "ami", `{"version":"1","pipelines":[]}`)
+
+	_, err := combineManifests([]*imageBuild{ib1, ib2})
+	assert.EqualError(t, err, `cannot combine manifests with versions "2" and "1"`)
+}
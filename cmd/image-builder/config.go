@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.yaml.in/yaml/v3"
+)
+
+// configEnvVar can be used to point to a config file instead of the
+// default system and user config files
+const configEnvVar = "IMAGE_BUILDER_CONFIG"
+
+// configImageTypesKey is the config option for the image types to build
+// when none are given on the command line, all other options are the
+// long names of the command line flags
+const configImageTypesKey = "image-types"
+
+var systemConfigPath = "/etc/image-builder/config.yaml"
+
+// configOptions maps option names to their values, a value is either a
+// scalar or a list of scalars for flags that can be repeated
+type configOptions map[string]any
+
+// configFile is the image-builder config file, it contains defaults for
+// all command line flags and named profiles that override them
+type configFile struct {
+	Defaults configOptions            `yaml:"defaults"`
+	Profiles map[string]configOptions `yaml:"profiles"`
+}
+
+// effectiveConfig is the result of merging all config files and the
+// selected profile
+type effectiveConfig struct {
+	Files    []string      `yaml:"files" json:"files"`
+	Profile  string        `yaml:"profile,omitempty" json:"profile,omitempty"`
+	Profiles []string      `yaml:"profiles" json:"profiles"`
+	Options  configOptions `yaml:"options" json:"options"`
+}
+
+// ImageTypes returns the image types to build from the configuration
+func (cfg *effectiveConfig) ImageTypes() []string {
+	if cfg == nil {
+		return nil
+	}
+	// validated in loadConfig()
+	imageTypes, _ := configValues(cfg.Options[configImageTypesKey])
+	return imageTypes
+}
+
+func userConfigPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "image-builder", "config.yaml"), nil
+}
+
+// configPaths returns the config files to load and if they must exist,
+// an explicit config file must exist while the default system and user
+// config files are optional
+func configPaths(cmd *cobra.Command) (paths []string, required bool, err error) {
+	path, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return nil, false, err
+	}
+	if path == "" {
+		path = os.Getenv(configEnvVar)
+	}
+	if path != "" {
+		return []string{path}, true, nil
+	}
+
+	paths = []string{systemConfigPath}
+	userPath, err := userConfigPath()
+	if err == nil {
+		paths = append(paths, userPath)
+	}
+	return paths, false, nil
+}
+
+func loadConfigFile(path string) (*configFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cf configFile
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cf); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("cannot parse config file %q: %w", path, err)
+	}
+	// blueprints are relative to the config file, like in the batch
+	// build matrix
+	for _, opts := range append([]configOptions{cf.Defaults}, slices.Collect(maps.Values(cf.Profiles))...) {
+		if bp, ok := opts["blueprint"].(string); ok && bp != "" && bp != "-" && !filepath.IsAbs(bp) {
+			opts["blueprint"] = filepath.Join(filepath.Dir(path), bp)
+		}
+	}
+	return &cf, nil
+}
+
+func isConfigScalar(v any) bool {
+	switch v.(type) {
+	case string, bool, int, int64, uint64, float64:
+		return true
+	}
+	return false
+}
+
+// configValues converts a config value to the string values for
+// the command line flag
+func configValues(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		var values []string
+		for _, elem := range v {
+			if !isConfigScalar(elem) {
+				return nil, fmt.Errorf("unsupported value %v", elem)
+			}
+			values = append(values, fmt.Sprint(elem))
+		}
+		return values, nil
+	default:
+		if !isConfigScalar(v) {
+			return nil, fmt.Errorf("unsupported value %v", v)
+		}
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+// knownFlags returns all flags of the given command and its
+// subcommands
+func knownFlags(cmd *cobra.Command) map[string]*pflag.Flag {
+	flags := map[string]*pflag.Flag{}
+	addFlag := func(f *pflag.Flag) {
+		flags[f.Name] = f
+	}
+	cmd.PersistentFlags().VisitAll(addFlag)
+	cmd.Flags().VisitAll(addFlag)
+	for _, sub := range cmd.Commands() {
+		for name, f := range knownFlags(sub) {
+			flags[name] = f
+		}
+	}
+	return flags
+}
+
+func validateConfigOptions(root *cobra.Command, opts configOptions) error {
+	flags := knownFlags(root)
+	for name, value := range opts {
+		values, err := configValues(value)
+		if err != nil {
+			return fmt.Errorf("option %q: %w", name, err)
+		}
+		if name == configImageTypesKey {
+			continue
+		}
+		switch name {
+		case "config", "profile", "help":
+			return fmt.Errorf("option %q cannot be set in the config file", name)
+		}
+		f, ok := flags[name]
+		if !ok {
+			return fmt.Errorf("unknown option %q", name)
+		}
+		if _, isSlice := f.Value.(pflag.SliceValue); !isSlice && len(values) > 1 {
+			return fmt.Errorf("option %q takes a single value", name)
+		}
+	}
+	return nil
+}
+
+// loadConfig loads the config files, merges them and applies the
+// profile selected with --profile
+func loadConfig(cmd *cobra.Command) (*effectiveConfig, error) {
+	paths, required, err := configPaths(cmd)
+	if err != nil {
+		return nil, err
+	}
+	profileName, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &effectiveConfig{
+		Files:    []string{},
+		Profiles: []string{},
+		Options:  configOptions{},
+	}
+	defaults := configOptions{}
+	profiles := map[string]configOptions{}
+	for _, path := range paths {
+		cf, err := loadConfigFile(path)
+		if errors.Is(err, os.ErrNotExist) && !required {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot load config file: %w", err)
+		}
+		cfg.Files = append(cfg.Files, path)
+		for name, value := range cf.Defaults {
+			defaults[name] = value
+		}
+		for name, profile := range cf.Profiles {
+			profiles[name] = profile
+		}
+	}
+
+	for name, value := range defaults {
+		cfg.Options[name] = value
+	}
+	for name := range profiles {
+		cfg.Profiles = append(cfg.Profiles, name)
+	}
+	sort.Strings(cfg.Profiles)
+	if profileName != "" {
+		profile, ok := profiles[profileName]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q, available profiles: %s", profileName, strings.Join(cfg.Profiles, ", "))
+		}
+		cfg.Profile = profileName
+		for name, value := range profile {
+			cfg.Options[name] = value
+		}
+	}
+
+	if err := validateConfigOptions(cmd.Root(), cfg.Options); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// applyConfig sets all flags of the command that are not given on
+// the command line to the value from the configuration
+func applyConfig(cmd *cobra.Command, cfg *effectiveConfig) error {
+	for name, value := range cfg.Options {
+		f := cmd.Flags().Lookup(name)
+		// options can be for any command
+		if f == nil || f.Changed {
+			continue
+		}
+		values, err := configValues(value)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if err := cmd.Flags().Set(name, v); err != nil {
+				return fmt.Errorf("invalid value %q for option %q: %w", v, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+type configContextKey struct{}
+
+// configFromContext returns the configuration that got applied to
+// the running command
+func configFromContext(ctx context.Context) *effectiveConfig {
+	cfg, _ := ctx.Value(configContextKey{}).(*effectiveConfig)
+	return cfg
+}
+
+func rootPersistentPreRunE(cmd *cobra.Command, args []string) error {
+	memProfilePersistentPreRun(cmd, args)
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if err := applyConfig(cmd, cfg); err != nil {
+		return err
+	}
+	cmd.SetContext(context.WithValue(cmd.Context(), configContextKey{}, cfg))
+	return nil
+}
+
+func cmdConfigShow(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	cfg := redactedConfig(configFromContext(cmd.Context()))
+
+	switch format {
+	case "", "yaml":
+		enc := yaml.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent(2)
+		return enc.Encode(cfg)
+	case "json":
+		b, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\n", b)
+		return nil
+	default:
+		return fmt.Errorf("unsupported format %q, supported formats: yaml, json", format)
+	}
+}
+
+// isCredentialOption returns true if the value of the given option is a
+// credential (e.g. "azure-client-secret") that must not be shown
+func isCredentialOption(name string) bool {
+	name = strings.ReplaceAll(name, "-", "_")
+	return isSecretKey(name) || strings.Contains(name, "api_key") || strings.Contains(name, "access_key")
+}
+
+// redactedConfig returns a copy of the given configuration with the
+// values of all credential options replaced by a placeholder
+func redactedConfig(cfg *effectiveConfig) *effectiveConfig {
+	if cfg == nil {
+		return nil
+	}
+	redacted := *cfg
+	redacted.Options = make(configOptions, len(cfg.Options))
+	for name, value := range cfg.Options {
+		if isCredentialOption(name) && value != nil {
+			value = redactedValue
+		}
+		redacted.Options[name] = value
+	}
+	return &redacted
+}
+
+// configuredImageTypes returns the image types given on the command
+// line or, if there are none, the ones from the configuration
+func configuredImageTypes(cmd *cobra.Command, args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	imageTypes := configFromContext(cmd.Context()).ImageTypes()
+	if len(imageTypes) == 0 {
+		return nil, fmt.Errorf("no image types given, pass them as arguments or set %q in the config file", configImageTypesKey)
+	}
+	return slices.Clone(imageTypes), nil
+}
@@ -0,0 +1,280 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testrepos "github.com/osbuild/image-builder/test/data/repositories"
+
+	main "github.com/osbuild/image-builder/cmd/image-builder"
+	"github.com/osbuild/image-builder/internal/testutil"
+	"github.com/osbuild/image-builder/pkg/arch"
+)
+
+// mockConfigFiles writes the given system and user config files (if
+// not empty) and makes image-builder use them
+func mockConfigFiles(t *testing.T, systemConfig, userConfig string) (systemPath, userPath string) {
+	t.Helper()
+	tmpdir := t.TempDir()
+
+	systemPath = filepath.Join(tmpdir, "etc", "config.yaml")
+	if systemConfig != "" {
+		require.NoError(t, os.MkdirAll(filepath.Dir(systemPath), 0755))
+		require.NoError(t, os.WriteFile(systemPath, []byte(systemConfig), 0644))
+	}
+	restore := main.MockSystemConfigPath(systemPath)
+	t.Cleanup(restore)
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpdir, "config"))
+	t.Setenv("IMAGE_BUILDER_CONFIG", "")
+	userPath = filepath.Join(tmpdir, "config", "image-builder", "config.yaml")
+	if userConfig != "" {
+		require.NoError(t, os.MkdirAll(filepath.Dir(userPath), 0755))
+		require.NoError(t, os.WriteFile(userPath, []byte(userConfig), 0644))
+	}
+	return systemPath, userPath
+}
+
+func runConfigShow(t *testing.T, args ...string) (map[string]any, error) {
+	t.Helper()
+	restore := main.MockOsArgs(append([]string{"config", "show", "--format=json"}, args...))
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	if err := main.Run(); err != nil {
+		return nil, err
+	}
+	var cfg map[string]any
+	require.NoError(t, json.Unmarshal(fakeStdout.Bytes(), &cfg))
+	return cfg, nil
+}
+
+var testSystemConfig = `
+defaults:
+  distro: centos-9
+  output-dir: /var/lib/images
+profiles:
+  prod-aws:
+    distro: rhel-9.6
+    image-types: [ami]
+    to: aws
+`
+
+var testUserConfig = `
+defaults:
+  output-dir: /home/user/images
+  extra-repo:
+    - https://example.com/repo1
+    - https://example.com/repo2
+profiles:
+  dev:
+    blueprint: dev.toml
+    image-types: [qcow2, raw]
+    arch: aarch64
+`
+
+func TestConfigShowMerged(t *testing.T) {
+	systemPath, userPath := mockConfigFiles(t, testSystemConfig, testUserConfig)
+
+	cfg, err := runConfigShow(t)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"files":    []any{systemPath, userPath},
+		"profiles": []any{"dev", "prod-aws"},
+		"options": map[string]any{
+			"distro":     "centos-9",
+			"output-dir": "/home/user/images",
+			"extra-repo": []any{"https://example.com/repo1", "https://example.com/repo2"},
+		},
+	}, cfg)
+}
+
+func TestConfigShowProfile(t *testing.T) {
+	_, userPath := mockConfigFiles(t, testSystemConfig, testUserConfig)
+
+	cfg, err := runConfigShow(t, "--profile", "dev")
+	require.NoError(t, err)
+	assert.Equal(t, "dev", cfg["profile"])
+	assert.Equal(t, map[string]any{
+		"distro":      "centos-9",
+		"output-dir":  "/home/user/images",
+		"extra-repo":  []any{"https://example.com/repo1", "https://example.com/repo2"},
+		"blueprint":   filepath.Join(filepath.Dir(userPath), "dev.toml"),
+		"image-types": []any{"qcow2", "raw"},
+		"arch":        "aarch64",
+	}, cfg["options"])
+}
+
+func TestConfigShowNoConfig(t *testing.T) {
+	mockConfigFiles(t, "", "")
+
+	cfg, err := runConfigShow(t)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"files":    []any{},
+		"profiles": []any{},
+		"options":  map[string]any{},
+	}, cfg)
+}
+
+func TestConfigShowExplicitConfig(t *testing.T) {
+	mockConfigFiles(t, testSystemConfig, testUserConfig)
+	explicitPath := filepath.Join(t.TempDir(), "custom.yaml")
+	require.NoError(t, os.WriteFile(explicitPath, []byte("defaults:\n  distro: fedora-42\n"), 0644))
+
+	cfg, err := runConfigShow(t, "--config", explicitPath)
+	require.NoError(t, err)
+	assert.Equal(t, []any{explicitPath}, cfg["files"])
+	assert.Equal(t, map[string]any{"distro": "fedora-42"}, cfg["options"])
+
+	t.Setenv("IMAGE_BUILDER_CONFIG", explicitPath)
+	cfg, err = runConfigShow(t)
+	require.NoError(t, err)
+	assert.Equal(t, []any{explicitPath}, cfg["files"])
+
+	_, err = runConfigShow(t, "--config", filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "cannot load config file: open ")
+}
+
+func TestConfigShowRedactsCredentials(t *testing.T) {
+	mockConfigFiles(t, "defaults:\n  azure-client-id: my-client\n  azure-client-secret: s3cr3t\n", "")
+
+	cfg, err := runConfigShow(t)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"azure-client-id":     "my-client",
+		"azure-client-secret": "<redacted>",
+	}, cfg["options"])
+
+	restore := main.MockOsArgs([]string{"config", "show", "--format=yaml"})
+	defer restore()
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+	require.NoError(t, main.Run())
+	assert.Contains(t, fakeStdout.String(), "azure-client-secret: <redacted>")
+	assert.NotContains(t, fakeStdout.String(), "s3cr3t")
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, tc := range []struct {
+		config   string
+		args     []string
+		expected string
+	}{
+		{"defaults:\n  no-such-flag: 1\n", nil, `invalid configuration: unknown option "no-such-flag"`},
+		{"defaults:\n  distro: [a, b]\n", nil, `invalid configuration: option "distro" takes a single value`},
+		{"defaults:\n  profile: dev\n", nil, `invalid configuration: option "profile" cannot be set in the config file`},
+		{"defaults:\n  distro: {a: b}\n", nil, `invalid configuration: option "distro": unsupported value map[a:b]`},
+		{"default:\n  distro: centos-9\n", nil, "field default not found in type main.configFile"},
+		{testSystemConfig, []string{"--profile", "nope"}, `unknown profile "nope", available profiles: prod-aws`},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			mockConfigFiles(t, tc.config, "")
+			_, err := runConfigShow(t, tc.args...)
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+func TestManifestIntegrationConfigDefaults(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	mockConfigFiles(t, "", fmt.Sprintf(`
+defaults:
+  distro: centos-9
+  arch: x86_64
+  blueprint: %s
+`, makeTestBlueprint(t, testBlueprint)))
+
+	for _, tc := range []struct {
+		args     []string
+		expected string
+	}{
+		{nil, `"runner":"org.osbuild.centos9"`},
+		// the command line takes precedence over the config file
+		{[]string{"--distro", "centos-10"}, `"runner":"org.osbuild.centos10"`},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			restore := main.MockOsArgs(append([]string{"manifest", "qcow2"}, tc.args...))
+			defer restore()
+
+			var fakeStdout bytes.Buffer
+			restore = main.MockOsStdout(&fakeStdout)
+			defer restore()
+
+			err := main.Run()
+			require.NoError(t, err)
+			assertJsonContains(t, fakeStdout.String(), `{"type":"org.osbuild.users","options":{"users":{"alice":{}}}}`)
+			assertJsonContains(t, fakeStdout.String(), tc.expected)
+		})
+	}
+}
+
+func TestBuildIntegrationProfileImageTypes(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	outputDir := t.TempDir()
+	mockConfigFiles(t, "", fmt.Sprintf(`
+profiles:
+  test:
+    distro: centos-9
+    image-types: [qcow2]
+    output-dir: %s
+    cache: %s
+`, outputDir, t.TempDir()))
+
+	restore = main.MockOsArgs([]string{"build", "--profile", "test"})
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	fakeOsbuildCmd := testutil.MockCommand(t, "osbuild", makeFakeOsbuildScript())
+
+	err := main.Run()
+	require.NoError(t, err)
+
+	require.Equal(t, 1, len(fakeOsbuildCmd.CallArgsList()))
+	osbuildCall := fakeOsbuildCmd.CallArgsList()[0]
+	outputDirPos := slices.Index(osbuildCall, "--output-directory")
+	require.True(t, outputDirPos > -1)
+	assert.Equal(t, outputDir, osbuildCall[outputDirPos+1])
+	assert.FileExists(t, filepath.Join(outputDir, fmt.Sprintf("centos-9-qcow2-%s.qcow2", arch.Current())))
+}
+
+func TestBuildNoImageTypes(t *testing.T) {
+	mockConfigFiles(t, "", "")
+
+	restore := main.MockOsArgs([]string{"build", "--distro", "centos-9"})
+	defer restore()
+
+	err := main.Run()
+	assert.EqualError(t, err, `no image types given, pass them as arguments or set "image-types" in the config file`)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+
+	"github.com/osbuild/image-builder/pkg/progress"
+)
+
+// redactedValue replaces secrets in the diagnostics bundle
+const redactedValue = "<redacted>"
+
+// isSecretKey returns true if the given (blueprint or manifest) key
+// contains a secret that must not end up in a diagnostics bundle
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range []string{"password", "passphrase", "secret", "token", "activation_key", "activationkey", "private_key"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecrets replaces all values of secret keys in the given
+// (decoded) JSON with a placeholder
+func redactSecrets(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, val := range v {
+			if isSecretKey(key) && val != nil {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactSecrets(val)
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = redactSecrets(val)
+		}
+	}
+	return v
+}
+
+// redactField replaces the given field of every object in the list
+// found at the given path (of object keys) in the decoded JSON
+func redactField(v any, path []string, field string) {
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return
+		}
+		v = m[key]
+	}
+	var objs []any
+	switch v := v.(type) {
+	case map[string]any:
+		for _, obj := range v {
+			objs = append(objs, obj)
+		}
+	case []any:
+		objs = v
+	}
+	for _, obj := range objs {
+		if m, ok := obj.(map[string]any); ok && m[field] != nil {
+			m[field] = redactedValue
+		}
+	}
+}
+
+// redactManifest redacts the data of all inline sources of the given
+// (decoded) manifest, these are the files that are created in the
+// image and may contain credentials under any key
+func redactManifest(v any) {
+	redactField(v, []string{"sources", "org.osbuild.inline", "items"}, "data")
+}
+
+// redactBlueprint redacts the data of all file customizations of the
+// given (decoded) blueprint
+func redactBlueprint(v any) {
+	redactField(v, []string{"customizations", "files"}, "data")
+}
+
+// redactJSON returns the given JSON with all secrets redacted, extra
+// redacts secrets that cannot be found by their key (if not nil)
+func redactJSON(data []byte, extra func(v any)) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if extra != nil {
+		extra(v)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(redactSecrets(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// diagnosticsFailure describes what failed in the diagnostics bundle
+type diagnosticsFailure struct {
+	Error    string `json:"error"`
+	Pipeline string `json:"pipeline,omitempty"`
+	Stage    string `json:"stage,omitempty"`
+	// Stages contains all stages of the failed pipeline with the
+	// type of the failed stage (usually just one)
+	Stages []json.RawMessage `json:"stages,omitempty"`
+}
+
+// failedStages returns all stages of the given type in the given
+// pipeline of the manifest
+func failedStages(manifest []byte, pipeline, stageType string) []json.RawMessage {
+	var mf struct {
+		Pipelines []struct {
+			Name   string            `json:"name"`
+			Stages []json.RawMessage `json:"stages"`
+		} `json:"pipelines"`
+	}
+	if err := json.Unmarshal(manifest, &mf); err != nil {
+		return nil
+	}
+	var stages []json.RawMessage
+	for _, p := range mf.Pipelines {
+		if p.Name != pipeline {
+			continue
+		}
+		for _, stage := range p.Stages {
+			var st struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(stage, &st); err == nil && st.Type == stageType {
+				stages = append(stages, stage)
+			}
+		}
+	}
+	return stages
+}
+
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+type diagnosticsOptions struct {
+	Manifest   []byte
+	MonitorLog string
+	// Blueprint is the blueprint the manifest was generated from
+	Blueprint *blueprint.Blueprint
+}
+
+// writeDiagnosticsBundle writes a tarball with everything needed to
+// debug the failed build to the given path: the manifest, the osbuild
+// monitor log, the failed pipeline and stage, the osbuild version and
+// host information and the blueprint. Secrets are redacted.
+func writeDiagnosticsBundle(path string, buildErr error, opts *diagnosticsOptions) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create diagnostics bundle: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("cannot write diagnostics bundle: %w", cerr)
+		}
+	}()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	prefix := strings.TrimSuffix(filepath.Base(path), ".tar.gz")
+	now := time.Now()
+	addFile := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:    filepath.Join(prefix, name),
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	failure := diagnosticsFailure{
+		Error: buildErr.Error(),
+	}
+	var osbuildErr *progress.OSBuildError
+	if errors.As(buildErr, &osbuildErr) {
+		failure.Error = osbuildErr.Err.Error()
+		failure.Pipeline = osbuildErr.Pipeline
+		failure.Stage = osbuildErr.Stage
+		failure.Stages = failedStages(opts.Manifest, osbuildErr.Pipeline, osbuildErr.Stage)
+	}
+	failureJSON, err := json.MarshalIndent(failure, "", "  ")
+	if err != nil {
+		return err
+	}
+	if failureJSON, err = redactJSON(failureJSON, nil); err != nil {
+		return err
+	}
+	files := []bundleFile{
+		{"failure.json", failureJSON},
+		{"error.txt", []byte(buildErr.Error() + "\n")},
+		{"version.yaml", []byte(prettyVersion())},
+		{"system.yaml", []byte(prettySystemStatus())},
+	}
+	if len(opts.Manifest) > 0 {
+		manifest, err := redactJSON(opts.Manifest, redactManifest)
+		if err != nil {
+			return fmt.Errorf("cannot redact manifest: %w", err)
+		}
+		files = append(files, bundleFile{"manifest.json", manifest})
+	}
+	if opts.MonitorLog != "" {
+		data, err := os.ReadFile(opts.MonitorLog)
+		if err != nil {
+			return fmt.Errorf("cannot read osbuild monitor log: %w", err)
+		}
+		files = append(files, bundleFile{"osbuild-monitor.jsonseq", data})
+	}
+	if opts.Blueprint != nil {
+		data, err := json.Marshal(opts.Blueprint)
+		if err != nil {
+			return err
+		}
+		if data, err = redactJSON(data, redactBlueprint); err != nil {
+			return err
+		}
+		files = append(files, bundleFile{"blueprint.json", data})
+	}
+
+	for _, file := range files {
+		if err := addFile(file.name, file.data); err != nil {
+			return fmt.Errorf("cannot write diagnostics bundle: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("cannot write diagnostics bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("cannot write diagnostics bundle: %w", err)
+	}
+	return nil
+}
+
+// withDiagnosticsBundle writes a diagnostics bundle for the failed
+// build and adds its path to the error
+func withDiagnosticsBundle(path string, buildErr error, opts *diagnosticsOptions) error {
+	if err := writeDiagnosticsBundle(path, buildErr, opts); err != nil {
+		return fmt.Errorf("%w\n(%v)", buildErr, err)
+	}
+	return fmt.Errorf("%w\nDiagnostics for this failure were written to %s, please attach it when reporting a bug", buildErr, path)
+}
@@ -0,0 +1,230 @@
+package main_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testrepos "github.com/osbuild/image-builder/test/data/repositories"
+
+	main "github.com/osbuild/image-builder/cmd/image-builder"
+	"github.com/osbuild/image-builder/internal/testutil"
+	"github.com/osbuild/image-builder/pkg/arch"
+	"github.com/osbuild/image-builder/pkg/osbuild"
+	"github.com/osbuild/image-builder/pkg/progress"
+)
+
+var failingOsbuildInStage = `
+if [ "$1" = "--version" ]; then
+    echo "osbuild 999"
+    exit 0
+fi
+cat - > "$0".stdin
+>&3 echo '{"message": "Starting pipeline os", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "os"}}}'
+>&3 echo '{"message": "Starting module org.osbuild.users", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "os", "stage": {"name": "org.osbuild.users"}}}}'
+>&3 echo '{"message": "useradd: failure", "context": {"origin": "org.osbuild", "pipeline": {"name": "os", "stage": {"name": "org.osbuild.users"}}}}'
+exit 1
+`
+
+func readTarGz(t *testing.T, path string) map[string]string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	content := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		content[hdr.Name] = string(data)
+	}
+	return content
+}
+
+func TestBuildIntegrationErrorsDiagnosticsBundle(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	outputDir := t.TempDir()
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2",
+		"--distro", "centos-9",
+		"--progress=debug",
+		"--blueprint", makeTestBlueprint(t, `
+[[customizations.user]]
+name = "alice"
+password = "$6$secret-hash"
+`),
+		"--cache", t.TempDir(),
+		"--output-dir", outputDir,
+	})
+	defer restore()
+
+	testutil.MockCommand(t, "osbuild", failingOsbuildInStage)
+
+	var err error
+	testutil.CaptureStdio(t, func() {
+		err = main.Run()
+	})
+	bundleName := fmt.Sprintf("centos-9-qcow2-%s.diagnostics", arch.Current())
+	bundlePath := filepath.Join(outputDir, bundleName+".tar.gz")
+	assert.ErrorContains(t, err, "error running osbuild: exit status 1\n")
+	assert.ErrorContains(t, err, fmt.Sprintf("\nDiagnostics for this failure were written to %s, please attach it when reporting a bug", bundlePath))
+
+	content := readTarGz(t, bundlePath)
+	for _, name := range []string{"failure.json", "error.txt", "version.yaml", "system.yaml", "manifest.json", "osbuild-monitor.jsonseq", "blueprint.json"} {
+		assert.Contains(t, content, filepath.Join(bundleName, name))
+	}
+	for name, data := range content {
+		assert.NotContains(t, data, "secret-hash", name)
+	}
+
+	var failure struct {
+		Error    string `json:"error"`
+		Pipeline string `json:"pipeline"`
+		Stage    string `json:"stage"`
+		Stages   []struct {
+			Type    string         `json:"type"`
+			Options map[string]any `json:"options"`
+		} `json:"stages"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(content[filepath.Join(bundleName, "failure.json")]), &failure))
+	assert.Equal(t, "exit status 1", failure.Error)
+	assert.Equal(t, "os", failure.Pipeline)
+	assert.Equal(t, "org.osbuild.users", failure.Stage)
+	require.Len(t, failure.Stages, 1)
+	assert.Equal(t, "org.osbuild.users", failure.Stages[0].Type)
+	assert.Equal(t, map[string]any{
+		"users": map[string]any{
+			"alice": map[string]any{"password": "<redacted>"},
+		},
+	}, failure.Stages[0].Options)
+
+	assert.Contains(t, content[filepath.Join(bundleName, "version.yaml")], "osbuild: \"999\"")
+	assert.Contains(t, content[filepath.Join(bundleName, "blueprint.json")], `"password": "<redacted>"`)
+	assert.True(t, strings.HasSuffix(content[filepath.Join(bundleName, "osbuild-monitor.jsonseq")], `"useradd: failure", "context": {"origin": "org.osbuild", "pipeline": {"name": "os", "stage": {"name": "org.osbuild.users"}}}}`+"\n"))
+}
+
+func TestBuildIntegrationVerboseDiagnosticsBundleSnapshot(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	outputDir := t.TempDir()
+	bpPath := makeTestBlueprint(t, `
+[[customizations.files]]
+path = "/etc/myapp/credentials"
+data = "token-in-file-secret"
+`)
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2",
+		"--distro", "centos-9",
+		"--progress=verbose",
+		"--blueprint", bpPath,
+		"--cache", t.TempDir(),
+		"--output-dir", outputDir,
+	})
+	defer restore()
+
+	// the blueprint changes while osbuild runs
+	testutil.MockCommand(t, "osbuild", fmt.Sprintf("echo 'name = \"changed\"' > %q\n", bpPath)+failingOsbuildInStage)
+
+	var err error
+	testutil.CaptureStdio(t, func() {
+		err = main.Run()
+	})
+	bundleName := fmt.Sprintf("centos-9-qcow2-%s.diagnostics", arch.Current())
+	assert.ErrorContains(t, err, "error running osbuild: exit status 1")
+
+	content := readTarGz(t, filepath.Join(outputDir, bundleName+".tar.gz"))
+	for name, data := range content {
+		assert.NotContains(t, data, "token-in-file-secret", name)
+		assert.NotContains(t, data, base64.StdEncoding.EncodeToString([]byte("token-in-file-secret")), name)
+	}
+	bp := content[filepath.Join(bundleName, "blueprint.json")]
+	assert.Contains(t, bp, `"path": "/etc/myapp/credentials"`)
+	assert.Contains(t, bp, `"data": "<redacted>"`)
+	assert.NotContains(t, bp, "changed")
+	assert.Contains(t, content[filepath.Join(bundleName, "osbuild-monitor.jsonseq")], `"useradd: failure"`)
+}
+
+func TestDiagnosticsBundleRedactsEncryptedPartition(t *testing.T) {
+	inline := osbuild.NewInlineSource()
+	inline.AddItem("user=admin\nsecret=inline-file-secret\n")
+	mf := osbuild.Manifest{
+		Version: "2",
+		Pipelines: []osbuild.Pipeline{
+			{
+				Name: "image",
+				Stages: []*osbuild.Stage{
+					osbuild.NewLUKS2CreateStage(&osbuild.LUKS2CreateStageOptions{
+						Passphrase: "luks-secret-passphrase",
+						UUID:       "fb180daf-48a7-4ee0-b10d-394651850fd4",
+						PBKDF: osbuild.Argon2id{
+							Method:      "argon2id",
+							Iterations:  4,
+							Memory:      32,
+							Parallelism: 1,
+						},
+					}, nil),
+				},
+			},
+		},
+		Sources: osbuild.Sources{
+			osbuild.SourceNameInline: inline,
+		},
+	}
+	manifest, err := json.Marshal(mf)
+	require.NoError(t, err)
+
+	bundlePath := filepath.Join(t.TempDir(), "test.diagnostics.tar.gz")
+	buildErr := &progress.OSBuildError{
+		Err:      errors.New("exit status 1"),
+		Pipeline: "image",
+		Stage:    "org.osbuild.luks2.format",
+	}
+	err = main.WriteDiagnosticsBundle(bundlePath, buildErr, &main.DiagnosticsOptions{
+		Manifest: manifest,
+	})
+	require.NoError(t, err)
+
+	content := readTarGz(t, bundlePath)
+	require.Contains(t, content, "test.diagnostics/manifest.json")
+	assert.Contains(t, content["test.diagnostics/failure.json"], "org.osbuild.luks2.format")
+	for name, data := range content {
+		for _, secret := range []string{"luks-secret-passphrase", "inline-file-secret", base64.StdEncoding.EncodeToString([]byte("user=admin\nsecret=inline-file-secret\n"))} {
+			assert.NotContains(t, data, secret, name)
+		}
+	}
+}
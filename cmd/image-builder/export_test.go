@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/osbuild/image-builder/pkg/bootc"
 	"github.com/osbuild/image-builder/pkg/cloud"
@@ -15,19 +16,29 @@ import (
 )
 
 var (
-	GetOneImage           = getOneImage
-	GetAllImages          = getAllImages
-	Run                   = run
-	FindDistro            = findDistro
-	DescribeImage         = describeImage
-	ProgressFromCmd       = progressFromCmd
-	BasenameFor           = basenameFor
-	CacheDirForUid        = cacheDirForUid
-	NewPkgSearchFormatter = newPkgSearchFormatter
+	GetOneImage            = getOneImage
+	GetAllImages           = getAllImages
+	Run                    = run
+	FindDistro             = findDistro
+	DescribeImage          = describeImage
+	ProgressFromCmd        = progressFromCmd
+	MetricsFromCmd         = metricsFromCmd
+	WriteDiagnosticsBundle = writeDiagnosticsBundle
+	BasenameFor            = basenameFor
+	LocalUploadResult      = localUploadResult
+	CacheDirForUid         = cacheDirForUid
+	NewPkgSearchFormatter  = newPkgSearchFormatter
+	QemuCmdline            = qemuCmdline
+	CloudInitUserData      = cloudInitUserData
+	ServeNoCloudSeed       = serveNoCloudSeed
 )
 
 type DescribeImgYAML describeImgYAML
 
+type DiagnosticsOptions = diagnosticsOptions
+
+type VMConfig = vmConfig
+
 func MockOsArgs(args []string) (restore func()) {
 	saved := os.Args
 	os.Args = append([]string{"argv0"}, args...)
@@ -105,6 +116,38 @@ func MockDirSize(f func(string) (int64, error)) (restore func()) {
 	}
 }
 
+func MockSystemConfigPath(path string) (restore func()) {
+	saved := systemConfigPath
+	systemConfigPath = path
+	return func() {
+		systemConfigPath = saved
+	}
+}
+
+func MockWatchPollInterval(d time.Duration) (restore func()) {
+	saved := watchPollInterval
+	watchPollInterval = d
+	return func() {
+		watchPollInterval = saved
+	}
+}
+
+func MockWatchWaiting(f func()) (restore func()) {
+	saved := watchWaiting
+	watchWaiting = f
+	return func() {
+		watchWaiting = saved
+	}
+}
+
+func MockKvmAvailable(f func(string) bool) (restore func()) {
+	saved := kvmAvailable
+	kvmAvailable = f
+	return func() {
+		kvmAvailable = saved
+	}
+}
+
 func MockManifestgenDepsolver(fn manifestgen.DepsolveFunc) (restore func()) {
 	saved := manifestgenDepsolver
 	manifestgenDepsolver = fn
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,25 +11,33 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
 
 	"github.com/spf13/cobra"
 
+	"github.com/osbuild/blueprint/pkg/blueprint"
+
 	"go.yaml.in/yaml/v3"
 
 	"github.com/osbuild/image-builder/pkg/arch"
 	"github.com/osbuild/image-builder/pkg/bootc"
 	"github.com/osbuild/image-builder/pkg/cloud"
+	"github.com/osbuild/image-builder/pkg/container"
 	"github.com/osbuild/image-builder/pkg/customizations/subscription"
+	"github.com/osbuild/image-builder/pkg/depsolvednf"
+	"github.com/osbuild/image-builder/pkg/distro"
 	"github.com/osbuild/image-builder/pkg/distro/generic"
 	"github.com/osbuild/image-builder/pkg/imagefilter"
 	"github.com/osbuild/image-builder/pkg/manifestgen"
 	"github.com/osbuild/image-builder/pkg/osbuild"
 	"github.com/osbuild/image-builder/pkg/ostree"
 	"github.com/osbuild/image-builder/pkg/progress"
+	"github.com/osbuild/image-builder/pkg/rpmmd"
 
 	"github.com/osbuild/image-builder/internal/blueprintload"
+	"github.com/osbuild/image-builder/internal/cmdutil"
 	"github.com/osbuild/image-builder/pkg/setup"
 )
 
@@ -237,6 +246,13 @@ func subscriptionImageOptions(cmd *cobra.Command) (*subscription.ImageOptions, e
 
 type cmdManifestWrapperOptions struct {
 	useBootstrapIfNeeded bool
+	// depsolveCache is shared between the image types of a build so
+	// that common package sets are only depsolved once
+	depsolveCache *manifestgen.DepsolveCache
+	// blueprint is the blueprint loaded at the start of a build, it
+	// is used for all image types of the build instead of loading
+	// the blueprint again
+	blueprint *blueprint.Blueprint
 }
 
 // used in tests
@@ -490,9 +506,12 @@ func cmdManifestWrapper(pbar progress.ProgressBar, cmd *cobra.Command, args []st
 	// manifests we would change this
 	outputFilename, _ := cmd.Flags().GetString("output-name")
 
-	bp, err := blueprintload.Load(blueprintPath)
-	if err != nil {
-		return err
+	bp := wrapperOpts.blueprint
+	if bp == nil {
+		bp, err = blueprintload.Load(blueprintPath)
+		if err != nil {
+			return err
+		}
 	}
 	if bootcRef == "" {
 		distroStr, err = findDistro(distroStr, bp.Distro)
@@ -508,21 +527,47 @@ func cmdManifestWrapper(pbar progress.ProgressBar, cmd *cobra.Command, args []st
 	}
 
 	imgTypeStr := args[0]
+	progress.SetPhase(pbar, progress.PhaseManifest)
 	pbar.SetPulseMsgf("Manifest generation step")
 	pbar.SetMessagef("Building manifest for %s-%s", distroStr, imgTypeStr)
 
+	depsolver := manifestgenDepsolver
+	if depsolver == nil {
+		depsolver = manifestgen.DepsolveWithContext(cmd.Context())
+	}
+	if wrapperOpts.depsolveCache != nil {
+		depsolver = wrapperOpts.depsolveCache.Wrap(depsolver)
+	}
+	containerResolver := manifestgenContainerResolver
+	if containerResolver == nil {
+		containerResolver = manifestgen.ContainerResolverWithContext(cmd.Context())
+	}
+
 	opts := &manifestOptions{
 		ManifestgenOptions: manifestgen.Options{
 			Cachedir:               rpmmdCacheDir,
 			CustomSeed:             customSeed,
 			RpmDownloader:          rpmDownloader,
 			DepsolveWarningsOutput: wd,
-			Depsolve:               manifestgenDepsolver,
-			ContainerResolver:      manifestgenContainerResolver,
+			Depsolve: func(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error) {
+				if err := cmd.Context().Err(); err != nil {
+					return nil, fmt.Errorf("depsolving was cancelled: %w", err)
+				}
+				progress.SetPhase(pbar, progress.PhaseDepsolve)
+				return depsolver(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch)
+			},
+			ContainerResolver: func(containerSources map[string][]container.SourceSpec, archName string) (map[string][]container.Spec, error) {
+				if err := cmd.Context().Err(); err != nil {
+					return nil, fmt.Errorf("resolving containers was cancelled: %w", err)
+				}
+				progress.SetPhase(pbar, progress.PhaseResolve)
+				return containerResolver(containerSources, archName)
+			},
 		},
 		OutputDir:                  outputDir,
 		OutputFilename:             outputFilename,
 		BlueprintPath:              blueprintPath,
+		Blueprint:                  bp,
 		Ostree:                     ostreeImgOpts,
 		BootcRef:                   bootcRef,
 		BootcInstallerPayloadRef:   bootcInstallerPayloadRef,
@@ -556,129 +601,271 @@ func cmdManifest(cmd *cobra.Command, args []string) error {
 	return cmdManifestWrapper(pbar, cmd, args, img, osStdout, io.Discard, nil)
 }
 
-func progressFromCmd(cmd *cobra.Command, conf progress.ProgressConfig) (progress.ProgressBar, error) {
+// signalContext returns a context for the command that is cancelled on
+// SIGINT/SIGTERM so that osbuild and uploads can be stopped gracefully
+// and their leftovers removed. Once cancelled the default signal
+// handling is restored, i.e. a second interrupt terminates right away.
+func signalContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	cmd.SetContext(ctx)
+	return ctx, stop
+}
+
+// progressFromCmd returns the progress bar selected on the commandline
+// and a function that closes the --progress-fd (if any) once the progress
+// bar is stopped
+func progressFromCmd(cmd *cobra.Command, conf progress.ProgressConfig) (progress.ProgressBar, func() error, error) {
+	noClose := func() error { return nil }
+
 	progressType, err := cmd.Flags().GetString("progress")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	verbose, err := cmd.Flags().GetBool("verbose")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if progressType == "auto" && verbose {
 		progressType = "verbose"
 	}
+	// only available for "build"
+	if fl := cmd.Flags().Lookup("progress-fd"); fl != nil && fl.Changed {
+		fd, err := cmd.Flags().GetInt("progress-fd")
+		if err != nil {
+			return nil, nil, err
+		}
+		if progressType != "jsonl" {
+			return nil, nil, fmt.Errorf("--progress-fd can only be used with --progress=jsonl")
+		}
+		if fd < 0 {
+			return nil, nil, fmt.Errorf("invalid --progress-fd %d", fd)
+		}
+		// #nosec: G115
+		f := os.NewFile(uintptr(fd), "progress-fd")
+		if f == nil {
+			return nil, nil, fmt.Errorf("invalid --progress-fd %d", fd)
+		}
+		if _, err := f.Stat(); err != nil {
+			return nil, nil, fmt.Errorf("invalid --progress-fd %d: %w", fd, err)
+		}
+		conf.Output = f
+		pbar, err := progress.New(progressType, conf)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return pbar, f.Close, nil
+	}
 
-	return progress.New(progressType, conf)
+	if progressType == "jsonl" && conf.Output == nil {
+		conf.Output = osStdout
+	}
+	pbar, err := progress.New(progressType, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pbar, noClose, nil
+}
+
+// metricsFromCmd returns if metrics should be printed (--with-metrics)
+// and the path of the metrics report (--metrics-report)
+func metricsFromCmd(cmd *cobra.Command) (bool, string, error) {
+	withMetrics, err := cmd.Flags().GetBool("with-metrics")
+	if err != nil {
+		return false, "", err
+	}
+	metricsReport, err := cmd.Flags().GetString("metrics-report")
+	if err != nil {
+		return false, "", err
+	}
+	return withMetrics, metricsReport, nil
+}
+
+// humanOutputFor returns where messages for humans (e.g. the location of
+// the built images) go, this is stderr when the jsonl progress is written
+// to stdout so that stdout stays machine readable
+func humanOutputFor(cmd *cobra.Command) io.Writer {
+	progressType, _ := cmd.Flags().GetString("progress")
+	if progressType != "jsonl" {
+		return osStdout
+	}
+	if fl := cmd.Flags().Lookup("progress-fd"); fl != nil && fl.Changed {
+		return osStdout
+	}
+	return osStderr
 }
 
 func cmdBuild(cmd *cobra.Command, args []string) error {
-	cacheDir, err := cmd.Flags().GetString("cache")
+	ctx, stop := signalContext(cmd)
+	defer stop()
+
+	imageTypes, err := configuredImageTypes(cmd, args)
 	if err != nil {
 		return err
 	}
-	outputDir, err := cmd.Flags().GetString("output-dir")
+	watch, err := cmd.Flags().GetBool("watch")
 	if err != nil {
 		return err
 	}
+	if watch {
+		return watchBuild(ctx, cmd, imageTypes)
+	}
+	_, err = buildFromCmd(ctx, cmd, imageTypes, nil)
+	return err
+}
+
+// buildFromCmd builds the given image types with the options of the
+// command and returns the checksums of the generated manifests. The
+// build is skipped if the checksums match the given skipChecksums.
+func buildFromCmd(ctx context.Context, cmd *cobra.Command, imageTypes []string, skipChecksums []string) ([]string, error) {
+	cacheDir, err := cmd.Flags().GetString("cache")
+	if err != nil {
+		return nil, err
+	}
+	outputDir, err := cmd.Flags().GetString("output-dir")
+	if err != nil {
+		return nil, err
+	}
 	outputBasename, err := cmd.Flags().GetString("output-name")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	withManifest, err := cmd.Flags().GetBool("with-manifest")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	withBuildlog, err := cmd.Flags().GetBool("with-buildlog")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	withUploadResult, err := cmd.Flags().GetBool("with-upload-result")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	withMetrics, err := cmd.Flags().GetBool("with-metrics")
+	withMetrics, metricsReport, err := metricsFromCmd(cmd)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	runInVm, err := cmd.Flags().GetBool("in-vm")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	format, err := cmd.Flags().GetString("format")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	blueprintPath, err := cmd.Flags().GetString("blueprint")
+	if err != nil {
+		return nil, err
+	}
+	// the manifests and the diagnostics of a failed build use the
+	// same snapshot of the blueprint
+	bp, err := blueprintload.Load(blueprintPath)
+	if err != nil {
+		return nil, err
 	}
 	// Fail early if the cache directory is not writable, instead of
 	// waiting for osbuild to fail after slow manifest generation.
 	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return fmt.Errorf("cannot create cache directory %q: %w\nHint: use --cache to specify a writable path", cacheDir, err)
+		return nil, fmt.Errorf("cannot create cache directory %q: %w\nHint: use --cache to specify a writable path", cacheDir, err)
 	}
 
 	// Setup osbuild environment if running in a container
 	if setup.IsContainer() {
 		if err := setup.EnsureEnvironment(cacheDir, runInVm); err != nil {
-			return fmt.Errorf("entrypoint setup failed: %w", err)
+			return nil, fmt.Errorf("entrypoint setup failed: %w", err)
 		}
 	}
 
 	if runInVm && !setup.IsContainer() {
-		return fmt.Errorf("running in VM outside container is not supported yet")
+		return nil, fmt.Errorf("running in VM outside container is not supported yet")
 	}
 
-	img, err := getImage(cmd, args)
-	if err != nil {
-		return err
+	var builds []*imageBuild
+	for _, imgTypeStr := range imageTypes {
+		img, err := getImage(cmd, []string{imgTypeStr})
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, &imageBuild{img: img})
 	}
 	// Ensure the output directory exists before (file) progress starts.
-	outputDir = basenameFor(img, outputDir)
+	outputDir = buildBasenameFor(builds, outputDir)
+	_, err = os.Stat(outputDir)
+	createdOutputDir := os.IsNotExist(err)
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		return fmt.Errorf("cannot create output base directory %s: %w", outputDir, err)
-	}
+		return nil, fmt.Errorf("cannot create output base directory %s: %w", outputDir, err)
+	}
+	buildFinished := false
+	defer func() {
+		// do not leave the partial outputs of an interrupted
+		// build behind
+		if ctx.Err() != nil && createdOutputDir && !buildFinished {
+			_ = os.RemoveAll(outputDir)
+		}
+	}()
 
-	pbar, err := progressFromCmd(cmd, progress.ProgressConfig{
-		FilePath: filepath.Join(outputDir, fmt.Sprintf("%s.progress", basenameFor(img, outputBasename))),
+	pbar, closeProgress, err := progressFromCmd(cmd, progress.ProgressConfig{
+		FilePath: filepath.Join(outputDir, fmt.Sprintf("%s.progress", buildBasenameFor(builds, outputBasename))),
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	// registered before pbar.Stop() so that it runs after it
+	defer func() {
+		_ = closeProgress()
+	}()
+	humanOut := humanOutputFor(cmd)
 
 	pbar.Start()
 	defer pbar.Stop()
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-		<-c
-		pbar.Stop()
-	}()
+	// restore the terminal right away when interrupted, cleaning
+	// up may take a bit
+	defer context.AfterFunc(ctx, pbar.Stop)()
 
-	var mf bytes.Buffer
 	opts := &cmdManifestWrapperOptions{
 		useBootstrapIfNeeded: true,
-	}
-
-	// We discard any warnings from the depsolver until we figure out a better
-	// idea (likely in manifestgen)
-	err = cmdManifestWrapper(pbar, cmd, args, img, &mf, io.Discard, opts)
-	if err != nil {
-		return err
-	}
+		depsolveCache:        manifestgen.NewDepsolveCache(),
+		blueprint:            bp,
+	}
+	uploaders := make([]cloud.Uploader, len(builds))
+	for i, ib := range builds {
+		var mf bytes.Buffer
+		// We discard any warnings from the depsolver until we figure out a better
+		// idea (likely in manifestgen), only progress bars with structured
+		// events report them
+		err = cmdManifestWrapper(pbar, cmd, []string{ib.img.ImgType.Name()}, ib.img, &mf, progress.NewWarningsWriter(pbar), opts)
+		if err != nil {
+			return nil, err
+		}
+		ib.manifest = mf.Bytes()
 
-	bootMode := img.ImgType.BootMode()
-	uploader, err := uploaderFor(cmd, img.ImgType.Name(), img.ImgType.Arch().Name(), &bootMode, "")
-	if errors.Is(err, ErrUploadTypeUnsupported) || errors.Is(err, ErrUploadConfigNotProvided) {
-		err = nil
+		bootMode := ib.img.ImgType.BootMode()
+		uploader, err := uploaderFor(cmd, ib.img.ImgType.Name(), ib.img.ImgType.Arch().Name(), &bootMode, "")
+		if errors.Is(err, ErrUploadTypeUnsupported) || errors.Is(err, ErrUploadConfigNotProvided) {
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if uploader != nil {
+			pbar.SetPulseMsgf("Checking cloud access")
+			if err := uploaderCheckWithProgress(pbar, uploader); err != nil {
+				return nil, err
+			}
+		}
+		uploaders[i] = uploader
 	}
-
-	if err != nil {
-		return err
+	checksums := make([]string, len(builds))
+	for i, ib := range builds {
+		checksums[i] = cmdutil.ManifestChecksum(ib.manifest)
 	}
-
-	if uploader != nil {
-		pbar.SetPulseMsgf("Checking cloud access")
-		if err := uploaderCheckWithProgress(pbar, uploader); err != nil {
-			return err
-		}
+	if skipChecksums != nil && slices.Equal(checksums, skipChecksums) {
+		buildFinished = true
+		pbar.Stop()
+		fmt.Fprintf(humanOut, "Manifest unchanged, skipping build\n")
+		return checksums, nil
 	}
 
 	buildOpts := &buildOptions{
@@ -688,45 +875,67 @@ func cmdBuild(cmd *cobra.Command, args []string) error {
 		WriteManifest:  withManifest,
 		WriteBuildlog:  withBuildlog,
 		Metrics:        withMetrics,
+		MetricsReport:  metricsReport,
 		JSONOutput:     format == "json",
+		Blueprint:      bp,
 	}
 	if runInVm {
 		buildOpts.InVm = []string{"image"}
 	}
+	progress.SetPhase(pbar, progress.PhaseBuild)
 	pbar.SetPulseMsgf("Image building step")
-	imagePath, err := buildImage(pbar, img, mf.Bytes(), buildOpts)
+	imagePaths, err := buildImages(ctx, pbar, builds, buildOpts)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	buildFinished = true
+	for _, imagePath := range imagePaths {
+		progress.ReportArtifact(pbar, imagePath)
 	}
 	pbar.Stop()
 
-	fmt.Fprintf(osStdout, "Image build successful: %s\n", imagePath)
-
-	// Default upload result to write out in case no uploader was specified
-	uploadResult := &cloud.UploadResult{
-		Provider: "LocalPath",
-		ImageID:  imagePath,
+	for _, imagePath := range imagePaths {
+		fmt.Fprintf(humanOut, "Image build successful: %s\n", imagePath)
 	}
-	if uploader != nil {
-		// XXX: integrate better into the progress, see bib
-		uploadResult, err = uploadImageWithProgress(uploader, imagePath)
-		if err != nil {
-			return err
+
+	for i, ib := range builds {
+		imagePath := imagePaths[i]
+		uploader := uploaders[i]
+		bootMode := ib.img.ImgType.BootMode()
+
+		// Default upload result to write out in case no uploader was specified
+		uploadResult := &cloud.UploadResult{
+			Provider: "LocalPath",
+			ImageID:  imagePath,
 		}
-	}
-	if withUploadResult {
-		p := filepath.Join(outputDir, fmt.Sprintf("%s.upload-result", basenameFor(img, outputBasename)))
-		data, err := json.Marshal(uploadResult)
-		if err != nil {
-			return err
+		if uploader == nil && withUploadResult {
+			uploadResult, err = localUploadResult(imagePath, ib.img.ImgType.Filename(), bootMode)
+			if err != nil {
+				return nil, err
+			}
 		}
-		// #nosec: G306
-		if err := os.WriteFile(p, data, 0640); err != nil {
-			return err
+		if uploader != nil {
+			progress.SetPhase(pbar, progress.PhaseUpload)
+			// XXX: integrate better into the progress, see bib
+			uploadResult, err = uploadImageWithProgress(ctx, uploader, imagePath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if withUploadResult {
+			p := filepath.Join(outputDir, fmt.Sprintf("%s.upload-result", basenameFor(ib.img, outputBasename)))
+			data, err := json.Marshal(uploadResult)
+			if err != nil {
+				return nil, err
+			}
+			// #nosec: G306
+			if err := os.WriteFile(p, data, 0640); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	return nil
+	return checksums, nil
 }
 
 func cmdDescribeImg(cmd *cobra.Command, args []string) error {
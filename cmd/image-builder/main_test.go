@@ -2,6 +2,7 @@ package main_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -389,7 +390,7 @@ func makeFakeOsbuildScript() string {
 cat - > "$0".stdin
 
 output_dir=""
-export=""
+exports=()
 format=""
 while [[ $# -gt 0 ]]; do
   key="$1"
@@ -399,7 +400,7 @@ while [[ $# -gt 0 ]]; do
       shift 2
       ;;
     --export)
-      export="$2"
+      exports+=("$2")
       shift 2
       ;;
     --json)
@@ -410,19 +411,22 @@ while [[ $# -gt 0 ]]; do
       shift 1
   esac
 done
-mkdir -p "$output_dir/$export"
-case $export in
-  qcow2)
-    echo "fake-img-qcow2" > "$output_dir/$export/disk.qcow2"
-    ;;
-  image)
-    echo "fake-img-raw" > "$output_dir/$export/image.raw"
-    ;;
-  *)
-    echo "Unknown export: $1 - add to testscript"
-    exit 1
-    ;;
-esac
+for export in "${exports[@]}"; do
+  mkdir -p "$output_dir/$export"
+  # exports of combined manifests may be prefixed with the image type
+  case $export in
+    *qcow2)
+      echo "fake-img-qcow2" > "$output_dir/$export/disk.qcow2"
+      ;;
+    *image)
+      echo "fake-img-raw" > "$output_dir/$export/image.raw"
+      ;;
+    *)
+      echo "Unknown export: $export - add to testscript"
+      exit 1
+      ;;
+  esac
+done
 if [ "$format" = "json" ]; then
   echo '{"message": "hai"}' >&3
   echo '{"success": true}'
@@ -488,6 +492,275 @@ func TestBuildIntegrationHappy(t *testing.T) {
 	assertJsonContains(t, string(manifest), `"image":{"name":"resolved-cnt-registry.gitlab.com/redhat/services/products/image-builder/ci/osbuild-composer/fedora-minimal"`)
 }
 
+func TestBuildIntegrationMultipleImageTypes(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2", "ami",
+		"--distro", "centos-9",
+		"--cache", t.TempDir(),
+		"--output-dir", outputDir,
+		"--with-manifest",
+	})
+	defer restore()
+
+	script := makeFakeOsbuildScript()
+	fakeOsbuildCmd := testutil.MockCommand(t, "osbuild", script)
+
+	err := main.Run()
+	require.NoError(t, err)
+
+	currentArch := arch.Current().String()
+	qcow2Path := filepath.Join(outputDir, fmt.Sprintf("centos-9-qcow2-%s.qcow2", currentArch))
+	amiPath := filepath.Join(outputDir, fmt.Sprintf("centos-9-ami-%s.raw", currentArch))
+	assert.Contains(t, fakeStdout.String(), fmt.Sprintf("Image build successful: %s\n", qcow2Path))
+	assert.Contains(t, fakeStdout.String(), fmt.Sprintf("Image build successful: %s\n", amiPath))
+	assert.FileExists(t, qcow2Path)
+	assert.FileExists(t, amiPath)
+	assert.FileExists(t, filepath.Join(outputDir, fmt.Sprintf("centos-9-%s.osbuild-manifest.json", currentArch)))
+
+	// both images are exported from a single osbuild run
+	require.Equal(t, 1, len(fakeOsbuildCmd.CallArgsList()))
+	osbuildCall := fakeOsbuildCmd.CallArgsList()[0]
+	var exports []string
+	for i, arg := range osbuildCall {
+		if arg == "--export" {
+			exports = append(exports, osbuildCall[i+1])
+		}
+	}
+	assert.Equal(t, []string{"qcow2", "ami-image"}, exports)
+
+	// pipelines of the two images do not clash in the combined manifest
+	manifest, err := os.ReadFile(fakeOsbuildCmd.Path() + ".stdin")
+	require.NoError(t, err)
+	var mf struct {
+		Pipelines []struct {
+			Name string `json:"name"`
+		} `json:"pipelines"`
+	}
+	require.NoError(t, json.Unmarshal(manifest, &mf))
+	var names []string
+	for _, p := range mf.Pipelines {
+		assert.NotContains(t, names, p.Name)
+		names = append(names, p.Name)
+	}
+	assert.Contains(t, names, "qcow2")
+	assert.Contains(t, names, "ami-image")
+}
+
+func TestBuildIntegrationMultipleImageTypesDepsolveOnce(t *testing.T) {
+	var depsolved []string
+	restore := main.MockManifestgenDepsolver(func(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error) {
+		for plName := range packageSets {
+			depsolved = append(depsolved, plName)
+		}
+		return fakeDepsolve(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch)
+	})
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	restore = main.MockOsStdout(io.Discard)
+	defer restore()
+
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2", "openstack",
+		"--distro", "centos-9",
+		"--cache", t.TempDir(),
+		"--output-dir", filepath.Join(t.TempDir(), "output"),
+	})
+	defer restore()
+
+	testutil.MockCommand(t, "osbuild", makeFakeOsbuildScript())
+
+	err := main.Run()
+	require.NoError(t, err)
+
+	// the buildroot of the two image types is the same and only
+	// depsolved once
+	assert.ElementsMatch(t, []string{"build", "os", "os"}, depsolved)
+}
+
+func TestBuildIntegrationMultipleImageTypesSameOutputName(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2", "qcow2",
+		"--distro", "centos-9",
+		"--cache", t.TempDir(),
+		"--output-dir", t.TempDir(),
+		"--output-name", "disk",
+	})
+	defer restore()
+
+	fakeOsbuildCmd := testutil.MockCommand(t, "osbuild", makeFakeOsbuildScript())
+
+	err := main.Run()
+	assert.ErrorContains(t, err, `image types "qcow2" and "qcow2" would both be written to`)
+	assert.Equal(t, 0, len(fakeOsbuildCmd.CallArgsList()))
+}
+
+func TestBuildIntegrationJSONLProgress(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	eventsPath := filepath.Join(t.TempDir(), "events.jsonl")
+	f, err := os.Create(eventsPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2",
+		fmt.Sprintf("--blueprint=%s", makeTestBlueprint(t, testBlueprint)),
+		"--distro", "centos-9",
+		"--cache", t.TempDir(),
+		"--output-dir", outputDir,
+		"--progress", "jsonl",
+		"--progress-fd", fmt.Sprintf("%d", f.Fd()),
+	})
+	defer restore()
+
+	testutil.MockCommand(t, "osbuild", makeFakeOsbuildScript())
+
+	err = main.Run()
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(eventsPath)
+	require.NoError(t, err)
+	var phases []string
+	var artifacts []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var ev progress.JSONLEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &ev))
+		assert.Equal(t, progress.JSONLSchemaVersion, ev.Version)
+		switch ev.Type {
+		case "phase":
+			phases = append(phases, string(ev.Phase))
+		case "artifact":
+			artifacts = append(artifacts, ev.Path)
+		}
+	}
+	assert.Equal(t, []string{"manifest", "depsolve", "resolve", "build"}, phases)
+	currentArch := arch.Current().String()
+	assert.Equal(t, []string{filepath.Join(outputDir, fmt.Sprintf("centos-9-qcow2-%s.qcow2", currentArch))}, artifacts)
+}
+
+func TestBuildIntegrationJSONLProgressStdoutIsJSONL(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	var fakeStdout, fakeStderr bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+	restore = main.MockOsStderr(&fakeStderr)
+	defer restore()
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2",
+		fmt.Sprintf("--blueprint=%s", makeTestBlueprint(t, testBlueprint)),
+		"--distro", "centos-9",
+		"--cache", t.TempDir(),
+		"--output-dir", outputDir,
+		"--progress", "jsonl",
+	})
+	defer restore()
+
+	testutil.MockCommand(t, "osbuild", makeFakeOsbuildScript())
+
+	err := main.Run()
+	require.NoError(t, err)
+
+	for _, line := range strings.Split(strings.TrimSpace(fakeStdout.String()), "\n") {
+		var ev progress.JSONLEvent
+		assert.NoError(t, json.Unmarshal([]byte(line), &ev), "stdout line %q is not jsonl", line)
+	}
+	assert.Contains(t, fakeStderr.String(), "Image build successful: ")
+}
+
+func TestBuildIntegrationProgressFdInvalid(t *testing.T) {
+	restore := main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2",
+		"--distro", "centos-9",
+		"--cache", t.TempDir(),
+		"--output-dir", t.TempDir(),
+		"--progress", "jsonl",
+		"--progress-fd", "-1",
+	})
+	defer restore()
+
+	err := main.Run()
+	assert.EqualError(t, err, "invalid --progress-fd -1")
+}
+
+func TestBuildIntegrationProgressFdNeedsJSONL(t *testing.T) {
+	restore := main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2",
+		"--distro", "centos-9",
+		"--cache", t.TempDir(),
+		"--output-dir", t.TempDir(),
+		"--progress", "verbose",
+		"--progress-fd", "3",
+	})
+	defer restore()
+
+	err := main.Run()
+	assert.EqualError(t, err, "--progress-fd can only be used with --progress=jsonl")
+}
+
 func TestBuildIntegrationArgs(t *testing.T) {
 	restore := main.MockManifestgenDepsolver(fakeDepsolve)
 	defer restore()
@@ -604,6 +877,13 @@ sleep 0.1
 exit 1
 `
 
+// diagnosticsNote returns the note about the diagnostics bundle that
+// is added to the error of failed builds
+func diagnosticsNote(outputDir string) string {
+	p := filepath.Join(outputDir, fmt.Sprintf("centos-9-qcow2-%s.diagnostics.tar.gz", arch.Current()))
+	return fmt.Sprintf("\nDiagnostics for this failure were written to %s, please attach it when reporting a bug", p)
+}
+
 func TestBuildIntegrationErrorsProgressVerbose(t *testing.T) {
 	restore := main.MockManifestgenDepsolver(fakeDepsolve)
 	defer restore()
@@ -630,7 +910,7 @@ func TestBuildIntegrationErrorsProgressVerbose(t *testing.T) {
 	stdout, stderr := testutil.CaptureStdio(t, func() {
 		err = main.Run()
 	})
-	assert.EqualError(t, err, "error running osbuild: exit status 1")
+	assert.EqualError(t, err, "error running osbuild: exit status 1"+diagnosticsNote(outputDir))
 
 	assert.Contains(t, stdout, "error on stdout\n")
 	assert.Contains(t, stderr, "error on stderr\n")
@@ -669,7 +949,7 @@ exit 1
 	stdout, _ := testutil.CaptureStdio(t, func() {
 		err = main.Run()
 	})
-	assert.EqualError(t, err, "error running osbuild: exit status 1")
+	assert.EqualError(t, err, "error running osbuild: exit status 1"+diagnosticsNote(outputDir))
 
 	// when the buildlog is used we do not get the direct output of
 	// osbuild on stderr, to avoid races everything goes via stdout
@@ -721,7 +1001,7 @@ osbuild-stage-output
 Output:
 error on stdout
 error on stderr
-`)
+`+diagnosticsNote(outputDir))
 			assert.NotContains(t, stdout, "error on stdout")
 			assert.NotContains(t, stderr, "error on stderr")
 
@@ -741,6 +1021,56 @@ osbuild-stage-output
 	}
 }
 
+func TestBuildIntegrationInterrupted(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	outputDir := filepath.Join(t.TempDir(), "output")
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2",
+		"--distro", "centos-9",
+		"--progress=debug",
+		"--cache", t.TempDir(),
+		"--output-dir", outputDir,
+	})
+	defer restore()
+
+	signalDeliveredMarkerPath := filepath.Join(t.TempDir(), "sigint-delivered")
+	testutil.MockCommand(t, "osbuild", fmt.Sprintf(`
+cat - > "$0".stdin
+trap 'touch "%s"; exit 2' INT
+
+mkdir -p "%s/qcow2"
+echo "partial" > "%s/qcow2/disk.qcow2"
+# simulate e.g. systemd stopping image-builder
+kill -TERM $PPID
+while true; do
+    sleep 0.1
+done
+`, signalDeliveredMarkerPath, outputDir, outputDir))
+
+	var err error
+	testutil.CaptureStdio(t, func() {
+		err = main.Run()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.EqualError(t, err, "osbuild was cancelled: terminated signal received")
+
+	// osbuild was interrupted gracefully
+	_, err = os.Stat(signalDeliveredMarkerPath)
+	assert.NoError(t, err)
+	// and the partial output was removed
+	_, err = os.Stat(outputDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
 func TestManifestIntegrationWithSBOMWithOutputDir(t *testing.T) {
 	restore := main.MockManifestgenDepsolver(fakeDepsolve)
 	defer restore()
@@ -850,9 +1180,34 @@ func TestProgressFromCmd(t *testing.T) {
 	} {
 		assert.NoError(t, cmd.Flags().Set("progress", tc.progress))
 		assert.NoError(t, cmd.Flags().Set("verbose", fmt.Sprintf("%v", tc.verbose)))
-		pbar, err := main.ProgressFromCmd(cmd, progress.ProgressConfig{})
+		pbar, closeProgress, err := main.ProgressFromCmd(cmd, progress.ProgressConfig{})
 		assert.NoError(t, err)
 		assert.Equal(t, tc.expectedProgress, fmt.Sprintf("%T", pbar))
+		assert.NoError(t, closeProgress())
+	}
+}
+
+func TestMetricsFromCmd(t *testing.T) {
+	for _, tc := range []struct {
+		args           []string
+		expectedPrint  bool
+		expectedReport string
+	}{
+		{nil, false, ""},
+		{[]string{"--with-metrics"}, true, ""},
+		{[]string{"--with-metrics=false"}, false, ""},
+		{[]string{"--metrics-report=report.csv"}, false, "report.csv"},
+		{[]string{"--with-metrics", "--metrics-report", "report.json"}, true, "report.json"},
+	} {
+		cmd := &cobra.Command{}
+		cmd.Flags().Bool("with-metrics", false, "")
+		cmd.Flags().String("metrics-report", "", "")
+		require.NoError(t, cmd.Flags().Parse(tc.args))
+
+		withMetrics, report, err := main.MetricsFromCmd(cmd)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expectedPrint, withMetrics, tc.args)
+		assert.Equal(t, tc.expectedReport, report, tc.args)
 	}
 }
 
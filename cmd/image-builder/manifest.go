@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/osbuild/blueprint/pkg/blueprint"
+
 	"github.com/osbuild/image-builder/pkg/customizations/subscription"
 	"github.com/osbuild/image-builder/pkg/distro"
 	"github.com/osbuild/image-builder/pkg/imagefilter"
@@ -24,9 +26,11 @@ import (
 type manifestOptions struct {
 	ManifestgenOptions manifestgen.Options
 
-	OutputDir                  string
-	OutputFilename             string
-	BlueprintPath              string
+	OutputDir      string
+	OutputFilename string
+	BlueprintPath  string
+	// Blueprint is used instead of loading BlueprintPath if set
+	Blueprint                  *blueprint.Blueprint
 	Ostree                     *ostree.ImageOptions
 	BootcRef                   string
 	BootcInstallerPayloadRef   string
@@ -99,9 +103,12 @@ func generateManifest(repoDir string, extraRepos []string, img *imagefilter.Resu
 		return err
 	}
 
-	bp, err := blueprintload.Load(opts.BlueprintPath)
-	if err != nil {
-		return err
+	bp := opts.Blueprint
+	if bp == nil {
+		bp, err = blueprintload.Load(opts.BlueprintPath)
+		if err != nil {
+			return err
+		}
 	}
 
 	imgOpts := &distro.ImageOptions{
@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"debug/elf"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/osbuild/image-builder/internal/blueprintload"
+	"github.com/osbuild/image-builder/internal/buildconfig"
+	"github.com/osbuild/image-builder/pkg/arch"
+)
+
+// runUser is the user that gets created via cloud-init and that is
+// used to log into the booted image
+const runUser = "image-builder"
+
+// qemuCancelWaitDelay is the time qemu gets to shut down after SIGTERM
+var qemuCancelWaitDelay = 10 * time.Second
+
+// sshRetryInterval is the time between attempts to log into the image
+var sshRetryInterval = 2 * time.Second
+
+// firmwarePaths are the known locations of the UEFI firmware on
+// the various distributions
+var firmwarePaths = map[string][]string{
+	"x86_64": {
+		"/usr/share/OVMF/OVMF_CODE.fd",
+		"/usr/share/edk2/ovmf/OVMF_CODE.fd",
+		"/usr/share/ovmf/OVMF.fd",
+		"/usr/share/qemu/ovmf-x86_64.bin",
+	},
+	"aarch64": {
+		"/usr/share/AAVMF/AAVMF_CODE.fd",
+		"/usr/share/edk2/aarch64/QEMU_EFI.fd",
+		"/usr/share/qemu-efi-aarch64/QEMU_EFI.fd",
+	},
+}
+
+func findFirmware(archName string) (string, error) {
+	for _, path := range firmwarePaths[archName] {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find UEFI firmware for %s, tried: %s", archName, strings.Join(firmwarePaths[archName], ", "))
+}
+
+// kvmAvailable returns true if images of the given architecture can
+// be run with KVM acceleration
+var kvmAvailable = func(archName string) bool {
+	if archName != arch.Current().String() {
+		return false
+	}
+	f, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// vmConfig describes how an image is booted in QEMU
+type vmConfig struct {
+	Arch   string
+	Image  string
+	Memory int
+	CPUs   int
+	KVM    bool
+	// Firmware is the UEFI firmware, if empty the default BIOS
+	// of QEMU is used
+	Firmware string
+	// SSHPort is the port on localhost that is forwarded to the
+	// SSH port of the image
+	SSHPort int
+	// SeedURL is the URL of the cloud-init NoCloud seed as seen
+	// from the image
+	SeedURL    string
+	ConsoleLog string
+}
+
+// imageFormat returns the QEMU format of the given artifact
+func imageFormat(path string) (string, error) {
+	switch ext := filepath.Ext(path); ext {
+	case ".qcow2":
+		return "qcow2", nil
+	case ".raw", ".img":
+		return "raw", nil
+	case ".iso":
+		// an installer needs a kickstart to install unattended and
+		// the installed system has no cloud-init seed to log in with
+		return "", fmt.Errorf("cannot run ISO %q, only disk images are supported", path)
+	case ".xz", ".zst", ".gz":
+		return "", fmt.Errorf("cannot run compressed image %q, decompress it first", path)
+	default:
+		return "", fmt.Errorf("cannot run %q: unsupported image extension %q (supported: .qcow2, .raw, .img)", path, ext)
+	}
+}
+
+// qemuCmdline returns the QEMU command line that boots the image
+// described by the config
+func qemuCmdline(cfg *vmConfig) ([]string, error) {
+	format, err := imageFormat(cfg.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	accel := "tcg"
+	cpu := "max"
+	if cfg.KVM {
+		accel = "kvm"
+		cpu = "host"
+	}
+	var cmdline []string
+	switch cfg.Arch {
+	case "x86_64":
+		cmdline = []string{"qemu-system-x86_64", "-machine", "q35,accel=" + accel}
+	case "aarch64":
+		cmdline = []string{"qemu-system-aarch64", "-machine", "virt,accel=" + accel}
+	default:
+		return nil, fmt.Errorf("running images for %q is not supported", cfg.Arch)
+	}
+	cmdline = append(cmdline,
+		"-cpu", cpu,
+		"-smp", fmt.Sprintf("%d", cfg.CPUs),
+		"-m", fmt.Sprintf("%d", cfg.Memory),
+		"-display", "none",
+		"-monitor", "none",
+		"-serial", "file:"+cfg.ConsoleLog,
+		// make sure SSH key generation during boot does not block
+		// due to lack of entropy
+		"-object", "rng-random,filename=/dev/urandom,id=rng0",
+		"-device", "virtio-rng-pci,rng=rng0",
+		"-netdev", fmt.Sprintf("user,id=net0,hostfwd=tcp:127.0.0.1:%d-:22", cfg.SSHPort),
+		"-device", "virtio-net-pci,netdev=net0",
+		// the cloud-init NoCloud datasource is passed via SMBIOS
+		"-smbios", fmt.Sprintf("type=1,serial=ds=nocloud;s=%s", cfg.SeedURL),
+	)
+	if cfg.Firmware != "" {
+		cmdline = append(cmdline, "-bios", cfg.Firmware)
+	}
+	// never modify the image itself
+	cmdline = append(cmdline,
+		"-drive", fmt.Sprintf("file=%s,if=virtio,format=%s,snapshot=on", cfg.Image, format),
+	)
+	return cmdline, nil
+}
+
+type cloudConfigUser struct {
+	Name              string   `yaml:"name"`
+	Groups            []string `yaml:"groups,omitempty"`
+	Sudo              string   `yaml:"sudo,omitempty"`
+	SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys"`
+}
+
+// cloudInitUserData returns the cloud-init user-data that creates the
+// user to log in with the given SSH public key
+func cloudInitUserData(authorizedKey string) ([]byte, error) {
+	userData := struct {
+		Users []cloudConfigUser `yaml:"users"`
+	}{
+		Users: []cloudConfigUser{
+			{
+				Name:              runUser,
+				Groups:            []string{"wheel"},
+				Sudo:              "ALL=(ALL) NOPASSWD:ALL",
+				SSHAuthorizedKeys: []string{authorizedKey},
+			},
+		},
+	}
+	data, err := yaml.Marshal(userData)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("#cloud-config\n"), data...), nil
+}
+
+// serveNoCloudSeed serves a cloud-init NoCloud seed on a random port
+// of localhost that is reachable from the image via QEMU user
+// networking, it returns the port.
+func serveNoCloudSeed(ctx context.Context, userData []byte) (int, error) {
+	files := map[string][]byte{
+		"/user-data":   userData,
+		"/meta-data":   []byte("instance-id: image-builder-run\nlocal-hostname: image-builder-run\n"),
+		"/vendor-data": nil,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("cannot serve cloud-init seed: %w", err)
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	context.AfterFunc(ctx, func() {
+		srv.Close()
+	})
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// freePort returns a free port on localhost, this is racy but there is
+// no way to pass a listening socket to QEMU user networking
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// sshKey returns the signer for the given private key file or a newly
+// generated key that is also written to dir (to log in manually)
+func sshKey(privateKeyPath, dir string) (ssh.Signer, string, error) {
+	if privateKeyPath != "" {
+		data, err := os.ReadFile(privateKeyPath)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot read SSH key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot parse SSH key %q: %w", privateKeyPath, err)
+		}
+		return signer, privateKeyPath, nil
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, "", err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "image-builder run")
+	if err != nil {
+		return nil, "", err
+	}
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, "", err
+	}
+	return signer, keyPath, nil
+}
+
+// consoleTail returns the last lines of the console log
+func consoleTail(path string, n int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// errQemuExited is returned when QEMU exits before the image booted
+var errQemuExited = errors.New("qemu exited before the image booted")
+
+// waitForSSH waits until the image can be logged into via SSH, it
+// fails early when QEMU exits
+func waitForSSH(ctx context.Context, port int, signer ssh.Signer, qemuDone <-chan struct{}) (*ssh.Client, error) {
+	config := &ssh.ClientConfig{
+		User: runUser,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		// the image was just booted, there is no known host key
+		// #nosec G106
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         10 * time.Second,
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	for {
+		// sshd may be up before cloud-init added the key, so
+		// retry until the login works
+		client, err := ssh.Dial("tcp", addr, config)
+		if err == nil {
+			return client, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("cannot log into the image: %w (last error: %v)", context.Cause(ctx), err)
+		case <-qemuDone:
+			return nil, errQemuExited
+		case <-time.After(sshRetryInterval):
+		}
+	}
+}
+
+// runSSH runs the given command in the image and writes its output
+// to w, the command is stopped when the context is done
+func runSSH(ctx context.Context, client *ssh.Client, cmd string, stdin io.Reader, w io.Writer) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdin = stdin
+	session.Stdout = w
+	session.Stderr = w
+	stop := context.AfterFunc(ctx, func() {
+		session.Close()
+	})
+	defer stop()
+	err = session.Run(cmd)
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// systemState waits until systemd finished booting and returns the
+// state of the system and the failed units
+func systemState(ctx context.Context, client *ssh.Client) (string, []string, error) {
+	var out bytes.Buffer
+	// "is-system-running" fails for any other state than "running"
+	err := runSSH(ctx, client, "systemctl is-system-running --wait", nil, &out)
+	if ctx.Err() != nil {
+		return "", nil, fmt.Errorf("cannot get the system state: %w", err)
+	}
+	state := strings.TrimSpace(out.String())
+	if state == "" {
+		return "", nil, fmt.Errorf("cannot get the system state")
+	}
+
+	out.Reset()
+	if err := runSSH(ctx, client, "systemctl list-units --failed --plain --no-legend --no-pager", nil, &out); err != nil {
+		return state, nil, fmt.Errorf("cannot get the failed units: %w", err)
+	}
+	var failed []string
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			failed = append(failed, fields[0])
+		}
+	}
+	return state, failed, nil
+}
+
+// elfArchs maps the ELF machines to the architecture names
+var elfArchs = map[elf.Machine]string{
+	elf.EM_X86_64:  "x86_64",
+	elf.EM_AARCH64: "aarch64",
+	elf.EM_PPC64:   "ppc64le",
+	elf.EM_S390:    "s390x",
+}
+
+// checkBinaryArch makes sure that the given binary can be run in an
+// image of the given architecture
+func checkBinaryArch(path, archName string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	defer f.Close()
+	binArch, ok := elfArchs[f.Machine]
+	if !ok {
+		binArch = f.Machine.String()
+	}
+	if binArch != archName {
+		return fmt.Errorf("cannot run %s in the image: it is built for %s but the image is %s", path, binArch, archName)
+	}
+	return nil
+}
+
+// runHostChecks copies check-host-config and the build config of the
+// blueprint into the image and runs it
+func runHostChecks(ctx context.Context, client *ssh.Client, checkBinary, blueprintPath string, w io.Writer) error {
+	config := buildconfig.BuildConfig{Name: "image-builder-run"}
+	if blueprintPath != "" {
+		bp, err := blueprintload.Load(blueprintPath)
+		if err != nil {
+			return err
+		}
+		config.Blueprint = bp
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if err := runSSH(ctx, client, "cat > /tmp/build-config.json", bytes.NewReader(configJSON), io.Discard); err != nil {
+		return fmt.Errorf("cannot copy the build config: %w", err)
+	}
+
+	f, err := os.Open(checkBinary)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := runSSH(ctx, client, "cat > /tmp/check-host-config && chmod 0755 /tmp/check-host-config", f, io.Discard); err != nil {
+		return fmt.Errorf("cannot copy %s: %w", checkBinary, err)
+	}
+	return runSSH(ctx, client, "sudo /tmp/check-host-config /tmp/build-config.json", nil, w)
+}
+
+type runOptions struct {
+	Arch       string
+	Memory     int
+	CPUs       int
+	UEFI       bool
+	Timeout    time.Duration
+	SSHKey     string
+	ConsoleLog string
+	// CheckHostConfig is the check-host-config binary to run in the
+	// image, the checks are skipped if empty
+	CheckHostConfig string
+	BlueprintPath   string
+	KeepRunning     bool
+}
+
+// runResult is the result of booting an image
+type runResult struct {
+	Image       string   `json:"image"`
+	Accel       string   `json:"accel"`
+	BootTime    float64  `json:"boot_time"`
+	State       string   `json:"state"`
+	FailedUnits []string `json:"failed_units,omitempty"`
+	Checks      string   `json:"checks"`
+	Passed      bool     `json:"passed"`
+}
+
+// runImage boots the image and checks its health
+func runImage(ctx context.Context, imagePath string, opts *runOptions, w io.Writer) (res *runResult, err error) {
+	if _, err := imageFormat(imagePath); err != nil {
+		return nil, err
+	}
+	// fail before booting, check-host-config is not run on the host
+	if opts.CheckHostConfig != "" {
+		if err := checkBinaryArch(opts.CheckHostConfig, opts.Arch); err != nil {
+			return nil, err
+		}
+	}
+
+	tmpdir, err := os.MkdirTemp("", "image-builder-run-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	cfg := &vmConfig{
+		Arch:       opts.Arch,
+		Image:      imagePath,
+		Memory:     opts.Memory,
+		CPUs:       opts.CPUs,
+		KVM:        kvmAvailable(opts.Arch),
+		ConsoleLog: opts.ConsoleLog,
+	}
+	if cfg.ConsoleLog == "" {
+		cfg.ConsoleLog = filepath.Join(tmpdir, "console.log")
+	}
+	// aarch64 can only boot via UEFI
+	if opts.UEFI || opts.Arch == "aarch64" {
+		if cfg.Firmware, err = findFirmware(opts.Arch); err != nil {
+			return nil, err
+		}
+	}
+	signer, keyPath, err := sshKey(opts.SSHKey, tmpdir)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := cloudInitUserData(strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))))
+	if err != nil {
+		return nil, err
+	}
+	seedPort, err := serveNoCloudSeed(ctx, userData)
+	if err != nil {
+		return nil, err
+	}
+	// the host is reachable as 10.0.2.2 with QEMU user networking
+	cfg.SeedURL = fmt.Sprintf("http://10.0.2.2:%d/", seedPort)
+	if cfg.SSHPort, err = freePort(); err != nil {
+		return nil, err
+	}
+
+	cmdline, err := qemuCmdline(cfg)
+	if err != nil {
+		return nil, err
+	}
+	qemuCtx, stopQemu := context.WithCancel(ctx)
+	defer stopQemu()
+	// #nosec G204
+	qemu := exec.CommandContext(qemuCtx, cmdline[0], cmdline[1:]...)
+	qemu.Cancel = func() error {
+		return qemu.Process.Signal(syscall.SIGTERM)
+	}
+	qemu.WaitDelay = qemuCancelWaitDelay
+	var qemuOutput bytes.Buffer
+	qemu.Stdout = &qemuOutput
+	qemu.Stderr = &qemuOutput
+	if err := qemu.Start(); err != nil {
+		return nil, fmt.Errorf("cannot start qemu: %w", err)
+	}
+	// qemuErr is only valid once qemuDone is closed
+	var qemuErr error
+	qemuDone := make(chan struct{})
+	go func() {
+		qemuErr = qemu.Wait()
+		close(qemuDone)
+	}()
+	defer func() {
+		stopQemu()
+		<-qemuDone
+	}()
+
+	res = &runResult{Image: imagePath, Accel: "tcg"}
+	if cfg.KVM {
+		res.Accel = "kvm"
+	}
+	fmt.Fprintf(w, "Booting %s (%s)\n", imagePath, res.Accel)
+	start := time.Now()
+	bootCtx, cancel := context.WithTimeoutCause(ctx, opts.Timeout, fmt.Errorf("image did not boot within %v", opts.Timeout))
+	defer cancel()
+	client, err := waitForSSH(bootCtx, cfg.SSHPort, signer, qemuDone)
+	if errors.Is(err, errQemuExited) {
+		err = fmt.Errorf("%w: %v, output:\n%s", err, qemuErr, qemuOutput.String())
+	}
+	if err != nil {
+		if tail := consoleTail(cfg.ConsoleLog, 20); tail != "" {
+			err = fmt.Errorf("%w\nlast console output:\n%s", err, tail)
+		}
+		return nil, err
+	}
+	defer client.Close()
+	res.BootTime = time.Since(start).Seconds()
+
+	// the boot is only done once systemd finished starting the units
+	res.State, res.FailedUnits, err = systemState(bootCtx, client)
+	if err != nil {
+		return nil, err
+	}
+	res.Passed = res.State == "running"
+
+	res.Checks = "skipped"
+	if opts.CheckHostConfig != "" {
+		res.Checks = "passed"
+		var exitErr *ssh.ExitError
+		if err := runHostChecks(ctx, client, opts.CheckHostConfig, opts.BlueprintPath, w); errors.As(err, &exitErr) {
+			res.Checks = "failed"
+			res.Passed = false
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.KeepRunning {
+		fmt.Fprintf(w, "The image keeps running, press Ctrl-C to stop it. To log in run:\n")
+		fmt.Fprintf(w, "ssh -i %s -p %d -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no %s@localhost\n", keyPath, cfg.SSHPort, runUser)
+		select {
+		case <-ctx.Done():
+		case <-qemuDone:
+		}
+	}
+	return res, nil
+}
+
+func cmdRun(cmd *cobra.Command, args []string) error {
+	ctx, stop := signalContext(cmd)
+	defer stop()
+
+	archStr, err := cmd.Flags().GetString("arch")
+	if err != nil {
+		return err
+	}
+	if archStr == "" {
+		archStr = arch.Current().String()
+	}
+	memory, err := cmd.Flags().GetInt("memory")
+	if err != nil {
+		return err
+	}
+	cpus, err := cmd.Flags().GetInt("cpus")
+	if err != nil {
+		return err
+	}
+	uefi, err := cmd.Flags().GetBool("uefi")
+	if err != nil {
+		return err
+	}
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return err
+	}
+	sshKeyPath, err := cmd.Flags().GetString("ssh-key")
+	if err != nil {
+		return err
+	}
+	consoleLog, err := cmd.Flags().GetString("console-log")
+	if err != nil {
+		return err
+	}
+	checkHostConfig, err := cmd.Flags().GetString("check-host-config")
+	if err != nil {
+		return err
+	}
+	blueprintPath, err := cmd.Flags().GetString("blueprint")
+	if err != nil {
+		return err
+	}
+	keepRunning, err := cmd.Flags().GetBool("keep-running")
+	if err != nil {
+		return err
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unsupported format %q, supported formats: text, json", format)
+	}
+
+	opts := &runOptions{
+		Arch:            archStr,
+		Memory:          memory,
+		CPUs:            cpus,
+		UEFI:            uefi,
+		Timeout:         timeout,
+		SSHKey:          sshKeyPath,
+		ConsoleLog:      consoleLog,
+		CheckHostConfig: checkHostConfig,
+		BlueprintPath:   blueprintPath,
+		KeepRunning:     keepRunning,
+	}
+	progressOut := osStdout
+	if format == "json" {
+		progressOut = osStderr
+	}
+	res, err := runImage(ctx, args[0], opts, progressOut)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(osStdout, "%s\n", b)
+	} else {
+		fmt.Fprintf(osStdout, "Boot time: %.1fs\n", res.BootTime)
+		fmt.Fprintf(osStdout, "System state: %s\n", res.State)
+		if len(res.FailedUnits) > 0 {
+			fmt.Fprintf(osStdout, "Failed units: %s\n", strings.Join(res.FailedUnits, ", "))
+		}
+		fmt.Fprintf(osStdout, "Host checks: %s\n", res.Checks)
+	}
+	if !res.Passed {
+		return fmt.Errorf("image %s failed the boot test", args[0])
+	}
+	fmt.Fprintf(osStdout, "Boot test of %s passed\n", args[0])
+	return nil
+}
@@ -0,0 +1,198 @@
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v3"
+
+	main "github.com/osbuild/image-builder/cmd/image-builder"
+	"github.com/osbuild/image-builder/internal/testutil"
+	"github.com/osbuild/image-builder/pkg/arch"
+)
+
+func TestRunQemuCmdline(t *testing.T) {
+	cfg := &main.VMConfig{
+		Arch:       "x86_64",
+		Image:      "/images/disk.qcow2",
+		Memory:     2048,
+		CPUs:       2,
+		KVM:        true,
+		SSHPort:    2222,
+		SeedURL:    "http://10.0.2.2:8000/",
+		ConsoleLog: "/tmp/console.log",
+	}
+	cmdline, err := main.QemuCmdline(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"qemu-system-x86_64", "-machine", "q35,accel=kvm",
+		"-cpu", "host",
+		"-smp", "2",
+		"-m", "2048",
+		"-display", "none",
+		"-monitor", "none",
+		"-serial", "file:/tmp/console.log",
+		"-object", "rng-random,filename=/dev/urandom,id=rng0",
+		"-device", "virtio-rng-pci,rng=rng0",
+		"-netdev", "user,id=net0,hostfwd=tcp:127.0.0.1:2222-:22",
+		"-device", "virtio-net-pci,netdev=net0",
+		"-smbios", "type=1,serial=ds=nocloud;s=http://10.0.2.2:8000/",
+		"-drive", "file=/images/disk.qcow2,if=virtio,format=qcow2,snapshot=on",
+	}, cmdline)
+}
+
+func TestRunQemuCmdlineUEFI(t *testing.T) {
+	cfg := &main.VMConfig{
+		Arch:       "aarch64",
+		Image:      "/images/disk.raw",
+		Memory:     4096,
+		CPUs:       4,
+		Firmware:   "/usr/share/AAVMF/AAVMF_CODE.fd",
+		SSHPort:    2222,
+		SeedURL:    "http://10.0.2.2:8000/",
+		ConsoleLog: "/tmp/console.log",
+	}
+	cmdline, err := main.QemuCmdline(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"qemu-system-aarch64", "-machine", "virt,accel=tcg", "-cpu", "max"}, cmdline[:5])
+	assert.Equal(t, []string{
+		"-bios", "/usr/share/AAVMF/AAVMF_CODE.fd",
+		"-drive", "file=/images/disk.raw,if=virtio,format=raw,snapshot=on",
+	}, cmdline[len(cmdline)-4:])
+}
+
+func TestRunQemuCmdlineErrors(t *testing.T) {
+	for _, tc := range []struct {
+		arch     string
+		image    string
+		expected string
+	}{
+		{"x86_64", "disk.raw.xz", `cannot run compressed image "disk.raw.xz", decompress it first`},
+		{"x86_64", "disk.vmdk", `cannot run "disk.vmdk": unsupported image extension ".vmdk" (supported: .qcow2, .raw, .img)`},
+		{"x86_64", "installer.iso", `cannot run ISO "installer.iso", only disk images are supported`},
+		{"s390x", "disk.qcow2", `running images for "s390x" is not supported`},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			_, err := main.QemuCmdline(&main.VMConfig{Arch: tc.arch, Image: tc.image})
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestRunNoCloudSeed(t *testing.T) {
+	userData, err := main.CloudInitUserData("ssh-ed25519 AAAA test")
+	require.NoError(t, err)
+	assert.Contains(t, string(userData), "#cloud-config\n")
+
+	var parsed map[string]any
+	require.NoError(t, yaml.Unmarshal(userData, &parsed))
+	assert.Equal(t, map[string]any{
+		"users": []any{
+			map[string]any{
+				"name":                "image-builder",
+				"groups":              []any{"wheel"},
+				"sudo":                "ALL=(ALL) NOPASSWD:ALL",
+				"ssh_authorized_keys": []any{"ssh-ed25519 AAAA test"},
+			},
+		},
+	}, parsed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	port, err := main.ServeNoCloudSeed(ctx, userData)
+	require.NoError(t, err)
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/%s", port, path))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+	status, body := get("user-data")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, string(userData), body)
+	status, body = get("meta-data")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "instance-id: image-builder-run\n")
+	status, _ = get("vendor-data")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = get("other")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestRunUnsupportedImage(t *testing.T) {
+	restore := main.MockOsArgs([]string{"run", "--arch", "x86_64", "disk.raw.xz"})
+	defer restore()
+
+	err := main.Run()
+	assert.EqualError(t, err, `cannot run compressed image "disk.raw.xz", decompress it first`)
+}
+
+func TestRunCheckHostConfigWrongArch(t *testing.T) {
+	fakeQemuCmd := testutil.MockCommand(t, "qemu-system-x86_64", "exit 1")
+	imagePath := filepath.Join(t.TempDir(), "disk.qcow2")
+
+	// the test binary is an ELF binary of the host architecture
+	testBinary, err := os.Executable()
+	require.NoError(t, err)
+	otherArch := "aarch64"
+	if arch.Current() == arch.ARCH_AARCH64 {
+		otherArch = "x86_64"
+	}
+	restore := main.MockOsArgs([]string{"run", "--arch", otherArch, "--check-host-config", testBinary, imagePath})
+	defer restore()
+	err = main.Run()
+	assert.EqualError(t, err, fmt.Sprintf("cannot run %s in the image: it is built for %s but the image is %s", testBinary, arch.Current(), otherArch))
+
+	notELF := filepath.Join(t.TempDir(), "check-host-config")
+	require.NoError(t, os.WriteFile(notELF, []byte("#!/bin/sh\n"), 0755))
+	restore = main.MockOsArgs([]string{"run", "--arch", "x86_64", "--check-host-config", notELF, imagePath})
+	defer restore()
+	err = main.Run()
+	assert.ErrorContains(t, err, fmt.Sprintf("cannot read %s: ", notELF))
+
+	assert.Equal(t, 0, len(fakeQemuCmd.CallArgsList()))
+}
+
+func TestRunQemuExitsEarly(t *testing.T) {
+	restore := main.MockKvmAvailable(func(string) bool { return false })
+	defer restore()
+
+	// write some console output and fail like qemu does for a
+	// broken image
+	fakeQemuCmd := testutil.MockCommand(t, "qemu-system-x86_64", `
+for arg in "$@"; do
+    case "$arg" in
+    file:*) echo "Kernel panic - not syncing" > "${arg#file:}";;
+    esac
+done
+echo "qemu: could not load disk" >&2
+exit 1
+`)
+	imagePath := filepath.Join(t.TempDir(), "disk.qcow2")
+	restore = main.MockOsArgs([]string{"run", "--arch", "x86_64", imagePath})
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	err := main.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "qemu exited before the image booted: exit status 1, output:\nqemu: could not load disk\n")
+	assert.Contains(t, err.Error(), "last console output:\nKernel panic - not syncing")
+	assert.Contains(t, fakeStdout.String(), fmt.Sprintf("Booting %s (tcg)\n", imagePath))
+
+	require.Equal(t, 1, len(fakeQemuCmd.CallArgsList()))
+	assert.Contains(t, fakeQemuCmd.CallArgsList()[0], fmt.Sprintf("file=%s,if=virtio,format=qcow2,snapshot=on", imagePath))
+}
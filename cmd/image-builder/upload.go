@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -22,6 +23,7 @@ import (
 	"github.com/osbuild/image-builder/pkg/cloud/ibmcloud"
 	"github.com/osbuild/image-builder/pkg/cloud/libvirt"
 	"github.com/osbuild/image-builder/pkg/cloud/openstack"
+	"github.com/osbuild/image-builder/pkg/hashutil"
 	"github.com/osbuild/image-builder/pkg/platform"
 	"github.com/osbuild/image-builder/pkg/progress"
 )
@@ -44,7 +46,7 @@ var (
 	ibmNewUploader       = ibmcloud.NewUploader
 )
 
-func uploadImageWithProgress(uploader cloud.Uploader, imagePath string) (*cloud.UploadResult, error) {
+func uploadImageWithProgress(ctx context.Context, uploader cloud.Uploader, imagePath string) (*cloud.UploadResult, error) {
 	f, err := os.Open(imagePath)
 	if err != nil {
 		return nil, err
@@ -68,7 +70,45 @@ func uploadImageWithProgress(uploader cloud.Uploader, imagePath string) (*cloud.
 	pbar.Start()
 	defer pbar.Finish()
 
-	return uploader.UploadAndRegister(r, size, osStderr)
+	return uploader.UploadAndRegister(ctx, r, size, osStderr)
+}
+
+// localUploadResult returns the upload result for an image that is not
+// uploaded anywhere. Besides the path it describes the image file so that
+// consumers that import local images themselves (e.g. Azure Stack HCI,
+// which imports vhdx images from a path on the cluster) can verify it and
+// pick the right virtual machine generation. The format is taken from
+// imgFilename, the filename of the image type, as the path can contain
+// dots (e.g. "rhel-9.6-hyperv-x86_64.vhdx").
+func localUploadResult(imagePath, imgFilename string, bootMode platform.BootMode) (*cloud.UploadResult, error) {
+	st, err := os.Stat(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	sha256, err := hashutil.Sha256sum(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot checksum %s: %w", imagePath, err)
+	}
+
+	res := &cloud.UploadResult{
+		Provider: "LocalPath",
+		ImageID:  imagePath,
+		Size:     uint64(st.Size()),
+		SHA256:   sha256,
+	}
+	if l := strings.SplitN(imgFilename, ".", 2); len(l) == 2 {
+		res.Format = l[1]
+	}
+	switch strings.SplitN(res.Format, ".", 2)[0] {
+	case "vhd", "vhdx":
+		// generation 2 virtual machines boot via UEFI
+		if bootMode == platform.BOOT_LEGACY {
+			res.HyperVGeneration = "V1"
+		} else {
+			res.HyperVGeneration = "V2"
+		}
+	}
+	return res, nil
 }
 
 func uploaderCheckWithProgress(pbar progress.ProgressBar, uploader cloud.Uploader) error {
@@ -337,6 +377,9 @@ func bootModeFromFlag(cmd *cobra.Command) (*platform.BootMode, error) {
 }
 
 func cmdUpload(cmd *cobra.Command, args []string) error {
+	ctx, stop := signalContext(cmd)
+	defer stop()
+
 	imagePath := args[0]
 
 	uploadTo, err := cmd.Flags().GetString("to")
@@ -372,7 +415,7 @@ func cmdUpload(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	result, err := uploadImageWithProgress(uploader, imagePath)
+	result, err := uploadImageWithProgress(ctx, uploader, imagePath)
 	if err != nil {
 		return err
 	}
@@ -378,3 +378,43 @@ func TestBuildAndUploadWithAWSPartialCmdlineErrors(t *testing.T) {
 	err := main.Run()
 	assert.EqualError(t, err, `missing upload configuration: ["--aws-ami-name" "--aws-bucket"]`)
 }
+
+func TestLocalUploadResult(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "centos-9-hyperv-x86_64.vhdx")
+	require.NoError(t, os.WriteFile(imagePath, []byte("fake-vhdx"), 0644))
+
+	res, err := main.LocalUploadResult(imagePath, "disk.vhdx", platform.BOOT_UEFI)
+	require.NoError(t, err)
+	assert.Equal(t, &cloud.UploadResult{
+		Provider:         "LocalPath",
+		ImageID:          imagePath,
+		Format:           "vhdx",
+		Size:             9,
+		SHA256:           "eadc841cac238dd22862ff601a79cda457750127a9ea77be25ffb7cff510fcc6",
+		HyperVGeneration: "V2",
+	}, res)
+
+	imagePath = filepath.Join(t.TempDir(), "centos-9-qcow2-x86_64.qcow2")
+	require.NoError(t, os.WriteFile(imagePath, nil, 0644))
+	res, err = main.LocalUploadResult(imagePath, "disk.qcow2", platform.BOOT_HYBRID)
+	require.NoError(t, err)
+	assert.Equal(t, "qcow2", res.Format)
+	assert.Equal(t, "", res.HyperVGeneration)
+}
+
+func TestLocalUploadResultDottedVersion(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "rhel-9.6-hyperv-x86_64.vhdx")
+	require.NoError(t, os.WriteFile(imagePath, nil, 0644))
+
+	res, err := main.LocalUploadResult(imagePath, "disk.vhdx", platform.BOOT_LEGACY)
+	require.NoError(t, err)
+	assert.Equal(t, "vhdx", res.Format)
+	assert.Equal(t, "V1", res.HyperVGeneration)
+
+	imagePath = filepath.Join(t.TempDir(), "fedora-43.1-minimal-raw-xz-x86_64.raw.xz")
+	require.NoError(t, os.WriteFile(imagePath, nil, 0644))
+	res, err = main.LocalUploadResult(imagePath, "disk.raw.xz", platform.BOOT_UEFI)
+	require.NoError(t, err)
+	assert.Equal(t, "raw.xz", res.Format)
+	assert.Equal(t, "", res.HyperVGeneration)
+}
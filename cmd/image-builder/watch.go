@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"math/rand/v2"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/osbuild/image-builder/internal/blueprintload"
+)
+
+// watchPollInterval is the interval in which the watched files are
+// checked for changes
+var watchPollInterval = 1 * time.Second
+
+// watchWaiting is called when the watch mode waits for changes
+var watchWaiting = func() {}
+
+// localPathFromURL returns the local path for "file://" URLs and plain
+// paths, all other URLs are not local
+func localPathFromURL(s string) (string, bool) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", false
+	}
+	switch u.Scheme {
+	case "", "file":
+		return u.Path, u.Path != ""
+	default:
+		return "", false
+	}
+}
+
+// watchedPaths returns the local files and directories the build
+// depends on: the blueprint, the local files of the file
+// customizations and the local repositories
+func watchedPaths(blueprintPath string, repoURLs []string) []string {
+	paths := map[string]bool{}
+	for _, repoURL := range repoURLs {
+		if u, err := url.Parse(repoURL); err == nil && u.Scheme == "file" {
+			paths[u.Path] = true
+		}
+	}
+	// blueprints from stdin cannot be watched
+	if blueprintPath != "" && blueprintPath != "-" {
+		paths[blueprintPath] = true
+
+		// the blueprint may be broken while it is edited, the files
+		// it references are picked up again once it is fixed
+		bp, err := blueprintload.Load(blueprintPath)
+		if err == nil && bp.Customizations != nil {
+			for _, file := range bp.Customizations.GetFiles() {
+				if path, ok := localPathFromURL(file.URI); ok {
+					paths[path] = true
+				}
+			}
+			repos, _ := bp.Customizations.GetRepositories()
+			for _, repo := range repos {
+				for _, baseURL := range repo.BaseURLs {
+					if u, err := url.Parse(baseURL); err == nil && u.Scheme == "file" {
+						paths[u.Path] = true
+					}
+				}
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(paths))
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshotPaths returns the size and modification time of all the
+// given files and of all files in the given directories, missing
+// files are not part of the snapshot
+func snapshotPaths(paths []string) map[string]fileState {
+	snapshot := map[string]fileState{}
+	for _, path := range paths {
+		_ = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// files can disappear while they are edited
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			snapshot[p] = fileState{size: info.Size(), modTime: info.ModTime()}
+			return nil
+		})
+	}
+	return snapshot
+}
+
+// waitForChanges waits until any of the given paths changed compared
+// to the given snapshot or the context is done
+func waitForChanges(ctx context.Context, paths []string, before map[string]fileState) error {
+	watchWaiting()
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if !maps.Equal(before, snapshotPaths(paths)) {
+				return nil
+			}
+		}
+	}
+}
+
+// watchBuild builds the given image types and rebuilds them whenever
+// the blueprint or one of the local files it references change. The
+// build is skipped when the manifests did not change.
+func watchBuild(ctx context.Context, cmd *cobra.Command, imageTypes []string) error {
+	blueprintPath, err := cmd.Flags().GetString("blueprint")
+	if err != nil {
+		return err
+	}
+	extraRepos, err := cmd.Flags().GetStringArray("extra-repo")
+	if err != nil {
+		return err
+	}
+	forceRepos, err := cmd.Flags().GetStringArray("force-repo")
+	if err != nil {
+		return err
+	}
+	if blueprintPath == "-" {
+		return fmt.Errorf("cannot watch a blueprint from stdin")
+	}
+	// without a fixed seed every manifest is different (e.g. the
+	// partition UUIDs), use the same seed for all rebuilds
+	if !cmd.Flags().Changed("seed") {
+		if err := cmd.Flags().Set("seed", strconv.FormatInt(rand.Int64(), 10)); err != nil {
+			return err
+		}
+	}
+
+	var checksums []string
+	for {
+		// take the paths before the build so that changes while
+		// building trigger another build
+		paths := watchedPaths(blueprintPath, append(extraRepos, forceRepos...))
+		before := snapshotPaths(paths)
+
+		newChecksums, err := buildFromCmd(ctx, cmd, imageTypes, checksums)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(osStderr, "error: %v\n", err)
+		} else {
+			checksums = newChecksums
+		}
+
+		if !maps.Equal(before, snapshotPaths(paths)) {
+			continue
+		}
+		fmt.Fprintf(humanOutputFor(cmd), "Watching %d paths for changes, press Ctrl-C to stop\n", len(paths))
+		if err := waitForChanges(ctx, paths, before); err != nil {
+			// interrupted by the user
+			return nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testrepos "github.com/osbuild/image-builder/test/data/repositories"
+
+	main "github.com/osbuild/image-builder/cmd/image-builder"
+	"github.com/osbuild/image-builder/internal/testutil"
+)
+
+func TestBuildIntegrationWatch(t *testing.T) {
+	restore := main.MockManifestgenDepsolver(fakeDepsolve)
+	defer restore()
+
+	restore = main.MockManifestgenContainerResolver(fakeContainerResolver)
+	defer restore()
+
+	restore = main.MockNewRepoRegistry(testrepos.New)
+	defer restore()
+
+	restore = main.MockWatchPollInterval(10 * time.Millisecond)
+	defer restore()
+
+	waiting := make(chan struct{})
+	restore = main.MockWatchWaiting(func() {
+		waiting <- struct{}{}
+	})
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = main.MockOsStdout(&fakeStdout)
+	defer restore()
+
+	localFile := filepath.Join(t.TempDir(), "motd")
+	require.NoError(t, os.WriteFile(localFile, []byte("hello"), 0644))
+	blueprintPath := makeTestBlueprint(t, fmt.Sprintf(`
+[[customizations.files]]
+path = "/etc/motd"
+uri = "file://%s"
+`, localFile))
+
+	restore = main.MockOsArgs([]string{
+		"build",
+		"qcow2",
+		"--distro", "centos-9",
+		"--blueprint", blueprintPath,
+		"--cache", t.TempDir(),
+		"--output-dir", t.TempDir(),
+		"--watch",
+	})
+	defer restore()
+
+	fakeOsbuildCmd := testutil.MockCommand(t, "osbuild", makeFakeOsbuildScript())
+
+	done := make(chan error)
+	go func() {
+		done <- main.Run()
+	}()
+
+	<-waiting
+	assert.Equal(t, 1, len(fakeOsbuildCmd.CallArgsList()))
+
+	// a change to the blueprint that does not change the manifest
+	// does not trigger a build
+	f, err := os.OpenFile(blueprintPath, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("\n# just a comment\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	<-waiting
+	assert.Equal(t, 1, len(fakeOsbuildCmd.CallArgsList()))
+
+	// a change to a file referenced by the blueprint does
+	require.NoError(t, os.WriteFile(localFile, []byte("hello world"), 0644))
+	<-waiting
+	assert.Equal(t, 2, len(fakeOsbuildCmd.CallArgsList()))
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+	assert.NoError(t, <-done)
+	assert.Contains(t, fakeStdout.String(), "Manifest unchanged, skipping build\n")
+	assert.Contains(t, fakeStdout.String(), "Watching 2 paths for changes, press Ctrl-C to stop\n")
+}
+
+func TestBuildWatchStdinBlueprint(t *testing.T) {
+	restore := main.MockOsArgs([]string{"build", "qcow2", "--blueprint", "-", "--watch"})
+	defer restore()
+
+	err := main.Run()
+	assert.EqualError(t, err, "cannot watch a blueprint from stdin")
+}
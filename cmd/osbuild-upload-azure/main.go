@@ -72,6 +72,7 @@ func main() {
 		ContainerName:  containerName,
 	}
 	err = c.UploadPageBlob(
+		context.Background(),
 		blobMetadata,
 		fileName,
 		threads,
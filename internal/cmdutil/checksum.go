@@ -0,0 +1,14 @@
+package cmdutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ManifestChecksum returns the checksum of the given (serialized)
+// manifest, it is used by gen-manifests for the checksum files in the
+// tree and by image-builder to detect unchanged manifests
+func ManifestChecksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,15 @@
+package cmdutil_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/internal/cmdutil"
+)
+
+func TestManifestChecksum(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", cmdutil.ManifestChecksum(nil))
+	assert.Equal(t, cmdutil.ManifestChecksum([]byte(`{"version":"2"}`)), cmdutil.ManifestChecksum([]byte(`{"version":"2"}`)))
+	assert.NotEqual(t, cmdutil.ManifestChecksum([]byte(`{"version":"2"}`)), cmdutil.ManifestChecksum([]byte(`{"version":"1"}`)))
+}
@@ -1,15 +1,30 @@
-package main
+package cmdutil
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
 )
 
-type workerQueue struct {
-	// manifest job channel
-	jobQueue chan manifestJob
+// Job is a unit of work of a WorkerQueue, messages sent to msgq are
+// printed by the queue
+type Job func(msgq chan string) error
+
+// WorkerQueue runs jobs with a fixed number of workers and collects
+// their errors
+type WorkerQueue struct {
+	// Output receives the messages of the jobs, defaults to os.Stdout
+	Output io.Writer
+
+	// ShowStatus prints a status line with the number of queued,
+	// active and total jobs after every message
+	ShowStatus bool
+
+	// job channel
+	jobQueue chan Job
 
 	// channel for sending messages from jobs to the printer
 	msgQueue chan string
@@ -20,11 +35,11 @@ type workerQueue struct {
 	// global error list
 	errors []error
 
-	// total job count defined on workerQueue creation
+	// total job count defined on WorkerQueue creation
 	// sets the length of the job queue so that pushing to the queue doesn't block
 	njobs uint32
 
-	// total workers defined on workerQueue creation
+	// total workers defined on WorkerQueue creation
 	nworkers uint32
 
 	// active worker count
@@ -37,9 +52,10 @@ type workerQueue struct {
 	utilWG sync.WaitGroup
 }
 
-func newWorkerQueue(nworkers uint32, njobs uint32) *workerQueue {
-	wq := workerQueue{
-		jobQueue:      make(chan manifestJob, njobs),
+func NewWorkerQueue(nworkers uint32, njobs uint32) *WorkerQueue {
+	wq := WorkerQueue{
+		Output:        os.Stdout,
+		jobQueue:      make(chan Job, njobs),
 		msgQueue:      make(chan string, nworkers),
 		errQueue:      make(chan error, nworkers),
 		errors:        make([]error, 0, nworkers),
@@ -51,7 +67,7 @@ func newWorkerQueue(nworkers uint32, njobs uint32) *workerQueue {
 	return &wq
 }
 
-func (wq *workerQueue) start() {
+func (wq *WorkerQueue) Start() {
 	wq.startMessagePrinter()
 	wq.startErrorCollector()
 	for idx := uint32(0); idx < wq.nworkers; idx++ {
@@ -59,8 +75,9 @@ func (wq *workerQueue) start() {
 	}
 }
 
-// close all queues and wait for waitgroups
-func (wq *workerQueue) wait() []error {
+// Wait closes all queues, waits for the jobs to finish and returns their
+// errors
+func (wq *WorkerQueue) Wait() []error {
 	// close job channel and wait for workers to finish
 	close(wq.jobQueue)
 	wq.workerWG.Wait()
@@ -72,7 +89,7 @@ func (wq *workerQueue) wait() []error {
 	return wq.errors
 }
 
-func (wq *workerQueue) startWorker(idx uint32) {
+func (wq *WorkerQueue) startWorker(idx uint32) {
 	wq.workerWG.Add(1)
 	go func() {
 		atomic.AddInt32(&(wq.activeWorkers), 1)
@@ -87,22 +104,28 @@ func (wq *workerQueue) startWorker(idx uint32) {
 	}()
 }
 
-func (wq *workerQueue) startMessagePrinter() {
+func (wq *WorkerQueue) startMessagePrinter() {
 	wq.utilWG.Add(1)
 	go func() {
 		defer wq.utilWG.Done()
 		var msglen int
 		for msg := range wq.msgQueue {
-			// clear previous line (avoids leftover trailing characters from progress)
-			fmt.Printf("%s\r", strings.Repeat(" ", msglen))
-			fmt.Println(msg)
-			msglen, _ = fmt.Printf(" == Jobs == Queue: %4d  Active: %4d  Total: %4d\r", len(wq.jobQueue), wq.activeWorkers, wq.njobs)
+			if wq.ShowStatus {
+				// clear previous line (avoids leftover trailing characters from progress)
+				fmt.Fprintf(wq.Output, "%s\r", strings.Repeat(" ", msglen))
+			}
+			fmt.Fprintln(wq.Output, msg)
+			if wq.ShowStatus {
+				msglen, _ = fmt.Fprintf(wq.Output, " == Jobs == Queue: %4d  Active: %4d  Total: %4d\r", len(wq.jobQueue), atomic.LoadInt32(&wq.activeWorkers), wq.njobs)
+			}
+		}
+		if wq.ShowStatus {
+			fmt.Fprintln(wq.Output)
 		}
-		fmt.Println()
 	}()
 }
 
-func (wq *workerQueue) startErrorCollector() {
+func (wq *WorkerQueue) startErrorCollector() {
 	wq.utilWG.Add(1)
 	go func() {
 		defer wq.utilWG.Done()
@@ -112,6 +135,7 @@ func (wq *workerQueue) startErrorCollector() {
 	}()
 }
 
-func (wq *workerQueue) submitJob(j manifestJob) {
+// SubmitJob queues the job j, it must be called before Wait
+func (wq *WorkerQueue) SubmitJob(j Job) {
 	wq.jobQueue <- j
 }
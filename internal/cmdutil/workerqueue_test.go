@@ -0,0 +1,33 @@
+package cmdutil_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/internal/cmdutil"
+)
+
+func TestWorkerQueue(t *testing.T) {
+	var out bytes.Buffer
+	wq := cmdutil.NewWorkerQueue(2, 4)
+	wq.Output = &out
+	wq.Start()
+	for i := 0; i < 4; i++ {
+		wq.SubmitJob(func(msgq chan string) error {
+			msgq <- fmt.Sprintf("job %d", i)
+			if i%2 == 1 {
+				return fmt.Errorf("job %d failed", i)
+			}
+			return nil
+		})
+	}
+	errs := wq.Wait()
+
+	assert.Len(t, errs, 2)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.ElementsMatch(t, []string{"job 0", "job 1", "job 2", "job 3"}, lines)
+}
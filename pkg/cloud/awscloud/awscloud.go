@@ -201,13 +201,13 @@ func (a *AWS) Upload(filename, bucket, key string) (*transfermanager.UploadObjec
 			olog.Printf("[AWS] ‼ Failed to close the file uploaded to S3️: %v", err)
 		}
 	}()
-	return a.UploadFromReader(file, bucket, key)
+	return a.UploadFromReader(context.TODO(), file, bucket, key)
 }
 
-func (a *AWS) UploadFromReader(r io.Reader, bucket, key string) (*transfermanager.UploadObjectOutput, error) {
+func (a *AWS) UploadFromReader(ctx context.Context, r io.Reader, bucket, key string) (*transfermanager.UploadObjectOutput, error) {
 	olog.Printf("[AWS] 🚀 Uploading image to S3: %s/%s", bucket, key)
 	return a.s3uploader.UploadObject(
-		context.TODO(),
+		ctx,
 		&transfermanager.UploadObjectInput{
 			Bucket: aws.String(bucket),
 			Key:    aws.String(key),
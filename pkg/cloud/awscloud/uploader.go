@@ -1,6 +1,7 @@
 package awscloud
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -46,7 +47,7 @@ type awsClient interface {
 	Regions() ([]string, error)
 	Buckets() ([]string, error)
 	CheckBucketPermission(string, s3types.Permission) (bool, error)
-	UploadFromReader(io.Reader, string, string) (*transfermanager.UploadObjectOutput, error)
+	UploadFromReader(context.Context, io.Reader, string, string) (*transfermanager.UploadObjectOutput, error)
 	Register(name, bucket, key string, tags []AWSTag, shareWith []string, architecture arch.Arch, bootMode *platform.BootMode, importRole *string) (string, string, error)
 	DeleteObject(string, string) error
 }
@@ -111,11 +112,11 @@ func (au *awsUploader) Check(status io.Writer) error {
 	return nil
 }
 
-func (au *awsUploader) UploadAndRegister(r io.Reader, _ uint64, status io.Writer) (result *cloud.UploadResult, err error) {
+func (au *awsUploader) UploadAndRegister(ctx context.Context, r io.Reader, _ uint64, status io.Writer) (result *cloud.UploadResult, err error) {
 	keyName := fmt.Sprintf("%s-%s", uuid.New().String(), au.imageName)
 	fmt.Fprintf(status, "Uploading %s to %s:%s\n", au.imageName, au.bucketName, keyName)
 
-	res, err := au.client.UploadFromReader(r, au.bucketName, keyName)
+	res, err := au.client.UploadFromReader(ctx, r, au.bucketName, keyName)
 	if err != nil {
 		return nil, err
 	}
@@ -127,6 +128,11 @@ func (au *awsUploader) UploadAndRegister(r io.Reader, _ uint64, status io.Writer
 		}
 	}()
 	fmt.Fprintf(status, "File uploaded to %s\n", aws.ToString(res.Location))
+	// registering cannot be interrupted, do not start it when
+	// the upload got cancelled
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if au.targetArch == arch.ARCH_UNSET {
 		au.targetArch = arch.Current()
 	}
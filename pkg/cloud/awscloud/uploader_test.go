@@ -2,6 +2,7 @@ package awscloud_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"testing"
@@ -35,6 +36,7 @@ type fakeAWSClient struct {
 	uploadFromReader      *transfermanager.UploadObjectOutput
 	uploadFromReaderErr   error
 	uploadFromReaderCalls int
+	uploadFromReaderHook  func()
 
 	registerErr        error
 	registerImageId    string
@@ -61,8 +63,11 @@ func (fa *fakeAWSClient) CheckBucketPermission(string, s3types.Permission) (bool
 	return fa.checkBucketPermission, fa.checkBucketPermissionErr
 }
 
-func (fa *fakeAWSClient) UploadFromReader(io.Reader, string, string) (*transfermanager.UploadObjectOutput, error) {
+func (fa *fakeAWSClient) UploadFromReader(context.Context, io.Reader, string, string) (*transfermanager.UploadObjectOutput, error) {
 	fa.uploadFromReaderCalls++
+	if fa.uploadFromReaderHook != nil {
+		fa.uploadFromReaderHook()
+	}
 	return fa.uploadFromReader, fa.uploadFromReaderErr
 }
 
@@ -157,7 +162,7 @@ func TestUploaderUploadHappy(t *testing.T) {
 			uploader, err := awscloud.NewUploader("region", "bucket", "ami", tc.opts)
 			assert.NoError(t, err)
 			var uploadLog bytes.Buffer
-			result, err := uploader.UploadAndRegister(fakeImage, 0, &uploadLog)
+			result, err := uploader.UploadAndRegister(context.Background(), fakeImage, 0, &uploadLog)
 			assert.NoError(t, err)
 			assert.Equal(t, "aws", result.Provider)
 			assert.Equal(t, "image-id", result.ImageID)
@@ -195,7 +200,7 @@ func TestUploaderUploadButRegisterError(t *testing.T) {
 	uploader, err := awscloud.NewUploader("region", "bucket", "ami", nil)
 	assert.NoError(t, err)
 	var uploadLog bytes.Buffer
-	result, err := uploader.UploadAndRegister(fakeImage, 0, &uploadLog)
+	result, err := uploader.UploadAndRegister(context.Background(), fakeImage, 0, &uploadLog)
 	// XXX: this should probably have a context
 	assert.EqualError(t, err, "fake-register-err")
 	assert.Nil(t, result)
@@ -227,8 +232,42 @@ func TestUploaderUploadButRegisterErrorAndDeleteError(t *testing.T) {
 	uploader, err := awscloud.NewUploader("region", "bucket", "ami", nil)
 	assert.NoError(t, err)
 	var uploadLog bytes.Buffer
-	result, err := uploader.UploadAndRegister(fakeImage, 0, &uploadLog)
+	result, err := uploader.UploadAndRegister(context.Background(), fakeImage, 0, &uploadLog)
 	// XXX: this should probably have a context
 	assert.EqualError(t, err, "fake-register-err\nfake-delete-object-err")
 	assert.Nil(t, result)
 }
+
+func TestUploaderUploadCancelled(t *testing.T) {
+	uuid.SetRand(&repeatReader{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fa := &fakeAWSClient{
+		uploadFromReader: &transfermanager.UploadObjectOutput{
+			Location: aws.String("some-location"),
+		},
+		// cancelled right when the upload finished
+		uploadFromReaderHook: cancel,
+	}
+	restore := awscloud.MockNewAwsClient(func(string, string) (awscloud.AwsClient, error) {
+		return fa, nil
+	})
+	defer restore()
+
+	fakeImage := bytes.NewBufferString("fake-aws-image")
+	uploader, err := awscloud.NewUploader("region", "bucket", "ami", nil)
+	assert.NoError(t, err)
+	var uploadLog bytes.Buffer
+	result, err := uploader.UploadAndRegister(ctx, fakeImage, 0, &uploadLog)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, result)
+	assert.Equal(t, 1, fa.uploadFromReaderCalls)
+	assert.Equal(t, 0, fa.registerCalls)
+	// the staged object got removed again
+	assert.Equal(t, 1, fa.deleteObjectCalls)
+	expectedUploadLog := `Uploading ami to bucket:01010101-0101-4101-8101-010101010101-ami
+File uploaded to some-location
+Deleted S3 object bucket:01010101-0101-4101-8101-010101010101-ami
+`
+	assert.Equal(t, expectedUploadLog, uploadLog.String())
+}
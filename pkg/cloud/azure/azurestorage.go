@@ -69,7 +69,7 @@ const PageBlobMaxUploadPagesBytes = 4 * datasizes.MiB
 //
 // Note that if you want to create an image out of the page blob, make sure that metadata.BlobName
 // has a .vhd extension, see EnsureVHDExtension.
-func (c StorageClient) UploadPageBlob(metadata BlobMetadata, fileName string, threads int) error {
+func (c StorageClient) UploadPageBlob(ctx context.Context, metadata BlobMetadata, fileName string, threads int) error {
 	// Create a page blob client.
 	URL, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", metadata.StorageAccount, metadata.ContainerName, metadata.BlobName))
 	client, err := pageblob.NewClientWithSharedKeyCredential(URL.String(), c.credential, nil)
@@ -77,9 +77,6 @@ func (c StorageClient) UploadPageBlob(metadata BlobMetadata, fileName string, th
 		return fmt.Errorf("cannot create a pageblob client: %w", err)
 	}
 
-	// Create the container, use a never-expiring context
-	ctx := context.Background()
-
 	// Open the image file for reading
 	imageFile, err := os.Open(fileName)
 	if err != nil {
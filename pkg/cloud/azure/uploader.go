@@ -2,6 +2,7 @@ package azure
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 
@@ -51,9 +52,7 @@ func (au *azureUploader) Check(status io.Writer) error {
 	return nil
 }
 
-func (au *azureUploader) UploadAndRegister(_ io.Reader, _ uint64, status io.Writer) (*cloud.UploadResult, error) {
-	ctx := context.Background()
-
+func (au *azureUploader) UploadAndRegister(ctx context.Context, _ io.Reader, _ uint64, status io.Writer) (result *cloud.UploadResult, err error) {
 	location, err := au.client.GetResourceGroupLocation(ctx, au.resourceGroup)
 	if err != nil {
 		return nil, err
@@ -93,19 +92,31 @@ func (au *azureUploader) UploadAndRegister(_ io.Reader, _ uint64, status io.Writ
 	}
 
 	blobName := EnsureVHDExtension(au.imageName)
+	blobMetadata := BlobMetadata{
+		StorageAccount: stacc,
+		ContainerName:  uploaderStorageContainer,
+		BlobName:       blobName,
+	}
 	fmt.Fprintf(status, "Uploading %s to Azure...\n", blobName)
-	err = storeClient.UploadPageBlob(
-		BlobMetadata{
-			StorageAccount: stacc,
-			ContainerName:  uploaderStorageContainer,
-			BlobName:       blobName,
-		},
-		au.imagePath,
-		DefaultUploadThreads,
-	)
+	defer func() {
+		// do not leave a partial blob or one that was never
+		// registered behind, this must work with a cancelled
+		// context too
+		if err != nil {
+			if dErr := storeClient.DeleteBlob(context.Background(), blobMetadata); dErr != nil {
+				err = errors.Join(err, dErr)
+			} else {
+				fmt.Fprintf(status, "Deleted blob %s\n", blobName)
+			}
+		}
+	}()
+	err = storeClient.UploadPageBlob(ctx, blobMetadata, au.imagePath, DefaultUploadThreads)
 	if err != nil {
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	switch au.architecture {
 	case arch.ARCH_X86_64:
@@ -128,8 +139,9 @@ func (au *azureUploader) UploadAndRegister(_ io.Reader, _ uint64, status io.Writ
 			au.client.SubscriptionID(), au.resourceGroup, au.imageName)
 		fmt.Fprintf(status, "Image registered: %s\n", imageID)
 		return &cloud.UploadResult{
-			Provider: "azure",
-			ImageID:  imageID,
+			Provider:         "azure",
+			ImageID:          imageID,
+			HyperVGeneration: string(HyperVGenV2),
 		}, nil
 	case arch.ARCH_AARCH64:
 		fmt.Fprintf(status, "Registering gallery image %s...\n", au.imageName)
@@ -149,8 +161,9 @@ func (au *azureUploader) UploadAndRegister(_ io.Reader, _ uint64, status io.Writ
 		}
 		fmt.Fprintf(status, "Gallery image registered: %s\n", gi.ImageRef)
 		return &cloud.UploadResult{
-			Provider: "azure",
-			ImageID:  gi.ImageRef,
+			Provider:         "azure",
+			ImageID:          gi.ImageRef,
+			HyperVGeneration: string(HyperVGenV2),
 		}, nil
 	default:
 		return nil, fmt.Errorf("unsupported architecture %q for Azure upload", au.architecture)
@@ -1,6 +1,7 @@
 package ibmcloud
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -53,7 +54,7 @@ func (iu *ibmcloudUploader) Check(status io.Writer) error {
 	return nil
 }
 
-func (iu *ibmcloudUploader) UploadAndRegister(r io.Reader, uploadSize uint64, status io.Writer) (*cloud.UploadResult, error) {
+func (iu *ibmcloudUploader) UploadAndRegister(ctx context.Context, r io.Reader, uploadSize uint64, status io.Writer) (*cloud.UploadResult, error) {
 	fmt.Fprintf(status, "Uploading to IBM Cloud...\n")
 
 	endpoint := fmt.Sprintf("s3.%s.cloud-object-storage.appdomain.cloud", iu.region)
@@ -72,8 +73,10 @@ func (iu *ibmcloudUploader) UploadAndRegister(r io.Reader, uploadSize uint64, st
 		return nil, fmt.Errorf("Failed to create a session: %w", err)
 	}
 
+	// a failed or cancelled multipart upload is aborted by the
+	// uploader, so nothing is left behind in the bucket
 	uploader := s3manager.NewUploader(session)
-	_, err = uploader.Upload(&s3manager.UploadInput{
+	_, err = uploader.UploadWithContext(ctx, &s3manager.UploadInput{
 		Bucket: aws.String(iu.bucketName),
 		Key:    aws.String(iu.imageName),
 		Body:   r,
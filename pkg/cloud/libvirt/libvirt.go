@@ -3,6 +3,8 @@
 package libvirt
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 
@@ -32,7 +34,7 @@ func (lu *libvirtUploader) Check(status io.Writer) error {
 	return nil
 }
 
-func (lu *libvirtUploader) UploadAndRegister(r io.Reader, uploadSize uint64, status io.Writer) (*cloud.UploadResult, error) {
+func (lu *libvirtUploader) UploadAndRegister(ctx context.Context, r io.Reader, uploadSize uint64, status io.Writer) (*cloud.UploadResult, error) {
 	fmt.Fprintf(status, "Uploading to libvirt...\n")
 
 	conn, err := lv.NewConnect(lu.connection)
@@ -63,9 +65,15 @@ func (lu *libvirtUploader) UploadAndRegister(r io.Reader, uploadSize uint64, sta
 		}
 	}()
 
-	err = lu.Upload(conn, vol, r, uploadSize)
+	err = lu.Upload(ctx, conn, vol, r, uploadSize)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to upload the file to libvirt: %w", err)
+		err = fmt.Errorf("Failed to upload the file to libvirt: %w", err)
+		// do not leave a partial volume behind
+		if dErr := vol.Delete(0); dErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("Failed to delete the volume: %w", dErr))
+		}
+		fmt.Fprintf(status, "Deleted volume %s\n", lu.volume)
+		return nil, err
 	}
 
 	return &cloud.UploadResult{
@@ -84,7 +92,7 @@ func (lu *libvirtUploader) VolumeXML(name string, size uint64) string {
 </volume>`, name, size)
 }
 
-func (lu *libvirtUploader) Upload(conn *lv.Connect, vol *lv.StorageVol, r io.Reader, size uint64) (err error) {
+func (lu *libvirtUploader) Upload(ctx context.Context, conn *lv.Connect, vol *lv.StorageVol, r io.Reader, size uint64) (err error) {
 	stream, err := conn.NewStream(lv.STREAM_NONBLOCK)
 	if err != nil {
 		return fmt.Errorf("Failed to initialize an upload stream: %w", err)
@@ -101,6 +109,12 @@ func (lu *libvirtUploader) Upload(conn *lv.Connect, vol *lv.StorageVol, r io.Rea
 
 	buf := make([]byte, 64*1024)
 	for {
+		if err := ctx.Err(); err != nil {
+			if abortErr := stream.Abort(); abortErr != nil {
+				olog.Printf("Failed to abort stream: %v", abortErr)
+			}
+			return err
+		}
 		n, err := r.Read(buf)
 		if err != nil {
 			if err == io.EOF {
@@ -3,6 +3,7 @@
 package libvirt
 
 import (
+	"context"
 	"fmt"
 	"io"
 
@@ -22,6 +23,6 @@ func (lu *libvirtUploader) Check(status io.Writer) error {
 	return fmt.Errorf("cannot use libvirt: build without cgo")
 }
 
-func (lu *libvirtUploader) UploadAndRegister(r io.Reader, uploadSize uint64, status io.Writer) (*cloud.UploadResult, error) {
+func (lu *libvirtUploader) UploadAndRegister(ctx context.Context, r io.Reader, uploadSize uint64, status io.Writer) (*cloud.UploadResult, error) {
 	return nil, fmt.Errorf("cannot use libvirt: build without cgo")
 }
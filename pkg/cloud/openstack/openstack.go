@@ -2,6 +2,7 @@ package openstack
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -39,7 +40,7 @@ func (ou *openstackUploader) Check(status io.Writer) error {
 	return nil
 }
 
-func (ou *openstackUploader) UploadAndRegister(r io.Reader, uploadSize uint64, status io.Writer) (*cloud.UploadResult, error) {
+func (ou *openstackUploader) UploadAndRegister(ctx context.Context, r io.Reader, uploadSize uint64, status io.Writer) (*cloud.UploadResult, error) {
 	fmt.Fprintf(status, "Uploading to OpenStack...\n")
 
 	opts, err := ostack.AuthOptionsFromEnv()
@@ -57,7 +58,6 @@ func (ou *openstackUploader) UploadAndRegister(r io.Reader, uploadSize uint64, s
 		opts.DomainName = os.Getenv("OS_USER_DOMAIN_NAME")
 	}
 
-	ctx := context.Background()
 	provider, err := ostack.AuthenticatedClient(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to authenticate to OpenStack: %w", err)
@@ -82,7 +82,14 @@ func (ou *openstackUploader) UploadAndRegister(r io.Reader, uploadSize uint64, s
 
 	err = imagedata.Upload(ctx, client, img.ID, r).ExtractErr()
 	if err != nil {
-		return nil, fmt.Errorf("Failed to upload the image: %w", err)
+		err = fmt.Errorf("Failed to upload the image: %w", err)
+		// do not leave the image without data behind, this must
+		// work with a cancelled context too
+		if dErr := images.Delete(context.Background(), client, img.ID).ExtractErr(); dErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("Failed to delete the image: %w", dErr))
+		}
+		fmt.Fprintf(status, "Deleted image %s\n", img.ID)
+		return nil, err
 	}
 
 	return &cloud.UploadResult{
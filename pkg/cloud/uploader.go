@@ -1,6 +1,7 @@
 package cloud
 
 import (
+	"context"
 	"io"
 )
 
@@ -9,6 +10,15 @@ import (
 type UploadResult struct {
 	Provider string `json:"provider" yaml:"provider"`
 	ImageID  string `json:"image_id,omitempty" yaml:"image_id,omitempty"`
+
+	// Details about the image file, for consumers that import the
+	// image themselves (e.g. Azure Stack HCI)
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	Size   uint64 `json:"size,omitempty" yaml:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+
+	// The Hyper-V generation ("V1" or "V2") of vhd and vhdx images
+	HyperVGeneration string `json:"hyperv_generation,omitempty" yaml:"hyperv_generation,omitempty"`
 }
 
 // Uploader is an interface that is returned from the actual
@@ -29,5 +39,7 @@ type Uploader interface {
 	// To implement progress a proxy reader can be used.
 	// For more complex scenarios an optional uploadSize can be
 	// passed.
-	UploadAndRegister(r io.Reader, uploadSize uint64, status io.Writer) (*UploadResult, error)
+	// When the context is cancelled (or the upload fails) the
+	// objects that were staged for the upload are deleted again.
+	UploadAndRegister(ctx context.Context, r io.Reader, uploadSize uint64, status io.Writer) (*UploadResult, error)
 }
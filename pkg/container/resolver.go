@@ -25,6 +25,7 @@ type Resolver interface {
 type asyncResolver struct {
 	jobs  int
 	queue chan resolveResult
+	ctx   context.Context
 
 	Arch         string
 	AuthFilePath string
@@ -42,10 +43,17 @@ type SourceSpec struct {
 
 // XXX: use arch.Arch here?
 func NewResolver(arch string) *asyncResolver {
+	return NewResolverWithContext(context.Background(), arch)
+}
+
+// NewResolverWithContext returns a resolver that stops resolving when the
+// given context is cancelled.
+func NewResolverWithContext(ctx context.Context, arch string) *asyncResolver {
 	// NOTE: this should return the Resolver interface, but osbuild-composer
 	// sets the AuthFilePath and for now we don't want to break the API.
 	return &asyncResolver{
 		queue: make(chan resolveResult, 2),
+		ctx:   ctx,
 		Arch:  arch,
 
 		newClient: NewClient,
@@ -68,7 +76,7 @@ func (r *asyncResolver) Add(spec SourceSpec) {
 	}
 
 	go func() {
-		ctx, cancelTimeout := context.WithTimeout(context.Background(), 60*time.Second)
+		ctx, cancelTimeout := context.WithTimeout(r.ctx, 60*time.Second)
 		defer cancelTimeout()
 		spec, err := client.Resolve(ctx, spec.Name, spec.Local)
 		if err != nil {
@@ -135,6 +143,8 @@ func (r *asyncResolver) ResolveAll(sources map[string][]SourceSpec) (map[string]
 }
 
 type blockingResolver struct {
+	ctx context.Context
+
 	Arch         string
 	AuthFilePath string
 
@@ -147,7 +157,14 @@ type blockingResolver struct {
 // synchronously (blocking).
 // TODO: Make this the only resolver after all clients have migrated to this.
 func NewBlockingResolver(arch string) Resolver {
+	return NewBlockingResolverWithContext(context.Background(), arch)
+}
+
+// NewBlockingResolverWithContext returns a blocking resolver that stops
+// resolving when the given context is cancelled.
+func NewBlockingResolverWithContext(ctx context.Context, arch string) Resolver {
 	return &blockingResolver{
+		ctx:       ctx,
 		Arch:      arch,
 		newClient: NewClient,
 	}
@@ -195,7 +212,7 @@ func (r *blockingResolver) Resolve(source SourceSpec) (Spec, error) {
 		client.SetAuthFilePath(r.AuthFilePath)
 	}
 
-	ctx, cancelTimeout := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancelTimeout := context.WithTimeout(r.ctx, 60*time.Second)
 	defer cancelTimeout()
 	return client.Resolve(ctx, source.Name, source.Local)
 }
@@ -0,0 +1,175 @@
+// Package autoupdate contains the automatic update policy of an image. The
+// policy is implemented with dnf-automatic on package-based images, with
+// the automatic update policy of rpm-ostreed on ostree-based images and
+// with the update timer of bootc on bootc images.
+package autoupdate
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const (
+	// PolicySecurity applies security updates only
+	PolicySecurity = "security"
+	// PolicyDownloadOnly downloads the updates but does not apply them
+	PolicyDownloadOnly = "download-only"
+	// PolicyApply applies all updates
+	PolicyApply = "apply"
+)
+
+// Policies are the supported update policies
+var Policies = []string{PolicySecurity, PolicyDownloadOnly, PolicyApply}
+
+const (
+	DNFAutomaticTimer        = "dnf-automatic.timer"
+	RpmOstreedAutomaticTimer = "rpm-ostreed-automatic.timer"
+	BootcUpdateTimer         = "bootc-fetch-apply-updates.timer"
+
+	rpmOstreedAutomaticService = "rpm-ostreed-automatic.service"
+	bootcUpdateService         = "bootc-fetch-apply-updates.service"
+
+	rpmOstreedConfigPath = "/etc/rpm-ostreed.conf"
+	unitDir              = "/etc/systemd/system"
+	dropInName           = "50-auto-update.conf"
+)
+
+type Options struct {
+	Policy string `json:"policy" yaml:"policy"`
+	// RebootWindow is a systemd calendar expression, e.g. "Sun 03:00",
+	// the updates are applied at that time and the system reboots when an
+	// update requires it
+	RebootWindow string `json:"reboot_window,omitempty" yaml:"reboot_window,omitempty"`
+}
+
+// Validate checks the policy and the reboot window
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if !slices.Contains(Policies, o.Policy) {
+		return fmt.Errorf("unsupported policy %q (must be one of %s)", o.Policy, strings.Join(Policies, ", "))
+	}
+	if o.RebootWindow != "" {
+		if o.Policy == PolicyDownloadOnly {
+			return fmt.Errorf("reboot_window requires a policy that applies updates")
+		}
+		if strings.TrimSpace(o.RebootWindow) != o.RebootWindow || strings.ContainsAny(o.RebootWindow, "\n\r=") {
+			return fmt.Errorf("invalid reboot_window %q", o.RebootWindow)
+		}
+	}
+	return nil
+}
+
+// ValidateImageBased checks that the policy can be implemented on an
+// ostree or bootc image, these update the whole image and cannot select
+// security updates
+func (o *Options) ValidateImageBased() error {
+	if o.Policy == PolicySecurity {
+		return fmt.Errorf("policy %q is only supported on package-based images", o.Policy)
+	}
+	return nil
+}
+
+// nodes collects the directories and files of an update policy
+type nodes struct {
+	dirs  []*fsnode.Directory
+	files []*fsnode.File
+}
+
+// addDropIn adds a drop-in for a unit and its directory
+func (n *nodes) addDropIn(unit, contents string) error {
+	dir := filepath.Join(unitDir, unit+".d")
+	// no mode or owner, so that an existing directory is not an error
+	d, err := fsnode.NewDirectory(dir, nil, nil, nil, true)
+	if err != nil {
+		return err
+	}
+	f, err := fsnode.NewFile(filepath.Join(dir, dropInName), common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(contents))
+	if err != nil {
+		return err
+	}
+	n.dirs = append(n.dirs, d)
+	n.files = append(n.files, f)
+	return nil
+}
+
+// addTimerDropIn moves a timer to the reboot window. The randomized delay
+// of the timer is removed, it could push the update out of the window.
+func (n *nodes) addTimerDropIn(timer, window string) error {
+	return n.addDropIn(timer, fmt.Sprintf("[Timer]\nOnCalendar=\nOnCalendar=%s\nRandomizedDelaySec=0\n", window))
+}
+
+// DNFAutomaticReboot returns the dnf-automatic reboot setting, the system
+// is only rebooted when there is a reboot window
+func (o *Options) DNFAutomaticReboot() string {
+	if o.RebootWindow != "" {
+		return "when-needed"
+	}
+	return "never"
+}
+
+// DNFAutomaticNodes returns the drop-in of the dnf-automatic timer for the
+// reboot window, the dnf-automatic configuration itself is written by the
+// dnf-automatic stage
+func (o *Options) DNFAutomaticNodes() ([]*fsnode.Directory, []*fsnode.File, error) {
+	var n nodes
+	if o.RebootWindow != "" {
+		if err := n.addTimerDropIn(DNFAutomaticTimer, o.RebootWindow); err != nil {
+			return nil, nil, err
+		}
+	}
+	return n.dirs, n.files, nil
+}
+
+// RpmOstreeNodes returns the rpm-ostreed configuration and drop-ins.
+// rpm-ostreed stages the updates, they are deployed on the next boot; to
+// apply them the automatic update service reboots into a staged
+// deployment.
+func (o *Options) RpmOstreeNodes() ([]*fsnode.Directory, []*fsnode.File, error) {
+	conf, err := fsnode.NewFile(rpmOstreedConfigPath, common.ToPtr(fs.FileMode(0644)), "root", "root", []byte("[Daemon]\nAutomaticUpdatePolicy=stage\n"))
+	if err != nil {
+		return nil, nil, err
+	}
+	n := nodes{files: []*fsnode.File{conf}}
+	if o.Policy == PolicyApply {
+		// the service runs rpm-ostree in the foreground, ExecStopPost runs
+		// when it is done; rpm-ostree status exits with 77 when a
+		// deployment is staged
+		reboot := "[Service]\nExecStopPost=/bin/sh -c 'rpm-ostree status --pending-exit-77 >/dev/null; [ $? -ne 77 ] || systemctl reboot'\n"
+		if err := n.addDropIn(rpmOstreedAutomaticService, reboot); err != nil {
+			return nil, nil, err
+		}
+	}
+	if o.RebootWindow != "" {
+		if err := n.addTimerDropIn(RpmOstreedAutomaticTimer, o.RebootWindow); err != nil {
+			return nil, nil, err
+		}
+	}
+	return n.dirs, n.files, nil
+}
+
+// BootcNodes returns the drop-ins of the bootc update timer and service.
+// The service applies the updates and reboots by default, with the
+// download-only policy it only stages them.
+func (o *Options) BootcNodes() ([]*fsnode.Directory, []*fsnode.File, error) {
+	var n nodes
+	if o.Policy == PolicyDownloadOnly {
+		stage := "[Service]\nExecStart=\nExecStart=/usr/bin/bootc upgrade --quiet\n"
+		if err := n.addDropIn(bootcUpdateService, stage); err != nil {
+			return nil, nil, err
+		}
+	}
+	if o.RebootWindow != "" {
+		if err := n.addTimerDropIn(BootcUpdateTimer, o.RebootWindow); err != nil {
+			return nil, nil, err
+		}
+	}
+	return n.dirs, n.files, nil
+}
@@ -0,0 +1,137 @@
+package autoupdate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/autoupdate"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *autoupdate.Options
+		err     string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name:    "security",
+			options: &autoupdate.Options{Policy: "security"},
+		},
+		{
+			name:    "apply-with-window",
+			options: &autoupdate.Options{Policy: "apply", RebootWindow: "Sun *-*-* 03:00"},
+		},
+		{
+			name:    "bad-policy",
+			options: &autoupdate.Options{Policy: "all"},
+			err:     `unsupported policy "all" (must be one of security, download-only, apply)`,
+		},
+		{
+			name:    "download-only-with-window",
+			options: &autoupdate.Options{Policy: "download-only", RebootWindow: "03:00"},
+			err:     "reboot_window requires a policy that applies updates",
+		},
+		{
+			name:    "multiline-window",
+			options: &autoupdate.Options{Policy: "apply", RebootWindow: "03:00\nPersistent=true"},
+			err:     `invalid reboot_window "03:00\nPersistent=true"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateImageBased(t *testing.T) {
+	assert.NoError(t, (&autoupdate.Options{Policy: "apply"}).ValidateImageBased())
+	assert.NoError(t, (&autoupdate.Options{Policy: "download-only"}).ValidateImageBased())
+	assert.EqualError(t, (&autoupdate.Options{Policy: "security"}).ValidateImageBased(), `policy "security" is only supported on package-based images`)
+}
+
+func fileData(files []*fsnode.File) map[string]string {
+	data := map[string]string{}
+	for _, f := range files {
+		data[f.Path()] = string(f.Data())
+	}
+	return data
+}
+
+func dirPaths(dirs []*fsnode.Directory) []string {
+	var paths []string
+	for _, d := range dirs {
+		paths = append(paths, d.Path())
+	}
+	return paths
+}
+
+func TestDNFAutomatic(t *testing.T) {
+	o := &autoupdate.Options{Policy: "security"}
+	assert.Equal(t, "never", o.DNFAutomaticReboot())
+	dirs, files, err := o.DNFAutomaticNodes()
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Empty(t, files)
+
+	o = &autoupdate.Options{Policy: "apply", RebootWindow: "Sun 03:00"}
+	assert.Equal(t, "when-needed", o.DNFAutomaticReboot())
+	dirs, files, err = o.DNFAutomaticNodes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/etc/systemd/system/dnf-automatic.timer.d"}, dirPaths(dirs))
+	assert.Equal(t, map[string]string{
+		"/etc/systemd/system/dnf-automatic.timer.d/50-auto-update.conf": "[Timer]\nOnCalendar=\nOnCalendar=Sun 03:00\nRandomizedDelaySec=0\n",
+	}, fileData(files))
+}
+
+func TestRpmOstreeNodes(t *testing.T) {
+	dirs, files, err := (&autoupdate.Options{Policy: "download-only"}).RpmOstreeNodes()
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Equal(t, map[string]string{
+		"/etc/rpm-ostreed.conf": "[Daemon]\nAutomaticUpdatePolicy=stage\n",
+	}, fileData(files))
+
+	dirs, files, err = (&autoupdate.Options{Policy: "apply", RebootWindow: "04:00"}).RpmOstreeNodes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"/etc/systemd/system/rpm-ostreed-automatic.service.d",
+		"/etc/systemd/system/rpm-ostreed-automatic.timer.d",
+	}, dirPaths(dirs))
+	data := fileData(files)
+	assert.Len(t, data, 3)
+	assert.Contains(t, data["/etc/systemd/system/rpm-ostreed-automatic.service.d/50-auto-update.conf"], "rpm-ostree status --pending-exit-77")
+	assert.Contains(t, data["/etc/systemd/system/rpm-ostreed-automatic.timer.d/50-auto-update.conf"], "OnCalendar=04:00\n")
+}
+
+func TestBootcNodes(t *testing.T) {
+	// the bootc update service applies updates by default
+	dirs, files, err := (&autoupdate.Options{Policy: "apply"}).BootcNodes()
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Empty(t, files)
+
+	dirs, files, err = (&autoupdate.Options{Policy: "download-only"}).BootcNodes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/etc/systemd/system/bootc-fetch-apply-updates.service.d"}, dirPaths(dirs))
+	assert.Equal(t, map[string]string{
+		"/etc/systemd/system/bootc-fetch-apply-updates.service.d/50-auto-update.conf": "[Service]\nExecStart=\nExecStart=/usr/bin/bootc upgrade --quiet\n",
+	}, fileData(files))
+
+	dirs, files, err = (&autoupdate.Options{Policy: "apply", RebootWindow: "Sat 02:00"}).BootcNodes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/etc/systemd/system/bootc-fetch-apply-updates.timer.d"}, dirPaths(dirs))
+	assert.Len(t, files, 1)
+}
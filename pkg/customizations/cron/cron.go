@@ -0,0 +1,127 @@
+// Package cron contains scheduled jobs that are written to /etc/cron.d, one
+// file per job.
+package cron
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const (
+	dropInDir   = "/etc/cron.d"
+	defaultUser = "root"
+)
+
+// Nicknames are the schedules that replace the time and date fields
+var Nicknames = []string{"@reboot", "@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly"}
+
+var (
+	// jobNameRegex matches the file names cron reads from /etc/cron.d,
+	// files with a "." in the name are skipped by some cron
+	// implementations
+	jobNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+	// fieldRegex matches a time or date field, e.g. "*", "*/15", "1-5" or
+	// "mon,wed,fri"
+	fieldRegex    = regexp.MustCompile(`^(\*|[0-9A-Za-z]+(-[0-9A-Za-z]+)?)(/[0-9]+)?(,(\*|[0-9A-Za-z]+(-[0-9A-Za-z]+)?)(/[0-9]+)?)*$`)
+	userNameRegex = regexp.MustCompile(`^[a-z_][a-z0-9_.-]*\$?$`)
+)
+
+type Options struct {
+	Jobs []Job `json:"jobs" yaml:"jobs"`
+}
+
+// Job is a file in /etc/cron.d with a single crontab(5) entry
+type Job struct {
+	Name string `json:"name" yaml:"name"`
+	// Schedule is the five time and date fields, e.g. "*/15 * * * *", or
+	// a nickname like "@daily"
+	Schedule string `json:"schedule" yaml:"schedule"`
+	// User the command runs as, defaults to root
+	User    string `json:"user,omitempty" yaml:"user,omitempty"`
+	Command string `json:"command" yaml:"command"`
+}
+
+// Validate checks the names, schedules and commands of the jobs
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, j := range o.Jobs {
+		if !jobNameRegex.MatchString(j.Name) {
+			return fmt.Errorf("invalid job name %q (must match %s)", j.Name, jobNameRegex.String())
+		}
+		if names[j.Name] {
+			return fmt.Errorf("duplicate job %q", j.Name)
+		}
+		names[j.Name] = true
+		if err := j.validate(); err != nil {
+			return fmt.Errorf("job %q: %w", j.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateSchedule(schedule string) error {
+	if strings.HasPrefix(schedule, "@") {
+		if !slices.Contains(Nicknames, schedule) {
+			return fmt.Errorf("unsupported schedule %q (must be one of %s)", schedule, strings.Join(Nicknames, ", "))
+		}
+		return nil
+	}
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return fmt.Errorf("schedule %q must have five time and date fields", schedule)
+	}
+	for _, field := range fields {
+		if !fieldRegex.MatchString(field) {
+			return fmt.Errorf("invalid field %q in schedule %q", field, schedule)
+		}
+	}
+	return nil
+}
+
+func (j *Job) validate() error {
+	if err := validateSchedule(j.Schedule); err != nil {
+		return err
+	}
+	if j.User != "" && !userNameRegex.MatchString(j.User) {
+		return fmt.Errorf("invalid user %q", j.User)
+	}
+	if strings.TrimSpace(j.Command) == "" {
+		return fmt.Errorf("command is required")
+	}
+	if strings.ContainsAny(j.Command, "\n\r") {
+		return fmt.Errorf("command must be a single line")
+	}
+	// cron turns an unescaped % into a newline
+	if strings.Contains(strings.ReplaceAll(j.Command, `\%`, ""), "%") {
+		return fmt.Errorf(`command %q must escape %% as \%%`, j.Command)
+	}
+	return nil
+}
+
+// Files returns the cron.d files of the jobs
+func (o *Options) Files() ([]*fsnode.File, error) {
+	var files []*fsnode.File
+	for _, j := range o.Jobs {
+		user := j.User
+		if user == "" {
+			user = defaultUser
+		}
+		data := fmt.Sprintf("%s %s %s\n", strings.Join(strings.Fields(j.Schedule), " "), user, j.Command)
+		f, err := fsnode.NewFile(filepath.Join(dropInDir, j.Name), common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
@@ -0,0 +1,103 @@
+package cron_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/cron"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		job  cron.Job
+		err  string
+	}{
+		{
+			name: "fields",
+			job:  cron.Job{Name: "backup", Schedule: "*/15 1-5,22 * jan-jun mon,wed,fri", Command: "/usr/local/bin/backup"},
+		},
+		{
+			name: "nickname",
+			job:  cron.Job{Name: "report", Schedule: "@daily", User: "reports", Command: `date +\%F >> /var/log/report`},
+		},
+		{
+			name: "bad-name",
+			job:  cron.Job{Name: "backup.cron", Schedule: "@daily", Command: "/bin/true"},
+			err:  `invalid job name "backup.cron" (must match ^[A-Za-z0-9_-]{1,100}$)`,
+		},
+		{
+			name: "bad-nickname",
+			job:  cron.Job{Name: "backup", Schedule: "@fortnightly", Command: "/bin/true"},
+			err:  `job "backup": unsupported schedule "@fortnightly" (must be one of @reboot, @yearly, @annually, @monthly, @weekly, @daily, @midnight, @hourly)`,
+		},
+		{
+			name: "too-few-fields",
+			job:  cron.Job{Name: "backup", Schedule: "0 3 * *", Command: "/bin/true"},
+			err:  `job "backup": schedule "0 3 * *" must have five time and date fields`,
+		},
+		{
+			name: "bad-field-syntax",
+			job:  cron.Job{Name: "backup", Schedule: "0 3 ** * *", Command: "/bin/true"},
+			err:  `job "backup": invalid field "**" in schedule "0 3 ** * *"`,
+		},
+		{
+			name: "bad-user",
+			job:  cron.Job{Name: "backup", Schedule: "@daily", User: "Backup User", Command: "/bin/true"},
+			err:  `job "backup": invalid user "Backup User"`,
+		},
+		{
+			name: "no-command",
+			job:  cron.Job{Name: "backup", Schedule: "@daily", Command: " "},
+			err:  `job "backup": command is required`,
+		},
+		{
+			name: "multiline-command",
+			job:  cron.Job{Name: "backup", Schedule: "@daily", Command: "/bin/true\n* * * * * root /bin/sh"},
+			err:  `job "backup": command must be a single line`,
+		},
+		{
+			name: "unescaped-percent",
+			job:  cron.Job{Name: "backup", Schedule: "@daily", Command: "date +%F"},
+			err:  `job "backup": command "date +%F" must escape % as \%`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&cron.Options{Jobs: []cron.Job{tc.job}}).Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOptionsValidateDuplicate(t *testing.T) {
+	var o *cron.Options
+	assert.NoError(t, o.Validate())
+
+	job := cron.Job{Name: "backup", Schedule: "@daily", Command: "/bin/true"}
+	o = &cron.Options{Jobs: []cron.Job{job, job}}
+	assert.EqualError(t, o.Validate(), `duplicate job "backup"`)
+}
+
+func TestOptionsFiles(t *testing.T) {
+	o := &cron.Options{Jobs: []cron.Job{
+		{Name: "backup", Schedule: "0  3 * * *", Command: "/usr/local/bin/backup --full"},
+		{Name: "report", Schedule: "@weekly", User: "reports", Command: "/usr/local/bin/report"},
+	}}
+	files, err := o.Files()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "/etc/cron.d/backup", files[0].Path())
+	assert.Equal(t, fs.FileMode(0644), *files[0].Mode())
+	assert.Equal(t, "0 3 * * * root /usr/local/bin/backup --full\n", string(files[0].Data()))
+	assert.Equal(t, "/etc/cron.d/report", files[1].Path())
+	assert.Equal(t, "@weekly reports /usr/local/bin/report\n", string(files[1].Data()))
+}
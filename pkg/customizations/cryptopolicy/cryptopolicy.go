@@ -0,0 +1,94 @@
+// Package cryptopolicy contains the system-wide crypto policy selection
+// together with custom subpolicy modules.
+package cryptopolicy
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// policyRegex matches a policy with optional subpolicies, e.g. "DEFAULT",
+// "FIPS:OSPP" or "DEFAULT:SHA1:NO-CAMELLIA"
+var policyRegex = regexp.MustCompile(`^[A-Z0-9_-]+(:[A-Z0-9_-]+)*$`)
+
+var moduleNameRegex = regexp.MustCompile(`^[A-Z0-9_-]{1,100}$`)
+
+// moduleLineRegex matches a "key = value" line of a subpolicy module, the
+// key may be scoped, e.g. "cipher@TLS"
+var moduleLineRegex = regexp.MustCompile(`^[\w@.-]+\s*=.*$`)
+
+type Options struct {
+	// Policy is the policy and the subpolicies to apply, separated by
+	// ":", e.g. "FIPS:OSPP"
+	Policy string `json:"policy" yaml:"policy"`
+	// Modules are custom subpolicy modules, each of them needs to be
+	// referenced in the policy
+	Modules []Module `json:"modules,omitempty" yaml:"modules,omitempty"`
+}
+
+// Module is a custom subpolicy module that is written to
+// /etc/crypto-policies/policies/modules/<name>.pmod
+type Module struct {
+	Name     string `json:"name" yaml:"name"`
+	Contents string `json:"contents" yaml:"contents"`
+}
+
+// BasePolicy returns the policy without subpolicies
+func (o *Options) BasePolicy() string {
+	base, _, _ := strings.Cut(o.Policy, ":")
+	return base
+}
+
+// Subpolicies returns the subpolicies applied on top of the base policy
+func (o *Options) Subpolicies() []string {
+	parts := strings.Split(o.Policy, ":")
+	return parts[1:]
+}
+
+func (m *Module) validate() error {
+	lines := 0
+	for idx, line := range strings.Split(m.Contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !moduleLineRegex.MatchString(line) {
+			return fmt.Errorf("line %d: expected \"key = value\", got %q", idx+1, line)
+		}
+		lines++
+	}
+	if lines == 0 {
+		return fmt.Errorf("empty module")
+	}
+	return nil
+}
+
+// Validate checks the policy string and the custom modules
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if !policyRegex.MatchString(o.Policy) {
+		return fmt.Errorf("invalid crypto policy %q (must match %s)", o.Policy, policyRegex.String())
+	}
+	subpolicies := o.Subpolicies()
+	var names []string
+	for _, m := range o.Modules {
+		if !moduleNameRegex.MatchString(m.Name) {
+			return fmt.Errorf("invalid crypto policy module name %q (must match %s)", m.Name, moduleNameRegex.String())
+		}
+		if slices.Contains(names, m.Name) {
+			return fmt.Errorf("duplicate crypto policy module %q", m.Name)
+		}
+		names = append(names, m.Name)
+		if !slices.Contains(subpolicies, m.Name) {
+			return fmt.Errorf("crypto policy module %q is not used by policy %q", m.Name, o.Policy)
+		}
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("crypto policy module %q: %w", m.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package cryptopolicy_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/pkg/customizations/cryptopolicy"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *cryptopolicy.Options
+		err     string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name:    "policy",
+			options: &cryptopolicy.Options{Policy: "FUTURE"},
+		},
+		{
+			name:    "subpolicies",
+			options: &cryptopolicy.Options{Policy: "DEFAULT:SHA1:NO-CAMELLIA"},
+		},
+		{
+			name: "custom-module",
+			options: &cryptopolicy.Options{
+				Policy: "FIPS:OSPP:NO-CBC",
+				Modules: []cryptopolicy.Module{
+					{Name: "NO-CBC", Contents: "# disable CBC ciphers\ncipher = -*-CBC\ncipher@SSH = -AES-128-CBC\n"},
+				},
+			},
+		},
+		{
+			name:    "lowercase-policy",
+			options: &cryptopolicy.Options{Policy: "future"},
+			err:     `invalid crypto policy "future" (must match ^[A-Z0-9_-]+(:[A-Z0-9_-]+)*$)`,
+		},
+		{
+			name:    "empty-subpolicy",
+			options: &cryptopolicy.Options{Policy: "DEFAULT::SHA1"},
+			err:     `invalid crypto policy "DEFAULT::SHA1" (must match ^[A-Z0-9_-]+(:[A-Z0-9_-]+)*$)`,
+		},
+		{
+			name: "bad-module-name",
+			options: &cryptopolicy.Options{
+				Policy:  "DEFAULT:../X",
+				Modules: []cryptopolicy.Module{{Name: "../X", Contents: "cipher = -*-CBC"}},
+			},
+			err: `invalid crypto policy "DEFAULT:../X" (must match ^[A-Z0-9_-]+(:[A-Z0-9_-]+)*$)`,
+		},
+		{
+			name: "duplicate-module",
+			options: &cryptopolicy.Options{
+				Policy: "DEFAULT:NO-CBC",
+				Modules: []cryptopolicy.Module{
+					{Name: "NO-CBC", Contents: "cipher = -*-CBC"},
+					{Name: "NO-CBC", Contents: "cipher = -*-CBC"},
+				},
+			},
+			err: `duplicate crypto policy module "NO-CBC"`,
+		},
+		{
+			name: "unused-module",
+			options: &cryptopolicy.Options{
+				Policy:  "DEFAULT",
+				Modules: []cryptopolicy.Module{{Name: "NO-CBC", Contents: "cipher = -*-CBC"}},
+			},
+			err: `crypto policy module "NO-CBC" is not used by policy "DEFAULT"`,
+		},
+		{
+			name: "invalid-module-line",
+			options: &cryptopolicy.Options{
+				Policy:  "DEFAULT:NO-CBC",
+				Modules: []cryptopolicy.Module{{Name: "NO-CBC", Contents: "# comment\ncipher -*-CBC\n"}},
+			},
+			err: `crypto policy module "NO-CBC": line 2: expected "key = value", got "cipher -*-CBC"`,
+		},
+		{
+			name: "empty-module",
+			options: &cryptopolicy.Options{
+				Policy:  "DEFAULT:NO-CBC",
+				Modules: []cryptopolicy.Module{{Name: "NO-CBC", Contents: "# nothing\n"}},
+			},
+			err: `crypto policy module "NO-CBC": empty module`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOptionsPolicyParts(t *testing.T) {
+	options := &cryptopolicy.Options{Policy: "FIPS:OSPP:NO-CBC"}
+	assert.Equal(t, "FIPS", options.BasePolicy())
+	assert.Equal(t, []string{"OSPP", "NO-CBC"}, options.Subpolicies())
+
+	options = &cryptopolicy.Options{Policy: "DEFAULT"}
+	assert.Equal(t, "DEFAULT", options.BasePolicy())
+	assert.Empty(t, options.Subpolicies())
+}
@@ -0,0 +1,142 @@
+// Package kdump contains the kdump configuration of an image: the memory
+// reserved for the crash kernel and where the vmcore is dumped to.
+package kdump
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const (
+	configPath = "/etc/kdump.conf"
+
+	defaultPath          = "/var/crash"
+	defaultCoreCollector = "makedumpfile -l --message-level 7 -d 31"
+	// dumps over ssh need to be written in the flattened format
+	defaultSSHCoreCollector = "makedumpfile -F -l --message-level 7 -d 31"
+)
+
+// TargetTypes are the dump targets supported by kdump.conf(5)
+var TargetTypes = []string{"btrfs", "ext2", "ext3", "ext4", "nfs", "raw", "ssh", "virtiofs", "xfs"}
+
+// crashKernelRegex matches the crashkernel= syntax of the kernel, e.g.
+// "auto", "256M", "256M@16M" or "1G-4G:192M,4G-64G:256M,64G-:512M"
+var crashKernelRegex = regexp.MustCompile(`^(auto|\d+[KMG](@\d+[KMG])?|\d+[KMG]-(\d+[KMG])?:\d+[KMG](,\d+[KMG]-(\d+[KMG])?:\d+[KMG])*(@\d+[KMG])?)$`)
+
+type Options struct {
+	// CrashKernel is the crashkernel= memory reservation, the distro
+	// default for the architecture is used when empty
+	CrashKernel string `json:"crashkernel,omitempty" yaml:"crashkernel,omitempty"`
+	// Target is where the vmcore is dumped to, the local root filesystem
+	// is used when nil
+	Target *Target `json:"target,omitempty" yaml:"target,omitempty"`
+	// Path is the directory on the target the vmcore is saved in,
+	// defaults to /var/crash
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// CoreCollector is the command that copies the vmcore, defaults to
+	// makedumpfile
+	CoreCollector string `json:"core_collector,omitempty" yaml:"core_collector,omitempty"`
+	// SSHKey is the private key used for ssh targets
+	SSHKey string `json:"sshkey,omitempty" yaml:"sshkey,omitempty"`
+}
+
+// Target is a dump target of kdump.conf(5), e.g. {"nfs", "server:/export"}
+// or {"ssh", "user@server"}
+type Target struct {
+	Type     string `json:"type" yaml:"type"`
+	Location string `json:"location" yaml:"location"`
+}
+
+func isSingleWord(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " \t\n\"'")
+}
+
+func isAbsCleanPath(s string) bool {
+	return isSingleWord(s) && filepath.IsAbs(s) && filepath.Clean(s) == s
+}
+
+func (t *Target) validate() error {
+	if !slices.Contains(TargetTypes, t.Type) {
+		return fmt.Errorf("unsupported target type %q (must be one of %s)", t.Type, strings.Join(TargetTypes, ", "))
+	}
+	if !isSingleWord(t.Location) {
+		return fmt.Errorf("invalid %s target location %q", t.Type, t.Location)
+	}
+	switch t.Type {
+	case "ssh":
+		if user, host, ok := strings.Cut(t.Location, "@"); !ok || user == "" || host == "" {
+			return fmt.Errorf("ssh target location %q must be user@host", t.Location)
+		}
+	case "nfs":
+		if host, path, ok := strings.Cut(t.Location, ":"); !ok || host == "" || !filepath.IsAbs(path) {
+			return fmt.Errorf("nfs target location %q must be host:/path", t.Location)
+		}
+	}
+	return nil
+}
+
+// Validate checks the kdump options
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.CrashKernel != "" && !crashKernelRegex.MatchString(o.CrashKernel) {
+		return fmt.Errorf("invalid crashkernel reservation %q", o.CrashKernel)
+	}
+	if o.Target != nil {
+		if err := o.Target.validate(); err != nil {
+			return err
+		}
+	}
+	if o.Path != "" && !isAbsCleanPath(o.Path) {
+		return fmt.Errorf("path %q must be an absolute, clean path", o.Path)
+	}
+	if strings.ContainsAny(o.CoreCollector, "\n") {
+		return fmt.Errorf("core_collector must be a single line")
+	}
+	if o.SSHKey != "" {
+		if o.Target == nil || o.Target.Type != "ssh" {
+			return fmt.Errorf("sshkey requires an ssh target")
+		}
+		if !isAbsCleanPath(o.SSHKey) {
+			return fmt.Errorf("sshkey %q must be an absolute, clean path", o.SSHKey)
+		}
+	}
+	return nil
+}
+
+// ConfigFile returns the /etc/kdump.conf file for the options
+func (o *Options) ConfigFile() (*fsnode.File, error) {
+	var b strings.Builder
+	if o.Target != nil {
+		fmt.Fprintf(&b, "%s %s\n", o.Target.Type, o.Target.Location)
+	}
+
+	path := o.Path
+	if path == "" {
+		path = defaultPath
+	}
+	fmt.Fprintf(&b, "path %s\n", path)
+
+	coreCollector := o.CoreCollector
+	if coreCollector == "" {
+		coreCollector = defaultCoreCollector
+		if o.Target != nil && o.Target.Type == "ssh" {
+			coreCollector = defaultSSHCoreCollector
+		}
+	}
+	fmt.Fprintf(&b, "core_collector %s\n", coreCollector)
+
+	if o.SSHKey != "" {
+		fmt.Fprintf(&b, "sshkey %s\n", o.SSHKey)
+	}
+
+	return fsnode.NewFile(configPath, common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(b.String()))
+}
@@ -0,0 +1,134 @@
+package kdump_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/kdump"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *kdump.Options
+		err     string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name:    "defaults",
+			options: &kdump.Options{},
+		},
+		{
+			name:    "crashkernel-auto",
+			options: &kdump.Options{CrashKernel: "auto"},
+		},
+		{
+			name:    "crashkernel-offset",
+			options: &kdump.Options{CrashKernel: "256M@16M"},
+		},
+		{
+			name:    "crashkernel-ranges",
+			options: &kdump.Options{CrashKernel: "1G-4G:192M,4G-64G:256M,64G-:512M"},
+		},
+		{
+			name: "ssh",
+			options: &kdump.Options{
+				Target: &kdump.Target{Type: "ssh", Location: "kdump@dump.example.com"},
+				Path:   "/var/crash/vmcores",
+				SSHKey: "/root/.ssh/kdump_id_rsa",
+			},
+		},
+		{
+			name:    "nfs",
+			options: &kdump.Options{Target: &kdump.Target{Type: "nfs", Location: "dump.example.com:/export/crash"}},
+		},
+		{
+			name:    "bad-crashkernel",
+			options: &kdump.Options{CrashKernel: "256MB"},
+			err:     `invalid crashkernel reservation "256MB"`,
+		},
+		{
+			name:    "bad-crashkernel-range",
+			options: &kdump.Options{CrashKernel: "1G-4G:192M,"},
+			err:     `invalid crashkernel reservation "1G-4G:192M,"`,
+		},
+		{
+			name:    "bad-target-type",
+			options: &kdump.Options{Target: &kdump.Target{Type: "ftp", Location: "dump.example.com"}},
+			err:     `unsupported target type "ftp" (must be one of btrfs, ext2, ext3, ext4, nfs, raw, ssh, virtiofs, xfs)`,
+		},
+		{
+			name:    "empty-location",
+			options: &kdump.Options{Target: &kdump.Target{Type: "raw"}},
+			err:     `invalid raw target location ""`,
+		},
+		{
+			name:    "bad-ssh-location",
+			options: &kdump.Options{Target: &kdump.Target{Type: "ssh", Location: "dump.example.com"}},
+			err:     `ssh target location "dump.example.com" must be user@host`,
+		},
+		{
+			name:    "bad-nfs-location",
+			options: &kdump.Options{Target: &kdump.Target{Type: "nfs", Location: "dump.example.com"}},
+			err:     `nfs target location "dump.example.com" must be host:/path`,
+		},
+		{
+			name:    "relative-path",
+			options: &kdump.Options{Path: "var/crash"},
+			err:     `path "var/crash" must be an absolute, clean path`,
+		},
+		{
+			name:    "multiline-core-collector",
+			options: &kdump.Options{CoreCollector: "makedumpfile -d 31\nextra_bins /bin/sh"},
+			err:     "core_collector must be a single line",
+		},
+		{
+			name:    "sshkey-without-ssh",
+			options: &kdump.Options{SSHKey: "/root/.ssh/kdump_id_rsa"},
+			err:     "sshkey requires an ssh target",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOptionsConfigFile(t *testing.T) {
+	f, err := (&kdump.Options{}).ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/kdump.conf", f.Path())
+	assert.Equal(t, fs.FileMode(0644), *f.Mode())
+	assert.Equal(t, "path /var/crash\ncore_collector makedumpfile -l --message-level 7 -d 31\n", string(f.Data()))
+
+	f, err = (&kdump.Options{
+		Target: &kdump.Target{Type: "ssh", Location: "kdump@dump.example.com"},
+		Path:   "/var/crash/vmcores",
+		SSHKey: "/root/.ssh/kdump_id_rsa",
+	}).ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, `ssh kdump@dump.example.com
+path /var/crash/vmcores
+core_collector makedumpfile -F -l --message-level 7 -d 31
+sshkey /root/.ssh/kdump_id_rsa
+`, string(f.Data()))
+
+	f, err = (&kdump.Options{
+		Target:        &kdump.Target{Type: "xfs", Location: "LABEL=crash"},
+		CoreCollector: "makedumpfile -c -d 31",
+	}).ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, "xfs LABEL=crash\npath /var/crash\ncore_collector makedumpfile -c -d 31\n", string(f.Data()))
+}
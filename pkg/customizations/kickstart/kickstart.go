@@ -7,6 +7,7 @@ import (
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
 
+	"github.com/osbuild/image-builder/pkg/customizations/network"
 	"github.com/osbuild/image-builder/pkg/customizations/users"
 )
 
@@ -37,6 +38,10 @@ type Options struct {
 	// Enable networking on on boot in the installed system
 	NetworkOnBoot bool
 
+	// Network connections to configure in the installed system, these
+	// replace the default DHCP configuration
+	Network []network.Connection
+
 	Language *string
 	Keyboard *string
 	Timezone *string
@@ -105,6 +110,13 @@ func (options Options) Validate() error {
 		if len(options.Users)+len(options.Groups) > 0 {
 			return fmt.Errorf("kickstart users and/or groups are not compatible with user-supplied kickstart content")
 		}
+		if len(options.Network) > 0 {
+			return fmt.Errorf("kickstart network connections are not compatible with user-supplied kickstart content")
+		}
+	}
+
+	if err := network.ValidateConnections(options.Network); err != nil {
+		return err
 	}
 
 	// This check repeats the same checks that are made in the kickstart stage
@@ -0,0 +1,167 @@
+// Package logrotate contains logrotate drop-in files that are written to
+// /etc/logrotate.d, one file per drop-in with a single set of log files.
+package logrotate
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const dropInDir = "/etc/logrotate.d"
+
+// Frequencies are the rotation intervals supported by logrotate(8)
+var Frequencies = []string{"hourly", "daily", "weekly", "monthly", "yearly"}
+
+var (
+	// dropInNameRegex matches the file names logrotate reads from an
+	// include directory, names with an extension like ".rpmsave" or
+	// ".disabled" are skipped by logrotate
+	dropInNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+	// sizeRegex matches a logrotate size, e.g. "100", "100k", "10M" or "1G"
+	sizeRegex = regexp.MustCompile(`^[0-9]+[kMG]?$`)
+	// createRegex matches "<mode> [<owner> [<group>]]"
+	createRegex = regexp.MustCompile(`^[0-7]{3,4}( [a-z_][a-z0-9_.-]*( [a-z_][a-z0-9_.-]*)?)?$`)
+)
+
+type Options struct {
+	DropIns []DropIn `json:"drop_ins" yaml:"drop_ins"`
+}
+
+// DropIn is a file in /etc/logrotate.d with the rotation settings of a set
+// of log files
+type DropIn struct {
+	Name string `json:"name" yaml:"name"`
+	// Paths are the log files, they may contain shell globs
+	Paths []string `json:"paths" yaml:"paths"`
+	// Frequency is the rotation interval, the global setting of the
+	// distribution is used when empty
+	Frequency string `json:"frequency,omitempty" yaml:"frequency,omitempty"`
+	// Rotate is the number of rotated logs that are kept
+	Rotate *int `json:"rotate,omitempty" yaml:"rotate,omitempty"`
+	// MaxSize rotates the logs before the interval when they grow larger
+	MaxSize      string `json:"maxsize,omitempty" yaml:"maxsize,omitempty"`
+	Compress     bool   `json:"compress,omitempty" yaml:"compress,omitempty"`
+	MissingOK    bool   `json:"missingok,omitempty" yaml:"missingok,omitempty"`
+	NotIfEmpty   bool   `json:"notifempty,omitempty" yaml:"notifempty,omitempty"`
+	CopyTruncate bool   `json:"copytruncate,omitempty" yaml:"copytruncate,omitempty"`
+	// Create is the "<mode> <owner> <group>" of the log file created after
+	// the rotation
+	Create string `json:"create,omitempty" yaml:"create,omitempty"`
+	// PostRotate is a script that runs after the rotation
+	PostRotate string `json:"postrotate,omitempty" yaml:"postrotate,omitempty"`
+}
+
+// Validate checks the names and the settings of the drop-ins
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, d := range o.DropIns {
+		if !dropInNameRegex.MatchString(d.Name) {
+			return fmt.Errorf("invalid drop-in name %q (must match %s)", d.Name, dropInNameRegex.String())
+		}
+		if names[d.Name] {
+			return fmt.Errorf("duplicate drop-in %q", d.Name)
+		}
+		names[d.Name] = true
+		if err := d.validate(); err != nil {
+			return fmt.Errorf("drop-in %q: %w", d.Name, err)
+		}
+	}
+	return nil
+}
+
+func (d *DropIn) validate() error {
+	if len(d.Paths) == 0 {
+		return fmt.Errorf("no paths")
+	}
+	for _, path := range d.Paths {
+		if !filepath.IsAbs(path) || strings.ContainsAny(path, " \t\n\"'{}") {
+			return fmt.Errorf("path %q must be absolute and must not contain whitespace, quotes or braces", path)
+		}
+	}
+	if d.Frequency != "" && !slices.Contains(Frequencies, d.Frequency) {
+		return fmt.Errorf("unsupported frequency %q (must be one of %s)", d.Frequency, strings.Join(Frequencies, ", "))
+	}
+	if d.Rotate != nil && *d.Rotate < 0 {
+		return fmt.Errorf("rotate must not be negative")
+	}
+	if d.MaxSize != "" && !sizeRegex.MatchString(d.MaxSize) {
+		return fmt.Errorf("invalid maxsize %q (must match %s)", d.MaxSize, sizeRegex.String())
+	}
+	if d.Create != "" && !createRegex.MatchString(d.Create) {
+		return fmt.Errorf("create %q must be \"<mode> [<owner> [<group>]]\"", d.Create)
+	}
+	// with copytruncate the log file is never moved, so there is nothing
+	// to create
+	if d.CopyTruncate && d.Create != "" {
+		return fmt.Errorf("copytruncate and create are mutually exclusive")
+	}
+	for _, line := range strings.Split(d.PostRotate, "\n") {
+		if strings.TrimSpace(line) == "endscript" {
+			return fmt.Errorf("postrotate must not contain endscript")
+		}
+	}
+	return nil
+}
+
+func (d *DropIn) config() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s {\n", strings.Join(d.Paths, " "))
+	if d.Frequency != "" {
+		fmt.Fprintf(&b, "    %s\n", d.Frequency)
+	}
+	if d.Rotate != nil {
+		fmt.Fprintf(&b, "    rotate %d\n", *d.Rotate)
+	}
+	if d.MaxSize != "" {
+		fmt.Fprintf(&b, "    maxsize %s\n", d.MaxSize)
+	}
+	directives := []struct {
+		set  bool
+		name string
+	}{
+		{d.Compress, "compress"},
+		{d.MissingOK, "missingok"},
+		{d.NotIfEmpty, "notifempty"},
+		{d.CopyTruncate, "copytruncate"},
+	}
+	for _, directive := range directives {
+		if directive.set {
+			fmt.Fprintf(&b, "    %s\n", directive.name)
+		}
+	}
+	if d.Create != "" {
+		fmt.Fprintf(&b, "    create %s\n", d.Create)
+	}
+	if d.PostRotate != "" {
+		b.WriteString("    postrotate\n")
+		for _, line := range strings.Split(strings.TrimRight(d.PostRotate, "\n"), "\n") {
+			fmt.Fprintf(&b, "        %s\n", line)
+		}
+		b.WriteString("    endscript\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Files returns the drop-in files
+func (o *Options) Files() ([]*fsnode.File, error) {
+	var files []*fsnode.File
+	for _, d := range o.DropIns {
+		f, err := fsnode.NewFile(filepath.Join(dropInDir, d.Name), common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(d.config()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
@@ -0,0 +1,144 @@
+package logrotate_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/logrotate"
+)
+
+var myapp = logrotate.DropIn{
+	Name:       "myapp",
+	Paths:      []string{"/var/log/myapp/*.log", "/var/log/myapp/audit"},
+	Frequency:  "daily",
+	Rotate:     common.ToPtr(7),
+	MaxSize:    "100M",
+	Compress:   true,
+	MissingOK:  true,
+	NotIfEmpty: true,
+	Create:     "0640 myapp adm",
+	PostRotate: "/usr/bin/systemctl kill -s HUP myapp.service\n",
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(d *logrotate.DropIn)
+		err    string
+	}{
+		{
+			name:   "full",
+			modify: func(d *logrotate.DropIn) {},
+		},
+		{
+			name: "copytruncate",
+			modify: func(d *logrotate.DropIn) {
+				d.Create = ""
+				d.CopyTruncate = true
+				d.Rotate = common.ToPtr(0)
+			},
+		},
+		{
+			name:   "bad-name",
+			modify: func(d *logrotate.DropIn) { d.Name = "myapp.conf" },
+			err:    `invalid drop-in name "myapp.conf" (must match ^[A-Za-z0-9_-]{1,100}$)`,
+		},
+		{
+			name:   "no-paths",
+			modify: func(d *logrotate.DropIn) { d.Paths = nil },
+			err:    `drop-in "myapp": no paths`,
+		},
+		{
+			name:   "relative-path",
+			modify: func(d *logrotate.DropIn) { d.Paths = []string{"myapp.log"} },
+			err:    `drop-in "myapp": path "myapp.log" must be absolute and must not contain whitespace, quotes or braces`,
+		},
+		{
+			name:   "path-with-brace",
+			modify: func(d *logrotate.DropIn) { d.Paths = []string{"/var/log/x { daily }"} },
+			err:    `drop-in "myapp": path "/var/log/x { daily }" must be absolute and must not contain whitespace, quotes or braces`,
+		},
+		{
+			name:   "bad-frequency",
+			modify: func(d *logrotate.DropIn) { d.Frequency = "fortnightly" },
+			err:    `drop-in "myapp": unsupported frequency "fortnightly" (must be one of hourly, daily, weekly, monthly, yearly)`,
+		},
+		{
+			name:   "negative-rotate",
+			modify: func(d *logrotate.DropIn) { d.Rotate = common.ToPtr(-1) },
+			err:    `drop-in "myapp": rotate must not be negative`,
+		},
+		{
+			name:   "bad-maxsize",
+			modify: func(d *logrotate.DropIn) { d.MaxSize = "100MB" },
+			err:    `drop-in "myapp": invalid maxsize "100MB" (must match ^[0-9]+[kMG]?$)`,
+		},
+		{
+			name:   "bad-create",
+			modify: func(d *logrotate.DropIn) { d.Create = "rw-r----- myapp adm" },
+			err:    `drop-in "myapp": create "rw-r----- myapp adm" must be "<mode> [<owner> [<group>]]"`,
+		},
+		{
+			name:   "copytruncate-and-create",
+			modify: func(d *logrotate.DropIn) { d.CopyTruncate = true },
+			err:    `drop-in "myapp": copytruncate and create are mutually exclusive`,
+		},
+		{
+			name:   "postrotate-endscript",
+			modify: func(d *logrotate.DropIn) { d.PostRotate = "true\n  endscript\n/var/log/other {" },
+			err:    `drop-in "myapp": postrotate must not contain endscript`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := myapp
+			tc.modify(&d)
+			err := (&logrotate.Options{DropIns: []logrotate.DropIn{d}}).Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOptionsValidateDuplicate(t *testing.T) {
+	var o *logrotate.Options
+	assert.NoError(t, o.Validate())
+
+	o = &logrotate.Options{DropIns: []logrotate.DropIn{myapp, myapp}}
+	assert.EqualError(t, o.Validate(), `duplicate drop-in "myapp"`)
+}
+
+func TestOptionsFiles(t *testing.T) {
+	o := &logrotate.Options{DropIns: []logrotate.DropIn{
+		myapp,
+		{Name: "minimal", Paths: []string{"/var/log/minimal.log"}},
+	}}
+	files, err := o.Files()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "/etc/logrotate.d/myapp", files[0].Path())
+	assert.Equal(t, fs.FileMode(0644), *files[0].Mode())
+	assert.Equal(t, `/var/log/myapp/*.log /var/log/myapp/audit {
+    daily
+    rotate 7
+    maxsize 100M
+    compress
+    missingok
+    notifempty
+    create 0640 myapp adm
+    postrotate
+        /usr/bin/systemctl kill -s HUP myapp.service
+    endscript
+}
+`, string(files[0].Data()))
+	assert.Equal(t, "/etc/logrotate.d/minimal", files[1].Path())
+	assert.Equal(t, "/var/log/minimal.log {\n}\n", string(files[1].Data()))
+}
@@ -0,0 +1,113 @@
+// Package modprobe contains kernel module configuration files that are
+// written to /etc/modprobe.d.
+package modprobe
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const configDir = "/etc/modprobe.d"
+
+var (
+	// fileNameRegex matches the names of the configuration files
+	// without the ".conf" extension
+	fileNameRegex = regexp.MustCompile(`^[\w.-]{1,245}$`)
+	moduleRegex   = regexp.MustCompile(`^[\w-]{1,100}$`)
+)
+
+type Options struct {
+	Files []File `json:"files" yaml:"files"`
+}
+
+// File is a configuration file in /etc/modprobe.d
+type File struct {
+	Name string `json:"name" yaml:"name"`
+	// Blacklist are the modules that are not loaded automatically
+	Blacklist []string `json:"blacklist,omitempty" yaml:"blacklist,omitempty"`
+	// Install are the commands that run instead of loading a module
+	Install []Install `json:"install,omitempty" yaml:"install,omitempty"`
+}
+
+// Install replaces loading a module with a command, e.g. "/bin/false"
+// to prevent the module from being loaded at all
+type Install struct {
+	Module  string `json:"module" yaml:"module"`
+	Command string `json:"command" yaml:"command"`
+}
+
+// Filename returns the name of the file in /etc/modprobe.d
+func (f *File) Filename() string {
+	return f.Name + ".conf"
+}
+
+// Validate checks the names of the files, modules and the commands
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, f := range o.Files {
+		if !fileNameRegex.MatchString(f.Name) {
+			return fmt.Errorf("invalid file name %q (must match %s)", f.Name, fileNameRegex.String())
+		}
+		if names[f.Name] {
+			return fmt.Errorf("duplicate file %q", f.Name)
+		}
+		names[f.Name] = true
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("file %q: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func (f *File) validate() error {
+	if len(f.Blacklist) == 0 && len(f.Install) == 0 {
+		return fmt.Errorf("no blacklist or install commands")
+	}
+	for _, module := range f.Blacklist {
+		if !moduleRegex.MatchString(module) {
+			return fmt.Errorf("invalid module name %q (must match %s)", module, moduleRegex.String())
+		}
+	}
+	for _, install := range f.Install {
+		if !moduleRegex.MatchString(install.Module) {
+			return fmt.Errorf("invalid module name %q (must match %s)", install.Module, moduleRegex.String())
+		}
+		if strings.TrimSpace(install.Command) == "" {
+			return fmt.Errorf("install %q: command required", install.Module)
+		}
+		if strings.ContainsAny(install.Command, "\n\r") {
+			return fmt.Errorf("install %q: command must not contain newlines", install.Module)
+		}
+	}
+	return nil
+}
+
+// FsNodeFiles returns the configuration files in /etc/modprobe.d, for
+// images that cannot run the modprobe stage
+func (o *Options) FsNodeFiles() ([]*fsnode.File, error) {
+	var files []*fsnode.File
+	for _, f := range o.Files {
+		var b strings.Builder
+		for _, module := range f.Blacklist {
+			fmt.Fprintf(&b, "blacklist %s\n", module)
+		}
+		for _, install := range f.Install {
+			fmt.Fprintf(&b, "install %s %s\n", install.Module, install.Command)
+		}
+		file, err := fsnode.NewFile(path.Join(configDir, f.Filename()), common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(b.String()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,87 @@
+package modprobe_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/modprobe"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		file modprobe.File
+		err  string
+	}{
+		{
+			name: "ok",
+			file: modprobe.File{
+				Name:      "disable-usb-storage",
+				Blacklist: []string{"usb_storage", "uas"},
+				Install:   []modprobe.Install{{Module: "usb_storage", Command: "/bin/false"}},
+			},
+		},
+		{
+			name: "bad-name",
+			file: modprobe.File{Name: "disable usb", Blacklist: []string{"uas"}},
+			err:  `invalid file name "disable usb" (must match ^[\w.-]{1,245}$)`,
+		},
+		{
+			name: "empty",
+			file: modprobe.File{Name: "disable-usb-storage"},
+			err:  `file "disable-usb-storage": no blacklist or install commands`,
+		},
+		{
+			name: "bad-module",
+			file: modprobe.File{Name: "disable-usb-storage", Blacklist: []string{"usb storage"}},
+			err:  `file "disable-usb-storage": invalid module name "usb storage" (must match ^[\w-]{1,100}$)`,
+		},
+		{
+			name: "no-command",
+			file: modprobe.File{Name: "disable-usb-storage", Install: []modprobe.Install{{Module: "usb_storage"}}},
+			err:  `file "disable-usb-storage": install "usb_storage": command required`,
+		},
+		{
+			name: "newline",
+			file: modprobe.File{Name: "disable-usb-storage", Install: []modprobe.Install{{Module: "usb_storage", Command: "/bin/false\nblacklist uas"}}},
+			err:  `file "disable-usb-storage": install "usb_storage": command must not contain newlines`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := &modprobe.Options{Files: []modprobe.File{tc.file}}
+			if tc.err == "" {
+				assert.NoError(t, o.Validate())
+			} else {
+				assert.EqualError(t, o.Validate(), tc.err)
+			}
+		})
+	}
+}
+
+func TestOptionsValidateDuplicate(t *testing.T) {
+	f := modprobe.File{Name: "blacklist", Blacklist: []string{"floppy"}}
+	o := &modprobe.Options{Files: []modprobe.File{f, f}}
+	assert.EqualError(t, o.Validate(), `duplicate file "blacklist"`)
+}
+
+func TestOptionsFsNodeFiles(t *testing.T) {
+	o := &modprobe.Options{
+		Files: []modprobe.File{
+			{
+				Name:      "disable-usb-storage",
+				Blacklist: []string{"usb_storage"},
+				Install:   []modprobe.Install{{Module: "usb_storage", Command: "/bin/false"}},
+			},
+		},
+	}
+	files, err := o.FsNodeFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/etc/modprobe.d/disable-usb-storage.conf", files[0].Path())
+	assert.Equal(t, fs.FileMode(0644), *files[0].Mode())
+	assert.Equal(t, "blacklist usb_storage\ninstall usb_storage /bin/false\n", string(files[0].Data()))
+}
@@ -0,0 +1,349 @@
+// Package network contains the typed NetworkManager connection profiles
+// that are written as keyfiles into the image or translated into kickstart
+// network commands for installers.
+package network
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+)
+
+type ConnectionType string
+
+const (
+	ConnectionTypeEthernet ConnectionType = "ethernet"
+	ConnectionTypeBond     ConnectionType = "bond"
+	ConnectionTypeVLAN     ConnectionType = "vlan"
+	ConnectionTypeBridge   ConnectionType = "bridge"
+)
+
+type IPMethod string
+
+const (
+	IPMethodAuto      IPMethod = "auto"
+	IPMethodManual    IPMethod = "manual"
+	IPMethodLinkLocal IPMethod = "link-local"
+	IPMethodDisabled  IPMethod = "disabled"
+)
+
+// connectionNameRegex limits connection names to what can be used as a
+// file name for the keyfile
+var connectionNameRegex = regexp.MustCompile(`^[\w.-]{1,200}$`)
+
+// interfaceNameRegex follows the kernel rules for interface names
+var interfaceNameRegex = regexp.MustCompile(`^[^/:\s]{1,15}$`)
+
+var bondOptionRegex = regexp.MustCompile(`^[\w-]+$`)
+
+// keyfileValueRegex matches values that can be written to a keyfile
+// without escaping, they are also used in lists separated by ";"
+var keyfileValueRegex = regexp.MustCompile(`^[^;\s]+$`)
+
+var bondModes = []string{
+	"balance-rr",
+	"active-backup",
+	"balance-xor",
+	"broadcast",
+	"802.3ad",
+	"balance-tlb",
+	"balance-alb",
+}
+
+// Options are the connections configured in the image
+type Options struct {
+	Connections []Connection `json:"connections" yaml:"connections"`
+}
+
+// Validate checks the connections
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	return ValidateConnections(o.Connections)
+}
+
+// Connection is a NetworkManager connection profile
+type Connection struct {
+	// Name is the connection id and the name of the keyfile
+	Name string         `json:"name" yaml:"name"`
+	Type ConnectionType `json:"type" yaml:"type"`
+	// Interface is the name of the interface the connection applies
+	// to, required for all but ethernet connections
+	Interface   string `json:"interface,omitempty" yaml:"interface,omitempty"`
+	Autoconnect *bool  `json:"autoconnect,omitempty" yaml:"autoconnect,omitempty"`
+
+	Bond   *Bond   `json:"bond,omitempty" yaml:"bond,omitempty"`
+	VLAN   *VLAN   `json:"vlan,omitempty" yaml:"vlan,omitempty"`
+	Bridge *Bridge `json:"bridge,omitempty" yaml:"bridge,omitempty"`
+
+	IPv4 *IPConfig `json:"ipv4,omitempty" yaml:"ipv4,omitempty"`
+	IPv6 *IPConfig `json:"ipv6,omitempty" yaml:"ipv6,omitempty"`
+}
+
+// Bond configures a bond, the ports are attached to it with their own
+// connection profiles
+type Bond struct {
+	Mode    string            `json:"mode" yaml:"mode"`
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+	Ports   []string          `json:"ports,omitempty" yaml:"ports,omitempty"`
+}
+
+type VLAN struct {
+	ID     uint16 `json:"id" yaml:"id"`
+	Parent string `json:"parent" yaml:"parent"`
+}
+
+// Bridge configures a bridge, the ports are attached to it with their
+// own connection profiles
+type Bridge struct {
+	STP   *bool    `json:"stp,omitempty" yaml:"stp,omitempty"`
+	Ports []string `json:"ports,omitempty" yaml:"ports,omitempty"`
+}
+
+// IPConfig is the IPv4 or IPv6 configuration of a connection, the
+// method defaults to "auto"
+type IPConfig struct {
+	Method IPMethod `json:"method,omitempty" yaml:"method,omitempty"`
+	// Addresses in CIDR notation, e.g. 192.168.1.10/24
+	Addresses []string `json:"addresses,omitempty" yaml:"addresses,omitempty"`
+	Gateway   string   `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	DNS       []string `json:"dns,omitempty" yaml:"dns,omitempty"`
+	DNSSearch []string `json:"dns_search,omitempty" yaml:"dns_search,omitempty"`
+	Routes    []Route  `json:"routes,omitempty" yaml:"routes,omitempty"`
+}
+
+type Route struct {
+	// Destination in CIDR notation, e.g. 10.0.0.0/8
+	Destination string  `json:"destination" yaml:"destination"`
+	Gateway     string  `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	Metric      *uint32 `json:"metric,omitempty" yaml:"metric,omitempty"`
+}
+
+// GetMethod returns the method of the IP configuration, "auto" if unset
+func (ip *IPConfig) GetMethod() IPMethod {
+	if ip == nil || ip.Method == "" {
+		return IPMethodAuto
+	}
+	return ip.Method
+}
+
+func validateAddr(s string, ipv6 bool) error {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return err
+	}
+	if addr.Is6() != ipv6 || addr.Is4In6() {
+		return fmt.Errorf("%q is not an IPv%s address", s, ipFamily(ipv6))
+	}
+	return nil
+}
+
+func validatePrefix(s string, ipv6 bool) error {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return err
+	}
+	if prefix.Addr().Is6() != ipv6 {
+		return fmt.Errorf("%q is not an IPv%s prefix", s, ipFamily(ipv6))
+	}
+	return nil
+}
+
+func ipFamily(ipv6 bool) string {
+	if ipv6 {
+		return "6"
+	}
+	return "4"
+}
+
+func (ip *IPConfig) validate(ipv6 bool) error {
+	switch ip.GetMethod() {
+	case IPMethodManual:
+		if len(ip.Addresses) == 0 {
+			return fmt.Errorf("method %q requires at least one address", IPMethodManual)
+		}
+	case IPMethodAuto, IPMethodLinkLocal, IPMethodDisabled:
+		if len(ip.Addresses) > 0 || ip.Gateway != "" {
+			return fmt.Errorf("addresses and gateway require method %q", IPMethodManual)
+		}
+	default:
+		return fmt.Errorf("unknown method %q", ip.Method)
+	}
+	if ip.GetMethod() == IPMethodDisabled && (len(ip.DNS) > 0 || len(ip.DNSSearch) > 0 || len(ip.Routes) > 0) {
+		return fmt.Errorf("dns and routes cannot be set with method %q", IPMethodDisabled)
+	}
+
+	for _, addr := range ip.Addresses {
+		if err := validatePrefix(addr, ipv6); err != nil {
+			return fmt.Errorf("invalid address: %w", err)
+		}
+	}
+	if ip.Gateway != "" {
+		if err := validateAddr(ip.Gateway, ipv6); err != nil {
+			return fmt.Errorf("invalid gateway: %w", err)
+		}
+	}
+	for _, dns := range ip.DNS {
+		if err := validateAddr(dns, ipv6); err != nil {
+			return fmt.Errorf("invalid dns server: %w", err)
+		}
+	}
+	for _, search := range ip.DNSSearch {
+		if !keyfileValueRegex.MatchString(search) {
+			return fmt.Errorf("invalid dns search domain %q", search)
+		}
+	}
+	for _, route := range ip.Routes {
+		if err := validatePrefix(route.Destination, ipv6); err != nil {
+			return fmt.Errorf("invalid route destination: %w", err)
+		}
+		if route.Gateway != "" {
+			if err := validateAddr(route.Gateway, ipv6); err != nil {
+				return fmt.Errorf("invalid route gateway: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateInterfaceName(name string) error {
+	if !interfaceNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid interface name %q", name)
+	}
+	return nil
+}
+
+// Validate checks that the connection can be written as a keyfile
+func (c *Connection) Validate() error {
+	if !connectionNameRegex.MatchString(c.Name) {
+		return fmt.Errorf("invalid connection name %q (must match %s)", c.Name, connectionNameRegex.String())
+	}
+	if err := c.validate(); err != nil {
+		return fmt.Errorf("connection %q: %w", c.Name, err)
+	}
+	return nil
+}
+
+func (c *Connection) validate() error {
+	switch c.Type {
+	case ConnectionTypeEthernet:
+	case ConnectionTypeBond, ConnectionTypeVLAN, ConnectionTypeBridge:
+		if c.Interface == "" {
+			return fmt.Errorf("%s connections require an interface name", c.Type)
+		}
+	default:
+		return fmt.Errorf("unknown connection type %q", c.Type)
+	}
+	if c.Interface != "" {
+		if err := validateInterfaceName(c.Interface); err != nil {
+			return err
+		}
+	}
+
+	if c.Bond != nil && c.Type != ConnectionTypeBond {
+		return fmt.Errorf("bond settings require type %q", ConnectionTypeBond)
+	}
+	if c.VLAN != nil && c.Type != ConnectionTypeVLAN {
+		return fmt.Errorf("vlan settings require type %q", ConnectionTypeVLAN)
+	}
+	if c.Bridge != nil && c.Type != ConnectionTypeBridge {
+		return fmt.Errorf("bridge settings require type %q", ConnectionTypeBridge)
+	}
+
+	var ports []string
+	switch c.Type {
+	case ConnectionTypeBond:
+		if c.Bond == nil {
+			return fmt.Errorf("bond connections require bond settings")
+		}
+		if !slices.Contains(bondModes, c.Bond.Mode) {
+			return fmt.Errorf("unknown bond mode %q", c.Bond.Mode)
+		}
+		for name, value := range c.Bond.Options {
+			if name == "mode" || !bondOptionRegex.MatchString(name) {
+				return fmt.Errorf("invalid bond option %q", name)
+			}
+			if !keyfileValueRegex.MatchString(value) {
+				return fmt.Errorf("invalid value %q for bond option %q", value, name)
+			}
+		}
+		ports = c.Bond.Ports
+	case ConnectionTypeVLAN:
+		if c.VLAN == nil {
+			return fmt.Errorf("vlan connections require vlan settings")
+		}
+		if c.VLAN.ID == 0 || c.VLAN.ID > 4094 {
+			return fmt.Errorf("vlan id %d out of range (1-4094)", c.VLAN.ID)
+		}
+		if err := validateInterfaceName(c.VLAN.Parent); err != nil {
+			return fmt.Errorf("vlan parent: %w", err)
+		}
+	case ConnectionTypeBridge:
+		if c.Bridge != nil {
+			ports = c.Bridge.Ports
+		}
+	}
+	for _, port := range ports {
+		if err := validateInterfaceName(port); err != nil {
+			return fmt.Errorf("port: %w", err)
+		}
+	}
+
+	if c.IPv4 != nil {
+		if err := c.IPv4.validate(false); err != nil {
+			return fmt.Errorf("ipv4: %w", err)
+		}
+	}
+	if c.IPv6 != nil {
+		if err := c.IPv6.validate(true); err != nil {
+			return fmt.Errorf("ipv6: %w", err)
+		}
+	}
+	return nil
+}
+
+// Ports returns the interfaces attached to a bond or bridge connection
+func (c *Connection) Ports() []string {
+	switch {
+	case c.Bond != nil:
+		return c.Bond.Ports
+	case c.Bridge != nil:
+		return c.Bridge.Ports
+	}
+	return nil
+}
+
+// PortName returns the name of the connection profile that attaches the
+// given port to the connection
+func (c *Connection) PortName(port string) string {
+	return fmt.Sprintf("%s-port-%s", c.Name, port)
+}
+
+// ValidateConnections validates all connections and checks that their
+// names and the names of the generated port profiles are unique
+func ValidateConnections(conns []Connection) error {
+	names := map[string]bool{}
+	addName := func(name string) error {
+		if names[name] {
+			return fmt.Errorf("duplicate connection name %q", name)
+		}
+		names[name] = true
+		return nil
+	}
+	for _, conn := range conns {
+		if err := conn.Validate(); err != nil {
+			return err
+		}
+		if err := addName(conn.Name); err != nil {
+			return err
+		}
+		for _, port := range conn.Ports() {
+			if err := addName(conn.PortName(port)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
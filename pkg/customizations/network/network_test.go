@@ -0,0 +1,204 @@
+package network_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
+)
+
+func TestValidateConnections(t *testing.T) {
+	tests := []struct {
+		name  string
+		conns []network.Connection
+		err   string
+	}{
+		{
+			name: "ethernet-dhcp",
+			conns: []network.Connection{
+				{Name: "eth0", Type: network.ConnectionTypeEthernet},
+			},
+		},
+		{
+			name: "ethernet-static",
+			conns: []network.Connection{
+				{
+					Name:      "eth0",
+					Type:      network.ConnectionTypeEthernet,
+					Interface: "eth0",
+					IPv4: &network.IPConfig{
+						Method:    network.IPMethodManual,
+						Addresses: []string{"192.168.1.10/24"},
+						Gateway:   "192.168.1.1",
+						DNS:       []string{"192.168.1.1"},
+						DNSSearch: []string{"example.com"},
+						Routes: []network.Route{
+							{Destination: "10.0.0.0/8", Gateway: "192.168.1.254", Metric: common.ToPtr(uint32(100))},
+						},
+					},
+					IPv6: &network.IPConfig{
+						Method:    network.IPMethodManual,
+						Addresses: []string{"2001:db8::10/64"},
+						Gateway:   "2001:db8::1",
+					},
+				},
+			},
+		},
+		{
+			name: "bond-vlan-bridge",
+			conns: []network.Connection{
+				{
+					Name:      "bond0",
+					Type:      network.ConnectionTypeBond,
+					Interface: "bond0",
+					Bond: &network.Bond{
+						Mode:    "active-backup",
+						Options: map[string]string{"miimon": "100"},
+						Ports:   []string{"eth0", "eth1"},
+					},
+				},
+				{
+					Name:      "vlan10",
+					Type:      network.ConnectionTypeVLAN,
+					Interface: "bond0.10",
+					VLAN:      &network.VLAN{ID: 10, Parent: "bond0"},
+				},
+				{
+					Name:      "br0",
+					Type:      network.ConnectionTypeBridge,
+					Interface: "br0",
+					Bridge:    &network.Bridge{STP: common.ToPtr(false), Ports: []string{"eth2"}},
+				},
+			},
+		},
+		{
+			name: "bad-name",
+			conns: []network.Connection{
+				{Name: "../eth0", Type: network.ConnectionTypeEthernet},
+			},
+			err: `invalid connection name "../eth0" (must match ^[\w.-]{1,200}$)`,
+		},
+		{
+			name: "bad-type",
+			conns: []network.Connection{
+				{Name: "wlan0", Type: "wifi"},
+			},
+			err: `connection "wlan0": unknown connection type "wifi"`,
+		},
+		{
+			name: "bad-interface",
+			conns: []network.Connection{
+				{Name: "eth0", Type: network.ConnectionTypeEthernet, Interface: "a-very-long-interface"},
+			},
+			err: `connection "eth0": invalid interface name "a-very-long-interface"`,
+		},
+		{
+			name: "bond-without-interface",
+			conns: []network.Connection{
+				{Name: "bond0", Type: network.ConnectionTypeBond, Bond: &network.Bond{Mode: "802.3ad"}},
+			},
+			err: `connection "bond0": bond connections require an interface name`,
+		},
+		{
+			name: "bond-bad-mode",
+			conns: []network.Connection{
+				{Name: "bond0", Type: network.ConnectionTypeBond, Interface: "bond0", Bond: &network.Bond{Mode: "fast"}},
+			},
+			err: `connection "bond0": unknown bond mode "fast"`,
+		},
+		{
+			name: "bond-mode-option",
+			conns: []network.Connection{
+				{
+					Name:      "bond0",
+					Type:      network.ConnectionTypeBond,
+					Interface: "bond0",
+					Bond:      &network.Bond{Mode: "802.3ad", Options: map[string]string{"mode": "balance-rr"}},
+				},
+			},
+			err: `connection "bond0": invalid bond option "mode"`,
+		},
+		{
+			name: "bond-settings-on-ethernet",
+			conns: []network.Connection{
+				{Name: "eth0", Type: network.ConnectionTypeEthernet, Bond: &network.Bond{Mode: "802.3ad"}},
+			},
+			err: `connection "eth0": bond settings require type "bond"`,
+		},
+		{
+			name: "vlan-bad-id",
+			conns: []network.Connection{
+				{Name: "vlan0", Type: network.ConnectionTypeVLAN, Interface: "eth0.0", VLAN: &network.VLAN{ID: 0, Parent: "eth0"}},
+			},
+			err: `connection "vlan0": vlan id 0 out of range (1-4094)`,
+		},
+		{
+			name: "manual-without-address",
+			conns: []network.Connection{
+				{Name: "eth0", Type: network.ConnectionTypeEthernet, IPv4: &network.IPConfig{Method: network.IPMethodManual}},
+			},
+			err: `connection "eth0": ipv4: method "manual" requires at least one address`,
+		},
+		{
+			name: "auto-with-address",
+			conns: []network.Connection{
+				{Name: "eth0", Type: network.ConnectionTypeEthernet, IPv4: &network.IPConfig{Addresses: []string{"192.168.1.10/24"}}},
+			},
+			err: `connection "eth0": ipv4: addresses and gateway require method "manual"`,
+		},
+		{
+			name: "ipv6-address-in-ipv4",
+			conns: []network.Connection{
+				{
+					Name: "eth0",
+					Type: network.ConnectionTypeEthernet,
+					IPv4: &network.IPConfig{Method: network.IPMethodManual, Addresses: []string{"2001:db8::10/64"}},
+				},
+			},
+			err: `connection "eth0": ipv4: invalid address: "2001:db8::10/64" is not an IPv4 prefix`,
+		},
+		{
+			name: "bad-dns",
+			conns: []network.Connection{
+				{Name: "eth0", Type: network.ConnectionTypeEthernet, IPv6: &network.IPConfig{DNS: []string{"192.168.1.1"}}},
+			},
+			err: `connection "eth0": ipv6: invalid dns server: "192.168.1.1" is not an IPv6 address`,
+		},
+		{
+			name: "disabled-with-dns",
+			conns: []network.Connection{
+				{Name: "eth0", Type: network.ConnectionTypeEthernet, IPv6: &network.IPConfig{Method: network.IPMethodDisabled, DNS: []string{"2001:db8::1"}}},
+			},
+			err: `connection "eth0": ipv6: dns and routes cannot be set with method "disabled"`,
+		},
+		{
+			name: "duplicate-name",
+			conns: []network.Connection{
+				{Name: "eth0", Type: network.ConnectionTypeEthernet},
+				{Name: "eth0", Type: network.ConnectionTypeEthernet},
+			},
+			err: `duplicate connection name "eth0"`,
+		},
+		{
+			name: "duplicate-port-name",
+			conns: []network.Connection{
+				{Name: "br0-port-eth0", Type: network.ConnectionTypeEthernet},
+				{Name: "br0", Type: network.ConnectionTypeBridge, Interface: "br0", Bridge: &network.Bridge{Ports: []string{"eth0"}}},
+			},
+			err: `duplicate connection name "br0-port-eth0"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := network.ValidateConnections(tc.conns)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Package selinux contains the SELinux policy customizations that are
+// applied to the image tree at build time: booleans, custom policy modules,
+// file contexts and port labels.
+package selinux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type ModuleType string
+
+const (
+	ModuleTypePP  ModuleType = "pp"
+	ModuleTypeCIL ModuleType = "cil"
+)
+
+// moduleNameRegex follows the rules of semodule for module names
+var moduleNameRegex = regexp.MustCompile(`^[A-Za-z][\w-]{0,199}$`)
+
+// identifierRegex matches SELinux boolean and type names
+var identifierRegex = regexp.MustCompile(`^[A-Za-z]\w{0,199}$`)
+
+var portRegex = regexp.MustCompile(`^\d{1,5}(-\d{1,5})?$`)
+
+// fileTypes are the file types accepted by "semanage fcontext -f"
+var fileTypes = []string{"a", "f", "d", "c", "b", "s", "l", "p"}
+
+var protocols = []string{"tcp", "udp", "sctp", "dccp"}
+
+// ppMagic is the magic number of a compiled policy package, bzip2
+// compressed packages are accepted as well
+const ppMagic = 0xf97cff8f
+
+type Options struct {
+	Booleans     map[string]bool `json:"booleans,omitempty" yaml:"booleans,omitempty"`
+	Modules      []Module        `json:"modules,omitempty" yaml:"modules,omitempty"`
+	FileContexts []FileContext   `json:"file_contexts,omitempty" yaml:"file_contexts,omitempty"`
+	Ports        []Port          `json:"ports,omitempty" yaml:"ports,omitempty"`
+}
+
+// Module is a custom policy module that is installed with semodule
+type Module struct {
+	Name string     `json:"name" yaml:"name"`
+	Type ModuleType `json:"type" yaml:"type"`
+	// Contents of the .pp or .cil file
+	Contents []byte `json:"contents" yaml:"contents"`
+	// Priority of the module, semodule uses 400 if unset
+	Priority *uint16 `json:"priority,omitempty" yaml:"priority,omitempty"`
+}
+
+// FileContext is a local file context rule as added by
+// "semanage fcontext -a"
+type FileContext struct {
+	// Path is a regular expression matching the paths
+	Path string `json:"path" yaml:"path"`
+	Type string `json:"type" yaml:"type"`
+	// FileType restricts the rule to a file type ("f", "d", ...), it
+	// applies to all file types if unset
+	FileType string `json:"file_type,omitempty" yaml:"file_type,omitempty"`
+}
+
+// Port is a local port label as added by "semanage port -a"
+type Port struct {
+	Protocol string `json:"protocol" yaml:"protocol"`
+	// Port is a single port or a range, e.g. "8080" or "8000-8010"
+	Port string `json:"port" yaml:"port"`
+	Type string `json:"type" yaml:"type"`
+}
+
+// Filename returns the file name of the module with the extension that
+// semodule uses to detect the module type
+func (m *Module) Filename() string {
+	return fmt.Sprintf("%s.%s", m.Name, m.Type)
+}
+
+func (m *Module) validate() error {
+	if len(m.Contents) == 0 {
+		return fmt.Errorf("empty module")
+	}
+	switch m.Type {
+	case ModuleTypePP:
+		if bytes.HasPrefix(m.Contents, []byte("BZh")) {
+			return nil
+		}
+		if len(m.Contents) < 4 || binary.LittleEndian.Uint32(m.Contents) != ppMagic {
+			return fmt.Errorf("not a compiled policy package")
+		}
+	case ModuleTypeCIL:
+		return validateCIL(string(m.Contents))
+	default:
+		return fmt.Errorf("unknown module type %q", m.Type)
+	}
+	return nil
+}
+
+// validateCIL checks that the module consists of balanced CIL statements,
+// the policy itself is checked by semodule when the module is installed
+func validateCIL(src string) error {
+	depth := 0
+	statements := 0
+	inString := false
+	for _, line := range strings.Split(src, "\n") {
+		for _, c := range line {
+			if inString {
+				if c == '"' {
+					inString = false
+				}
+				continue
+			}
+			if c == ';' {
+				break
+			}
+			switch c {
+			case '"':
+				inString = true
+			case '(':
+				if depth == 0 {
+					statements++
+				}
+				depth++
+			case ')':
+				depth--
+				if depth < 0 {
+					return fmt.Errorf("unbalanced parentheses in CIL module")
+				}
+			case ' ', '\t', '\r':
+			default:
+				if depth == 0 {
+					return fmt.Errorf("unexpected %q outside of a CIL statement", c)
+				}
+			}
+		}
+	}
+	if inString {
+		return fmt.Errorf("unterminated string in CIL module")
+	}
+	if depth != 0 {
+		return fmt.Errorf("unbalanced parentheses in CIL module")
+	}
+	if statements == 0 {
+		return fmt.Errorf("no statements in CIL module")
+	}
+	return nil
+}
+
+func (fc *FileContext) validate() error {
+	if !strings.HasPrefix(fc.Path, "/") || strings.ContainsAny(fc.Path, " \t\n") {
+		return fmt.Errorf("invalid path %q", fc.Path)
+	}
+	if _, err := regexp.Compile(fc.Path); err != nil {
+		return fmt.Errorf("invalid path %q: %w", fc.Path, err)
+	}
+	if !identifierRegex.MatchString(fc.Type) {
+		return fmt.Errorf("invalid type %q", fc.Type)
+	}
+	if fc.FileType != "" && !slices.Contains(fileTypes, fc.FileType) {
+		return fmt.Errorf("invalid file type %q (must be one of %s)", fc.FileType, strings.Join(fileTypes, ", "))
+	}
+	return nil
+}
+
+func (p *Port) validate() error {
+	if !slices.Contains(protocols, p.Protocol) {
+		return fmt.Errorf("invalid protocol %q (must be one of %s)", p.Protocol, strings.Join(protocols, ", "))
+	}
+	if !portRegex.MatchString(p.Port) {
+		return fmt.Errorf("invalid port %q", p.Port)
+	}
+	low, high, _ := p.Range()
+	if low == 0 || high > 65535 || low > high {
+		return fmt.Errorf("invalid port %q", p.Port)
+	}
+	if !identifierRegex.MatchString(p.Type) {
+		return fmt.Errorf("invalid type %q", p.Type)
+	}
+	return nil
+}
+
+// Range returns the first and last port of the port label
+func (p *Port) Range() (uint64, uint64, error) {
+	lowStr, highStr, isRange := strings.Cut(p.Port, "-")
+	low, err := strconv.ParseUint(lowStr, 10, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return low, low, nil
+	}
+	high, err := strconv.ParseUint(highStr, 10, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	return low, high, nil
+}
+
+// Validate checks the booleans, modules, file contexts and ports
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(o.Booleans)) {
+		if !identifierRegex.MatchString(name) {
+			return fmt.Errorf("invalid SELinux boolean name %q", name)
+		}
+	}
+	names := map[string]bool{}
+	for _, m := range o.Modules {
+		if !moduleNameRegex.MatchString(m.Name) {
+			return fmt.Errorf("invalid SELinux module name %q (must match %s)", m.Name, moduleNameRegex.String())
+		}
+		if names[m.Name] {
+			return fmt.Errorf("duplicate SELinux module %q", m.Name)
+		}
+		names[m.Name] = true
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("SELinux module %q: %w", m.Name, err)
+		}
+	}
+	for _, fc := range o.FileContexts {
+		if err := fc.validate(); err != nil {
+			return fmt.Errorf("SELinux file context: %w", err)
+		}
+	}
+	for _, p := range o.Ports {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("SELinux port: %w", err)
+		}
+	}
+	return nil
+}
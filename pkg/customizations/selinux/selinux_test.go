@@ -0,0 +1,127 @@
+package selinux_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/pkg/customizations/selinux"
+)
+
+// the header of a compiled policy package
+var ppHeader = []byte{0x8f, 0xff, 0x7c, 0xf9, 0x01, 0x00, 0x00, 0x00}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *selinux.Options
+		err     string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "valid",
+			options: &selinux.Options{
+				Booleans: map[string]bool{"httpd_can_network_connect": true},
+				Modules: []selinux.Module{
+					{Name: "mywebapp", Type: selinux.ModuleTypePP, Contents: ppHeader},
+					{Name: "mywebapp-bz2", Type: selinux.ModuleTypePP, Contents: []byte("BZh91AY&SY")},
+					{Name: "local_rules", Type: selinux.ModuleTypeCIL, Contents: []byte("; allow the web app\n(allow httpd_t var_t (file (read open)))\n(typeattributeset cil_gen_require \"httpd_t\")\n")},
+				},
+				FileContexts: []selinux.FileContext{
+					{Path: "/srv/web(/.*)?", Type: "httpd_sys_content_t"},
+					{Path: "/srv/web/run", Type: "httpd_var_run_t", FileType: "d"},
+				},
+				Ports: []selinux.Port{
+					{Protocol: "tcp", Port: "8443", Type: "http_port_t"},
+					{Protocol: "udp", Port: "8000-8010", Type: "http_port_t"},
+				},
+			},
+		},
+		{
+			name:    "bad-boolean",
+			options: &selinux.Options{Booleans: map[string]bool{"httpd can": true}},
+			err:     `invalid SELinux boolean name "httpd can"`,
+		},
+		{
+			name:    "bad-module-name",
+			options: &selinux.Options{Modules: []selinux.Module{{Name: "../mod", Type: selinux.ModuleTypePP, Contents: ppHeader}}},
+			err:     `invalid SELinux module name "../mod" (must match ^[A-Za-z][\w-]{0,199}$)`,
+		},
+		{
+			name: "duplicate-module",
+			options: &selinux.Options{Modules: []selinux.Module{
+				{Name: "mod", Type: selinux.ModuleTypePP, Contents: ppHeader},
+				{Name: "mod", Type: selinux.ModuleTypeCIL, Contents: []byte("(allow a b (file (read)))")},
+			}},
+			err: `duplicate SELinux module "mod"`,
+		},
+		{
+			name:    "bad-module-type",
+			options: &selinux.Options{Modules: []selinux.Module{{Name: "mod", Type: "te", Contents: []byte("module mod 1.0;")}}},
+			err:     `SELinux module "mod": unknown module type "te"`,
+		},
+		{
+			name:    "empty-module",
+			options: &selinux.Options{Modules: []selinux.Module{{Name: "mod", Type: selinux.ModuleTypePP}}},
+			err:     `SELinux module "mod": empty module`,
+		},
+		{
+			name:    "pp-not-compiled",
+			options: &selinux.Options{Modules: []selinux.Module{{Name: "mod", Type: selinux.ModuleTypePP, Contents: []byte("module mod 1.0;")}}},
+			err:     `SELinux module "mod": not a compiled policy package`,
+		},
+		{
+			name:    "cil-unbalanced",
+			options: &selinux.Options{Modules: []selinux.Module{{Name: "mod", Type: selinux.ModuleTypeCIL, Contents: []byte("(allow a b (file (read))")}}},
+			err:     `SELinux module "mod": unbalanced parentheses in CIL module`,
+		},
+		{
+			name:    "cil-not-a-statement",
+			options: &selinux.Options{Modules: []selinux.Module{{Name: "mod", Type: selinux.ModuleTypeCIL, Contents: []byte("allow a b")}}},
+			err:     `SELinux module "mod": unexpected 'a' outside of a CIL statement`,
+		},
+		{
+			name:    "cil-only-comments",
+			options: &selinux.Options{Modules: []selinux.Module{{Name: "mod", Type: selinux.ModuleTypeCIL, Contents: []byte("; nothing here\n")}}},
+			err:     `SELinux module "mod": no statements in CIL module`,
+		},
+		{
+			name:    "fcontext-relative-path",
+			options: &selinux.Options{FileContexts: []selinux.FileContext{{Path: "srv/web", Type: "httpd_sys_content_t"}}},
+			err:     `SELinux file context: invalid path "srv/web"`,
+		},
+		{
+			name:    "fcontext-bad-file-type",
+			options: &selinux.Options{FileContexts: []selinux.FileContext{{Path: "/srv/web", Type: "httpd_sys_content_t", FileType: "x"}}},
+			err:     `SELinux file context: invalid file type "x" (must be one of a, f, d, c, b, s, l, p)`,
+		},
+		{
+			name:    "port-bad-protocol",
+			options: &selinux.Options{Ports: []selinux.Port{{Protocol: "icmp", Port: "1", Type: "http_port_t"}}},
+			err:     `SELinux port: invalid protocol "icmp" (must be one of tcp, udp, sctp, dccp)`,
+		},
+		{
+			name:    "port-out-of-range",
+			options: &selinux.Options{Ports: []selinux.Port{{Protocol: "tcp", Port: "8000-70000", Type: "http_port_t"}}},
+			err:     `SELinux port: invalid port "8000-70000"`,
+		},
+		{
+			name:    "port-reversed-range",
+			options: &selinux.Options{Ports: []selinux.Port{{Protocol: "tcp", Port: "8010-8000", Type: "http_port_t"}}},
+			err:     `SELinux port: invalid port "8010-8000"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
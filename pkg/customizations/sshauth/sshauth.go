@@ -0,0 +1,193 @@
+// Package sshauth contains the sshd authentication policy that goes beyond
+// the authorized keys of the users: trusted user certificate authorities,
+// the principals each user accepts in a certificate and a command that
+// looks up authorized keys.
+package sshauth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const (
+	TrustedUserCAKeysPath = "/etc/ssh/trusted_user_ca_keys"
+	principalsDir         = "/etc/ssh/auth_principals"
+	// AuthorizedPrincipalsFile is the sshd setting for the principals
+	// files, %u is replaced with the name of the user
+	AuthorizedPrincipalsFile = principalsDir + "/%u"
+
+	sshdConfigDropInDir = "/etc/ssh/sshd_config.d"
+	// sshdConfigDropInPath sorts before the distro drop-ins, sshd uses
+	// the first value it reads for a setting
+	sshdConfigDropInPath = sshdConfigDropInDir + "/40-ssh-auth.conf"
+)
+
+// KeyTypes are the public key types that can sign user certificates
+var KeyTypes = []string{
+	"ecdsa-sha2-nistp256",
+	"ecdsa-sha2-nistp384",
+	"ecdsa-sha2-nistp521",
+	"sk-ecdsa-sha2-nistp256@openssh.com",
+	"sk-ssh-ed25519@openssh.com",
+	"ssh-ed25519",
+	"ssh-rsa",
+}
+
+var userNameRegex = regexp.MustCompile(`^[a-z_][a-z0-9_.-]*\$?$`)
+
+type Options struct {
+	// TrustedUserCAKeys are the public keys of the certificate authorities
+	// that sign user certificates, in authorized_keys format
+	TrustedUserCAKeys []string `json:"trusted_user_ca_keys,omitempty" yaml:"trusted_user_ca_keys,omitempty"`
+	// AuthorizedPrincipals are the principals accepted in a certificate,
+	// by user name. When set, users that are not listed cannot log in with
+	// a certificate; when empty, a certificate needs to be issued for the
+	// name of the user.
+	AuthorizedPrincipals map[string][]string `json:"authorized_principals,omitempty" yaml:"authorized_principals,omitempty"`
+	// AuthorizedKeysCommand is a program that prints the authorized keys
+	// of a user
+	AuthorizedKeysCommand string `json:"authorized_keys_command,omitempty" yaml:"authorized_keys_command,omitempty"`
+	// AuthorizedKeysCommandUser is the user the command runs as
+	AuthorizedKeysCommandUser string `json:"authorized_keys_command_user,omitempty" yaml:"authorized_keys_command_user,omitempty"`
+}
+
+// principalUsers returns the users with authorized principals, sorted
+func (o *Options) principalUsers() []string {
+	return slices.Sorted(maps.Keys(o.AuthorizedPrincipals))
+}
+
+func validateCAKey(key string) error {
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return fmt.Errorf("CA key %q must be \"<type> <base64 key> [comment]\"", key)
+	}
+	if !slices.Contains(KeyTypes, fields[0]) {
+		return fmt.Errorf("unsupported CA key type %q (must be one of %s)", fields[0], strings.Join(KeyTypes, ", "))
+	}
+	if _, err := base64.StdEncoding.DecodeString(fields[1]); err != nil {
+		return fmt.Errorf("CA key of type %s is not valid base64: %w", fields[0], err)
+	}
+	if strings.Contains(key, "\n") {
+		return fmt.Errorf("CA key of type %s must be a single line", fields[0])
+	}
+	return nil
+}
+
+// Validate checks the CA keys, the principals and the authorized keys
+// command
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	for _, key := range o.TrustedUserCAKeys {
+		if err := validateCAKey(key); err != nil {
+			return err
+		}
+	}
+	if len(o.AuthorizedPrincipals) > 0 && len(o.TrustedUserCAKeys) == 0 {
+		return fmt.Errorf("authorized_principals requires trusted_user_ca_keys")
+	}
+	for _, user := range o.principalUsers() {
+		principals := o.AuthorizedPrincipals[user]
+		if !userNameRegex.MatchString(user) {
+			return fmt.Errorf("invalid user name %q in authorized_principals", user)
+		}
+		if len(principals) == 0 {
+			return fmt.Errorf("no principals for user %q", user)
+		}
+		for _, p := range principals {
+			if p == "" || strings.ContainsAny(p, " \t\n,") {
+				return fmt.Errorf("invalid principal %q for user %q", p, user)
+			}
+		}
+	}
+	if o.AuthorizedKeysCommand != "" {
+		path, _, _ := strings.Cut(o.AuthorizedKeysCommand, " ")
+		if !filepath.IsAbs(path) || strings.Contains(o.AuthorizedKeysCommand, "\n") {
+			return fmt.Errorf("authorized_keys_command %q must be an absolute path with optional arguments", o.AuthorizedKeysCommand)
+		}
+		// sshd refuses to run the command without a user
+		if o.AuthorizedKeysCommandUser == "" {
+			return fmt.Errorf("authorized_keys_command requires authorized_keys_command_user")
+		}
+	}
+	if o.AuthorizedKeysCommandUser != "" {
+		if o.AuthorizedKeysCommand == "" {
+			return fmt.Errorf("authorized_keys_command_user requires authorized_keys_command")
+		}
+		if !userNameRegex.MatchString(o.AuthorizedKeysCommandUser) {
+			return fmt.Errorf("invalid authorized_keys_command_user %q", o.AuthorizedKeysCommandUser)
+		}
+	}
+	return nil
+}
+
+// Files returns the trusted CA keys file and a principals file per user
+func (o *Options) Files() ([]*fsnode.File, error) {
+	var files []*fsnode.File
+	if len(o.TrustedUserCAKeys) > 0 {
+		data := strings.Join(o.TrustedUserCAKeys, "\n") + "\n"
+		f, err := fsnode.NewFile(TrustedUserCAKeysPath, common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	for _, user := range o.principalUsers() {
+		data := strings.Join(o.AuthorizedPrincipals[user], "\n") + "\n"
+		f, err := fsnode.NewFile(filepath.Join(principalsDir, user), common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// Directories returns the directory of the principals files
+func (o *Options) Directories() ([]*fsnode.Directory, error) {
+	if len(o.AuthorizedPrincipals) == 0 {
+		return nil, nil
+	}
+	d, err := fsnode.NewDirectory(principalsDir, common.ToPtr(fs.FileMode(0755)), "root", "root", false)
+	if err != nil {
+		return nil, err
+	}
+	return []*fsnode.Directory{d}, nil
+}
+
+// SshdConfigDropIn returns the sshd drop-in with the settings that point
+// sshd to the files, for images that cannot run the sshd config stage
+func (o *Options) SshdConfigDropIn() (*fsnode.Directory, *fsnode.File, error) {
+	var b strings.Builder
+	if len(o.TrustedUserCAKeys) > 0 {
+		fmt.Fprintf(&b, "TrustedUserCAKeys %s\n", TrustedUserCAKeysPath)
+	}
+	if len(o.AuthorizedPrincipals) > 0 {
+		fmt.Fprintf(&b, "AuthorizedPrincipalsFile %s\n", AuthorizedPrincipalsFile)
+	}
+	if o.AuthorizedKeysCommand != "" {
+		fmt.Fprintf(&b, "AuthorizedKeysCommand %s\n", o.AuthorizedKeysCommand)
+		fmt.Fprintf(&b, "AuthorizedKeysCommandUser %s\n", o.AuthorizedKeysCommandUser)
+	}
+	// no mode or owner, so that an existing directory is not an error
+	d, err := fsnode.NewDirectory(sshdConfigDropInDir, nil, nil, nil, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := fsnode.NewFile(sshdConfigDropInPath, common.ToPtr(fs.FileMode(0600)), "root", "root", []byte(b.String()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return d, f, nil
+}
@@ -0,0 +1,159 @@
+package sshauth_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/sshauth"
+)
+
+const caKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMZ4b0kJvIYSyqRhzD0y3WjKUpN1HhkJTjbIBtUc6mhN user-ca@example.com"
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *sshauth.Options
+		err     string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "ca-and-principals",
+			options: &sshauth.Options{
+				TrustedUserCAKeys:    []string{caKey},
+				AuthorizedPrincipals: map[string][]string{"root": {"admins", "oncall"}},
+			},
+		},
+		{
+			name: "authorized-keys-command",
+			options: &sshauth.Options{
+				AuthorizedKeysCommand:     "/usr/bin/sss_ssh_authorizedkeys %u",
+				AuthorizedKeysCommandUser: "nobody",
+			},
+		},
+		{
+			name:    "bad-key-type",
+			options: &sshauth.Options{TrustedUserCAKeys: []string{"ssh-dss AAAAB3NzaC1kc3M="}},
+			err:     `unsupported CA key type "ssh-dss" (must be one of ecdsa-sha2-nistp256, ecdsa-sha2-nistp384, ecdsa-sha2-nistp521, sk-ecdsa-sha2-nistp256@openssh.com, sk-ssh-ed25519@openssh.com, ssh-ed25519, ssh-rsa)`,
+		},
+		{
+			name:    "bad-key-data",
+			options: &sshauth.Options{TrustedUserCAKeys: []string{"ssh-ed25519 not-base64!"}},
+			err:     "CA key of type ssh-ed25519 is not valid base64: illegal base64 data at input byte 3",
+		},
+		{
+			name:    "key-without-data",
+			options: &sshauth.Options{TrustedUserCAKeys: []string{"ssh-ed25519"}},
+			err:     `CA key "ssh-ed25519" must be "<type> <base64 key> [comment]"`,
+		},
+		{
+			name:    "principals-without-ca",
+			options: &sshauth.Options{AuthorizedPrincipals: map[string][]string{"root": {"admins"}}},
+			err:     "authorized_principals requires trusted_user_ca_keys",
+		},
+		{
+			name: "bad-principal",
+			options: &sshauth.Options{
+				TrustedUserCAKeys:    []string{caKey},
+				AuthorizedPrincipals: map[string][]string{"root": {"admins,oncall"}},
+			},
+			err: `invalid principal "admins,oncall" for user "root"`,
+		},
+		{
+			name: "no-principals",
+			options: &sshauth.Options{
+				TrustedUserCAKeys:    []string{caKey},
+				AuthorizedPrincipals: map[string][]string{"root": nil},
+			},
+			err: `no principals for user "root"`,
+		},
+		{
+			name: "bad-user",
+			options: &sshauth.Options{
+				TrustedUserCAKeys:    []string{caKey},
+				AuthorizedPrincipals: map[string][]string{"../root": {"admins"}},
+			},
+			err: `invalid user name "../root" in authorized_principals`,
+		},
+		{
+			name:    "command-without-user",
+			options: &sshauth.Options{AuthorizedKeysCommand: "/usr/bin/sss_ssh_authorizedkeys"},
+			err:     "authorized_keys_command requires authorized_keys_command_user",
+		},
+		{
+			name:    "relative-command",
+			options: &sshauth.Options{AuthorizedKeysCommand: "sss_ssh_authorizedkeys", AuthorizedKeysCommandUser: "nobody"},
+			err:     `authorized_keys_command "sss_ssh_authorizedkeys" must be an absolute path with optional arguments`,
+		},
+		{
+			name:    "user-without-command",
+			options: &sshauth.Options{AuthorizedKeysCommandUser: "nobody"},
+			err:     "authorized_keys_command_user requires authorized_keys_command",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOptionsFiles(t *testing.T) {
+	o := &sshauth.Options{
+		TrustedUserCAKeys: []string{caKey},
+		AuthorizedPrincipals: map[string][]string{
+			"root":  {"admins"},
+			"admin": {"admins", "oncall"},
+		},
+	}
+
+	files, err := o.Files()
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.Equal(t, "/etc/ssh/trusted_user_ca_keys", files[0].Path())
+	assert.Equal(t, fs.FileMode(0644), *files[0].Mode())
+	assert.Equal(t, caKey+"\n", string(files[0].Data()))
+	assert.Equal(t, "/etc/ssh/auth_principals/admin", files[1].Path())
+	assert.Equal(t, "admins\noncall\n", string(files[1].Data()))
+	assert.Equal(t, "/etc/ssh/auth_principals/root", files[2].Path())
+	assert.Equal(t, "admins\n", string(files[2].Data()))
+
+	dirs, err := o.Directories()
+	require.NoError(t, err)
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "/etc/ssh/auth_principals", dirs[0].Path())
+
+	dirs, err = (&sshauth.Options{TrustedUserCAKeys: []string{caKey}}).Directories()
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+}
+
+func TestOptionsSshdConfigDropIn(t *testing.T) {
+	o := &sshauth.Options{
+		TrustedUserCAKeys:         []string{caKey},
+		AuthorizedPrincipals:      map[string][]string{"root": {"admins"}},
+		AuthorizedKeysCommand:     "/usr/bin/fetch-keys %u",
+		AuthorizedKeysCommandUser: "nobody",
+	}
+
+	dir, f, err := o.SshdConfigDropIn()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/ssh/sshd_config.d", dir.Path())
+	assert.Equal(t, "/etc/ssh/sshd_config.d/40-ssh-auth.conf", f.Path())
+	assert.Equal(t, fs.FileMode(0600), *f.Mode())
+	assert.Equal(t, `TrustedUserCAKeys /etc/ssh/trusted_user_ca_keys
+AuthorizedPrincipalsFile /etc/ssh/auth_principals/%u
+AuthorizedKeysCommand /usr/bin/fetch-keys %u
+AuthorizedKeysCommandUser nobody
+`, string(f.Data()))
+}
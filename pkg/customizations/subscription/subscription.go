@@ -40,10 +40,19 @@ type SubManRHSMCertdConfig struct {
 	AutoRegistration *bool `yaml:"auto_registration,omitempty"`
 }
 
+// Subscription Manager [server] configuration
+type SubManServerConfig struct {
+	ProxyHostname *string `yaml:"proxy_hostname,omitempty"`
+	ProxyScheme   *string `yaml:"proxy_scheme,omitempty"`
+	ProxyPort     *string `yaml:"proxy_port,omitempty"`
+	NoProxy       *string `yaml:"no_proxy,omitempty"`
+}
+
 // Subscription Manager 'rhsm.conf' configuration
 type SubManConfig struct {
 	Rhsm      SubManRHSMConfig
 	Rhsmcertd SubManRHSMCertdConfig
+	Server    SubManServerConfig
 }
 
 type DNFPluginConfig struct {
@@ -79,6 +88,7 @@ func (c *RHSMConfig) Clone() *RHSMConfig {
 	clone.SubMan.Rhsm.ManageRepos = common.ClonePtr(c.SubMan.Rhsm.ManageRepos)
 	clone.SubMan.Rhsm.AutoEnableYumPlugins = common.ClonePtr(c.SubMan.Rhsm.AutoEnableYumPlugins)
 	clone.SubMan.Rhsmcertd.AutoRegistration = common.ClonePtr(c.SubMan.Rhsmcertd.AutoRegistration)
+	clone.SubMan.Server = c.SubMan.Server.clone()
 
 	return clone
 }
@@ -120,10 +130,41 @@ func (c *RHSMConfig) Update(new *RHSMConfig) *RHSMConfig {
 	if new.SubMan.Rhsmcertd.AutoRegistration != nil {
 		c.SubMan.Rhsmcertd.AutoRegistration = common.ClonePtr(new.SubMan.Rhsmcertd.AutoRegistration)
 	}
+	c.SubMan.Server.update(new.SubMan.Server)
 
 	return c
 }
 
+func (s SubManServerConfig) clone() SubManServerConfig {
+	return SubManServerConfig{
+		ProxyHostname: common.ClonePtr(s.ProxyHostname),
+		ProxyScheme:   common.ClonePtr(s.ProxyScheme),
+		ProxyPort:     common.ClonePtr(s.ProxyPort),
+		NoProxy:       common.ClonePtr(s.NoProxy),
+	}
+}
+
+func (s *SubManServerConfig) update(new SubManServerConfig) {
+	new = new.clone()
+	if new.ProxyHostname != nil {
+		s.ProxyHostname = new.ProxyHostname
+	}
+	if new.ProxyScheme != nil {
+		s.ProxyScheme = new.ProxyScheme
+	}
+	if new.ProxyPort != nil {
+		s.ProxyPort = new.ProxyPort
+	}
+	if new.NoProxy != nil {
+		s.NoProxy = new.NoProxy
+	}
+}
+
+// IsZero returns true if no [server] option is set
+func (s SubManServerConfig) IsZero() bool {
+	return s == SubManServerConfig{}
+}
+
 // RHSMConfigFromBP creates a RHSMConfig from a blueprint RHSMCustomization
 func RHSMConfigFromBP(bpRHSM *blueprint.RHSMCustomization) *RHSMConfig {
 	if bpRHSM == nil || bpRHSM.Config == nil {
@@ -40,6 +40,9 @@ func rhsmConfigNotSamePointers(t *testing.T, c1, c2 *RHSMConfig) {
 		if c1.SubMan.Rhsmcertd.AutoRegistration != nil && c2.SubMan.Rhsmcertd.AutoRegistration != nil {
 			assert.NotSame(t, c1.SubMan.Rhsmcertd.AutoRegistration, c2.SubMan.Rhsmcertd.AutoRegistration)
 		}
+		if c1.SubMan.Server.ProxyHostname != nil && c2.SubMan.Server.ProxyHostname != nil {
+			assert.NotSame(t, c1.SubMan.Server.ProxyHostname, c2.SubMan.Server.ProxyHostname)
+		}
 
 	}
 }
@@ -383,6 +386,39 @@ func TestRHSMConfigUpdate(t *testing.T) {
 				},
 			},
 		},
+		{
+			old: &RHSMConfig{
+				SubMan: SubManConfig{
+					Rhsm: SubManRHSMConfig{
+						ManageRepos: common.ToPtr(true),
+					},
+					Server: SubManServerConfig{
+						ProxyHostname: common.ToPtr("old.example.com"),
+						ProxyPort:     common.ToPtr("8080"),
+					},
+				},
+			},
+			new: &RHSMConfig{
+				SubMan: SubManConfig{
+					Server: SubManServerConfig{
+						ProxyHostname: common.ToPtr("proxy.example.com"),
+						ProxyScheme:   common.ToPtr("http"),
+					},
+				},
+			},
+			expected: &RHSMConfig{
+				SubMan: SubManConfig{
+					Rhsm: SubManRHSMConfig{
+						ManageRepos: common.ToPtr(true),
+					},
+					Server: SubManServerConfig{
+						ProxyHostname: common.ToPtr("proxy.example.com"),
+						ProxyScheme:   common.ToPtr("http"),
+						ProxyPort:     common.ToPtr("8080"),
+					},
+				},
+			},
+		},
 	}
 
 	for idx, tc := range testCases {
@@ -0,0 +1,213 @@
+// Package sudoers contains sudoers drop-in files that are written to
+// /etc/sudoers.d. The rules are checked at build time so that a broken
+// drop-in cannot lock administrators out of sudo on the built system.
+package sudoers
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const dropInDir = "/etc/sudoers.d"
+
+// dropInNameRegex matches the file names sudo reads from an includedir,
+// names containing a "." or ending in "~" are skipped by sudo
+var dropInNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+
+var (
+	defaultsRegex = regexp.MustCompile(`^Defaults([:@!>]\S+)?\s+\S`)
+	aliasRegex    = regexp.MustCompile(`^(User|Runas|Host|Cmnd|Cmd)_Alias\s+[A-Z][A-Z0-9_]*\s*=\s*\S`)
+	// userSpecRegex matches "<users> <hosts> = <commands>"
+	userSpecRegex = regexp.MustCompile(`^(\S+(\s*,\s*\S+)*)\s+(\S+(\s*,\s*\S+)*)\s*=\s*(\S.*)$`)
+	runasRegex    = regexp.MustCompile(`^\([^()]*\)\s*`)
+	tagRegex      = regexp.MustCompile(`^(NOPASSWD|PASSWD|NOEXEC|EXEC|SETENV|NOSETENV|LOG_INPUT|NOLOG_INPUT|LOG_OUTPUT|NOLOG_OUTPUT|MAIL|NOMAIL|FOLLOW|NOFOLLOW|INTERCEPT|NOINTERCEPT):\s*`)
+	digestRegex   = regexp.MustCompile(`^sha(224|256|384|512):\S+\s+`)
+	cmndAlias     = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+)
+
+type Options struct {
+	DropIns []DropIn `json:"drop_ins" yaml:"drop_ins"`
+}
+
+// DropIn is a file in /etc/sudoers.d with sudoers(5) rules
+type DropIn struct {
+	Name     string `json:"name" yaml:"name"`
+	Contents string `json:"contents" yaml:"contents"`
+}
+
+// Validate checks the names and the rules of the drop-ins
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, d := range o.DropIns {
+		if !dropInNameRegex.MatchString(d.Name) {
+			return fmt.Errorf("invalid drop-in name %q (must match %s)", d.Name, dropInNameRegex.String())
+		}
+		if names[d.Name] {
+			return fmt.Errorf("duplicate drop-in %q", d.Name)
+		}
+		names[d.Name] = true
+		if err := d.validate(); err != nil {
+			return fmt.Errorf("drop-in %q: %w", d.Name, err)
+		}
+	}
+	return nil
+}
+
+// logicalLines joins the lines continued with a trailing backslash and
+// returns them with the number of the line they start on
+func logicalLines(contents string) ([]string, []int) {
+	var lines []string
+	var numbers []int
+	var cur strings.Builder
+	start := 0
+	for idx, line := range strings.Split(contents, "\n") {
+		if cur.Len() == 0 {
+			start = idx + 1
+		}
+		if strings.HasSuffix(line, "\\") {
+			cur.WriteString(strings.TrimSuffix(line, "\\"))
+			cur.WriteString(" ")
+			continue
+		}
+		cur.WriteString(line)
+		lines = append(lines, cur.String())
+		numbers = append(numbers, start)
+		cur.Reset()
+	}
+	if cur.Len() > 0 {
+		lines = append(lines, cur.String())
+		numbers = append(numbers, start)
+	}
+	return lines, numbers
+}
+
+// stripComment removes a trailing comment, "#" followed by a digit is a
+// uid or gid and not a comment
+func stripComment(line string) string {
+	for idx := 0; idx < len(line); idx++ {
+		if line[idx] != '#' {
+			continue
+		}
+		if idx+1 < len(line) && line[idx+1] >= '0' && line[idx+1] <= '9' {
+			continue
+		}
+		return line[:idx]
+	}
+	return line
+}
+
+// splitCommands splits a command list on the commas that separate the
+// commands, commas in a runas list or escaped with a backslash are kept
+func splitCommands(list string) []string {
+	var cmds []string
+	depth := 0
+	start := 0
+	for idx := 0; idx < len(list); idx++ {
+		switch list[idx] {
+		case '\\':
+			idx++
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				cmds = append(cmds, list[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+	return append(cmds, list[start:])
+}
+
+func validateCommand(cmd string) error {
+	cmd = strings.TrimSpace(cmd)
+	if m := runasRegex.FindString(cmd); m != "" {
+		cmd = cmd[len(m):]
+	}
+	for {
+		m := tagRegex.FindString(cmd)
+		if m == "" {
+			break
+		}
+		cmd = cmd[len(m):]
+	}
+	if m := digestRegex.FindString(cmd); m != "" {
+		cmd = cmd[len(m):]
+	}
+	cmd = strings.TrimLeft(cmd, "!")
+	path, _, _ := strings.Cut(cmd, " ")
+	switch {
+	case path == "ALL", path == "sudoedit", cmndAlias.MatchString(path):
+		return nil
+	case filepath.IsAbs(path):
+		return nil
+	case path == "":
+		return fmt.Errorf("missing command")
+	default:
+		return fmt.Errorf("command %q must be ALL, an alias or a fully qualified path", path)
+	}
+}
+
+func (d *DropIn) validate() error {
+	lines, numbers := logicalLines(d.Contents)
+	rules := 0
+	for idx, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#include") || strings.HasPrefix(trimmed, "@include") {
+			return fmt.Errorf("line %d: includes are not supported in drop-ins", numbers[idx])
+		}
+		trimmed = strings.TrimSpace(stripComment(trimmed))
+		if trimmed == "" {
+			continue
+		}
+		rules++
+		if defaultsRegex.MatchString(trimmed) || aliasRegex.MatchString(trimmed) {
+			continue
+		}
+		m := userSpecRegex.FindStringSubmatch(trimmed)
+		if m == nil {
+			return fmt.Errorf("line %d: syntax error in %q", numbers[idx], trimmed)
+		}
+		if strings.Count(m[5], "(") != strings.Count(m[5], ")") {
+			return fmt.Errorf("line %d: unbalanced parentheses in %q", numbers[idx], trimmed)
+		}
+		for _, cmd := range splitCommands(m[5]) {
+			if err := validateCommand(cmd); err != nil {
+				return fmt.Errorf("line %d: %w", numbers[idx], err)
+			}
+		}
+	}
+	if rules == 0 {
+		return fmt.Errorf("no rules")
+	}
+	return nil
+}
+
+// Files returns the drop-in files, sudo requires them to be owned by root
+// and not writable
+func (o *Options) Files() ([]*fsnode.File, error) {
+	var files []*fsnode.File
+	for _, d := range o.DropIns {
+		contents := d.Contents
+		// older sudo versions fail to parse a last line without a newline
+		if !strings.HasSuffix(contents, "\n") {
+			contents += "\n"
+		}
+		f, err := fsnode.NewFile(filepath.Join(dropInDir, d.Name), common.ToPtr(fs.FileMode(0440)), "root", "root", []byte(contents))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
@@ -0,0 +1,109 @@
+package sudoers_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/sudoers"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		dropIn   string
+		err      string
+	}{
+		{
+			name:     "group-nopasswd",
+			contents: "%wheel ALL=(ALL) NOPASSWD: ALL\n",
+		},
+		{
+			name: "full",
+			contents: `# operators can restart the web server
+Defaults:%operators !requiretty
+Cmnd_Alias WEB = /usr/bin/systemctl restart httpd, \
+                 /usr/bin/systemctl reload httpd
+User_Alias OPS = alice, bob
+OPS ALL = (root) NOPASSWD: WEB, /usr/bin/journalctl -u httpd  # logs
+#1000 ALL=(root, apache) /usr/bin/ls, !/usr/bin/su
+deploy ALL=(ALL:ALL) NOPASSWD:SETENV: /usr/bin/rsync -a src\,dst dst, sudoedit /etc/httpd/conf/httpd.conf
+`,
+		},
+		{
+			name:   "bad-name",
+			dropIn: "10-ops.conf",
+			err:    `invalid drop-in name "10-ops.conf" (must match ^[A-Za-z0-9_-]{1,100}$)`,
+		},
+		{
+			name:     "empty",
+			contents: "# nothing\n\n",
+			err:      `drop-in "test": no rules`,
+		},
+		{
+			name:     "include",
+			contents: "#includedir /etc/sudoers.d\n",
+			err:      `drop-in "test": line 1: includes are not supported in drop-ins`,
+		},
+		{
+			name:     "syntax-error",
+			contents: "%wheel ALL=(ALL) NOPASSWD: ALL\n%ops ALL\n",
+			err:      `drop-in "test": line 2: syntax error in "%ops ALL"`,
+		},
+		{
+			name:     "relative-command",
+			contents: "alice ALL = (root) systemctl restart httpd\n",
+			err:      `drop-in "test": line 1: command "systemctl" must be ALL, an alias or a fully qualified path`,
+		},
+		{
+			name:     "missing-command",
+			contents: "alice ALL = (root) NOPASSWD:\n",
+			err:      `drop-in "test": line 1: missing command`,
+		},
+		{
+			name:     "unbalanced",
+			contents: "alice ALL = (root /usr/bin/ls\n",
+			err:      `drop-in "test": line 1: unbalanced parentheses in "alice ALL = (root /usr/bin/ls"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			name := tc.dropIn
+			if name == "" {
+				name = "test"
+			}
+			o := &sudoers.Options{DropIns: []sudoers.DropIn{{Name: name, Contents: tc.contents}}}
+			err := o.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOptionsValidateDuplicate(t *testing.T) {
+	o := &sudoers.Options{DropIns: []sudoers.DropIn{
+		{Name: "ops", Contents: "%ops ALL=(ALL) ALL"},
+		{Name: "ops", Contents: "%wheel ALL=(ALL) ALL"},
+	}}
+	assert.EqualError(t, o.Validate(), `duplicate drop-in "ops"`)
+	assert.NoError(t, (*sudoers.Options)(nil).Validate())
+}
+
+func TestOptionsFiles(t *testing.T) {
+	o := &sudoers.Options{DropIns: []sudoers.DropIn{
+		{Name: "ops", Contents: "%ops ALL=(ALL) ALL"},
+	}}
+	files, err := o.Files()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/etc/sudoers.d/ops", files[0].Path())
+	assert.Equal(t, fs.FileMode(0440), *files[0].Mode())
+	assert.Equal(t, "%ops ALL=(ALL) ALL\n", string(files[0].Data()))
+}
@@ -0,0 +1,125 @@
+// Package swap contains the swap configuration of an image: a swap
+// partition, a swap file on one of the filesystems or a compressed swap
+// device in RAM set up by zram-generator.
+package swap
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+	"github.com/osbuild/image-builder/pkg/datasizes"
+)
+
+const zramGeneratorConfigPath = "/etc/systemd/zram-generator.conf"
+
+// CompressionAlgorithms are the algorithms supported by the zram kernel
+// module
+var CompressionAlgorithms = []string{"842", "deflate", "lz4", "lz4hc", "lzo", "lzo-rle", "zstd"}
+
+// zramSizeRegex matches a zram-generator size expression, e.g. "4096",
+// "ram / 2" or "min(ram / 2, 4096)"
+var zramSizeRegex = regexp.MustCompile(`^[a-z0-9 .+*/(),-]+$`)
+
+// swapFileDenyList are the directories that cannot hold a swap file
+// because they are not backed by a persistent filesystem or are special
+// in the image
+var swapFileDenyList = []string{"/boot", "/dev", "/proc", "/run", "/sys", "/tmp", "/usr"}
+
+type Options struct {
+	Partition *Partition `json:"partition,omitempty" yaml:"partition,omitempty"`
+	File      *File      `json:"file,omitempty" yaml:"file,omitempty"`
+	Zram      *Zram      `json:"zram,omitempty" yaml:"zram,omitempty"`
+}
+
+// Partition is a swap partition, or a swap logical volume when the root
+// filesystem is on LVM, that is added to the partition table of the image
+type Partition struct {
+	Size datasizes.Size `json:"size" yaml:"size"`
+}
+
+// File is a swap file that is created on first boot. The filesystem
+// holding the file is grown by its size.
+type File struct {
+	Path string         `json:"path" yaml:"path"`
+	Size datasizes.Size `json:"size" yaml:"size"`
+}
+
+// Zram is a compressed swap device in RAM
+type Zram struct {
+	// Size is the size of the device in MiB as an expression evaluated by
+	// zram-generator, e.g. "min(ram / 2, 4096)"; the zram-generator
+	// default is used when empty
+	Size string `json:"size,omitempty" yaml:"size,omitempty"`
+	// CompressionAlgorithm is the compression algorithm of the device; the
+	// kernel default is used when empty
+	CompressionAlgorithm string `json:"compression_algorithm,omitempty" yaml:"compression_algorithm,omitempty"`
+}
+
+// Validate checks the swap options
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.Partition != nil && o.Partition.Size == 0 {
+		return fmt.Errorf("partition: size is required")
+	}
+	if o.File != nil {
+		if err := o.File.validate(); err != nil {
+			return fmt.Errorf("file: %w", err)
+		}
+	}
+	if o.Zram != nil {
+		if err := o.Zram.validate(); err != nil {
+			return fmt.Errorf("zram: %w", err)
+		}
+	}
+	return nil
+}
+
+func (f *File) validate() error {
+	if f.Size == 0 {
+		return fmt.Errorf("size is required")
+	}
+	if !filepath.IsAbs(f.Path) || filepath.Clean(f.Path) != f.Path || f.Path == "/" {
+		return fmt.Errorf("path %q must be an absolute, clean file path", f.Path)
+	}
+	if strings.ContainsAny(f.Path, " \t\n\"'\\") {
+		return fmt.Errorf("path %q must not contain whitespace, quotes or backslashes", f.Path)
+	}
+	for _, dir := range swapFileDenyList {
+		if f.Path == dir || strings.HasPrefix(f.Path, dir+"/") {
+			return fmt.Errorf("path %q cannot be in %s", f.Path, dir)
+		}
+	}
+	return nil
+}
+
+func (z *Zram) validate() error {
+	if z.Size != "" && !zramSizeRegex.MatchString(z.Size) {
+		return fmt.Errorf("invalid size expression %q", z.Size)
+	}
+	if z.CompressionAlgorithm != "" && !slices.Contains(CompressionAlgorithms, z.CompressionAlgorithm) {
+		return fmt.Errorf("unsupported compression algorithm %q (must be one of %s)", z.CompressionAlgorithm, strings.Join(CompressionAlgorithms, ", "))
+	}
+	return nil
+}
+
+// ConfigFile returns the zram-generator configuration that sets up a
+// single zram0 swap device
+func (z *Zram) ConfigFile() (*fsnode.File, error) {
+	var b strings.Builder
+	b.WriteString("[zram0]\n")
+	if z.Size != "" {
+		fmt.Fprintf(&b, "zram-size = %s\n", z.Size)
+	}
+	if z.CompressionAlgorithm != "" {
+		fmt.Fprintf(&b, "compression-algorithm = %s\n", z.CompressionAlgorithm)
+	}
+	return fsnode.NewFile(zramGeneratorConfigPath, common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(b.String()))
+}
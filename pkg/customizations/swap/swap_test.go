@@ -0,0 +1,99 @@
+package swap_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
+	"github.com/osbuild/image-builder/pkg/datasizes"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *swap.Options
+		err     string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "all",
+			options: &swap.Options{
+				Partition: &swap.Partition{Size: 2 * datasizes.GiB},
+				File:      &swap.File{Path: "/var/swapfile", Size: 1 * datasizes.GiB},
+				Zram:      &swap.Zram{Size: "min(ram / 2, 4096)", CompressionAlgorithm: "zstd"},
+			},
+		},
+		{
+			name:    "zram-defaults",
+			options: &swap.Options{Zram: &swap.Zram{}},
+		},
+		{
+			name:    "partition-no-size",
+			options: &swap.Options{Partition: &swap.Partition{}},
+			err:     "partition: size is required",
+		},
+		{
+			name:    "file-no-size",
+			options: &swap.Options{File: &swap.File{Path: "/swapfile"}},
+			err:     "file: size is required",
+		},
+		{
+			name:    "file-relative",
+			options: &swap.Options{File: &swap.File{Path: "swapfile", Size: datasizes.GiB}},
+			err:     `file: path "swapfile" must be an absolute, clean file path`,
+		},
+		{
+			name:    "file-unclean",
+			options: &swap.Options{File: &swap.File{Path: "/var/../swapfile", Size: datasizes.GiB}},
+			err:     `file: path "/var/../swapfile" must be an absolute, clean file path`,
+		},
+		{
+			name:    "file-whitespace",
+			options: &swap.Options{File: &swap.File{Path: "/swap file", Size: datasizes.GiB}},
+			err:     `file: path "/swap file" must not contain whitespace, quotes or backslashes`,
+		},
+		{
+			name:    "file-tmp",
+			options: &swap.Options{File: &swap.File{Path: "/tmp/swapfile", Size: datasizes.GiB}},
+			err:     `file: path "/tmp/swapfile" cannot be in /tmp`,
+		},
+		{
+			name:    "zram-bad-size",
+			options: &swap.Options{Zram: &swap.Zram{Size: "ram; reboot"}},
+			err:     `zram: invalid size expression "ram; reboot"`,
+		},
+		{
+			name:    "zram-bad-algorithm",
+			options: &swap.Options{Zram: &swap.Zram{CompressionAlgorithm: "gzip"}},
+			err:     `zram: unsupported compression algorithm "gzip" (must be one of 842, deflate, lz4, lz4hc, lzo, lzo-rle, zstd)`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestZramConfigFile(t *testing.T) {
+	f, err := (&swap.Zram{Size: "min(ram / 2, 4096)", CompressionAlgorithm: "zstd"}).ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/zram-generator.conf", f.Path())
+	assert.Equal(t, fs.FileMode(0644), *f.Mode())
+	assert.Equal(t, "[zram0]\nzram-size = min(ram / 2, 4096)\ncompression-algorithm = zstd\n", string(f.Data()))
+
+	f, err = (&swap.Zram{}).ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, "[zram0]\n", string(f.Data()))
+}
@@ -0,0 +1,110 @@
+// Package sysctl contains kernel parameters that are written to
+// /etc/sysctl.d and applied by systemd-sysctl at boot.
+package sysctl
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const configDir = "/etc/sysctl.d"
+
+var (
+	// fileNameRegex matches the names of the configuration files
+	// without the ".conf" extension
+	fileNameRegex = regexp.MustCompile(`^[\w.-]{1,245}$`)
+	// keyRegex matches kernel parameter names in the dotted or the
+	// slash separated form, including globs and the "-" exclude prefix
+	keyRegex = regexp.MustCompile(`^-?[\w*?\[\]-]+([./][\w*?\[\]:-]+)*$`)
+)
+
+type Options struct {
+	Files []File `json:"files" yaml:"files"`
+}
+
+// File is a configuration file in /etc/sysctl.d, the files are applied
+// in the lexicographic order of their names, e.g. "90-network"
+type File struct {
+	Name     string    `json:"name" yaml:"name"`
+	Settings []Setting `json:"settings" yaml:"settings"`
+}
+
+// Setting is a kernel parameter, e.g. "net.ipv4.ip_forward" = "1"
+type Setting struct {
+	Key string `json:"key" yaml:"key"`
+	// Value must be set unless the key starts with "-", which excludes
+	// the key from being set by a glob
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// Filename returns the name of the file in /etc/sysctl.d
+func (f *File) Filename() string {
+	return f.Name + ".conf"
+}
+
+// Validate checks the names of the files and the settings
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, f := range o.Files {
+		if !fileNameRegex.MatchString(f.Name) {
+			return fmt.Errorf("invalid file name %q (must match %s)", f.Name, fileNameRegex.String())
+		}
+		if names[f.Name] {
+			return fmt.Errorf("duplicate file %q", f.Name)
+		}
+		names[f.Name] = true
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("file %q: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func (f *File) validate() error {
+	if len(f.Settings) == 0 {
+		return fmt.Errorf("no settings")
+	}
+	for _, s := range f.Settings {
+		if !keyRegex.MatchString(s.Key) {
+			return fmt.Errorf("invalid key %q (must match %s)", s.Key, keyRegex.String())
+		}
+		if s.Value == "" && !strings.HasPrefix(s.Key, "-") {
+			return fmt.Errorf("key %q: value required, only excluded keys starting with \"-\" have no value", s.Key)
+		}
+		if strings.ContainsAny(s.Value, "\n\r") {
+			return fmt.Errorf("key %q: value must not contain newlines", s.Key)
+		}
+	}
+	return nil
+}
+
+// FsNodeFiles returns the configuration files in /etc/sysctl.d, for
+// images that cannot run the sysctld stage
+func (o *Options) FsNodeFiles() ([]*fsnode.File, error) {
+	var files []*fsnode.File
+	for _, f := range o.Files {
+		var b strings.Builder
+		for _, s := range f.Settings {
+			if s.Value == "" {
+				fmt.Fprintf(&b, "%s\n", s.Key)
+			} else {
+				fmt.Fprintf(&b, "%s = %s\n", s.Key, s.Value)
+			}
+		}
+		file, err := fsnode.NewFile(path.Join(configDir, f.Filename()), common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(b.String()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,92 @@
+package sysctl_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/sysctl"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		file sysctl.File
+		err  string
+	}{
+		{
+			name: "ok",
+			file: sysctl.File{Name: "90-network", Settings: []sysctl.Setting{
+				{Key: "net.ipv4.ip_forward", Value: "1"},
+				{Key: "net/ipv4/conf/*/rp_filter", Value: "2"},
+				{Key: "-net.ipv4.conf.lo.rp_filter"},
+			}},
+		},
+		{
+			name: "bad-name",
+			file: sysctl.File{Name: "90/network", Settings: []sysctl.Setting{{Key: "vm.swappiness", Value: "10"}}},
+			err:  `invalid file name "90/network" (must match ^[\w.-]{1,245}$)`,
+		},
+		{
+			name: "no-settings",
+			file: sysctl.File{Name: "90-network"},
+			err:  `file "90-network": no settings`,
+		},
+		{
+			name: "bad-key",
+			file: sysctl.File{Name: "90-network", Settings: []sysctl.Setting{{Key: "vm swappiness", Value: "10"}}},
+			err:  `file "90-network": invalid key "vm swappiness" (must match ^-?[\w*?\[\]-]+([./][\w*?\[\]:-]+)*$)`,
+		},
+		{
+			name: "no-value",
+			file: sysctl.File{Name: "90-network", Settings: []sysctl.Setting{{Key: "vm.swappiness"}}},
+			err:  `file "90-network": key "vm.swappiness": value required, only excluded keys starting with "-" have no value`,
+		},
+		{
+			name: "newline",
+			file: sysctl.File{Name: "90-network", Settings: []sysctl.Setting{{Key: "kernel.hostname", Value: "a\nb"}}},
+			err:  `file "90-network": key "kernel.hostname": value must not contain newlines`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := &sysctl.Options{Files: []sysctl.File{tc.file}}
+			if tc.err == "" {
+				assert.NoError(t, o.Validate())
+			} else {
+				assert.EqualError(t, o.Validate(), tc.err)
+			}
+		})
+	}
+}
+
+func TestOptionsValidateDuplicate(t *testing.T) {
+	f := sysctl.File{Name: "90-network", Settings: []sysctl.Setting{{Key: "net.ipv4.ip_forward", Value: "1"}}}
+	o := &sysctl.Options{Files: []sysctl.File{f, f}}
+	assert.EqualError(t, o.Validate(), `duplicate file "90-network"`)
+
+	var nilOptions *sysctl.Options
+	assert.NoError(t, nilOptions.Validate())
+}
+
+func TestOptionsFsNodeFiles(t *testing.T) {
+	o := &sysctl.Options{
+		Files: []sysctl.File{
+			{
+				Name: "90-network",
+				Settings: []sysctl.Setting{
+					{Key: "net.ipv4.conf.*.rp_filter", Value: "2"},
+					{Key: "-net.ipv4.conf.lo.rp_filter"},
+				},
+			},
+		},
+	}
+	files, err := o.FsNodeFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/etc/sysctl.d/90-network.conf", files[0].Path())
+	assert.Equal(t, fs.FileMode(0644), *files[0].Mode())
+	assert.Equal(t, "net.ipv4.conf.*.rp_filter = 2\n-net.ipv4.conf.lo.rp_filter\n", string(files[0].Data()))
+}
@@ -0,0 +1,551 @@
+// Package systemd contains systemd units and unit drop-ins that are
+// defined by the user instead of the distro definitions: small services
+// with their timers, path units and mounts. It also contains the journald
+// and logind settings of the image.
+package systemd
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const (
+	unitDir = "/etc/systemd/system"
+	// configDropInName is the name of the journald and logind drop-ins,
+	// the same name is used by the journald and logind stages
+	configDropInName = "50-customizations.conf"
+)
+
+// UnitTypes are the unit types that can be created
+var UnitTypes = []string{"mount", "path", "service", "timer"}
+
+var (
+	unitNameRegex   = regexp.MustCompile(`^[A-Za-z0-9:_.\\-]+(@[A-Za-z0-9:_.\\-]*)?\.(mount|path|service|timer)$`)
+	dropInNameRegex = regexp.MustCompile(`^[A-Za-z0-9:_.\\-]+\.conf$`)
+	envVarRegex     = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	serviceTypes    = []string{"simple", "exec", "forking", "oneshot", "dbus", "notify", "notify-reload", "idle"}
+
+	// sizeRegex matches a journald size, e.g. "500M", "2G" or "10%"
+	sizeRegex = regexp.MustCompile(`^([0-9]+[KMGTPE]?|[0-9]{1,2}%)$`)
+	// timespanRegex matches a systemd time span, e.g. "30s", "15min",
+	// "1month" or "2 weeks"
+	timespanRegex = regexp.MustCompile(`^([0-9]+ ?(us|ms|s|sec|m|min|h|hr|d|day|days|w|week|weeks|month|months|y|year|years)? ?)+$`)
+)
+
+// JournalStorages are the values of the journald Storage setting
+var JournalStorages = []string{"auto", "none", "persistent", "volatile"}
+
+// IdleActions are the values of the logind IdleAction setting
+var IdleActions = []string{"ignore", "poweroff", "reboot", "halt", "kexec", "suspend", "hibernate", "hybrid-sleep", "suspend-then-hibernate", "lock"}
+
+type Options struct {
+	Units    []Unit    `json:"units,omitempty" yaml:"units,omitempty"`
+	DropIns  []DropIn  `json:"dropins,omitempty" yaml:"dropins,omitempty"`
+	Journald *Journald `json:"journald,omitempty" yaml:"journald,omitempty"`
+	Logind   *Logind   `json:"logind,omitempty" yaml:"logind,omitempty"`
+}
+
+// Journald is the journal persistence, size limits and forwarding
+type Journald struct {
+	Storage string `json:"storage,omitempty" yaml:"storage,omitempty"`
+	// SystemMaxUse and SystemKeepFree are the disk space the persistent
+	// journal may use at most and has to leave free, in bytes with an
+	// optional K, M, G, T, P or E suffix or in percent of the filesystem
+	SystemMaxUse      string `json:"system_max_use,omitempty" yaml:"system_max_use,omitempty"`
+	SystemKeepFree    string `json:"system_keep_free,omitempty" yaml:"system_keep_free,omitempty"`
+	SystemMaxFileSize string `json:"system_max_file_size,omitempty" yaml:"system_max_file_size,omitempty"`
+	RuntimeMaxUse     string `json:"runtime_max_use,omitempty" yaml:"runtime_max_use,omitempty"`
+	MaxRetentionSec   string `json:"max_retention_sec,omitempty" yaml:"max_retention_sec,omitempty"`
+	ForwardToSyslog   *bool  `json:"forward_to_syslog,omitempty" yaml:"forward_to_syslog,omitempty"`
+}
+
+// Logind is the session and idle behaviour of logind
+type Logind struct {
+	KillUserProcesses  *bool  `json:"kill_user_processes,omitempty" yaml:"kill_user_processes,omitempty"`
+	IdleAction         string `json:"idle_action,omitempty" yaml:"idle_action,omitempty"`
+	IdleActionSec      string `json:"idle_action_sec,omitempty" yaml:"idle_action_sec,omitempty"`
+	StopIdleSessionSec string `json:"stop_idle_session_sec,omitempty" yaml:"stop_idle_session_sec,omitempty"`
+}
+
+// Unit is a unit file that is created in /etc/systemd/system. The unit
+// type is taken from the extension of the name and the unit needs the
+// section of that type.
+type Unit struct {
+	Name string `json:"name" yaml:"name"`
+	// Enabled units are enabled together with the other services of the
+	// image and need an Install section
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+
+	Unit    *UnitSection    `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Service *ServiceSection `json:"service,omitempty" yaml:"service,omitempty"`
+	Timer   *TimerSection   `json:"timer,omitempty" yaml:"timer,omitempty"`
+	Path    *PathSection    `json:"path,omitempty" yaml:"path,omitempty"`
+	Mount   *MountSection   `json:"mount,omitempty" yaml:"mount,omitempty"`
+	Install *InstallSection `json:"install,omitempty" yaml:"install,omitempty"`
+}
+
+type UnitSection struct {
+	Description         string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Requires            []string `json:"requires,omitempty" yaml:"requires,omitempty"`
+	Wants               []string `json:"wants,omitempty" yaml:"wants,omitempty"`
+	After               []string `json:"after,omitempty" yaml:"after,omitempty"`
+	Before              []string `json:"before,omitempty" yaml:"before,omitempty"`
+	ConditionPathExists []string `json:"condition_path_exists,omitempty" yaml:"condition_path_exists,omitempty"`
+}
+
+type ServiceSection struct {
+	Type            string                `json:"type,omitempty" yaml:"type,omitempty"`
+	ExecStartPre    []string              `json:"exec_start_pre,omitempty" yaml:"exec_start_pre,omitempty"`
+	ExecStart       []string              `json:"exec_start" yaml:"exec_start"`
+	ExecStopPost    []string              `json:"exec_stop_post,omitempty" yaml:"exec_stop_post,omitempty"`
+	RemainAfterExit bool                  `json:"remain_after_exit,omitempty" yaml:"remain_after_exit,omitempty"`
+	Environment     []EnvironmentVariable `json:"environment,omitempty" yaml:"environment,omitempty"`
+	EnvironmentFile []string              `json:"environment_file,omitempty" yaml:"environment_file,omitempty"`
+	StandardOutput  string                `json:"standard_output,omitempty" yaml:"standard_output,omitempty"`
+}
+
+type EnvironmentVariable struct {
+	Key   string `json:"key" yaml:"key"`
+	Value string `json:"value" yaml:"value"`
+}
+
+type TimerSection struct {
+	OnActiveSec        string   `json:"on_active_sec,omitempty" yaml:"on_active_sec,omitempty"`
+	OnBootSec          string   `json:"on_boot_sec,omitempty" yaml:"on_boot_sec,omitempty"`
+	OnStartupSec       string   `json:"on_startup_sec,omitempty" yaml:"on_startup_sec,omitempty"`
+	OnUnitActiveSec    string   `json:"on_unit_active_sec,omitempty" yaml:"on_unit_active_sec,omitempty"`
+	OnUnitInactiveSec  string   `json:"on_unit_inactive_sec,omitempty" yaml:"on_unit_inactive_sec,omitempty"`
+	OnCalendar         []string `json:"on_calendar,omitempty" yaml:"on_calendar,omitempty"`
+	AccuracySec        string   `json:"accuracy_sec,omitempty" yaml:"accuracy_sec,omitempty"`
+	RandomizedDelaySec string   `json:"randomized_delay_sec,omitempty" yaml:"randomized_delay_sec,omitempty"`
+	Persistent         bool     `json:"persistent,omitempty" yaml:"persistent,omitempty"`
+	// Unit is the unit to activate, defaults to the service with the
+	// name of the timer
+	Unit string `json:"unit,omitempty" yaml:"unit,omitempty"`
+}
+
+type PathSection struct {
+	PathExists        []string `json:"path_exists,omitempty" yaml:"path_exists,omitempty"`
+	PathExistsGlob    []string `json:"path_exists_glob,omitempty" yaml:"path_exists_glob,omitempty"`
+	PathChanged       []string `json:"path_changed,omitempty" yaml:"path_changed,omitempty"`
+	PathModified      []string `json:"path_modified,omitempty" yaml:"path_modified,omitempty"`
+	DirectoryNotEmpty []string `json:"directory_not_empty,omitempty" yaml:"directory_not_empty,omitempty"`
+	MakeDirectory     bool     `json:"make_directory,omitempty" yaml:"make_directory,omitempty"`
+	// Unit is the unit to activate, defaults to the service with the
+	// name of the path unit
+	Unit string `json:"unit,omitempty" yaml:"unit,omitempty"`
+}
+
+type MountSection struct {
+	What    string `json:"what" yaml:"what"`
+	Where   string `json:"where" yaml:"where"`
+	Type    string `json:"type,omitempty" yaml:"type,omitempty"`
+	Options string `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+type InstallSection struct {
+	WantedBy   []string `json:"wanted_by,omitempty" yaml:"wanted_by,omitempty"`
+	RequiredBy []string `json:"required_by,omitempty" yaml:"required_by,omitempty"`
+}
+
+// DropIn is a drop-in for a service unit, e.g. to set the environment of
+// a service shipped by a package
+type DropIn struct {
+	// Unit is the name of the service the drop-in applies to
+	Unit string `json:"unit" yaml:"unit"`
+	// Name is the file name of the drop-in in the .d directory of the unit
+	Name                string                `json:"name" yaml:"name"`
+	Environment         []EnvironmentVariable `json:"environment,omitempty" yaml:"environment,omitempty"`
+	EnvironmentFile     []string              `json:"environment_file,omitempty" yaml:"environment_file,omitempty"`
+	ConditionPathExists string                `json:"condition_path_exists,omitempty" yaml:"condition_path_exists,omitempty"`
+}
+
+// Type returns the unit type, i.e. the extension of the unit name
+func (u *Unit) Type() string {
+	return strings.TrimPrefix(filepath.Ext(u.Name), ".")
+}
+
+// MountUnitName returns the name of the mount unit for the mount point,
+// systemd requires mount units to be named after their mount point
+func MountUnitName(where string) string {
+	where = strings.Trim(where, "/")
+	if where == "" {
+		return "-.mount"
+	}
+	where = strings.ReplaceAll(where, "\\", `\x5c`)
+	where = strings.ReplaceAll(where, "-", `\x2d`)
+	return strings.ReplaceAll(where, "/", "-") + ".mount"
+}
+
+// values returns the values of the unit that end up on a single line of
+// the unit file
+func (u *Unit) values() []string {
+	var values []string
+	if s := u.Unit; s != nil {
+		values = append(values, s.Description)
+		values = slices.Concat(values, s.Requires, s.Wants, s.After, s.Before, s.ConditionPathExists)
+	}
+	if s := u.Service; s != nil {
+		values = append(values, s.StandardOutput)
+		values = slices.Concat(values, s.ExecStartPre, s.ExecStart, s.ExecStopPost, s.EnvironmentFile)
+		for _, env := range s.Environment {
+			values = append(values, env.Value)
+		}
+	}
+	if s := u.Timer; s != nil {
+		values = append(values, s.OnActiveSec, s.OnBootSec, s.OnStartupSec, s.OnUnitActiveSec, s.OnUnitInactiveSec, s.AccuracySec, s.RandomizedDelaySec, s.Unit)
+		values = slices.Concat(values, s.OnCalendar)
+	}
+	if s := u.Path; s != nil {
+		values = append(values, s.Unit)
+		values = slices.Concat(values, s.PathExists, s.PathExistsGlob, s.PathChanged, s.PathModified, s.DirectoryNotEmpty)
+	}
+	if s := u.Mount; s != nil {
+		values = append(values, s.What, s.Where, s.Type, s.Options)
+	}
+	if s := u.Install; s != nil {
+		values = slices.Concat(values, s.WantedBy, s.RequiredBy)
+	}
+	return values
+}
+
+func validateEnvironment(env []EnvironmentVariable) error {
+	for _, v := range env {
+		if !envVarRegex.MatchString(v.Key) {
+			return fmt.Errorf("invalid environment variable name %q (must match %s)", v.Key, envVarRegex.String())
+		}
+	}
+	return nil
+}
+
+func validateAbsPaths(key string, paths []string) error {
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("%s path %q must be absolute", key, p)
+		}
+	}
+	return nil
+}
+
+func (u *Unit) validateSections() error {
+	sections := map[string]bool{
+		"service": u.Service != nil,
+		"timer":   u.Timer != nil,
+		"path":    u.Path != nil,
+		"mount":   u.Mount != nil,
+	}
+	for _, typ := range UnitTypes {
+		if typ == u.Type() && !sections[typ] {
+			return fmt.Errorf("%s section is required", typ)
+		}
+		if typ != u.Type() && sections[typ] {
+			return fmt.Errorf("%s section is not allowed in a %s unit", typ, u.Type())
+		}
+	}
+	return nil
+}
+
+func (u *Unit) validate() error {
+	if err := u.validateSections(); err != nil {
+		return err
+	}
+	for _, v := range u.values() {
+		if strings.ContainsAny(v, "\n\r") {
+			return fmt.Errorf("value %q must be a single line", v)
+		}
+	}
+
+	switch u.Type() {
+	case "service":
+		if len(u.Service.ExecStart) == 0 {
+			return fmt.Errorf("service.exec_start is required")
+		}
+		if u.Service.Type != "" && !slices.Contains(serviceTypes, u.Service.Type) {
+			return fmt.Errorf("unsupported service type %q (must be one of %s)", u.Service.Type, strings.Join(serviceTypes, ", "))
+		}
+		if err := validateEnvironment(u.Service.Environment); err != nil {
+			return err
+		}
+		// creating a service unit requires an Install section, see
+		// osbuild.SystemdUnitCreateStageOptions
+		if u.Install == nil {
+			return fmt.Errorf("install section is required for service units")
+		}
+	case "timer":
+		t := u.Timer
+		if t.OnActiveSec == "" && t.OnBootSec == "" && t.OnStartupSec == "" && t.OnUnitActiveSec == "" && t.OnUnitInactiveSec == "" && len(t.OnCalendar) == 0 {
+			return fmt.Errorf("timer section requires at least one on_* trigger")
+		}
+	case "path":
+		p := u.Path
+		watched := slices.Concat(p.PathExists, p.PathExistsGlob, p.PathChanged, p.PathModified, p.DirectoryNotEmpty)
+		if len(watched) == 0 {
+			return fmt.Errorf("path section requires at least one path to watch")
+		}
+		if err := validateAbsPaths("watched", watched); err != nil {
+			return err
+		}
+	case "mount":
+		m := u.Mount
+		if m.What == "" {
+			return fmt.Errorf("mount.what is required")
+		}
+		if !filepath.IsAbs(m.Where) || filepath.Clean(m.Where) != m.Where {
+			return fmt.Errorf("mount.where %q must be an absolute, clean path", m.Where)
+		}
+		if name := MountUnitName(m.Where); name != u.Name {
+			return fmt.Errorf("mount unit for %s must be named %q", m.Where, name)
+		}
+	}
+
+	if u.Enabled {
+		if strings.Contains(u.Name, "@.") {
+			return fmt.Errorf("template units cannot be enabled")
+		}
+		if u.Install == nil || len(u.Install.WantedBy)+len(u.Install.RequiredBy) == 0 {
+			return fmt.Errorf("enabled units require install.wanted_by or install.required_by")
+		}
+	}
+	return nil
+}
+
+func (d *DropIn) validate() error {
+	if !unitNameRegex.MatchString(d.Unit) || filepath.Ext(d.Unit) != ".service" {
+		return fmt.Errorf("invalid unit name %q, drop-ins are supported for service units only", d.Unit)
+	}
+	if !dropInNameRegex.MatchString(d.Name) {
+		return fmt.Errorf("invalid drop-in name %q (must match %s)", d.Name, dropInNameRegex.String())
+	}
+	if len(d.Environment) == 0 && len(d.EnvironmentFile) == 0 && d.ConditionPathExists == "" {
+		return fmt.Errorf("drop-in is empty")
+	}
+	if err := validateEnvironment(d.Environment); err != nil {
+		return err
+	}
+	for _, v := range d.Environment {
+		if strings.ContainsAny(v.Value, "\n\r") {
+			return fmt.Errorf("value %q must be a single line", v.Value)
+		}
+	}
+	return nil
+}
+
+func (j *Journald) validate() error {
+	if j.Storage != "" && !slices.Contains(JournalStorages, j.Storage) {
+		return fmt.Errorf("unsupported storage %q (must be one of %s)", j.Storage, strings.Join(JournalStorages, ", "))
+	}
+	sizes := []struct{ key, value string }{
+		{"system_max_use", j.SystemMaxUse},
+		{"system_keep_free", j.SystemKeepFree},
+		{"system_max_file_size", j.SystemMaxFileSize},
+		{"runtime_max_use", j.RuntimeMaxUse},
+	}
+	for _, size := range sizes {
+		if size.value != "" && !sizeRegex.MatchString(size.value) {
+			return fmt.Errorf("invalid %s %q (must match %s)", size.key, size.value, sizeRegex.String())
+		}
+	}
+	if j.MaxRetentionSec != "" && !timespanRegex.MatchString(j.MaxRetentionSec) {
+		return fmt.Errorf("invalid max_retention_sec %q", j.MaxRetentionSec)
+	}
+	if *j == (Journald{}) {
+		return fmt.Errorf("no settings")
+	}
+	return nil
+}
+
+func (l *Logind) validate() error {
+	if l.IdleAction != "" && !slices.Contains(IdleActions, l.IdleAction) {
+		return fmt.Errorf("unsupported idle_action %q (must be one of %s)", l.IdleAction, strings.Join(IdleActions, ", "))
+	}
+	if l.IdleActionSec != "" {
+		if !timespanRegex.MatchString(l.IdleActionSec) {
+			return fmt.Errorf("invalid idle_action_sec %q", l.IdleActionSec)
+		}
+		if l.IdleAction == "" || l.IdleAction == "ignore" {
+			return fmt.Errorf("idle_action_sec requires an idle_action")
+		}
+	}
+	if l.StopIdleSessionSec != "" && l.StopIdleSessionSec != "infinity" && !timespanRegex.MatchString(l.StopIdleSessionSec) {
+		return fmt.Errorf("invalid stop_idle_session_sec %q", l.StopIdleSessionSec)
+	}
+	if *l == (Logind{}) {
+		return fmt.Errorf("no settings")
+	}
+	return nil
+}
+
+// Validate checks the names of the units and drop-ins, that each unit
+// has the sections of its type and the journald and logind settings
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, u := range o.Units {
+		if !unitNameRegex.MatchString(u.Name) {
+			return fmt.Errorf("invalid unit name %q (must match %s)", u.Name, unitNameRegex.String())
+		}
+		if names[u.Name] {
+			return fmt.Errorf("duplicate unit %q", u.Name)
+		}
+		names[u.Name] = true
+		if err := u.validate(); err != nil {
+			return fmt.Errorf("unit %q: %w", u.Name, err)
+		}
+	}
+	dropIns := map[string]bool{}
+	for _, d := range o.DropIns {
+		if err := d.validate(); err != nil {
+			return fmt.Errorf("drop-in %q for %q: %w", d.Name, d.Unit, err)
+		}
+		path := d.Unit + ".d/" + d.Name
+		if dropIns[path] {
+			return fmt.Errorf("duplicate drop-in %q for %q", d.Name, d.Unit)
+		}
+		dropIns[path] = true
+	}
+	if o.Journald != nil {
+		if err := o.Journald.validate(); err != nil {
+			return fmt.Errorf("journald: %w", err)
+		}
+	}
+	if o.Logind != nil {
+		if err := o.Logind.validate(); err != nil {
+			return fmt.Errorf("logind: %w", err)
+		}
+	}
+	return nil
+}
+
+// EnabledUnits returns the names of the units to enable
+func (o *Options) EnabledUnits() []string {
+	var enabled []string
+	for _, u := range o.Units {
+		if u.Enabled {
+			enabled = append(enabled, u.Name)
+		}
+	}
+	return enabled
+}
+
+// boolValue returns the systemd boolean for b
+func boolValue(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// quoteEnvironment returns an Environment= assignment, quoted so that the
+// value may contain spaces, quotes and backslashes
+func quoteEnvironment(v EnvironmentVariable) string {
+	value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v.Value)
+	return fmt.Sprintf("Environment=\"%s=%s\"\n", v.Key, value)
+}
+
+func (d *DropIn) contents() string {
+	var b strings.Builder
+	if d.ConditionPathExists != "" {
+		fmt.Fprintf(&b, "[Unit]\nConditionPathExists=%s\n", d.ConditionPathExists)
+	}
+	if len(d.Environment) > 0 || len(d.EnvironmentFile) > 0 {
+		b.WriteString("[Service]\n")
+		for _, v := range d.Environment {
+			b.WriteString(quoteEnvironment(v))
+		}
+		for _, f := range d.EnvironmentFile {
+			fmt.Fprintf(&b, "EnvironmentFile=%s\n", f)
+		}
+	}
+	return b.String()
+}
+
+func (j *Journald) contents() string {
+	var b strings.Builder
+	b.WriteString("[Journal]\n")
+	settings := []struct{ key, value string }{
+		{"Storage", j.Storage},
+		{"SystemMaxUse", j.SystemMaxUse},
+		{"SystemKeepFree", j.SystemKeepFree},
+		{"SystemMaxFileSize", j.SystemMaxFileSize},
+		{"RuntimeMaxUse", j.RuntimeMaxUse},
+		{"MaxRetentionSec", j.MaxRetentionSec},
+	}
+	for _, s := range settings {
+		if s.value != "" {
+			fmt.Fprintf(&b, "%s=%s\n", s.key, s.value)
+		}
+	}
+	if j.ForwardToSyslog != nil {
+		fmt.Fprintf(&b, "ForwardToSyslog=%s\n", boolValue(*j.ForwardToSyslog))
+	}
+	return b.String()
+}
+
+func (l *Logind) contents() string {
+	var b strings.Builder
+	b.WriteString("[Login]\n")
+	if l.KillUserProcesses != nil {
+		fmt.Fprintf(&b, "KillUserProcesses=%s\n", boolValue(*l.KillUserProcesses))
+	}
+	settings := []struct{ key, value string }{
+		{"IdleAction", l.IdleAction},
+		{"IdleActionSec", l.IdleActionSec},
+		{"StopIdleSessionSec", l.StopIdleSessionSec},
+	}
+	for _, s := range settings {
+		if s.value != "" {
+			fmt.Fprintf(&b, "%s=%s\n", s.key, s.value)
+		}
+	}
+	return b.String()
+}
+
+// DropInNodes returns the service drop-ins and the journald and logind
+// settings as drop-in files in /etc, with their directories. It is used
+// for images that cannot run the systemd unit, journald and logind
+// stages; the units themselves are created in /etc by the unit create
+// stage.
+func (o *Options) DropInNodes() ([]*fsnode.Directory, []*fsnode.File, error) {
+	var dirs []*fsnode.Directory
+	var files []*fsnode.File
+	add := func(dir, name, contents string) error {
+		// no mode or owner, so that an existing directory is not an error
+		d, err := fsnode.NewDirectory(dir, nil, nil, nil, true)
+		if err != nil {
+			return err
+		}
+		f, err := fsnode.NewFile(filepath.Join(dir, name), common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(contents))
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, d)
+		files = append(files, f)
+		return nil
+	}
+
+	for _, d := range o.DropIns {
+		if err := add(filepath.Join(unitDir, d.Unit+".d"), d.Name, d.contents()); err != nil {
+			return nil, nil, err
+		}
+	}
+	if o.Journald != nil {
+		if err := add("/etc/systemd/journald.conf.d", configDropInName, o.Journald.contents()); err != nil {
+			return nil, nil, err
+		}
+	}
+	if o.Logind != nil {
+		if err := add("/etc/systemd/logind.conf.d", configDropInName, o.Logind.contents()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return dirs, files, nil
+}
@@ -0,0 +1,321 @@
+package systemd_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/systemd"
+)
+
+var (
+	cleanupService = systemd.Unit{
+		Name:    "cleanup.service",
+		Unit:    &systemd.UnitSection{Description: "Clean up the spool"},
+		Service: &systemd.ServiceSection{Type: "oneshot", ExecStart: []string{"/usr/local/bin/cleanup --all"}},
+		Install: &systemd.InstallSection{WantedBy: []string{"multi-user.target"}},
+	}
+	cleanupTimer = systemd.Unit{
+		Name:    "cleanup.timer",
+		Enabled: true,
+		Timer:   &systemd.TimerSection{OnCalendar: []string{"daily"}, Persistent: true},
+		Install: &systemd.InstallSection{WantedBy: []string{"timers.target"}},
+	}
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *systemd.Options
+		err     string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "service-and-timer",
+			options: &systemd.Options{
+				Units: []systemd.Unit{cleanupService, cleanupTimer},
+			},
+		},
+		{
+			name: "path-and-mount",
+			options: &systemd.Options{
+				Units: []systemd.Unit{
+					{
+						Name: "spool.path",
+						Path: &systemd.PathSection{DirectoryNotEmpty: []string{"/var/spool/in"}, Unit: "cleanup.service"},
+					},
+					{
+						Name:    "var-lib-data.mount",
+						Enabled: true,
+						Mount:   &systemd.MountSection{What: "nfs.example.com:/data", Where: "/var/lib/data", Type: "nfs"},
+						Install: &systemd.InstallSection{WantedBy: []string{"remote-fs.target"}},
+					},
+				},
+			},
+		},
+		{
+			name: "dropin",
+			options: &systemd.Options{
+				DropIns: []systemd.DropIn{{
+					Unit:        "httpd.service",
+					Name:        "10-env.conf",
+					Environment: []systemd.EnvironmentVariable{{Key: "OPTIONS", Value: "-DFOREGROUND"}},
+				}},
+			},
+		},
+		{
+			name:    "bad-name",
+			options: &systemd.Options{Units: []systemd.Unit{{Name: "cleanup.socket"}}},
+			err:     `invalid unit name "cleanup.socket" (must match ^[A-Za-z0-9:_.\\-]+(@[A-Za-z0-9:_.\\-]*)?\.(mount|path|service|timer)$)`,
+		},
+		{
+			name:    "duplicate",
+			options: &systemd.Options{Units: []systemd.Unit{cleanupService, cleanupService}},
+			err:     `duplicate unit "cleanup.service"`,
+		},
+		{
+			name:    "missing-section",
+			options: &systemd.Options{Units: []systemd.Unit{{Name: "cleanup.timer"}}},
+			err:     `unit "cleanup.timer": timer section is required`,
+		},
+		{
+			name: "wrong-section",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name:    "cleanup.timer",
+				Timer:   &systemd.TimerSection{OnBootSec: "5min"},
+				Service: &systemd.ServiceSection{ExecStart: []string{"/bin/true"}},
+			}}},
+			err: `unit "cleanup.timer": service section is not allowed in a timer unit`,
+		},
+		{
+			name: "service-without-exec-start",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name:    "cleanup.service",
+				Service: &systemd.ServiceSection{Type: "oneshot"},
+				Install: &systemd.InstallSection{},
+			}}},
+			err: `unit "cleanup.service": service.exec_start is required`,
+		},
+		{
+			name: "service-bad-type",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name:    "cleanup.service",
+				Service: &systemd.ServiceSection{Type: "cron", ExecStart: []string{"/bin/true"}},
+				Install: &systemd.InstallSection{},
+			}}},
+			err: `unit "cleanup.service": unsupported service type "cron" (must be one of simple, exec, forking, oneshot, dbus, notify, notify-reload, idle)`,
+		},
+		{
+			name: "service-without-install",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name:    "cleanup.service",
+				Service: &systemd.ServiceSection{ExecStart: []string{"/bin/true"}},
+			}}},
+			err: `unit "cleanup.service": install section is required for service units`,
+		},
+		{
+			name: "multiline-value",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name:    "cleanup.service",
+				Service: &systemd.ServiceSection{ExecStart: []string{"/bin/true\nExecStart=/bin/sh"}},
+				Install: &systemd.InstallSection{},
+			}}},
+			err: `unit "cleanup.service": value "/bin/true\nExecStart=/bin/sh" must be a single line`,
+		},
+		{
+			name: "timer-without-trigger",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name:  "cleanup.timer",
+				Timer: &systemd.TimerSection{Persistent: true},
+			}}},
+			err: `unit "cleanup.timer": timer section requires at least one on_* trigger`,
+		},
+		{
+			name: "path-relative",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name: "spool.path",
+				Path: &systemd.PathSection{PathChanged: []string{"spool"}},
+			}}},
+			err: `unit "spool.path": watched path "spool" must be absolute`,
+		},
+		{
+			name: "mount-wrong-name",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name:  "data.mount",
+				Mount: &systemd.MountSection{What: "/dev/vdb1", Where: "/var/lib/my-data"},
+			}}},
+			err: `unit "data.mount": mount unit for /var/lib/my-data must be named "var-lib-my\\x2ddata.mount"`,
+		},
+		{
+			name: "enabled-without-install",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name:    "cleanup.timer",
+				Enabled: true,
+				Timer:   &systemd.TimerSection{OnBootSec: "5min"},
+			}}},
+			err: `unit "cleanup.timer": enabled units require install.wanted_by or install.required_by`,
+		},
+		{
+			name: "enabled-template",
+			options: &systemd.Options{Units: []systemd.Unit{{
+				Name:    "worker@.service",
+				Enabled: true,
+				Service: &systemd.ServiceSection{ExecStart: []string{"/usr/bin/worker %i"}},
+				Install: &systemd.InstallSection{WantedBy: []string{"multi-user.target"}},
+			}}},
+			err: `unit "worker@.service": template units cannot be enabled`,
+		},
+		{
+			name: "dropin-not-service",
+			options: &systemd.Options{DropIns: []systemd.DropIn{{
+				Unit: "cleanup.timer", Name: "10-env.conf", ConditionPathExists: "/etc/cleanup",
+			}}},
+			err: `drop-in "10-env.conf" for "cleanup.timer": invalid unit name "cleanup.timer", drop-ins are supported for service units only`,
+		},
+		{
+			name: "dropin-bad-name",
+			options: &systemd.Options{DropIns: []systemd.DropIn{{
+				Unit: "httpd.service", Name: "env", ConditionPathExists: "/etc/httpd",
+			}}},
+			err: `drop-in "env" for "httpd.service": invalid drop-in name "env" (must match ^[A-Za-z0-9:_.\\-]+\.conf$)`,
+		},
+		{
+			name: "dropin-empty",
+			options: &systemd.Options{DropIns: []systemd.DropIn{{
+				Unit: "httpd.service", Name: "10-env.conf",
+			}}},
+			err: `drop-in "10-env.conf" for "httpd.service": drop-in is empty`,
+		},
+		{
+			name: "dropin-bad-env",
+			options: &systemd.Options{DropIns: []systemd.DropIn{{
+				Unit: "httpd.service", Name: "10-env.conf",
+				Environment: []systemd.EnvironmentVariable{{Key: "lower", Value: "x"}},
+			}}},
+			err: `drop-in "10-env.conf" for "httpd.service": invalid environment variable name "lower" (must match ^[A-Z][A-Z0-9_]*$)`,
+		},
+		{
+			name: "journald-and-logind",
+			options: &systemd.Options{
+				Journald: &systemd.Journald{
+					Storage:         "persistent",
+					SystemMaxUse:    "1G",
+					SystemKeepFree:  "15%",
+					MaxRetentionSec: "1month",
+					ForwardToSyslog: common.ToPtr(false),
+				},
+				Logind: &systemd.Logind{
+					KillUserProcesses:  common.ToPtr(true),
+					IdleAction:         "lock",
+					IdleActionSec:      "15min",
+					StopIdleSessionSec: "infinity",
+				},
+			},
+		},
+		{
+			name:    "journald-bad-storage",
+			options: &systemd.Options{Journald: &systemd.Journald{Storage: "disk"}},
+			err:     `journald: unsupported storage "disk" (must be one of auto, none, persistent, volatile)`,
+		},
+		{
+			name:    "journald-bad-size",
+			options: &systemd.Options{Journald: &systemd.Journald{SystemMaxUse: "1GB"}},
+			err:     `journald: invalid system_max_use "1GB" (must match ^([0-9]+[KMGTPE]?|[0-9]{1,2}%)$)`,
+		},
+		{
+			name:    "journald-bad-retention",
+			options: &systemd.Options{Journald: &systemd.Journald{MaxRetentionSec: "forever"}},
+			err:     `journald: invalid max_retention_sec "forever"`,
+		},
+		{
+			name:    "journald-empty",
+			options: &systemd.Options{Journald: &systemd.Journald{}},
+			err:     "journald: no settings",
+		},
+		{
+			name:    "logind-bad-idle-action",
+			options: &systemd.Options{Logind: &systemd.Logind{IdleAction: "sleep"}},
+			err:     `logind: unsupported idle_action "sleep" (must be one of ignore, poweroff, reboot, halt, kexec, suspend, hibernate, hybrid-sleep, suspend-then-hibernate, lock)`,
+		},
+		{
+			name:    "logind-idle-sec-without-action",
+			options: &systemd.Options{Logind: &systemd.Logind{IdleActionSec: "30min"}},
+			err:     "logind: idle_action_sec requires an idle_action",
+		},
+		{
+			name:    "logind-bad-stop-idle",
+			options: &systemd.Options{Logind: &systemd.Logind{StopIdleSessionSec: "-1"}},
+			err:     `logind: invalid stop_idle_session_sec "-1"`,
+		},
+		{
+			name:    "logind-empty",
+			options: &systemd.Options{Logind: &systemd.Logind{}},
+			err:     "logind: no settings",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMountUnitName(t *testing.T) {
+	assert.Equal(t, "-.mount", systemd.MountUnitName("/"))
+	assert.Equal(t, "var-lib-data.mount", systemd.MountUnitName("/var/lib/data"))
+	assert.Equal(t, `srv-my\x2ddata.mount`, systemd.MountUnitName("/srv/my-data"))
+}
+
+func TestEnabledUnits(t *testing.T) {
+	o := &systemd.Options{Units: []systemd.Unit{cleanupService, cleanupTimer}}
+	assert.Equal(t, []string{"cleanup.timer"}, o.EnabledUnits())
+}
+
+func TestDropInNodes(t *testing.T) {
+	o := &systemd.Options{
+		DropIns: []systemd.DropIn{
+			{
+				Unit:                "app.service",
+				Name:                "10-env.conf",
+				Environment:         []systemd.EnvironmentVariable{{Key: "GREETING", Value: `say "hi"`}},
+				EnvironmentFile:     []string{"-/etc/app.env"},
+				ConditionPathExists: "/etc/app.conf",
+			},
+		},
+		Journald: &systemd.Journald{Storage: "persistent", SystemMaxUse: "1G", ForwardToSyslog: common.ToPtr(false)},
+		Logind:   &systemd.Logind{KillUserProcesses: common.ToPtr(true), IdleAction: "lock", IdleActionSec: "15min"},
+	}
+	dirs, files, err := o.DropInNodes()
+	require.NoError(t, err)
+	require.Len(t, dirs, 3)
+	require.Len(t, files, 3)
+
+	assert.Equal(t, "/etc/systemd/system/app.service.d", dirs[0].Path())
+	assert.Equal(t, "/etc/systemd/system/app.service.d/10-env.conf", files[0].Path())
+	assert.Equal(t, fs.FileMode(0644), *files[0].Mode())
+	assert.Equal(t, `[Unit]
+ConditionPathExists=/etc/app.conf
+[Service]
+Environment="GREETING=say \"hi\""
+EnvironmentFile=-/etc/app.env
+`, string(files[0].Data()))
+
+	assert.Equal(t, "/etc/systemd/journald.conf.d", dirs[1].Path())
+	assert.Equal(t, "/etc/systemd/journald.conf.d/50-customizations.conf", files[1].Path())
+	assert.Equal(t, "[Journal]\nStorage=persistent\nSystemMaxUse=1G\nForwardToSyslog=no\n", string(files[1].Data()))
+
+	assert.Equal(t, "/etc/systemd/logind.conf.d", dirs[2].Path())
+	assert.Equal(t, "/etc/systemd/logind.conf.d/50-customizations.conf", files[2].Path())
+	assert.Equal(t, "[Login]\nKillUserProcesses=yes\nIdleAction=lock\nIdleActionSec=15min\n", string(files[2].Data()))
+}
@@ -0,0 +1,34 @@
+// Package tuned contains the TuneD profiles that are activated in the
+// image.
+package tuned
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// profileRegex matches the names of TuneD profiles, which are the names
+// of the directories in /usr/lib/tuned and /etc/tuned
+var profileRegex = regexp.MustCompile(`^[\w.+-]{1,250}$`)
+
+type Options struct {
+	// Profiles are merged by TuneD, later profiles override the
+	// settings of earlier ones
+	Profiles []string `json:"profiles" yaml:"profiles"`
+}
+
+// Validate checks the profile names
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if len(o.Profiles) == 0 {
+		return fmt.Errorf("at least one profile is required")
+	}
+	for _, profile := range o.Profiles {
+		if !profileRegex.MatchString(profile) {
+			return fmt.Errorf("invalid profile name %q (must match %s)", profile, profileRegex.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package tuned_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/pkg/customizations/tuned"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	var nilOptions *tuned.Options
+	assert.NoError(t, nilOptions.Validate())
+
+	assert.NoError(t, (&tuned.Options{Profiles: []string{"virtual-guest", "my-profile.v2"}}).Validate())
+	assert.EqualError(t, (&tuned.Options{}).Validate(), "at least one profile is required")
+	assert.EqualError(t, (&tuned.Options{Profiles: []string{"../balanced"}}).Validate(), `invalid profile name "../balanced" (must match ^[\w.+-]{1,250}$)`)
+}
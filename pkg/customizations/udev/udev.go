@@ -0,0 +1,218 @@
+// Package udev contains udev rules files that are written to
+// /etc/udev/rules.d.
+package udev
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+type udevOpType int
+
+const (
+	udevOpMatch  udevOpType = 0
+	udevOpAssign udevOpType = 1
+)
+
+var ops = map[string]udevOpType{
+	"=":  udevOpAssign,
+	"+=": udevOpAssign,
+	"-=": udevOpAssign,
+	":=": udevOpAssign,
+	"==": udevOpMatch,
+	"!=": udevOpMatch,
+}
+
+type udevKeyType struct {
+	Arg    bool
+	Assign bool
+	Match  bool
+}
+
+var keys = map[string]udevKeyType{
+	"ACTION":     {Match: true},
+	"DEVPATH":    {Match: true},
+	"KERNEL":     {Match: true},
+	"KERNELS":    {Match: true},
+	"NAME":       {Match: true, Assign: true},
+	"SYMLINK":    {Match: true, Assign: true},
+	"SUBSYSTEM":  {Match: true},
+	"SUBSYSTEMS": {Match: true},
+	"DRIVER":     {Match: true},
+	"DRIVERS":    {Match: true},
+	"TAG":        {Match: true, Assign: true},
+	"TAGS":       {Match: true},
+	"PROGRAM":    {Match: true},
+	"RESULT":     {Match: true},
+
+	"ATTR":   {Arg: true, Match: true, Assign: true},
+	"ATTRS":  {Arg: true, Match: true},
+	"SYSCTL": {Arg: true, Match: true, Assign: true},
+	"ENV":    {Arg: true, Match: true, Assign: true},
+	"CONST":  {Arg: true, Match: true},
+	"TEST":   {Arg: true, Match: true},
+
+	"OWNER":   {Assign: true},
+	"GROUP":   {Assign: true},
+	"MODE":    {Assign: true},
+	"LABEL":   {Assign: true},
+	"GOTO":    {Assign: true},
+	"OPTIONS": {Assign: true},
+
+	"SECLABEL": {Arg: true, Assign: true},
+	"RUN":      {Arg: true, Assign: true},
+	"IMPORT":   {Arg: true, Assign: true},
+}
+
+// ValidateOp checks that the key supports the operator, match operators
+// like "==" and assignments like "=" are only valid for some keys, and that
+// the keys that need an argument, e.g. ATTR{<file>}, have one
+func ValidateOp(key, op, val, arg string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if op == "" {
+		return fmt.Errorf("operator is required")
+	}
+	if val == "" {
+		return fmt.Errorf("value is required")
+	}
+
+	keyInfo, ok := keys[key]
+
+	if !ok {
+		return fmt.Errorf("key '%s' is unknown", key)
+	}
+
+	if keyInfo.Arg && arg == "" {
+		return fmt.Errorf("arg is required for key '%s'", key)
+	}
+
+	opType, ok := ops[op]
+
+	if !ok {
+		return fmt.Errorf("'%s' operator is not supported", op)
+	}
+
+	if (opType == udevOpMatch && !keyInfo.Match) ||
+		(opType == udevOpAssign && !keyInfo.Assign) {
+		return fmt.Errorf("key '%s' does not support '%s'", key, op)
+	}
+
+	return nil
+}
+
+const rulesDir = "/etc/udev/rules.d"
+
+// fileNameRegex matches the names of the rules files without the
+// ".rules" extension
+var fileNameRegex = regexp.MustCompile(`^[\w.-]{1,240}$`)
+
+type Options struct {
+	Files []File `json:"files" yaml:"files"`
+}
+
+// File is a rules file in /etc/udev/rules.d, the files are applied in the
+// lexicographic order of their names, e.g. "70-persistent-net"
+type File struct {
+	Name  string `json:"name" yaml:"name"`
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule is a single udev rule, all matches must apply for the assignments
+// to be made
+type Rule []Op
+
+// Op is a match or an assignment of a rule, e.g. SUBSYSTEM=="net" or
+// ATTR{mtu}="9000"
+type Op struct {
+	Key string `json:"key" yaml:"key"`
+	// Arg is the argument of the keys that need one, e.g. "mtu" for
+	// ATTR{mtu}
+	Arg   string `json:"arg,omitempty" yaml:"arg,omitempty"`
+	Op    string `json:"op" yaml:"op"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// Path returns the path of the rules file
+func (f *File) Path() string {
+	return path.Join(rulesDir, f.Name+".rules")
+}
+
+// Validate checks the names of the files and the rules
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, f := range o.Files {
+		if !fileNameRegex.MatchString(f.Name) {
+			return fmt.Errorf("invalid file name %q (must match %s)", f.Name, fileNameRegex.String())
+		}
+		if names[f.Name] {
+			return fmt.Errorf("duplicate file %q", f.Name)
+		}
+		names[f.Name] = true
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("file %q: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func (f *File) validate() error {
+	if len(f.Rules) == 0 {
+		return fmt.Errorf("no rules")
+	}
+	for i, rule := range f.Rules {
+		if len(rule) == 0 {
+			return fmt.Errorf("rule %d: empty", i)
+		}
+		for _, op := range rule {
+			if err := ValidateOp(op.Key, op.Op, op.Value, op.Arg); err != nil {
+				return fmt.Errorf("rule %d: %w", i, err)
+			}
+			if strings.ContainsAny(op.Value, "\"\n\r") || strings.ContainsAny(op.Arg, "{}\"\n\r") {
+				return fmt.Errorf("rule %d: key %q: value and arg must not contain quotes, braces or newlines", i, op.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// String returns the op as it is written in a rules file
+func (op Op) String() string {
+	key := op.Key
+	if op.Arg != "" {
+		key += "{" + op.Arg + "}"
+	}
+	return fmt.Sprintf("%s%s\"%s\"", key, op.Op, op.Value)
+}
+
+// FsNodeFiles returns the rules files in /etc/udev/rules.d, for images
+// that cannot run the udev rules stage
+func (o *Options) FsNodeFiles() ([]*fsnode.File, error) {
+	var files []*fsnode.File
+	for _, f := range o.Files {
+		var b strings.Builder
+		for _, rule := range f.Rules {
+			ops := make([]string, 0, len(rule))
+			for _, op := range rule {
+				ops = append(ops, op.String())
+			}
+			fmt.Fprintf(&b, "%s\n", strings.Join(ops, ", "))
+		}
+		file, err := fsnode.NewFile(f.Path(), common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(b.String()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
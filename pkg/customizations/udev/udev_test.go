@@ -0,0 +1,96 @@
+package udev_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/udev"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		file udev.File
+		err  string
+	}{
+		{
+			name: "ok",
+			file: udev.File{Name: "70-mtu", Rules: []udev.Rule{{
+				{Key: "ACTION", Op: "==", Value: "add"},
+				{Key: "SUBSYSTEM", Op: "==", Value: "net"},
+				{Key: "ATTR", Arg: "mtu", Op: "=", Value: "9000"},
+			}}},
+		},
+		{
+			name: "bad-name",
+			file: udev.File{Name: "../70-mtu", Rules: []udev.Rule{{{Key: "SUBSYSTEM", Op: "==", Value: "net"}}}},
+			err:  `invalid file name "../70-mtu" (must match ^[\w.-]{1,240}$)`,
+		},
+		{
+			name: "no-rules",
+			file: udev.File{Name: "70-mtu"},
+			err:  `file "70-mtu": no rules`,
+		},
+		{
+			name: "empty-rule",
+			file: udev.File{Name: "70-mtu", Rules: []udev.Rule{{}}},
+			err:  `file "70-mtu": rule 0: empty`,
+		},
+		{
+			name: "unknown-key",
+			file: udev.File{Name: "70-mtu", Rules: []udev.Rule{{{Key: "MTU", Op: "=", Value: "9000"}}}},
+			err:  `file "70-mtu": rule 0: key 'MTU' is unknown`,
+		},
+		{
+			name: "match-only-key",
+			file: udev.File{Name: "70-mtu", Rules: []udev.Rule{{{Key: "SUBSYSTEM", Op: "=", Value: "net"}}}},
+			err:  `file "70-mtu": rule 0: key 'SUBSYSTEM' does not support '='`,
+		},
+		{
+			name: "quote",
+			file: udev.File{Name: "70-mtu", Rules: []udev.Rule{{{Key: "RUN", Arg: "program", Op: "+=", Value: `/bin/sh -c "x"`}}}},
+			err:  `file "70-mtu": rule 0: key "RUN": value and arg must not contain quotes, braces or newlines`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := &udev.Options{Files: []udev.File{tc.file}}
+			if tc.err == "" {
+				assert.NoError(t, o.Validate())
+			} else {
+				assert.EqualError(t, o.Validate(), tc.err)
+			}
+		})
+	}
+}
+
+func TestFilePath(t *testing.T) {
+	f := udev.File{Name: "70-mtu"}
+	assert.Equal(t, "/etc/udev/rules.d/70-mtu.rules", f.Path())
+}
+
+func TestOptionsFsNodeFiles(t *testing.T) {
+	o := &udev.Options{
+		Files: []udev.File{
+			{
+				Name: "70-jumbo",
+				Rules: []udev.Rule{
+					{
+						{Key: "SUBSYSTEM", Op: "==", Value: "net"},
+						{Key: "ACTION", Op: "==", Value: "add"},
+						{Key: "ATTR", Arg: "mtu", Op: "=", Value: "9000"},
+					},
+				},
+			},
+		},
+	}
+	files, err := o.FsNodeFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/etc/udev/rules.d/70-jumbo.rules", files[0].Path())
+	assert.Equal(t, fs.FileMode(0644), *files[0].Mode())
+	assert.Equal(t, "SUBSYSTEM==\"net\", ACTION==\"add\", ATTR{mtu}=\"9000\"\n", string(files[0].Data()))
+}
@@ -16,6 +16,7 @@ package depsolvednf
 import (
 	"bytes"
 	"cmp"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -45,6 +46,9 @@ type BaseSolver struct {
 	depsolveDNFCmd []string
 
 	resultCache *dnfCache
+
+	// ctx stops a running osbuild-depsolve-dnf when cancelled
+	ctx context.Context
 }
 
 // Find the osbuild-depsolve-dnf script. This checks the default location in
@@ -92,6 +96,12 @@ func (s *BaseSolver) SetDepsolveDNFPath(cmd string, args ...string) {
 	s.depsolveDNFCmd = append([]string{cmd}, args...)
 }
 
+// SetContext sets the context that stops a running osbuild-depsolve-dnf
+// when it gets cancelled.
+func (s *BaseSolver) SetContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
 // NewWithConfig initialises a Solver with the platform information and the
 // BaseSolver's subscription info, cache directory, and osbuild-depsolve-dnf path.
 // Also loads system subscription information.
@@ -296,7 +306,7 @@ func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType
 	s.cache.locker.RLock()
 	defer s.cache.locker.RUnlock()
 
-	output, err := run(s.depsolveDNFCmd, reqData, s.Stderr)
+	output, err := run(s.ctx, s.depsolveDNFCmd, reqData, s.Stderr)
 	if err != nil {
 		return nil, parseError(output, allRepos, err)
 	}
@@ -369,7 +379,7 @@ func (s *Solver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, err
 		return pkgs, nil
 	}
 
-	rawRes, err := run(s.depsolveDNFCmd, reqData, s.Stderr)
+	rawRes, err := run(s.ctx, s.depsolveDNFCmd, reqData, s.Stderr)
 	if err != nil {
 		return nil, parseError(rawRes, repos, err)
 	}
@@ -417,7 +427,7 @@ func (s *Solver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rp
 		return pkgs, nil
 	}
 
-	rawRes, err := run(s.depsolveDNFCmd, reqData, s.Stderr)
+	rawRes, err := run(s.ctx, s.depsolveDNFCmd, reqData, s.Stderr)
 	if err != nil {
 		return nil, parseError(rawRes, repos, err)
 	}
@@ -596,7 +606,10 @@ func parseError(data []byte, repos []rpmmd.RepoConfig, cmdError error) Error {
 	return e
 }
 
-func run(dnfJsonCmd []string, reqData []byte, stderr io.Writer) ([]byte, error) {
+func run(ctx context.Context, dnfJsonCmd []string, reqData []byte, stderr io.Writer) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	if len(dnfJsonCmd) == 0 {
 		dnfJsonCmd = []string{findDepsolveDnf()}
 	}
@@ -608,7 +621,7 @@ func run(dnfJsonCmd []string, reqData []byte, stderr io.Writer) ([]byte, error)
 	if len(dnfJsonCmd) > 1 {
 		args = dnfJsonCmd[1:]
 	}
-	cmd := exec.Command(ex, args...)
+	cmd := exec.CommandContext(ctx, ex, args...)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("creating stdin pipe for %s failed: %w", ex, err)
@@ -635,6 +648,9 @@ func run(dnfJsonCmd []string, reqData []byte, stderr io.Writer) ([]byte, error)
 
 	err = cmd.Wait()
 	output := stdout.Bytes()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("running the depsolver was cancelled: %w", ctx.Err())
+	}
 	if err != nil {
 		return output, fmt.Errorf("running the depsolver failed: %w", err)
 	}
@@ -2,6 +2,7 @@ package depsolvednf
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -1096,6 +1097,28 @@ exit 1
 	}
 }
 
+func TestSolverRunCancelled(t *testing.T) {
+	fakeSolverPath := filepath.Join(t.TempDir(), "osbuild-depsolve-dnf")
+	fakeSolver := `#!/bin/sh -e
+cat - > "$0".stdin
+exec sleep 60
+`
+	err := os.WriteFile(fakeSolverPath, []byte(fakeSolver), 0o755) //nolint:gosec
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	solver := NewSolver("platform:f38", "38", "x86_64", "fedora-38", t.TempDir())
+	solver.depsolveDNFCmd = []string{fakeSolverPath}
+	solver.SetContext(ctx)
+
+	start := time.Now()
+	res, err := solver.Depsolve(nil, sbom.StandardTypeNone)
+	assert.ErrorContains(t, err, "running the depsolver was cancelled: context deadline exceeded")
+	assert.Nil(t, res)
+	assert.Less(t, time.Since(start), 30*time.Second)
+}
+
 func TestSolverRunWithSolverNoError(t *testing.T) {
 	for _, h := range getTestHandlers() {
 		t.Run(h.name, func(t *testing.T) {
@@ -856,6 +856,138 @@ func TestEnsureDirectorySizes(t *testing.T) {
 
 }
 
+func TestGrowToDirectorySizes(t *testing.T) {
+	partitionTables := testdisk.TestPartitionTables()
+	basePT := partitionTables["plain"]
+
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+	pt, err := disk.NewPartitionTable(&basePT, nil, 4*GiB, partition.RawPartitioningMode, arch.ARCH_X86_64, nil, "", rng)
+	require.NoError(t, err)
+	require.Equal(t, datasizes.Size(4*GiB), pt.Size)
+
+	// fits already: nothing changes
+	grown, err := pt.GrowToDirectorySizes(map[string]datasizes.Size{"/usr": 2 * GiB})
+	require.NoError(t, err)
+	assert.Empty(t, grown)
+	assert.Equal(t, datasizes.Size(4*GiB), pt.Size)
+
+	rootSize, err := pt.GetMountpointSize("/")
+	require.NoError(t, err)
+
+	// too big for the root partition: root and the table grow
+	grown, err = pt.GrowToDirectorySizes(map[string]datasizes.Size{"/usr": 6 * GiB})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]datasizes.Size{"/": rootSize}, grown)
+	newRootSize, err := pt.GetMountpointSize("/")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, newRootSize, datasizes.Size(6*GiB))
+	assert.Greater(t, pt.Size, datasizes.Size(6*GiB))
+
+	root := pt.Partitions[len(pt.Partitions)-1]
+	assert.LessOrEqual(t, root.Start+root.Size.Uint64(), pt.Size.Uint64())
+
+	_, err = pt.GrowToDirectorySizes(map[string]datasizes.Size{"invalid": 1})
+	assert.EqualError(t, err, `cannot find the filesystem of "invalid"`)
+
+	mountpoint, err := pt.GetMountpointOf("/usr/lib/modules")
+	require.NoError(t, err)
+	assert.Equal(t, "/", mountpoint)
+	mountpoint, err = pt.GetMountpointOf("/boot/efi/EFI")
+	require.NoError(t, err)
+	assert.Equal(t, "/boot/efi", mountpoint)
+}
+
+func TestAddSwapPartition(t *testing.T) {
+	partitionTables := testdisk.TestPartitionTables()
+
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+
+	t.Run("plain", func(t *testing.T) {
+		basePT := partitionTables["plain"]
+		pt, err := disk.NewPartitionTable(&basePT, nil, 4*GiB, partition.RawPartitioningMode, arch.ARCH_X86_64, nil, "", rng)
+		require.NoError(t, err)
+		rootSize, err := pt.GetMountpointSize("/")
+		require.NoError(t, err)
+
+		require.NoError(t, pt.AddSwapPartition(2*GiB, rng))
+		assert.True(t, disk.GetPartitionTableFeatures(*pt).Swap)
+		assert.GreaterOrEqual(t, pt.Size, datasizes.Size(6*GiB))
+
+		// the root partition keeps its size and stays the last partition
+		newRootSize, err := pt.GetMountpointSize("/")
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, newRootSize, rootSize)
+		root := pt.Partitions[len(pt.Partitions)-1]
+		assert.NotNil(t, disk.EntityPath(&root, "/"))
+
+		var swapPart *disk.Partition
+		for idx := range pt.Partitions {
+			if _, ok := pt.Partitions[idx].Payload.(*disk.Swap); ok {
+				swapPart = &pt.Partitions[idx]
+			}
+		}
+		require.NotNil(t, swapPart)
+		assert.Equal(t, disk.SwapPartitionGUID, swapPart.Type)
+		assert.Equal(t, datasizes.Size(2*GiB), swapPart.Size)
+		assert.NotEmpty(t, swapPart.UUID)
+		assert.NotEmpty(t, swapPart.Payload.(*disk.Swap).UUID)
+
+		assert.EqualError(t, pt.AddSwapPartition(2*GiB, rng), "partition table already contains a swap area")
+	})
+
+	t.Run("lvm", func(t *testing.T) {
+		basePT := partitionTables["plain"]
+		pt, err := disk.NewPartitionTable(&basePT, nil, 4*GiB, partition.LVMPartitioningMode, arch.ARCH_X86_64, nil, "", rng)
+		require.NoError(t, err)
+		nParts := len(pt.Partitions)
+
+		// the volume group is grown to hold the new logical volume
+		require.NoError(t, pt.AddSwapPartition(2*GiB, rng))
+		assert.Len(t, pt.Partitions, nParts)
+
+		var swapLV *disk.LVMLogicalVolume
+		for _, part := range pt.Partitions {
+			if vg, ok := part.Payload.(*disk.LVMVolumeGroup); ok {
+				for idx := range vg.LogicalVolumes {
+					if _, ok := vg.LogicalVolumes[idx].Payload.(*disk.Swap); ok {
+						swapLV = &vg.LogicalVolumes[idx]
+					}
+				}
+				assert.GreaterOrEqual(t, part.Size, datasizes.Size(2*GiB)+vg.LogicalVolumes[0].Size)
+			}
+		}
+		require.NotNil(t, swapLV)
+		assert.Equal(t, "swaplv", swapLV.Name)
+		assert.Equal(t, datasizes.Size(2*GiB), swapLV.Size)
+	})
+}
+
+func TestGrowForSwapFile(t *testing.T) {
+	partitionTables := testdisk.TestPartitionTables()
+
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+
+	basePT := partitionTables["plain"]
+	pt, err := disk.NewPartitionTable(&basePT, nil, 4*GiB, partition.RawPartitioningMode, arch.ARCH_X86_64, nil, "", rng)
+	require.NoError(t, err)
+	rootSize, err := pt.GetMountpointSize("/")
+	require.NoError(t, err)
+
+	require.NoError(t, pt.GrowForSwapFile("/var/swapfile", 2*GiB))
+	newRootSize, err := pt.GetMountpointSize("/")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, newRootSize, rootSize+2*GiB)
+	assert.GreaterOrEqual(t, pt.Size, datasizes.Size(6*GiB))
+
+	basePT = partitionTables["btrfs"]
+	pt, err = disk.NewPartitionTable(&basePT, nil, 4*GiB, partition.RawPartitioningMode, arch.ARCH_X86_64, nil, "", rng)
+	require.NoError(t, err)
+	assert.EqualError(t, pt.GrowForSwapFile("/swapfile", 2*GiB), `swap file "/swapfile" cannot be placed on a btrfs filesystem`)
+}
+
 func TestMinimumSizesWithRequiredSizes(t *testing.T) {
 	assert := assert.New(t)
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"slices"
 
 	"github.com/google/uuid"
 
@@ -34,6 +35,78 @@ type ClevisBind struct {
 	RemovePassphrase bool `json:"remove_passphrase,omitempty" yaml:"remove_passphrase,omitempty"`
 }
 
+// TPM2Enroll defines parameters for enrolling a TPM2 device in a LUKS device
+// with systemd-cryptenroll. The TPM2 of the target machine is not available
+// at build time, so the enrollment happens on first boot.
+type TPM2Enroll struct {
+	// The PCRs the key is bound to. Defaults to PCR 7 (Secure Boot state)
+	// when empty.
+	PCRs []uint `json:"pcrs,omitempty" yaml:"pcrs,omitempty"`
+
+	// If enabled, a recovery key is enrolled at build time and written
+	// next to the disk image (with mode 0600), so it can be stored away.
+	RecoveryKey bool `json:"recovery_key,omitempty" yaml:"recovery_key,omitempty"`
+
+	// If enabled, the passphrase will be removed from the LUKS device on
+	// first boot, after the TPM2 has been enrolled.
+	RemovePassphrase bool `json:"remove_passphrase,omitempty" yaml:"remove_passphrase,omitempty"`
+}
+
+// DefaultTPM2PCRs are the PCRs used for TPM2 enrollment if none are given.
+var DefaultTPM2PCRs = []uint{7}
+
+// maxTPM2PCR is the highest PCR index of a TPM2 (which has 24 PCRs).
+const maxTPM2PCR = 23
+
+// GetPCRs returns the PCRs to bind the key to, falling back to
+// DefaultTPM2PCRs.
+func (t *TPM2Enroll) GetPCRs() []uint {
+	if len(t.PCRs) == 0 {
+		return slices.Clone(DefaultTPM2PCRs)
+	}
+	return slices.Clone(t.PCRs)
+}
+
+// Validate checks that the PCRs exist on a TPM2.
+func (t *TPM2Enroll) Validate() error {
+	for _, pcr := range t.PCRs {
+		if pcr > maxTPM2PCR {
+			return fmt.Errorf("invalid TPM2 PCR %d: must be between 0 and %d", pcr, maxTPM2PCR)
+		}
+	}
+	return nil
+}
+
+// clone returns a deep copy of the enrollment parameters.
+func (t *TPM2Enroll) clone() *TPM2Enroll {
+	return &TPM2Enroll{
+		PCRs:             slices.Clone(t.PCRs),
+		RecoveryKey:      t.RecoveryKey,
+		RemovePassphrase: t.RemovePassphrase,
+	}
+}
+
+// EnrollTPM2 sets the TPM2 enrollment parameters on every LUKS container of
+// the partition table. It fails if the partition table has no LUKS
+// container, as there would be nothing to enroll the TPM2 in.
+func (pt *PartitionTable) EnrollTPM2(tpm2 *TPM2Enroll) error {
+	if err := tpm2.Validate(); err != nil {
+		return err
+	}
+	var found bool
+	_ = pt.ForEachEntity(func(e Entity, path []Entity) error {
+		if lc, ok := e.(*LUKSContainer); ok {
+			lc.TPM2 = tpm2.clone()
+			found = true
+		}
+		return nil
+	})
+	if !found {
+		return fmt.Errorf("partition table has no LUKS container to enroll the TPM2 in")
+	}
+	return nil
+}
+
 // LUKSContainer represents a LUKS encrypted volume.
 type LUKSContainer struct {
 	Passphrase string `json:"passphrase,omitempty" yaml:"passphrase,omitempty"`
@@ -49,6 +122,9 @@ type LUKSContainer struct {
 	// Parameters for binding the LUKS device.
 	Clevis *ClevisBind `json:"clevis,omitempty" yaml:"clevis,omitempty"`
 
+	// Parameters for enrolling a TPM2 device on first boot.
+	TPM2 *TPM2Enroll `json:"tpm2,omitempty" yaml:"tpm2,omitempty"`
+
 	Payload Entity `json:"payload,omitempty" yaml:"payload,omitempty"`
 }
 
@@ -99,6 +175,9 @@ func (lc *LUKSContainer) Clone() Entity {
 			RemovePassphrase: lc.Clevis.RemovePassphrase,
 		}
 	}
+	if lc.TPM2 != nil {
+		clc.TPM2 = lc.TPM2.clone()
+	}
 	return clc
 }
 
@@ -147,6 +226,12 @@ func (lc *LUKSContainer) UnmarshalJSON(data []byte) (err error) {
 	}
 	*lc = LUKSContainer(withoutPayload.alias)
 
+	if lc.TPM2 != nil {
+		if err := lc.TPM2.Validate(); err != nil {
+			return err
+		}
+	}
+
 	lc.Payload, err = unmarshalJSONPayload(data)
 	return err
 }
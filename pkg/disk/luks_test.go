@@ -1,11 +1,78 @@
 package disk_test
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/osbuild/image-builder/pkg/disk"
 )
 
 func TestImplementsInterfacesCompileTimeCheckLUKS(t *testing.T) {
 	var _ = disk.Container(&disk.LUKSContainer{})
 }
+
+func TestLUKSContainerUnmarshalTPM2InvalidPCR(t *testing.T) {
+	inputJSON := `{"passphrase": "osbuild", "tpm2": {"pcrs": [7, 24]}}`
+
+	var lc disk.LUKSContainer
+	err := json.Unmarshal([]byte(inputJSON), &lc)
+	assert.EqualError(t, err, "invalid TPM2 PCR 24: must be between 0 and 23")
+}
+
+func TestLUKSContainerCloneTPM2(t *testing.T) {
+	lc := &disk.LUKSContainer{
+		Passphrase: "osbuild",
+		TPM2: &disk.TPM2Enroll{
+			PCRs:        []uint{7},
+			RecoveryKey: true,
+		},
+		Payload: &disk.Filesystem{Type: "xfs", Mountpoint: "/"},
+	}
+
+	clone := lc.Clone().(*disk.LUKSContainer)
+	assert.Equal(t, lc, clone)
+
+	// the clone must not share the PCR list
+	clone.TPM2.PCRs[0] = 11
+	assert.Equal(t, []uint{7}, lc.TPM2.PCRs)
+}
+
+func TestTPM2EnrollGetPCRs(t *testing.T) {
+	assert.Equal(t, []uint{7}, (&disk.TPM2Enroll{}).GetPCRs())
+	assert.Equal(t, []uint{0, 7}, (&disk.TPM2Enroll{PCRs: []uint{0, 7}}).GetPCRs())
+}
+
+func TestPartitionTableEnrollTPM2(t *testing.T) {
+	pt := &disk.PartitionTable{
+		Partitions: []disk.Partition{
+			{Payload: &disk.Filesystem{Type: "xfs", Mountpoint: "/boot"}},
+			{
+				Payload: &disk.LUKSContainer{
+					Passphrase: "osbuild",
+					Payload:    &disk.Filesystem{Type: "xfs", Mountpoint: "/"},
+				},
+			},
+		},
+	}
+
+	tpm2 := &disk.TPM2Enroll{PCRs: []uint{0, 7}, RemovePassphrase: true}
+	assert.NoError(t, pt.EnrollTPM2(tpm2))
+	lc := pt.Partitions[1].Payload.(*disk.LUKSContainer)
+	assert.Equal(t, tpm2, lc.TPM2)
+
+	// every container gets its own copy
+	tpm2.PCRs[0] = 11
+	assert.Equal(t, []uint{0, 7}, lc.TPM2.PCRs)
+}
+
+func TestPartitionTableEnrollTPM2Errors(t *testing.T) {
+	pt := &disk.PartitionTable{
+		Partitions: []disk.Partition{
+			{Payload: &disk.Filesystem{Type: "xfs", Mountpoint: "/"}},
+		},
+	}
+	assert.EqualError(t, pt.EnrollTPM2(&disk.TPM2Enroll{}), "partition table has no LUKS container to enroll the TPM2 in")
+	assert.EqualError(t, pt.EnrollTPM2(&disk.TPM2Enroll{PCRs: []uint{24}}), "invalid TPM2 PCR 24: must be between 0 and 23")
+}
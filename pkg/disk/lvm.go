@@ -195,6 +195,11 @@ type LVMLogicalVolume struct {
 	Name    string         `json:"name,omitempty" yaml:"name,omitempty"`
 	Size    datasizes.Size `json:"size,omitempty" yaml:"size,omitempty"`
 	Payload Entity         `json:"payload,omitempty" yaml:"payload,omitempty"`
+
+	// If set, the logical volume and its filesystem are grown on first
+	// boot to take up the free space of the volume group. At most one
+	// logical volume per volume group can be grown.
+	Grow bool `json:"grow,omitempty" yaml:"grow,omitempty"`
 }
 
 func (lv *LVMLogicalVolume) Clone() Entity {
@@ -205,6 +210,7 @@ func (lv *LVMLogicalVolume) Clone() Entity {
 		Name:    lv.Name,
 		Size:    lv.Size,
 		Payload: lv.Payload.Clone(),
+		Grow:    lv.Grow,
 	}
 }
 
@@ -32,6 +32,11 @@ type Partition struct {
 
 	// Partition GPT attribute flags to set
 	Attrs []uint `json:"attrs,omitempty" yaml:"attrs,omitempty"`
+
+	// If set, the partition is grown to fill the disk on first boot, along
+	// with the filesystem or LVM physical volume it contains. Only the last
+	// partition of a partition table can be grown.
+	Grow bool `json:"grow,omitempty" yaml:"grow,omitempty"`
 }
 
 func (p *Partition) Clone() Entity {
@@ -47,6 +52,7 @@ func (p *Partition) Clone() Entity {
 		UUID:     p.UUID,
 		Label:    p.Label,
 		Attrs:    slices.Clone(p.Attrs),
+		Grow:     p.Grow,
 	}
 
 	if p.Payload != nil {
@@ -360,6 +360,16 @@ func (pt *PartitionTable) findDirectoryEntityPath(dir string) []Entity {
 	return pt.findDirectoryEntityPath(parent)
 }
 
+// GetMountpointOf returns the mountpoint of the filesystem that holds the
+// given absolute path.
+func (pt *PartitionTable) GetMountpointOf(path string) (string, error) {
+	entPath := pt.findDirectoryEntityPath(path)
+	if entPath == nil {
+		return "", fmt.Errorf("cannot find the filesystem of %q", path)
+	}
+	return entPath[0].(Mountable).GetMountpoint(), nil
+}
+
 // EnsureDirectorySizes takes a mapping of directory paths to sizes (in bytes)
 // and resizes the appropriate partitions such that they are at least the size
 // of the sum of their subdirectories plus their own sizes.
@@ -393,6 +403,44 @@ func (pt *PartitionTable) EnsureDirectorySizes(dirSizeMap map[string]datasizes.S
 	}
 }
 
+// GrowToDirectorySizes resizes the partition table like EnsureDirectorySizes
+// but can be used on a partition table that has already been laid out. The
+// layout is recalculated if any entity had to be grown, which grows the
+// overall size of the partition table if the partitions do not fit anymore.
+// Returns the mountpoints whose entities were grown, mapped to their previous
+// size.
+func (pt *PartitionTable) GrowToDirectorySizes(dirSizeMap map[string]datasizes.Size) (map[string]datasizes.Size, error) {
+	if entityPath(pt, "/") == nil {
+		return nil, nil
+	}
+
+	prevSizes := make(map[string]datasizes.Size)
+	for dir := range dirSizeMap {
+		mountpoint, err := pt.GetMountpointOf(dir)
+		if err != nil {
+			return nil, err
+		}
+		size, err := pt.GetMountpointSize(mountpoint)
+		if err != nil {
+			return nil, err
+		}
+		prevSizes[mountpoint] = size
+	}
+
+	pt.EnsureDirectorySizes(dirSizeMap)
+
+	grown := make(map[string]datasizes.Size)
+	for mountpoint, prevSize := range prevSizes {
+		if size, _ := pt.GetMountpointSize(mountpoint); size > prevSize {
+			grown[mountpoint] = prevSize
+		}
+	}
+	if len(grown) > 0 {
+		pt.relayout(pt.Size)
+	}
+	return grown, nil
+}
+
 func (pt *PartitionTable) CreateMountpoint(mountpoint, defaultFs string, size datasizes.Size) (Entity, error) {
 	filesystem := Filesystem{
 		Type:         defaultFs,
@@ -947,8 +995,10 @@ type partitionTableFeatures struct {
 	FAT   bool
 	EXT4  bool
 	LUKS  bool
+	TPM2  bool
 	Swap  bool
 	Raw   bool
+	Grow  bool
 }
 
 // features examines all of the PartitionTable entities and returns a struct
@@ -983,7 +1033,14 @@ func (pt *PartitionTable) features() partitionTableFeatures {
 			ptFeatures.Swap = true
 		case *LUKSContainer:
 			ptFeatures.LUKS = true
-		case *PartitionTable, *Partition:
+			if ent.TPM2 != nil {
+				ptFeatures.TPM2 = true
+			}
+		case *Partition:
+			if ent.Grow {
+				ptFeatures.Grow = true
+			}
+		case *PartitionTable:
 			// nothing to do
 		default:
 			panic(fmt.Errorf("unknown entity type %T", e))
@@ -995,6 +1052,12 @@ func (pt *PartitionTable) features() partitionTableFeatures {
 	return ptFeatures
 }
 
+// GrowsOnBoot returns true if a partition of the partition table is grown to
+// fill the disk on first boot.
+func (pt *PartitionTable) GrowsOnBoot() bool {
+	return pt.features().Grow
+}
+
 // GetBuildPackages returns an array of packages needed to support the features used in the PartitionTable.
 func (pt *PartitionTable) GetBuildPackages() []string {
 	packages := []string{}
@@ -1023,6 +1086,10 @@ func (pt *PartitionTable) GetBuildPackages() []string {
 			"cryptsetup",
 		)
 	}
+	if features.TPM2 {
+		// TPM2 support of systemd-cryptenroll and systemd-cryptsetup
+		packages = append(packages, "tpm2-tss")
+	}
 
 	return packages
 }
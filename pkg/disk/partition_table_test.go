@@ -3388,6 +3388,14 @@ func TestPartitionTableFeatures(t *testing.T) {
 	}
 }
 
+func TestPartitionTableGrowsOnBoot(t *testing.T) {
+	pt := testdisk.TestPartitionTables()["plain"]
+	assert.False(t, pt.GrowsOnBoot())
+
+	pt.Partitions[len(pt.Partitions)-1].Grow = true
+	assert.True(t, pt.GrowsOnBoot())
+}
+
 func TestUnmarshalSizeUnitStringPartitionTable(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -58,6 +58,7 @@ partition_table:
       size: "2 GiB"
       type: *filesystem_data_guid
       uuid: *root_partition_uuid
+      grow: true
       payload_type: "filesystem"
       payload:
         type: "ext4"
@@ -106,6 +107,7 @@ partition_table:
 				Type:     "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
 				Bootable: false,
 				UUID:     "6264D520-3FB9-423F-8AB8-7A0A8E3D3562",
+				Grow:     true,
 				Payload: &disk.Filesystem{
 					Type:         "ext4",
 					UUID:         "",
@@ -139,6 +141,10 @@ partition_table:
           iterations: 4
         clevis:
           pin: "null"
+        tpm2:
+          pcrs: [7, 11]
+          recovery_key: true
+          remove_passphrase: true
         payload_type: "lvm"
         payload:
           name: "rootvg"
@@ -146,6 +152,7 @@ partition_table:
           logical_volumes:
             - size: 123456789
               name: "rootlv"
+              grow: true
               payload_type: "filesystem"
               payload:
                 type: "ext4"
@@ -176,6 +183,11 @@ partition_table:
 					Clevis: &disk.ClevisBind{
 						Pin: "null",
 					},
+					TPM2: &disk.TPM2Enroll{
+						PCRs:             []uint{7, 11},
+						RecoveryKey:      true,
+						RemovePassphrase: true,
+					},
 					Payload: &disk.LVMVolumeGroup{
 						Name:        "rootvg",
 						Description: "bla",
@@ -183,6 +195,7 @@ partition_table:
 							{
 								Name: "rootlv",
 								Size: 123456789,
+								Grow: true,
 								Payload: &disk.Filesystem{
 									Type:       "ext4",
 									Mountpoint: "/",
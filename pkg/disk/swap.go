@@ -1,10 +1,15 @@
 package disk
 
 import (
+	"fmt"
 	"math/rand"
+	"path/filepath"
 	"reflect"
 
 	"github.com/google/uuid"
+
+	"github.com/osbuild/image-builder/pkg/arch"
+	"github.com/osbuild/image-builder/pkg/datasizes"
 )
 
 // Swap defines the payload for a swap partition. It's similar to a
@@ -75,3 +80,78 @@ func (s *Swap) GenUUID(rng *rand.Rand) {
 		s.UUID = uuid.Must(newRandomUUIDFromReader(rng)).String()
 	}
 }
+
+// AddSwapPartition adds a swap area of the given size to a partition table
+// that has already been laid out. When the root filesystem is on LVM the
+// swap area is added as a logical volume to the same volume group,
+// otherwise a new swap partition is added. The layout is recalculated,
+// which grows the partition table by the size of the swap area.
+func (pt *PartitionTable) AddSwapPartition(size datasizes.Size, rng *rand.Rand) error {
+	if pt.features().Swap {
+		return fmt.Errorf("partition table already contains a swap area")
+	}
+
+	rootPath := entityPath(pt, "/")
+	if rootPath == nil {
+		return fmt.Errorf("no root filesystem found in partition table")
+	}
+
+	swap := &Swap{FSTabOptions: "defaults"}
+
+	for idx, ent := range rootPath {
+		if vg, ok := ent.(*LVMVolumeGroup); ok {
+			lv, err := vg.CreateLogicalVolume("", 0, swap)
+			if err != nil {
+				return fmt.Errorf("failed creating swap logical volume: %w", err)
+			}
+			lvPath := append([]Entity{lv}, rootPath[idx:]...)
+			resizeEntityBranch(lvPath, alignEntityBranch(lvPath, size))
+			pt.relayout(pt.Size)
+			pt.GenerateUUIDs(rng)
+			return nil
+		}
+	}
+
+	maxNo := 4
+	if pt.Type == PT_GPT {
+		maxNo = 128
+	}
+	if len(pt.Partitions) >= maxNo {
+		return fmt.Errorf("maximum number of partitions reached (%d)", maxNo)
+	}
+
+	typeID, err := getPartitionTypeIDfor(pt.Type, "swap", arch.ARCH_UNSET)
+	if err != nil {
+		return err
+	}
+	pt.Partitions = append(pt.Partitions, Partition{
+		Type:    typeID,
+		Size:    pt.AlignUp(size),
+		Payload: swap,
+	})
+	pt.relayout(pt.Size)
+	pt.GenerateUUIDs(rng)
+	return nil
+}
+
+// GrowForSwapFile grows the filesystem that will hold the swap file at the
+// given path by the size of the file on a partition table that has already
+// been laid out. Swap files are not supported on btrfs.
+func (pt *PartitionTable) GrowForSwapFile(path string, size datasizes.Size) error {
+	entPath := pt.findDirectoryEntityPath(filepath.Dir(path))
+	if entPath == nil {
+		return fmt.Errorf("no filesystem found for swap file %q", path)
+	}
+	mnt := entPath[0].(Mountable)
+	if mnt.GetFSType() == "btrfs" {
+		return fmt.Errorf("swap file %q cannot be placed on a btrfs filesystem", path)
+	}
+
+	current, err := pt.GetMountpointSize(mnt.GetMountpoint())
+	if err != nil {
+		return err
+	}
+	resizeEntityBranch(entPath, alignEntityBranch(entPath, current+size))
+	pt.relayout(pt.Size)
+	return nil
+}
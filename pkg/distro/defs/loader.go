@@ -521,6 +521,7 @@ type ImageTypeYAML struct {
 	Filename    string                      `yaml:"filename"`
 	MimeType    string                      `yaml:"mime_type"`
 	Compression string                      `yaml:"compression"`
+	Bmap        bool                        `yaml:"bmap"`
 	Environment environment.EnvironmentConf `yaml:"environment"`
 	Bootable    bool                        `yaml:"bootable"`
 
@@ -557,6 +558,10 @@ type ImageTypeYAML struct {
 
 	SupportedPartitioningModes []partition.PartitioningMode `yaml:"supported_partitioning_modes"`
 
+	// image options (by their json name) that only some image types
+	// can apply, e.g. "sysctl", see checkOptionsCommon()
+	SupportedOptions []string `yaml:"supported_options"`
+
 	Blueprint struct {
 		SupportedOptions []string `yaml:"supported_options"`
 		RequiredOptions  []string `yaml:"required_options"`
@@ -14,6 +14,11 @@ type DiskConfig struct {
 
 	// Mostly for RHEL7 compat though might be purposed in the future
 	PartitioningTool *osbuild.PartTool `yaml:"partitioning_tool,omitempty"`
+
+	// GrowMethod determines how partitions that are marked to grow in the
+	// partition table are grown to fill the disk on first boot, either via
+	// systemd-repart ("repart") or cloud-init ("growpart")
+	GrowMethod *osbuild.GrowMethod `yaml:"grow_method,omitempty"`
 }
 
 // InheritFrom inherits unset values from the provided parent configuration and
@@ -5,7 +5,23 @@ import (
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/image-builder/pkg/arch"
+	"github.com/osbuild/image-builder/pkg/customizations/autoupdate"
+	"github.com/osbuild/image-builder/pkg/customizations/cron"
+	"github.com/osbuild/image-builder/pkg/customizations/cryptopolicy"
+	"github.com/osbuild/image-builder/pkg/customizations/kdump"
+	"github.com/osbuild/image-builder/pkg/customizations/logrotate"
+	"github.com/osbuild/image-builder/pkg/customizations/modprobe"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
+	"github.com/osbuild/image-builder/pkg/customizations/proxy"
+	"github.com/osbuild/image-builder/pkg/customizations/selinux"
+	"github.com/osbuild/image-builder/pkg/customizations/sshauth"
 	"github.com/osbuild/image-builder/pkg/customizations/subscription"
+	"github.com/osbuild/image-builder/pkg/customizations/sudoers"
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
+	"github.com/osbuild/image-builder/pkg/customizations/sysctl"
+	"github.com/osbuild/image-builder/pkg/customizations/systemd"
+	"github.com/osbuild/image-builder/pkg/customizations/tuned"
+	"github.com/osbuild/image-builder/pkg/customizations/udev"
 	"github.com/osbuild/image-builder/pkg/depsolvednf"
 	"github.com/osbuild/image-builder/pkg/disk"
 	"github.com/osbuild/image-builder/pkg/disk/partition"
@@ -189,6 +205,64 @@ type ImageOptions struct {
 	Facts            *facts.ImageOptions        `json:"facts,omitempty"`
 	PartitioningMode partition.PartitioningMode `json:"partitioning-mode,omitempty"`
 
+	// TPM2 enrollment on first boot for the LUKS containers of the
+	// partition table of disk images
+	TPM2 *disk.TPM2Enroll `json:"tpm2,omitempty"`
+
+	// SELinux policy modifications (booleans, modules, file contexts and
+	// ports) applied to the image at build time, this needs the
+	// org.osbuild.semanage stage which is not in osbuild yet
+	SELinux *selinux.Options `json:"selinux,omitempty"`
+
+	// System-wide crypto policy with optional custom subpolicy modules
+	CryptoPolicy *cryptopolicy.Options `json:"crypto_policy,omitempty"`
+
+	// HTTP proxy used by dnf/yum, subscription-manager and login sessions
+	Proxy *proxy.Options `json:"proxy,omitempty"`
+
+	// Swap partition, swap file and zram configuration
+	Swap *swap.Options `json:"swap,omitempty"`
+
+	// kdump crash kernel reservation and dump target
+	Kdump *kdump.Options `json:"kdump,omitempty"`
+
+	// sudoers drop-ins written to /etc/sudoers.d
+	Sudoers *sudoers.Options `json:"sudoers,omitempty"`
+
+	// sshd user certificate authorities, principals and authorized keys
+	// command
+	SSHAuth *sshauth.Options `json:"ssh_auth,omitempty"`
+
+	// systemd units (services, timers, paths and mounts), service
+	// drop-ins and journald and logind settings
+	Systemd *systemd.Options `json:"systemd,omitempty"`
+
+	// logrotate drop-ins written to /etc/logrotate.d
+	Logrotate *logrotate.Options `json:"logrotate,omitempty"`
+
+	// automatic update policy, implemented with dnf-automatic, rpm-ostreed
+	// or the bootc update timer depending on the image type
+	AutoUpdate *autoupdate.Options `json:"auto_update,omitempty"`
+
+	// scheduled jobs written to /etc/cron.d
+	Cron *cron.Options `json:"cron,omitempty"`
+
+	// kernel parameters written to /etc/sysctl.d
+	Sysctl *sysctl.Options `json:"sysctl,omitempty"`
+
+	// TuneD profiles activated in the image
+	Tuned *tuned.Options `json:"tuned,omitempty"`
+
+	// kernel module configuration written to /etc/modprobe.d
+	Modprobe *modprobe.Options `json:"modprobe,omitempty"`
+
+	// udev rules written to /etc/udev/rules.d
+	Udev *udev.Options `json:"udev,omitempty"`
+
+	// NetworkManager connection profiles, written as keyfiles or, for
+	// installers, as kickstart network commands
+	Network *network.Options `json:"network,omitempty"`
+
 	UseBootstrapContainer bool `json:"use_bootstrap_container,omitempty"`
 
 	// Determines if the image being built is a preview image or not. When left
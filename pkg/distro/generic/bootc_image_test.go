@@ -6,12 +6,29 @@ import (
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/autoupdate"
+	"github.com/osbuild/image-builder/pkg/customizations/cron"
+	"github.com/osbuild/image-builder/pkg/customizations/cryptopolicy"
+	"github.com/osbuild/image-builder/pkg/customizations/kdump"
+	"github.com/osbuild/image-builder/pkg/customizations/logrotate"
+	"github.com/osbuild/image-builder/pkg/customizations/modprobe"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
+	"github.com/osbuild/image-builder/pkg/customizations/proxy"
+	"github.com/osbuild/image-builder/pkg/customizations/selinux"
+	"github.com/osbuild/image-builder/pkg/customizations/sshauth"
 	"github.com/osbuild/image-builder/pkg/customizations/subscription"
+	"github.com/osbuild/image-builder/pkg/customizations/sudoers"
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
+	"github.com/osbuild/image-builder/pkg/customizations/sysctl"
+	"github.com/osbuild/image-builder/pkg/customizations/systemd"
+	"github.com/osbuild/image-builder/pkg/customizations/tuned"
+	"github.com/osbuild/image-builder/pkg/customizations/udev"
 	"github.com/osbuild/image-builder/pkg/datasizes"
 	"github.com/osbuild/image-builder/pkg/disk"
 	"github.com/osbuild/image-builder/pkg/disk/partition"
 	"github.com/osbuild/image-builder/pkg/distro"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func createRand() *rand.Rand {
@@ -692,3 +709,171 @@ func TestManifestSubscriptionCustomization(t *testing.T) {
 	assert.Contains(t, string(manifestJson), "osbuild-subscription-register.service")
 	assert.Contains(t, string(manifestJson), "/etc/osbuild-subscription-register.env")
 }
+
+func TestManifestAutoUpdateCronCustomization(t *testing.T) {
+	imgType := NewTestBootcImageType(t, "qcow2")
+	imgOpts := distro.ImageOptions{
+		AutoUpdate: &autoupdate.Options{Policy: "download-only"},
+		Cron: &cron.Options{
+			Jobs: []cron.Job{{Name: "backup", Schedule: "@daily", Command: "/usr/local/bin/backup"}},
+		},
+	}
+
+	mf, _, err := imgType.Manifest(&blueprint.Blueprint{}, imgOpts, nil, common.ToPtr(int64(0)))
+	assert.NoError(t, err)
+	manifestJson, err := mf.Serialize(nil, diskContainers, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, string(manifestJson), "/etc/systemd/system/bootc-fetch-apply-updates.service.d/50-auto-update.conf")
+	assert.Contains(t, string(manifestJson), "/etc/cron.d/backup")
+
+	// bootc images update the whole image
+	imgOpts.AutoUpdate.Policy = "security"
+	_, _, err = imgType.Manifest(&blueprint.Blueprint{}, imgOpts, nil, common.ToPtr(int64(0)))
+	assert.EqualError(t, err, `options validation failed for image type "qcow2": auto_update: policy "security" is only supported on package-based images`)
+}
+
+func TestManifestBootcFileImageOptions(t *testing.T) {
+	imgType := NewTestBootcImageType(t, "qcow2")
+	imgOpts := distro.ImageOptions{
+		Sudoers: &sudoers.Options{
+			DropIns: []sudoers.DropIn{{Name: "admins", Contents: "%admins ALL=(ALL) ALL"}},
+		},
+		SSHAuth: &sshauth.Options{
+			TrustedUserCAKeys: []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMZ4b0kJvIYSyqRhzD0y3WjKUpN1HhkJTjbIBtUc6mhN ca"},
+		},
+		Systemd: &systemd.Options{
+			Units: []systemd.Unit{
+				{
+					Name:    "app.service",
+					Enabled: true,
+					Service: &systemd.ServiceSection{ExecStart: []string{"/usr/bin/app"}},
+					Install: &systemd.InstallSection{WantedBy: []string{"multi-user.target"}},
+				},
+			},
+			DropIns: []systemd.DropIn{
+				{Unit: "sshd.service", Name: "10-env.conf", Environment: []systemd.EnvironmentVariable{{Key: "OPTIONS", Value: "-4"}}},
+			},
+			Journald: &systemd.Journald{Storage: "persistent"},
+			Logind:   &systemd.Logind{IdleAction: "lock", IdleActionSec: "15min"},
+		},
+		Logrotate: &logrotate.Options{
+			DropIns: []logrotate.DropIn{{Name: "app", Paths: []string{"/var/log/app.log"}, Frequency: "daily", Rotate: common.ToPtr(7)}},
+		},
+		Sysctl: &sysctl.Options{
+			Files: []sysctl.File{{Name: "90-forward", Settings: []sysctl.Setting{{Key: "net.ipv4.ip_forward", Value: "1"}}}},
+		},
+		Modprobe: &modprobe.Options{
+			Files: []modprobe.File{{Name: "no-usb-storage", Blacklist: []string{"usb_storage"}}},
+		},
+		Udev: &udev.Options{
+			Files: []udev.File{{Name: "70-mtu", Rules: []udev.Rule{{
+				{Key: "SUBSYSTEM", Op: "==", Value: "net"},
+				{Key: "ATTR", Arg: "mtu", Op: "=", Value: "9000"},
+			}}}},
+		},
+		Network: &network.Options{
+			Connections: []network.Connection{{Name: "eth0", Type: network.ConnectionTypeEthernet, Interface: "eth0", IPv4: &network.IPConfig{Method: network.IPMethodAuto}}},
+		},
+	}
+
+	mf, _, err := imgType.Manifest(&blueprint.Blueprint{}, imgOpts, nil, common.ToPtr(int64(0)))
+	require.NoError(t, err)
+	manifestJson, err := mf.Serialize(nil, diskContainers, nil, nil, nil)
+	require.NoError(t, err)
+	for _, path := range []string{
+		"/etc/sudoers.d/admins",
+		"/etc/ssh/trusted_user_ca_keys",
+		"/etc/ssh/sshd_config.d/40-ssh-auth.conf",
+		"/etc/systemd/system/sshd.service.d/10-env.conf",
+		"/etc/systemd/journald.conf.d/50-customizations.conf",
+		"/etc/systemd/logind.conf.d/50-customizations.conf",
+		"/etc/logrotate.d/app",
+		"/etc/sysctl.d/90-forward.conf",
+		"/etc/modprobe.d/no-usb-storage.conf",
+		"/etc/udev/rules.d/70-mtu.rules",
+		"/etc/NetworkManager/system-connections/eth0.nmconnection",
+	} {
+		assert.Contains(t, string(manifestJson), path)
+	}
+	assert.Contains(t, string(manifestJson), `"filename":"app.service"`)
+	assert.Contains(t, string(manifestJson), `"enabled_services":["app.service"]`)
+}
+
+func TestBootcCheckImageOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		it      string
+		options distro.ImageOptions
+		expErr  string
+	}{
+		{
+			name:    "file-based",
+			it:      "qcow2",
+			options: distro.ImageOptions{Sysctl: &sysctl.Options{Files: []sysctl.File{{Name: "a", Settings: []sysctl.Setting{{Key: "vm.swappiness", Value: "10"}}}}}},
+		},
+		{
+			name:    "file-based-invalid",
+			it:      "qcow2",
+			options: distro.ImageOptions{Sysctl: &sysctl.Options{Files: []sysctl.File{{Name: "a"}}}},
+			expErr:  `options validation failed for image type "qcow2": sysctl: file "a": no settings`,
+		},
+		{
+			name:    "file-based-iso",
+			it:      "bootc-generic-iso",
+			options: distro.ImageOptions{Cron: &cron.Options{Jobs: []cron.Job{{Name: "backup", Schedule: "@daily", Command: "/usr/local/bin/backup"}}}},
+			expErr:  `options validation failed for image type "bootc-generic-iso": cron: only supported for bootc disk and PXE images`,
+		},
+		{
+			name:    "tpm2",
+			it:      "qcow2",
+			options: distro.ImageOptions{TPM2: &disk.TPM2Enroll{}},
+			expErr:  `options validation failed for image type "qcow2": tpm2: not supported for bootc images, configure it in the container image`,
+		},
+		{
+			name:    "selinux",
+			it:      "qcow2",
+			options: distro.ImageOptions{SELinux: &selinux.Options{}},
+			expErr:  `options validation failed for image type "qcow2": selinux: not supported for bootc images, configure it in the container image`,
+		},
+		{
+			name:    "crypto-policy",
+			it:      "qcow2",
+			options: distro.ImageOptions{CryptoPolicy: &cryptopolicy.Options{Policy: "FUTURE"}},
+			expErr:  `options validation failed for image type "qcow2": crypto_policy: not supported for bootc images, configure it in the container image`,
+		},
+		{
+			name:    "proxy",
+			it:      "qcow2",
+			options: distro.ImageOptions{Proxy: &proxy.Options{URL: "http://proxy.example.com:3128"}},
+			expErr:  `options validation failed for image type "qcow2": proxy: not supported for bootc images, configure it in the container image`,
+		},
+		{
+			name:    "swap",
+			it:      "qcow2",
+			options: distro.ImageOptions{Swap: &swap.Options{}},
+			expErr:  `options validation failed for image type "qcow2": swap: not supported for bootc images, configure it in the container image`,
+		},
+		{
+			name:    "kdump",
+			it:      "qcow2",
+			options: distro.ImageOptions{Kdump: &kdump.Options{}},
+			expErr:  `options validation failed for image type "qcow2": kdump: not supported for bootc images, configure it in the container image`,
+		},
+		{
+			name:    "tuned",
+			it:      "qcow2",
+			options: distro.ImageOptions{Tuned: &tuned.Options{Profiles: []string{"virtual-guest"}}},
+			expErr:  `options validation failed for image type "qcow2": tuned: not supported for bootc images, configure it in the container image`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			imgType := NewTestBootcImageType(t, tc.it)
+			err := imgType.checkImageOptions(tc.options)
+			if tc.expErr != "" {
+				assert.EqualError(t, err, tc.expErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
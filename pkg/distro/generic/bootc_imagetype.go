@@ -13,6 +13,7 @@ import (
 	"github.com/osbuild/image-builder/pkg/bib/osinfo"
 	"github.com/osbuild/image-builder/pkg/container"
 	"github.com/osbuild/image-builder/pkg/customizations/anaconda"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
 	"github.com/osbuild/image-builder/pkg/customizations/ignition"
 	"github.com/osbuild/image-builder/pkg/customizations/kickstart"
 	"github.com/osbuild/image-builder/pkg/customizations/users"
@@ -153,6 +154,9 @@ func (t *bootcImageType) checkOptions(bp *blueprint.Blueprint) []string {
 
 func (t *bootcImageType) Manifest(bp *blueprint.Blueprint, options distro.ImageOptions, repos []rpmmd.RepoConfig, seedp *int64) (*manifest.Manifest, []string, error) {
 	validationWarnings := t.checkOptions(bp)
+	if err := t.checkImageOptions(options); err != nil {
+		return nil, validationWarnings, err
+	}
 
 	mani, manifestWarnings, err := t.manifestWithoutValidation(bp, options)
 	return mani, append(validationWarnings, manifestWarnings...), err
@@ -190,6 +194,169 @@ func (t *bootcImageType) useLocalStorage(options distro.ImageOptions) bool {
 	return true
 }
 
+// checkImageOptions validates the image options of bootc images. Packages
+// cannot be installed and only /etc of the deployment can be changed, so
+// the options that are not written as files to /etc are rejected, they
+// need to be configured in the container image. The files are only
+// written by the disk and PXE image types.
+// keep in sync with "generic/options.go:checkOptionsCommon()"
+func (t *bootcImageType) checkImageOptions(options distro.ImageOptions) error {
+	errPrefix := fmt.Sprintf("options validation failed for image type %q", t.Name())
+
+	unsupported := []struct {
+		name string
+		set  bool
+	}{
+		{"tpm2", options.TPM2 != nil},
+		{"selinux", options.SELinux != nil},
+		{"crypto_policy", options.CryptoPolicy != nil},
+		{"proxy", options.Proxy != nil},
+		{"swap", options.Swap != nil},
+		{"kdump", options.Kdump != nil},
+		{"tuned", options.Tuned != nil},
+	}
+	for _, o := range unsupported {
+		if o.set {
+			return fmt.Errorf("%s: %s: not supported for bootc images, configure it in the container image", errPrefix, o.name)
+		}
+	}
+
+	supported := []struct {
+		name     string
+		set      bool
+		validate func() error
+	}{
+		{"sudoers", options.Sudoers != nil, options.Sudoers.Validate},
+		{"ssh_auth", options.SSHAuth != nil, options.SSHAuth.Validate},
+		{"systemd", options.Systemd != nil, options.Systemd.Validate},
+		{"logrotate", options.Logrotate != nil, options.Logrotate.Validate},
+		{"auto_update", options.AutoUpdate != nil, func() error {
+			if err := options.AutoUpdate.Validate(); err != nil {
+				return err
+			}
+			return options.AutoUpdate.ValidateImageBased()
+		}},
+		{"cron", options.Cron != nil, options.Cron.Validate},
+		{"sysctl", options.Sysctl != nil, options.Sysctl.Validate},
+		{"modprobe", options.Modprobe != nil, options.Modprobe.Validate},
+		{"udev", options.Udev != nil, options.Udev.Validate},
+		{"network", options.Network != nil, options.Network.Validate},
+	}
+	for _, o := range supported {
+		if !o.set {
+			continue
+		}
+		if t.Image != "bootc_disk" && t.Image != "pxe_tar" {
+			return fmt.Errorf("%s: %s: only supported for bootc disk and PXE images", errPrefix, o.name)
+		}
+		if err := o.validate(); err != nil {
+			return fmt.Errorf("%s: %s: %w", errPrefix, o.name, err)
+		}
+	}
+	return nil
+}
+
+// applyBootcImageOptions adds the files of the image options that are
+// supported on bootc images, see checkImageOptions(). The files are
+// written to /etc of the deployment: the settings that are written by
+// stages on package-based images are written as drop-ins here, and the
+// automatic updates use the update timer of bootc. The packages the
+// options need, e.g. sudo, logrotate or a cron daemon, have to be in the
+// container image.
+func applyBootcImageOptions(osc *manifest.OSCustomizations, options distro.ImageOptions) error {
+	addFiles := func(files []*fsnode.File, err error) error {
+		if err != nil {
+			return err
+		}
+		osc.Files = append(osc.Files, files...)
+		return nil
+	}
+	addNodes := func(dirs []*fsnode.Directory, files []*fsnode.File, err error) error {
+		if err != nil {
+			return err
+		}
+		osc.Directories = append(osc.Directories, dirs...)
+		osc.Files = append(osc.Files, files...)
+		return nil
+	}
+
+	if s := options.Sudoers; s != nil {
+		if err := addFiles(s.Files()); err != nil {
+			return fmt.Errorf("sudoers: %w", err)
+		}
+	}
+
+	if a := options.SSHAuth; a != nil {
+		dirs, err := a.Directories()
+		if err != nil {
+			return fmt.Errorf("ssh_auth: %w", err)
+		}
+		files, err := a.Files()
+		if err != nil {
+			return fmt.Errorf("ssh_auth: %w", err)
+		}
+		// the sshd settings go into a drop-in instead of sshd_config
+		dropInDir, dropIn, err := a.SshdConfigDropIn()
+		if err != nil {
+			return fmt.Errorf("ssh_auth: %w", err)
+		}
+		osc.Directories = append(osc.Directories, append(dirs, dropInDir)...)
+		osc.Files = append(osc.Files, append(files, dropIn)...)
+	}
+
+	if s := options.Systemd; s != nil {
+		if err := addNodes(s.DropInNodes()); err != nil {
+			return fmt.Errorf("systemd: %w", err)
+		}
+		// the units are created in /etc by the unit create stage
+		osc.SystemdUnit = append(osc.SystemdUnit, osbuild.GenSystemdUnitCreateStageOptions(s.Units)...)
+		osc.EnabledServices = append(osc.EnabledServices, s.EnabledUnits()...)
+	}
+
+	if l := options.Logrotate; l != nil {
+		if err := addFiles(l.Files()); err != nil {
+			return fmt.Errorf("logrotate: %w", err)
+		}
+	}
+
+	if a := options.AutoUpdate; a != nil {
+		if err := addNodes(a.BootcNodes()); err != nil {
+			return fmt.Errorf("auto_update: %w", err)
+		}
+	}
+
+	if c := options.Cron; c != nil {
+		if err := addFiles(c.Files()); err != nil {
+			return fmt.Errorf("cron: %w", err)
+		}
+	}
+
+	if s := options.Sysctl; s != nil {
+		if err := addFiles(s.FsNodeFiles()); err != nil {
+			return fmt.Errorf("sysctl: %w", err)
+		}
+	}
+
+	if m := options.Modprobe; m != nil {
+		if err := addFiles(m.FsNodeFiles()); err != nil {
+			return fmt.Errorf("modprobe: %w", err)
+		}
+	}
+
+	if u := options.Udev; u != nil {
+		if err := addFiles(u.FsNodeFiles()); err != nil {
+			return fmt.Errorf("udev: %w", err)
+		}
+	}
+
+	if n := options.Network; n != nil {
+		if err := addNodes(osbuild.GenNMKeyfilesFromConnections(n.Connections)); err != nil {
+			return fmt.Errorf("network: %w", err)
+		}
+	}
+	return nil
+}
+
 func (t *bootcImageType) manifestForDisk(bp *blueprint.Blueprint, options distro.ImageOptions, rng *rand.Rand) (*manifest.Manifest, []string, error) {
 	bd := t.arch.distro.(*BootcDistro)
 	if bd.imgref == "" {
@@ -298,6 +465,9 @@ func (t *bootcImageType) manifestForDisk(bp *blueprint.Blueprint, options distro
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := applyBootcImageOptions(&img.OSCustomizations, options); err != nil {
+		return nil, nil, err
+	}
 
 	bpIgnitionCustomization, err := customizations.GetIgnition()
 	if err != nil {
@@ -752,6 +922,9 @@ func (t *bootcImageType) manifestForPXETar(bp *blueprint.Blueprint, options dist
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := applyBootcImageOptions(&img.OSCustomizations, options); err != nil {
+		return nil, nil, err
+	}
 
 	// Potentially KernelInfo is nil when we couldn't read it from the container; handle that so
 	// we don't panic
@@ -84,6 +84,14 @@ func TestFedoraFilenameFromType(t *testing.T) {
 				mimeType: "application/x-vhd",
 			},
 		},
+		{
+			name: "generic-hyperv",
+			args: args{"generic-hyperv"},
+			want: wantResult{
+				filename: "disk.vhdx",
+				mimeType: "application/x-vhdx",
+			},
+		},
 		{
 			name: "generic-vmdk",
 			args: args{"generic-vmdk"},
@@ -340,6 +348,8 @@ func TestFedoraImageType_Name(t *testing.T) {
 				"workstation-live-installer",
 				"minimal-raw-xz",
 				"minimal-raw-zst",
+				"minimal-raw-bmap",
+				"minimal-raw-simg",
 				"generic-oci",
 				"generic-openstack",
 				"generic-ova",
@@ -383,6 +393,8 @@ func TestFedoraImageType_Name(t *testing.T) {
 				"iot-raw-xz",
 				"minimal-raw-xz",
 				"minimal-raw-zst",
+				"minimal-raw-bmap",
+				"minimal-raw-simg",
 				"generic-oci",
 				"generic-openstack",
 				"generic-qcow2",
@@ -514,6 +526,7 @@ func TestFedoraArchitecture_ListImageTypes(t *testing.T) {
 			imgNames: []string{
 				"generic-ami",
 				"generic-container",
+				"generic-hyperv",
 				"minimal-installer",
 				"iot-commit",
 				"iot-container",
@@ -523,6 +536,8 @@ func TestFedoraArchitecture_ListImageTypes(t *testing.T) {
 				"workstation-live-installer",
 				"minimal-raw-xz",
 				"minimal-raw-zst",
+				"minimal-raw-bmap",
+				"minimal-raw-simg",
 				"generic-oci",
 				"generic-openstack",
 				"generic-ova",
@@ -558,6 +573,7 @@ func TestFedoraArchitecture_ListImageTypes(t *testing.T) {
 			imgNames: []string{
 				"generic-ami",
 				"generic-container",
+				"generic-hyperv",
 				"minimal-installer",
 				"iot-commit",
 				"iot-container",
@@ -567,6 +583,8 @@ func TestFedoraArchitecture_ListImageTypes(t *testing.T) {
 				"workstation-live-installer",
 				"minimal-raw-xz",
 				"minimal-raw-zst",
+				"minimal-raw-bmap",
+				"minimal-raw-simg",
 				"generic-oci",
 				"generic-openstack",
 				"generic-qcow2",
@@ -622,6 +640,8 @@ func TestFedoraArchitecture_ListImageTypes(t *testing.T) {
 				"generic-container",
 				"minimal-raw-xz",
 				"minimal-raw-zst",
+				"minimal-raw-bmap",
+				"minimal-raw-simg",
 			},
 		},
 	}
@@ -4,22 +4,31 @@ import (
 	"fmt"
 	"maps"
 	"math/rand"
+	"path"
 	"slices"
 	"strings"
 	"sync"
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
+	"github.com/osbuild/image-builder/internal/common"
 	"github.com/osbuild/image-builder/pkg/arch"
 	"github.com/osbuild/image-builder/pkg/container"
 	"github.com/osbuild/image-builder/pkg/customizations/anaconda"
+	"github.com/osbuild/image-builder/pkg/customizations/autoupdate"
 	"github.com/osbuild/image-builder/pkg/customizations/bootc"
+	"github.com/osbuild/image-builder/pkg/customizations/cron"
 	"github.com/osbuild/image-builder/pkg/customizations/fdo"
 	"github.com/osbuild/image-builder/pkg/customizations/firstboot"
 	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
 	"github.com/osbuild/image-builder/pkg/customizations/ignition"
+	"github.com/osbuild/image-builder/pkg/customizations/kdump"
 	"github.com/osbuild/image-builder/pkg/customizations/kickstart"
+	"github.com/osbuild/image-builder/pkg/customizations/logrotate"
 	"github.com/osbuild/image-builder/pkg/customizations/oscap"
+	"github.com/osbuild/image-builder/pkg/customizations/proxy"
+	"github.com/osbuild/image-builder/pkg/customizations/sshauth"
 	"github.com/osbuild/image-builder/pkg/customizations/subscription"
+	"github.com/osbuild/image-builder/pkg/customizations/sudoers"
 	"github.com/osbuild/image-builder/pkg/customizations/users"
 	"github.com/osbuild/image-builder/pkg/distro"
 	"github.com/osbuild/image-builder/pkg/flatpak"
@@ -40,6 +49,241 @@ func kernelOptions(t *imageType, c *blueprint.Customizations) []string {
 	return kernelOptions
 }
 
+// usesDNF5 returns true if the distribution of the image type uses dnf5,
+// which reads drop-in configurations from /etc/dnf/libdnf5.conf.d/
+func usesDNF5(t *imageType) bool {
+	d := t.Arch().Distro()
+	switch d.IDLike() {
+	case manifest.DISTRO_FEDORA:
+		return !common.VersionLessThan(d.OsVersion(), "41")
+	case manifest.DISTRO_ELN:
+		return true
+	}
+	return false
+}
+
+// applyProxy configures the proxy for the package manager and
+// subscription-manager and sets it in the environment of login sessions.
+// The configurations from the image config are copied, not modified.
+//
+// The credentials of the proxy are never written to the world-readable
+// dnf.conf, yum.conf or rhsm.conf, only to root-only files; they are
+// rejected when validating the options where no such file exists.
+func applyProxy(t *imageType, osc *manifest.OSCustomizations, p *proxy.Options) error {
+	user, _ := p.Credentials()
+
+	if t.Arch().Distro().IDLike() == manifest.DISTRO_EL7 {
+		yumConfig := &osbuild.YumConfigStageOptions{}
+		if osc.YUMConfig != nil {
+			*yumConfig = *osc.YUMConfig
+		}
+		config := osbuild.YumConfigConfig{}
+		if yumConfig.Config != nil {
+			config = *yumConfig.Config
+		}
+		config.Proxy = common.ToPtr(p.URLWithoutCredentials())
+		yumConfig.Config = &config
+		osc.YUMConfig = yumConfig
+	} else {
+		dnfConfig := &osbuild.DNFConfigStageOptions{}
+		if osc.DNFConfig != nil {
+			*dnfConfig = *osc.DNFConfig
+		}
+		config := osbuild.DNFConfig{}
+		if dnfConfig.Config != nil {
+			config = *dnfConfig.Config
+		}
+		main := osbuild.DNFConfigMain{}
+		if config.Main != nil {
+			main = *config.Main
+		}
+		main.Proxy = p.URLWithoutCredentials()
+		config.Main = &main
+		dnfConfig.Config = &config
+		osc.DNFConfig = dnfConfig
+
+		credentialsFile, err := p.DNF5CredentialsFile()
+		if err != nil {
+			return err
+		}
+		if credentialsFile != nil {
+			dropinDir, err := fsnode.NewDirectory(path.Dir(credentialsFile.Path()), nil, nil, nil, true)
+			if err != nil {
+				return err
+			}
+			osc.Directories = append(osc.Directories, dropinDir)
+			osc.Files = append(osc.Files, credentialsFile)
+		}
+	}
+
+	if osc.RHSMConfig != nil {
+		if user != "" {
+			return fmt.Errorf("proxy credentials are not supported for subscription-manager")
+		}
+		server := subscription.SubManServerConfig{
+			ProxyHostname: common.ToPtr(p.Hostname()),
+			ProxyScheme:   common.ToPtr(p.Scheme()),
+		}
+		if port := p.Port(); port != "" {
+			server.ProxyPort = common.ToPtr(port)
+		}
+		if len(p.NoProxy) > 0 {
+			server.NoProxy = common.ToPtr(strings.Join(p.NoProxy, ","))
+		}
+		osc.RHSMConfig = osc.RHSMConfig.Update(&subscription.RHSMConfig{
+			SubMan: subscription.SubManConfig{Server: server},
+		})
+	}
+
+	envFile, err := p.EnvironmentFile()
+	if err != nil {
+		return err
+	}
+	osc.Files = append(osc.Files, envFile)
+	return nil
+}
+
+// applyKdump installs and enables kdump, reserves the crash kernel memory
+// and writes its configuration. The crashkernel= reservation replaces any
+// reservation in the default kernel options of the image type.
+func applyKdump(t *imageType, osc *manifest.OSCustomizations, k *kdump.Options) error {
+	kdumpConfig := t.getDefaultImageConfig().Kdump
+	if kdumpConfig == nil {
+		return fmt.Errorf("kdump is not supported on %s", t.Arch().Distro().Name())
+	}
+
+	crashKernel := k.CrashKernel
+	if crashKernel == "" {
+		crashKernel = kdumpConfig.CrashKernel[t.Arch().Name()]
+	}
+	if crashKernel == "" {
+		return fmt.Errorf("no default crashkernel reservation for %s", t.Arch().Name())
+	}
+
+	kernelOptions := make([]string, 0, len(osc.KernelOptionsAppend)+1)
+	for _, opts := range osc.KernelOptionsAppend {
+		var keep []string
+		for _, opt := range strings.Fields(opts) {
+			if !strings.HasPrefix(opt, "crashkernel=") {
+				keep = append(keep, opt)
+			}
+		}
+		if len(keep) > 0 {
+			kernelOptions = append(kernelOptions, strings.Join(keep, " "))
+		}
+	}
+	osc.KernelOptionsAppend = append(kernelOptions, "crashkernel="+crashKernel)
+
+	osc.BasePackages = append(slices.Clone(osc.BasePackages), kdumpConfig.Packages...)
+	osc.EnabledServices = append(slices.Clone(osc.EnabledServices), "kdump.service")
+
+	configFile, err := k.ConfigFile()
+	if err != nil {
+		return err
+	}
+	osc.Files = append(osc.Files, configFile)
+	return nil
+}
+
+// applySudoers installs sudo and writes the sudoers drop-ins
+func applySudoers(osc *manifest.OSCustomizations, s *sudoers.Options) error {
+	files, err := s.Files()
+	if err != nil {
+		return err
+	}
+	osc.BasePackages = append(slices.Clone(osc.BasePackages), "sudo")
+	osc.Files = append(osc.Files, files...)
+	return nil
+}
+
+// applySSHAuth writes the trusted CA keys and the principals files and
+// points sshd to them. The sshd configuration from the image config is
+// copied, not modified.
+func applySSHAuth(osc *manifest.OSCustomizations, a *sshauth.Options) error {
+	files, err := a.Files()
+	if err != nil {
+		return err
+	}
+	dirs, err := a.Directories()
+	if err != nil {
+		return err
+	}
+
+	sshdConfig := &osbuild.SshdConfigStageOptions{}
+	if osc.SshdConfig != nil {
+		*sshdConfig = *osc.SshdConfig
+	}
+	if len(a.TrustedUserCAKeys) > 0 {
+		sshdConfig.Config.TrustedUserCAKeys = sshauth.TrustedUserCAKeysPath
+	}
+	if len(a.AuthorizedPrincipals) > 0 {
+		sshdConfig.Config.AuthorizedPrincipalsFile = sshauth.AuthorizedPrincipalsFile
+	}
+	sshdConfig.Config.AuthorizedKeysCommand = a.AuthorizedKeysCommand
+	sshdConfig.Config.AuthorizedKeysCommandUser = a.AuthorizedKeysCommandUser
+	osc.SshdConfig = sshdConfig
+
+	osc.BasePackages = append(slices.Clone(osc.BasePackages), "openssh-server")
+	osc.Directories = append(osc.Directories, dirs...)
+	osc.Files = append(osc.Files, files...)
+	return nil
+}
+
+// applyLogrotate installs logrotate and writes the logrotate drop-ins
+func applyLogrotate(osc *manifest.OSCustomizations, l *logrotate.Options) error {
+	files, err := l.Files()
+	if err != nil {
+		return err
+	}
+	osc.BasePackages = append(slices.Clone(osc.BasePackages), "logrotate")
+	osc.Files = append(osc.Files, files...)
+	return nil
+}
+
+// enableService adds a service to the enabled services unless the image
+// config already enables it
+func enableService(osc *manifest.OSCustomizations, service string) {
+	if !slices.Contains(osc.EnabledServices, service) {
+		osc.EnabledServices = append(slices.Clone(osc.EnabledServices), service)
+	}
+}
+
+// applyAutoUpdate configures the automatic updates, with rpm-ostreed on
+// ostree commits and with dnf-automatic on package-based images. The
+// dnf-automatic configuration replaces the one of the image config.
+func applyAutoUpdate(t *imageType, osc *manifest.OSCustomizations, a *autoupdate.Options) error {
+	var dirs []*fsnode.Directory
+	var files []*fsnode.File
+	var err error
+	if t.IsOSTreeBasedImageType() {
+		dirs, files, err = a.RpmOstreeNodes()
+		enableService(osc, autoupdate.RpmOstreedAutomaticTimer)
+	} else {
+		dirs, files, err = a.DNFAutomaticNodes()
+		osc.DNFAutomaticConfig = osbuild.GenDNFAutomaticConfigStageOptions(a)
+		osc.BasePackages = append(slices.Clone(osc.BasePackages), "dnf-automatic")
+		enableService(osc, autoupdate.DNFAutomaticTimer)
+	}
+	if err != nil {
+		return err
+	}
+	osc.Directories = append(osc.Directories, dirs...)
+	osc.Files = append(osc.Files, files...)
+	return nil
+}
+
+// applyCron installs cronie and writes the cron.d jobs
+func applyCron(osc *manifest.OSCustomizations, c *cron.Options) error {
+	files, err := c.Files()
+	if err != nil {
+		return err
+	}
+	osc.BasePackages = append(slices.Clone(osc.BasePackages), "cronie")
+	enableService(osc, "crond.service")
+	osc.Files = append(osc.Files, files...)
+	return nil
+}
+
 func osCustomizations(t *imageType, osPackageSet rpmmd.PackageSet, options distro.ImageOptions, containers []container.SourceSpec, bp *blueprint.Blueprint) (manifest.OSCustomizations, error) {
 	c := bp.Customizations
 	osc := manifest.OSCustomizations{}
@@ -114,6 +358,9 @@ func osCustomizations(t *imageType, osPackageSet rpmmd.PackageSet, options distr
 		osc.DisabledServices = append(osc.DisabledServices, services.Disabled...)
 		osc.MaskedServices = append(osc.MaskedServices, services.Masked...)
 	}
+	if options.Systemd != nil {
+		osc.EnabledServices = append(slices.Clone(osc.EnabledServices), options.Systemd.EnabledUnits()...)
+	}
 
 	if imageConfig.DefaultTarget != nil {
 		osc.DefaultTarget = *imageConfig.DefaultTarget
@@ -190,6 +437,7 @@ func osCustomizations(t *imageType, osPackageSet rpmmd.PackageSet, options distr
 	if imageConfig.NoSELinux == nil || imageConfig.NoSELinux != nil && !*imageConfig.NoSELinux {
 		osc.SELinux = "targeted"
 		osc.SELinuxForceRelabel = imageConfig.SELinuxForceRelabel
+		osc.SELinuxOptions = options.SELinux
 	}
 
 	// XXX: move into pure YAML
@@ -315,15 +563,37 @@ func osCustomizations(t *imageType, osPackageSet rpmmd.PackageSet, options distr
 	osc.SystemdLogind = imageConfig.SystemdLogind
 	osc.CloudInit = imageConfig.CloudInit
 	osc.Modprobe = imageConfig.Modprobe
+	if options.Modprobe != nil {
+		osc.Modprobe = append(slices.Clone(osc.Modprobe), osbuild.GenModprobeStageOptions(options.Modprobe)...)
+	}
 	osc.DracutConf = imageConfig.DracutConf
 	osc.SystemdDropin = imageConfig.SystemdDropin
 	osc.SystemdUnit = imageConfig.SystemdUnit
+	if options.Systemd != nil {
+		osc.SystemdDropin = append(slices.Clone(osc.SystemdDropin), osbuild.GenSystemdUnitStageOptions(options.Systemd.DropIns)...)
+		osc.SystemdUnit = append(slices.Clone(osc.SystemdUnit), osbuild.GenSystemdUnitCreateStageOptions(options.Systemd.Units)...)
+		if options.Systemd.Logind != nil {
+			osc.SystemdLogind = append(slices.Clone(osc.SystemdLogind), osbuild.GenSystemdLogindStageOptions(options.Systemd.Logind))
+		}
+		if options.Systemd.Journald != nil {
+			osc.SystemdJournald = []*osbuild.SystemdJournaldStageOptions{osbuild.GenSystemdJournaldStageOptions(options.Systemd.Journald)}
+		}
+	}
 	osc.Authselect = imageConfig.Authselect
 	osc.SELinuxConfig = imageConfig.SELinuxConfig
 	osc.Tuned = imageConfig.Tuned
+	if options.Tuned != nil {
+		// the profiles replace the ones of the image config
+		osc.Tuned = osbuild.NewTunedStageOptions(options.Tuned.Profiles...)
+		osc.BasePackages = append(slices.Clone(osc.BasePackages), "tuned")
+		enableService(&osc, "tuned.service")
+	}
 	osc.Tmpfilesd = imageConfig.Tmpfilesd
 	osc.PamLimitsConf = imageConfig.PamLimitsConf
 	osc.Sysctld = imageConfig.Sysctld
+	if options.Sysctl != nil {
+		osc.Sysctld = append(slices.Clone(osc.Sysctld), osbuild.GenSysctldStageOptions(options.Sysctl)...)
+	}
 	osc.DNFAutomaticConfig = imageConfig.DNFAutomaticConfig
 	osc.YUMConfig = imageConfig.YumConfig
 	osc.SshdConfig = imageConfig.SshdConfig
@@ -331,9 +601,19 @@ func osCustomizations(t *imageType, osPackageSet rpmmd.PackageSet, options distr
 	osc.PwQuality = imageConfig.PwQuality
 	osc.Subscription = options.Subscription
 	osc.WAAgentConfig = imageConfig.WAAgentConfig
-	osc.UdevRules = imageConfig.UdevRules
+	if imageConfig.UdevRules != nil {
+		osc.UdevRules = []*osbuild.UdevRulesStageOptions{imageConfig.UdevRules}
+	}
+	if options.Udev != nil {
+		osc.UdevRules = append(osc.UdevRules, osbuild.GenUdevRulesStageOptions(options.Udev)...)
+	}
 	osc.GCPGuestAgentConfig = imageConfig.GCPGuestAgentConfig
 	osc.NetworkManager = imageConfig.NetworkManager
+	osc.NetworkConnections = imageConfig.NetworkConnections
+	// installers pass the connections to anaconda in the kickstart
+	if options.Network != nil && !t.kickstartInstaller() {
+		osc.NetworkConnections = append(slices.Clone(osc.NetworkConnections), options.Network.Connections...)
+	}
 
 	if imageConfig.WSL != nil {
 		osc.WSLConfig = osbuild.NewWSLConfStageOptions(imageConfig.WSL.Config)
@@ -343,6 +623,51 @@ func osCustomizations(t *imageType, osPackageSet rpmmd.PackageSet, options distr
 	osc.Files = append(osc.Files, imageConfig.Files...)
 	osc.Directories = append(osc.Directories, imageConfig.Directories...)
 
+	if options.Proxy != nil {
+		if err := applyProxy(t, &osc, options.Proxy); err != nil {
+			return manifest.OSCustomizations{}, fmt.Errorf("proxy customization: %w", err)
+		}
+	}
+
+	osc.CryptoPolicy = options.CryptoPolicy
+	osc.Swap = options.Swap
+
+	if options.Kdump != nil {
+		if err := applyKdump(t, &osc, options.Kdump); err != nil {
+			return manifest.OSCustomizations{}, fmt.Errorf("kdump customization: %w", err)
+		}
+	}
+
+	if options.Sudoers != nil {
+		if err := applySudoers(&osc, options.Sudoers); err != nil {
+			return manifest.OSCustomizations{}, fmt.Errorf("sudoers customization: %w", err)
+		}
+	}
+
+	if options.SSHAuth != nil {
+		if err := applySSHAuth(&osc, options.SSHAuth); err != nil {
+			return manifest.OSCustomizations{}, fmt.Errorf("ssh_auth customization: %w", err)
+		}
+	}
+
+	if options.Logrotate != nil {
+		if err := applyLogrotate(&osc, options.Logrotate); err != nil {
+			return manifest.OSCustomizations{}, fmt.Errorf("logrotate customization: %w", err)
+		}
+	}
+
+	if options.AutoUpdate != nil {
+		if err := applyAutoUpdate(t, &osc, options.AutoUpdate); err != nil {
+			return manifest.OSCustomizations{}, fmt.Errorf("auto_update customization: %w", err)
+		}
+	}
+
+	if options.Cron != nil {
+		if err := applyCron(&osc, options.Cron); err != nil {
+			return manifest.OSCustomizations{}, fmt.Errorf("cron customization: %w", err)
+		}
+	}
+
 	if imageConfig.NoBLS != nil {
 		osc.NoBLS = *imageConfig.NoBLS
 	}
@@ -670,6 +995,10 @@ func diskCustomizations(t *imageType) (manifest.DiskCustomizations, error) {
 		if diskConfig.PartitioningTool != nil {
 			diskCust.PartitioningTool = *diskConfig.PartitioningTool
 		}
+
+		if diskConfig.GrowMethod != nil {
+			diskCust.GrowMethod = *diskConfig.GrowMethod
+		}
 	}
 
 	return diskCust, nil
@@ -789,6 +1118,7 @@ func diskImage(t *imageType,
 
 	img.Environment = &t.ImageTypeYAML.Environment
 	img.Compression = t.ImageTypeYAML.Compression
+	img.Bmap = t.ImageTypeYAML.Bmap
 
 	// TODO: move generation into LiveImage
 	pt, err := t.getPartitionTable(bp.Customizations, options, rng)
@@ -928,6 +1258,9 @@ func imageInstallerImage(t *imageType,
 	img.Kickstart.Language = &img.OSCustomizations.Language
 	img.Kickstart.Keyboard = img.OSCustomizations.Keyboard
 	img.Kickstart.Timezone = &img.OSCustomizations.Timezone
+	if options.Network != nil {
+		img.Kickstart.Network = options.Network.Connections
+	}
 
 	img.ExtraBasePackages = packageSets[installerPkgsKey]
 
@@ -1131,6 +1464,9 @@ func ostreeInstallerImage(t *imageType,
 	// ignore ntp servers - we don't currently support setting these in the
 	// kickstart though kickstart does support setting them
 	img.Kickstart.Timezone, _ = customizations.GetTimezoneSettings()
+	if options.Network != nil {
+		img.Kickstart.Network = options.Network.Connections
+	}
 
 	// XXX these bits should move into the `installerCustomization` function
 	// XXX directly
@@ -1344,6 +1680,10 @@ func networkInstallerImage(t *imageType,
 		return nil, err
 	}
 	// NOTE: The network installer only supports adding users and groups
+	// and the network configuration of the installed system
+	if options.Network != nil {
+		img.Kickstart.Network = options.Network.Connections
+	}
 
 	// If we have an empty kickstart options we don't want to put it on
 	// the image at all as an empty kickstart will create an empty kickstart
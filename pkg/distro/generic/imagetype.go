@@ -10,6 +10,7 @@ import (
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/image-builder/pkg/container"
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
 	"github.com/osbuild/image-builder/pkg/datasizes"
 	"github.com/osbuild/image-builder/pkg/disk"
 	"github.com/osbuild/image-builder/pkg/distro"
@@ -197,11 +198,43 @@ func (t *imageType) getPartitionTable(customizations *blueprint.Customizations,
 			RequiredMinSizes:   t.ImageTypeYAML.RequiredPartitionSizes,
 			Architecture:       t.platform.GetArch(),
 		}
-		return disk.NewCustomPartitionTable(partitioning, partOptions, nil, rng)
+		pt, err := disk.NewCustomPartitionTable(partitioning, partOptions, nil, rng)
+		if err != nil {
+			return nil, err
+		}
+		return addSwapToPartitionTable(pt, options.Swap, rng)
 	}
 
 	mountpoints := customizations.GetFilesystems()
-	return disk.NewPartitionTable(basePartitionTable, mountpoints, datasizes.Size(imageSize), options.PartitioningMode, t.platform.GetArch(), t.ImageTypeYAML.RequiredPartitionSizes, defaultFsType.String(), rng)
+	pt, err := disk.NewPartitionTable(basePartitionTable, mountpoints, datasizes.Size(imageSize), options.PartitioningMode, t.platform.GetArch(), t.ImageTypeYAML.RequiredPartitionSizes, defaultFsType.String(), rng)
+	if err != nil {
+		return nil, err
+	}
+	if options.TPM2 != nil {
+		if err := pt.EnrollTPM2(options.TPM2); err != nil {
+			return nil, fmt.Errorf("cannot enroll TPM2: %w", err)
+		}
+	}
+	return addSwapToPartitionTable(pt, options.Swap, rng)
+}
+
+// addSwapToPartitionTable adds the swap partition and makes room for the
+// swap file of the swap options on the laid out partition table
+func addSwapToPartitionTable(pt *disk.PartitionTable, swapOptions *swap.Options, rng *rand.Rand) (*disk.PartitionTable, error) {
+	if swapOptions == nil {
+		return pt, nil
+	}
+	if swapOptions.Partition != nil {
+		if err := pt.AddSwapPartition(swapOptions.Partition.Size, rng); err != nil {
+			return nil, fmt.Errorf("cannot add swap partition: %w", err)
+		}
+	}
+	if swapOptions.File != nil {
+		if err := pt.GrowForSwapFile(swapOptions.File.Path, swapOptions.File.Size); err != nil {
+			return nil, err
+		}
+	}
+	return pt, nil
 }
 
 func (t *imageType) getDefaultImageConfig() *distro.ImageConfig {
@@ -7,8 +7,12 @@ import (
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/image-builder/internal/common"
 	"github.com/osbuild/image-builder/pkg/arch"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
 	"github.com/osbuild/image-builder/pkg/customizations/oscap"
+	"github.com/osbuild/image-builder/pkg/disk"
 	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/osbuild/image-builder/pkg/manifest"
+	"github.com/osbuild/image-builder/pkg/osbuild"
 	"github.com/osbuild/image-builder/pkg/policies"
 )
 
@@ -40,6 +44,206 @@ func checkOptionsCommon(t *imageType, bp *blueprint.Blueprint, options distro.Im
 		}
 	}
 
+	if options.TPM2 != nil {
+		// the enrollment keys are written by the raw image pipeline, which
+		// only disk images run
+		if t.ImageTypeYAML.Image != "disk" {
+			return warnings, fmt.Errorf("options validation failed for image type %q: tpm2: only supported for disk images", t.Name())
+		}
+		if err := options.TPM2.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: tpm2: %w", t.Name(), err)
+		}
+		if partitioning, _ := customizations.GetPartitioning(); partitioning != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: tpm2: cannot be used with customizations.disk", t.Name())
+		}
+		pt, err := t.BasePartitionTable()
+		if err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: tpm2: %w", t.Name(), err)
+		}
+		if err := pt.Clone().(*disk.PartitionTable).EnrollTPM2(options.TPM2); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: tpm2: %w", t.Name(), err)
+		}
+	}
+
+	if options.SELinux != nil {
+		if err := checkOSOption(t, "selinux"); err != nil {
+			return warnings, err
+		}
+		if noSELinux := t.getDefaultImageConfig().NoSELinux; noSELinux != nil && *noSELinux {
+			return warnings, fmt.Errorf("options validation failed for image type %q: selinux: not supported, the image type is not labelled", t.Name())
+		}
+		if err := options.SELinux.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: selinux: %w", t.Name(), err)
+		}
+	}
+
+	if options.CryptoPolicy != nil {
+		if err := checkOSOption(t, "crypto_policy"); err != nil {
+			return warnings, err
+		}
+		if t.Arch().Distro().IDLike() == manifest.DISTRO_EL7 {
+			return warnings, fmt.Errorf("options validation failed for image type %q: crypto_policy: not supported", t.Name())
+		}
+		if err := options.CryptoPolicy.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: crypto_policy: %w", t.Name(), err)
+		}
+		if customizations.GetFIPS() && options.CryptoPolicy.BasePolicy() != "FIPS" {
+			return warnings, fmt.Errorf("options validation failed for image type %q: crypto_policy: %q cannot be used with FIPS mode", t.Name(), options.CryptoPolicy.Policy)
+		}
+	}
+
+	if options.Proxy != nil {
+		if err := checkOSOption(t, "proxy"); err != nil {
+			return warnings, err
+		}
+		if err := options.Proxy.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: proxy: %w", t.Name(), err)
+		}
+		// dnf4, yum and subscription-manager only read the credentials
+		// from their world-readable configuration files
+		if user, _ := options.Proxy.Credentials(); user != "" && !usesDNF5(t) {
+			return warnings, fmt.Errorf("options validation failed for image type %q: proxy: credentials are only supported for distributions that use dnf5", t.Name())
+		}
+	}
+
+	if options.Network != nil {
+		if err := options.Network.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: network: %w", t.Name(), err)
+		}
+		if t.kickstartInstaller() {
+			if err := checkKickstartNetwork(options.Network.Connections); err != nil {
+				return warnings, fmt.Errorf("options validation failed for image type %q: network: %w", t.Name(), err)
+			}
+			instCust, err := customizations.GetInstaller()
+			if err != nil {
+				return warnings, err
+			}
+			if instCust != nil && instCust.Kickstart != nil {
+				return warnings, fmt.Errorf("options validation failed for image type %q: network: cannot be used with customizations.installer.kickstart, add the network commands to the kickstart instead", t.Name())
+			}
+		} else {
+			if err := checkOSOption(t, "network"); err != nil {
+				return warnings, err
+			}
+			conns := append(slices.Clone(t.getDefaultImageConfig().NetworkConnections), options.Network.Connections...)
+			if err := network.ValidateConnections(conns); err != nil {
+				return warnings, fmt.Errorf("options validation failed for image type %q: network: %w", t.Name(), err)
+			}
+		}
+	}
+
+	if err := checkSystemTuningOptions(t, options); err != nil {
+		return warnings, err
+	}
+
+	if options.Swap != nil {
+		if err := checkOSOption(t, "swap"); err != nil {
+			return warnings, err
+		}
+		if err := options.Swap.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: swap: %w", t.Name(), err)
+		}
+		if options.Swap.Partition != nil || options.Swap.File != nil {
+			if t.PartitionType() == disk.PT_NONE || t.IsOSTreeBasedImageType() {
+				return warnings, fmt.Errorf("options validation failed for image type %q: swap: partition and file are only supported for disk images", t.Name())
+			}
+		}
+		if options.Swap.Partition != nil {
+			if partitioning, _ := customizations.GetPartitioning(); partitioning != nil {
+				return warnings, fmt.Errorf("options validation failed for image type %q: swap.partition: cannot be used with customizations.disk, add a swap partition to the disk customization instead", t.Name())
+			}
+			// the swap area is formatted at build time, see checkOptionsRhel8
+			if t.Arch().Distro().IDLike() == manifest.DISTRO_EL8 && t.Arch().Name() == arch.ARCH_AARCH64.String() {
+				return warnings, fmt.Errorf("options validation failed for image type %q: swap.partition: not supported on %s %s", t.Name(), t.Arch().Distro().Name(), t.Arch().Name())
+			}
+		}
+		if options.Swap.Zram != nil {
+			if idLike := t.Arch().Distro().IDLike(); idLike == manifest.DISTRO_EL7 || idLike == manifest.DISTRO_EL8 {
+				return warnings, fmt.Errorf("options validation failed for image type %q: swap.zram: not supported", t.Name())
+			}
+		}
+	}
+
+	if options.Kdump != nil {
+		// the kdump packages cannot be added to an ostree deployment and the
+		// kernel options are not set for non-bootable image types
+		if !t.Bootable || t.IsOSTreeBasedImageType() {
+			return warnings, fmt.Errorf("options validation failed for image type %q: kdump: only supported for bootable, package-based image types", t.Name())
+		}
+		if err := checkOSOption(t, "kdump"); err != nil {
+			return warnings, err
+		}
+		if err := options.Kdump.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: kdump: %w", t.Name(), err)
+		}
+		kdumpConfig := t.getDefaultImageConfig().Kdump
+		if kdumpConfig == nil || len(kdumpConfig.Packages) == 0 {
+			return warnings, fmt.Errorf("options validation failed for image type %q: kdump: not supported on %s", t.Name(), t.Arch().Distro().Name())
+		}
+		if options.Kdump.CrashKernel == "" && kdumpConfig.CrashKernel[t.Arch().Name()] == "" {
+			return warnings, fmt.Errorf("options validation failed for image type %q: kdump.crashkernel: required, there is no default for %s", t.Name(), t.Arch().Name())
+		}
+	}
+
+	if options.Sudoers != nil {
+		if err := checkOSOption(t, "sudoers"); err != nil {
+			return warnings, err
+		}
+		if err := options.Sudoers.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: sudoers: %w", t.Name(), err)
+		}
+	}
+
+	if options.SSHAuth != nil {
+		if err := checkOSOption(t, "ssh_auth"); err != nil {
+			return warnings, err
+		}
+		if err := options.SSHAuth.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: ssh_auth: %w", t.Name(), err)
+		}
+	}
+
+	if options.Systemd != nil {
+		if err := checkOSOption(t, "systemd"); err != nil {
+			return warnings, err
+		}
+		if err := options.Systemd.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: systemd: %w", t.Name(), err)
+		}
+	}
+
+	if options.Logrotate != nil {
+		if err := checkOSOption(t, "logrotate"); err != nil {
+			return warnings, err
+		}
+		if err := options.Logrotate.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: logrotate: %w", t.Name(), err)
+		}
+	}
+
+	if options.AutoUpdate != nil {
+		if err := checkOSOption(t, "auto_update"); err != nil {
+			return warnings, err
+		}
+		if err := options.AutoUpdate.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: auto_update: %w", t.Name(), err)
+		}
+		if t.IsOSTreeBasedImageType() {
+			if err := options.AutoUpdate.ValidateImageBased(); err != nil {
+				return warnings, fmt.Errorf("options validation failed for image type %q: auto_update: %w", t.Name(), err)
+			}
+		}
+	}
+
+	if options.Cron != nil {
+		if err := checkOSOption(t, "cron"); err != nil {
+			return warnings, err
+		}
+		if err := options.Cron.Validate(); err != nil {
+			return warnings, fmt.Errorf("options validation failed for image type %q: cron: %w", t.Name(), err)
+		}
+	}
+
 	if (t.BootISO || t.Bootable) && t.IsOSTreeBasedImageType() {
 		// ostree-based ISOs require a URL from which to pull a payload commit, this can either be a default URL or one
 		// supplied through options
@@ -218,3 +422,71 @@ func checkOptionsRhel8(t *imageType, bp *blueprint.Blueprint) error {
 
 	return nil
 }
+
+// checkSystemTuningOptions validates the sysctl, tuned, modprobe and udev
+// options. They can only be used for the image types that list them in
+// their "supported_options": containers and filesystem archives run on
+// the kernel of the host, ostree deployments get the configuration from
+// the commit and live installers do not apply the os customizations.
+func checkSystemTuningOptions(t *imageType, options distro.ImageOptions) error {
+	for _, opt := range []struct {
+		name    string
+		set     bool
+		options interface{ Validate() error }
+	}{
+		{"sysctl", options.Sysctl != nil, options.Sysctl},
+		{"tuned", options.Tuned != nil, options.Tuned},
+		{"modprobe", options.Modprobe != nil, options.Modprobe},
+		{"udev", options.Udev != nil, options.Udev},
+	} {
+		if !opt.set {
+			continue
+		}
+		if !slices.Contains(t.ImageTypeYAML.SupportedOptions, opt.name) {
+			return fmt.Errorf("options validation failed for image type %q: %s: not supported", t.Name(), opt.name)
+		}
+		if err := opt.options.Validate(); err != nil {
+			return fmt.Errorf("options validation failed for image type %q: %s: %w", t.Name(), opt.name, err)
+		}
+	}
+	return nil
+}
+
+// checkOSOption returns an error if an option that is applied to the os
+// tree by osCustomizations() is used for an image type that does not
+// build one: ostree deployments get their configuration from the commit
+// and the live and network installers ship or install a system that is
+// not customized
+func checkOSOption(t *imageType, name string) error {
+	if (t.Bootable || t.BootISO) && t.IsOSTreeBasedImageType() {
+		return fmt.Errorf("options validation failed for image type %q: %s: not supported for ostree deployments, set it on the commit", t.Name(), name)
+	}
+	switch t.ImageTypeYAML.Image {
+	case "live_installer", "network-installer":
+		return fmt.Errorf("options validation failed for image type %q: %s: not supported for live and network installers", t.Name(), name)
+	}
+	return nil
+}
+
+// kickstartInstaller returns true for the installer ISOs that configure
+// the installed system with a kickstart file
+func (t *imageType) kickstartInstaller() bool {
+	switch t.ImageTypeYAML.Image {
+	case "image_installer", "ostree_installer", "network-installer":
+		return true
+	default:
+		return false
+	}
+}
+
+// checkKickstartNetwork makes sure that the connections can be written as
+// kickstart network commands, which only configure ethernet devices
+func checkKickstartNetwork(conns []network.Connection) error {
+	for _, conn := range conns {
+		if conn.Type != network.ConnectionTypeEthernet {
+			return fmt.Errorf("connection %q: installers only support %s connections, not %s", conn.Name, network.ConnectionTypeEthernet, conn.Type)
+		}
+	}
+	_, err := osbuild.KickstartNetworkFromConnections(conns)
+	return err
+}
@@ -64,6 +64,14 @@ func TestRH10FilenameFromType(t *testing.T) {
 				mimeType: "application/x-vhd",
 			},
 		},
+		{
+			name: "hyperv",
+			args: args{"hyperv"},
+			want: wantResult{
+				filename: "disk.vhdx",
+				mimeType: "application/x-vhdx",
+			},
+		},
 		{
 			name: "vmdk",
 			args: args{"vmdk"},
@@ -249,6 +257,7 @@ func TestRH10Architecture_ListImageTypes(t *testing.T) {
 				"qcow2",
 				"oci",
 				"vhd",
+				"hyperv",
 				"vmdk",
 				"ova",
 				"ami",
@@ -284,6 +293,7 @@ func TestRH10Architecture_ListImageTypes(t *testing.T) {
 				"tar",
 				"vagrant-libvirt",
 				"vhd",
+				"hyperv",
 				"wsl",
 				"minimal-raw-xz",
 			},
@@ -476,6 +476,7 @@ func TestRhel9_Architecture_ListImageTypes(t *testing.T) {
 				"qcow2",
 				"openstack",
 				"vhd",
+				"hyperv",
 				"azure-rhui",
 				"azure-sap-rhui",
 				"azure-sapapps-rhui",
@@ -526,6 +527,7 @@ func TestRhel9_Architecture_ListImageTypes(t *testing.T) {
 				"tar",
 				"image-installer",
 				"vhd",
+				"hyperv",
 				"azure-rhui",
 				"vagrant-libvirt",
 				"wsl",
@@ -6,6 +6,7 @@ import (
 
 	"github.com/osbuild/image-builder/internal/common"
 	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
 	"github.com/osbuild/image-builder/pkg/customizations/oci"
 	"github.com/osbuild/image-builder/pkg/customizations/ostreeserver"
 	"github.com/osbuild/image-builder/pkg/customizations/shell"
@@ -43,6 +44,9 @@ type ImageConfig struct {
 	// These two options should be unified.
 	DefaultKernelName *string `yaml:"default_kernel_name"`
 
+	// Distro defaults for the kdump image option
+	Kdump *KdumpConfig `yaml:"kdump,omitempty"`
+
 	// List of files from which to import GPG keys into the RPM database
 	GPGKeyFiles []string `yaml:"gpgkey_files,omitempty"`
 
@@ -87,6 +91,7 @@ type ImageConfig struct {
 	UdevRules           *osbuild.UdevRulesStageOptions      `yaml:"udev_rules,omitempty"`
 	GCPGuestAgentConfig *osbuild.GcpGuestAgentConfigOptions `yaml:"gcp_guest_agent_config,omitempty"`
 	NetworkManager      *osbuild.NMConfStageOptions         `yaml:"network_manager,omitempty"`
+	NetworkConnections  []network.Connection                `yaml:"network_connections,omitempty"`
 	Presets             []osbuild.Preset                    `yaml:"presets,omitempty"`
 
 	WSL *wsl.WSL `yaml:"wsl,omitempty"`
@@ -236,6 +241,14 @@ func (c *ImageConfig) DNFConfigOptions(osVersion string) (*osbuild.DNFConfigStag
 	return options, nil
 }
 
+type KdumpConfig struct {
+	// Packages that provide the kdump service
+	Packages []string `yaml:"packages"`
+	// Default crashkernel= memory reservation by architecture name, used
+	// when kdump is enabled without an explicit reservation
+	CrashKernel map[string]string `yaml:"crashkernel"`
+}
+
 type Sysconfig struct {
 	Networking bool `yaml:"networking,omitempty"`
 	NoZeroConf bool `yaml:"no_zero_conf,omitempty"`
@@ -42,7 +42,10 @@ func (img *Archive) InstantiateManifest(m *manifest.Manifest,
 	tarPipeline := manifest.NewTar(buildPipeline, osPipeline, "archive")
 	tarPipeline.NumericOwner = common.ToPtr(true)
 
-	compressionPipeline := GetCompressionPipeline(img.Compression, buildPipeline, tarPipeline)
+	compressionPipeline, err := GetCompressionPipeline(img.Compression, buildPipeline, tarPipeline)
+	if err != nil {
+		return nil, err
+	}
 	compressionPipeline.SetFilename(img.filename)
 
 	return compressionPipeline.Export(), nil
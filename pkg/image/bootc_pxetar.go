@@ -146,7 +146,10 @@ func (img *BootcPXEImage) InstantiateManifestFromContainers(m *manifest.Manifest
 	tarPipeline := manifest.NewTar(buildPipeline, pxeTreePipeline, "tar")
 	tarPipeline.Paths = pxeTreePipeline.GetTarFiles()
 
-	compressionPipeline := GetCompressionPipeline(img.Compression, buildPipeline, tarPipeline)
+	compressionPipeline, err := GetCompressionPipeline(img.Compression, buildPipeline, tarPipeline)
+	if err != nil {
+		return err
+	}
 	compressionPipeline.SetFilename(img.filename)
 	return nil
 }
@@ -25,6 +25,9 @@ type DiskImage struct {
 	Environment        environment.Environment
 	Compression        string
 
+	// Create a block map for bmaptool next to the (compressed) raw image
+	Bmap bool
+
 	// Control the VPC subformat use of force_size
 	VPCForceSize *bool
 	PartTool     osbuild.PartTool
@@ -94,6 +97,8 @@ func (img *DiskImage) InstantiateManifest(m *manifest.Manifest,
 		vpcPipeline := manifest.NewVPC(buildPipeline, rawImagePipeline)
 		vpcPipeline.ForceSize = img.VPCForceSize
 		imagePipeline = vpcPipeline
+	case platform.FORMAT_VHDX:
+		imagePipeline = manifest.NewVHDX(buildPipeline, rawImagePipeline)
 	case platform.FORMAT_VMDK:
 		imagePipeline = manifest.NewVMDK(buildPipeline, rawImagePipeline)
 	case platform.FORMAT_OVA:
@@ -120,8 +125,29 @@ func (img *DiskImage) InstantiateManifest(m *manifest.Manifest,
 		panic("invalid image format for image kind")
 	}
 
-	compressionPipeline := GetCompressionPipeline(img.Compression, buildPipeline, imagePipeline)
+	compressionPipeline, err := GetCompressionPipeline(img.Compression, buildPipeline, imagePipeline)
+	if err != nil {
+		return nil, err
+	}
 	compressionPipeline.SetFilename(img.filename)
 
+	if img.Bmap {
+		if img.platform.GetImageFormat() != platform.FORMAT_RAW {
+			return nil, fmt.Errorf("block maps can only be created for raw images, not %q", img.platform.GetImageFormat())
+		}
+		// the block map is written next to the compressed image, in the
+		// same pipeline
+		switch p := compressionPipeline.(type) {
+		case *manifest.XZ:
+			p.Bmap = true
+		case *manifest.Zstd:
+			p.Bmap = true
+		case *manifest.Gzip:
+			p.Bmap = true
+		default:
+			return nil, fmt.Errorf("block maps can only be created for xz, zstd or gzip compressed images, not %q compression", img.Compression)
+		}
+	}
+
 	return compressionPipeline.Export(), nil
 }
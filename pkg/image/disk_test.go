@@ -0,0 +1,62 @@
+package image_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/arch"
+	"github.com/osbuild/image-builder/pkg/image"
+	"github.com/osbuild/image-builder/pkg/manifest"
+	"github.com/osbuild/image-builder/pkg/platform"
+	"github.com/osbuild/image-builder/pkg/runner"
+)
+
+func TestDiskImageCompressedOutputs(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		format      platform.ImageFormat
+		compression string
+		bmap        bool
+		err         string
+	}{
+		{"sparse-raw", platform.FORMAT_RAW, "android-sparse", false, ""},
+		{"sparse-qcow2", platform.FORMAT_QCOW2, "android-sparse", false, `android-sparse compression is only supported for raw images, not "qcow2"`},
+		{"bmap-zstd", platform.FORMAT_RAW, "zstd-seekable", true, ""},
+		{"bmap-qcow2", platform.FORMAT_QCOW2, "xz", true, `block maps can only be created for raw images, not "qcow2"`},
+		{"bmap-uncompressed", platform.FORMAT_RAW, "", true, `block maps can only be created for xz, zstd or gzip compressed images, not "" compression`},
+		{"bmap-sparse", platform.FORMAT_RAW, "android-sparse", true, `block maps can only be created for xz, zstd or gzip compressed images, not "android-sparse" compression`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			img := image.NewDiskImage(&platform.Data{Arch: arch.ARCH_X86_64, ImageFormat: tc.format}, "disk.img")
+			img.Compression = tc.compression
+			img.Bmap = tc.bmap
+
+			mf := manifest.New()
+			/* #nosec G404 */
+			_, err := img.InstantiateManifest(&mf, nil, &runner.Fedora{}, rand.New(rand.NewSource(0)))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetCompressionPipelineErrors(t *testing.T) {
+	mf := manifest.New()
+	build := manifest.NewBuild(&mf, &runner.Fedora{}, nil, nil)
+	raw := manifest.NewRawImage(build, nil, manifest.DiskCustomizations{})
+	qcow2 := manifest.NewQCOW2(build, raw)
+
+	sparse, err := image.GetCompressionPipeline("android-sparse", build, raw)
+	require.NoError(t, err)
+	assert.IsType(t, &manifest.AndroidSparse{}, sparse)
+	_, err = image.GetCompressionPipeline("android-sparse", build, qcow2)
+	assert.EqualError(t, err, `android-sparse compression is only supported for raw images, not "qcow2"`)
+	_, err = image.GetCompressionPipeline("lz4", build, raw)
+	assert.EqualError(t, err, `unsupported compression type "lz4"`)
+}
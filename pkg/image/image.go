@@ -35,18 +35,32 @@ func NewBase(name string, platform platform.Platform, filename string) Base {
 	}
 }
 
-func GetCompressionPipeline(compression string, buildPipeline manifest.Build, inputPipeline manifest.FilePipeline) manifest.FilePipeline {
+// GetCompressionPipeline returns the pipeline that compresses the output of
+// the input pipeline, or the input pipeline itself if there is no
+// compression.
+func GetCompressionPipeline(compression string, buildPipeline manifest.Build, inputPipeline manifest.FilePipeline) (manifest.FilePipeline, error) {
 	switch compression {
 	case "xz":
-		return manifest.NewXZ(buildPipeline, inputPipeline)
+		return manifest.NewXZ(buildPipeline, inputPipeline), nil
 	case "zstd":
-		return manifest.NewZstd(buildPipeline, inputPipeline)
+		return manifest.NewZstd(buildPipeline, inputPipeline), nil
+	case "zstd-seekable":
+		zstdPipeline := manifest.NewZstd(buildPipeline, inputPipeline)
+		zstdPipeline.Seekable = true
+		return zstdPipeline, nil
 	case "gzip":
-		return manifest.NewGzip(buildPipeline, inputPipeline)
+		return manifest.NewGzip(buildPipeline, inputPipeline), nil
+	case "android-sparse":
+		// img2simg only converts raw disk images
+		switch inputPipeline.(type) {
+		case *manifest.RawImage, *manifest.RawOSTreeImage, *manifest.RawBootcImage:
+		default:
+			return nil, fmt.Errorf("android-sparse compression is only supported for raw images, not %q", inputPipeline.Name())
+		}
+		return manifest.NewAndroidSparse(buildPipeline, inputPipeline), nil
 	case "":
-		return inputPipeline
+		return inputPipeline, nil
 	default:
-		// panic on unknown strings
-		panic(fmt.Sprintf("unsupported compression type %q", compression))
+		return nil, fmt.Errorf("unsupported compression type %q", compression)
 	}
 }
@@ -109,7 +109,10 @@ func (img *OSTreeDiskImage) InstantiateManifest(m *manifest.Manifest,
 		qcow2Pipeline.SetFilename(img.filename)
 		return qcow2Pipeline.Export(), nil
 	default:
-		compressionPipeline := GetCompressionPipeline(img.Compression, buildPipeline, baseImage)
+		compressionPipeline, err := GetCompressionPipeline(img.Compression, buildPipeline, baseImage)
+		if err != nil {
+			return nil, err
+		}
 		compressionPipeline.SetFilename(img.filename)
 
 		return compressionPipeline.Export(), nil
@@ -68,7 +68,10 @@ func (img *PXETar) InstantiateManifest(m *manifest.Manifest,
 
 	tarPipeline := manifest.NewTar(buildPipeline, pxeTreePipeline, "tar")
 
-	compressionPipeline := GetCompressionPipeline(img.Compression, buildPipeline, tarPipeline)
+	compressionPipeline, err := GetCompressionPipeline(img.Compression, buildPipeline, tarPipeline)
+	if err != nil {
+		return nil, err
+	}
 	compressionPipeline.SetFilename(img.filename)
 
 	return compressionPipeline.Export(), nil
@@ -726,6 +726,12 @@ func (p *AnacondaInstallerISOTree) bootcInstallerKickstartStages() ([]*osbuild.S
 			{BootProto: "dhcp", Device: "link", Activate: common.ToPtr(true), OnBoot: "on"},
 		}
 	}
+	if len(p.Kickstart.Network) > 0 {
+		kickstartOptions.Network, err = osbuild.KickstartNetworkFromConnections(p.Kickstart.Network)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	stages = append(stages, osbuild.NewKickstartStage(kickstartOptions))
 
@@ -879,6 +885,14 @@ func (p *AnacondaInstallerISOTree) makeKickstartStages(stageOptions *osbuild.Kic
 		}
 	}
 
+	if len(kickstartOptions.Network) > 0 {
+		ksNetwork, err := osbuild.KickstartNetworkFromConnections(kickstartOptions.Network)
+		if err != nil {
+			return nil, err
+		}
+		stageOptions.Network = ksNetwork
+	}
+
 	if sudoersPost := makeKickstartSudoersPost(kickstartOptions.SudoNopasswd); sudoersPost != nil {
 		stageOptions.Post = append(stageOptions.Post, *sudoersPost)
 	}
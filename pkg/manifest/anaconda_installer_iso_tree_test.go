@@ -17,6 +17,7 @@ import (
 	"github.com/osbuild/image-builder/pkg/arch"
 	"github.com/osbuild/image-builder/pkg/container"
 	"github.com/osbuild/image-builder/pkg/customizations/kickstart"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
 	"github.com/osbuild/image-builder/pkg/customizations/users"
 	"github.com/osbuild/image-builder/pkg/datasizes"
 	"github.com/osbuild/image-builder/pkg/disk"
@@ -500,6 +501,26 @@ func TestAnacondaISOTreeSerializeWithOS(t *testing.T) {
 		assert.NoError(t, checkKickstartOptions(sp.Stages, pipeline.Kickstart.Unattended, len(pipeline.Kickstart.SudoNopasswd) > 0, ""))
 	})
 
+	t.Run("unattended+network-connections", func(t *testing.T) {
+		pipeline := newTestAnacondaISOTree(manifest.SyslinuxISOBoot)
+		pipeline.OSPipeline = osPayload
+		pipeline.Kickstart = &kickstart.Options{
+			Path:       testKsPath,
+			Unattended: true,
+			Network: []network.Connection{
+				{Name: "eth0", Type: network.ConnectionTypeEthernet, Interface: "eth0"},
+			},
+		}
+		sp, err := manifest.SerializeWith(pipeline, manifest.Inputs{})
+		assert.NoError(t, err)
+		kickstartSt := findStage("org.osbuild.kickstart", sp.Stages)
+		assert.NotNil(t, kickstartSt)
+		opts := kickstartSt.Options.(*osbuild.KickstartStageOptions)
+		assert.Equal(t, []osbuild.NetworkOptions{
+			{Device: "eth0", Activate: common.ToPtr(true), OnBoot: "on", BootProto: "dhcp"},
+		}, opts.Network)
+	})
+
 	t.Run("unattended+sudo", func(t *testing.T) {
 		pipeline := newTestAnacondaISOTree(manifest.SyslinuxISOBoot)
 		pipeline.OSPipeline = osPayload
@@ -846,6 +867,37 @@ func TestAnacondaISOTreeSerializeWithContainer(t *testing.T) {
 		assert.Equal(t, "on", opts.Network[0].OnBoot)
 	})
 
+	t.Run("network-connections", func(t *testing.T) {
+		pipeline := newTestAnacondaISOTree(manifest.Grub2UEFIOnlyISOBoot)
+		pipeline.Kickstart = &kickstart.Options{
+			Path:          testKsPath,
+			NetworkOnBoot: true,
+			Network: []network.Connection{
+				{
+					Name:      "eth0",
+					Type:      network.ConnectionTypeEthernet,
+					Interface: "eth0",
+					IPv4:      &network.IPConfig{Method: network.IPMethodManual, Addresses: []string{"192.168.1.10/24"}},
+				},
+			},
+		}
+		sp, err := manifest.SerializeWith(pipeline, manifest.Inputs{Containers: []container.Spec{containerPayload}})
+		assert.NoError(t, err)
+		kickstartSt := findStage("org.osbuild.kickstart", sp.Stages)
+		assert.NotNil(t, kickstartSt)
+		opts := kickstartSt.Options.(*osbuild.KickstartStageOptions)
+		assert.Equal(t, []osbuild.NetworkOptions{
+			{
+				Device:    "eth0",
+				Activate:  common.ToPtr(true),
+				OnBoot:    "on",
+				BootProto: "static",
+				IP:        "192.168.1.10",
+				Netmask:   "255.255.255.0",
+			},
+		}, opts.Network)
+	})
+
 	t.Run("user-kickstart", func(t *testing.T) {
 		userks := "%post\necho 'Some kind of text in a file sent by post'\n%end"
 		pipeline := newTestAnacondaISOTree(manifest.SyslinuxISOBoot)
@@ -0,0 +1,64 @@
+package manifest
+
+import (
+	"github.com/osbuild/image-builder/pkg/artifact"
+	"github.com/osbuild/image-builder/pkg/osbuild"
+)
+
+// The AndroidSparse pipeline converts a raw image file into an Android sparse
+// image using img2simg.
+type AndroidSparse struct {
+	Base
+	filename string
+
+	// Block size of the sparse image, uses the default of img2simg if unset
+	BlockSize uint64
+
+	imgPipeline FilePipeline
+}
+
+func (p AndroidSparse) Filename() string {
+	return p.filename
+}
+
+func (p *AndroidSparse) SetFilename(filename string) {
+	p.filename = filename
+}
+
+// NewAndroidSparse creates a new AndroidSparse pipeline. imgPipeline is the
+// pipeline producing the raw image that will be converted.
+func NewAndroidSparse(buildPipeline Build, imgPipeline FilePipeline) *AndroidSparse {
+	p := &AndroidSparse{
+		Base:        NewBase("android-sparse", buildPipeline),
+		filename:    "image.simg",
+		imgPipeline: imgPipeline,
+	}
+	buildPipeline.addDependent(p)
+	return p
+}
+
+func (p *AndroidSparse) serialize() (osbuild.Pipeline, error) {
+	pipeline, err := p.Base.serialize()
+	if err != nil {
+		return osbuild.Pipeline{}, err
+	}
+
+	options := osbuild.NewImg2simgStageOptions(p.Filename())
+	options.BlockSize = p.BlockSize
+	pipeline.AddStage(osbuild.NewImg2simgStage(
+		options,
+		osbuild.NewImg2simgStageInputs(osbuild.NewFilesInputPipelineObjectRef(p.imgPipeline.Name(), p.imgPipeline.Export().Filename(), nil)),
+	))
+
+	return pipeline, nil
+}
+
+func (p *AndroidSparse) getBuildPackages(Distro) ([]string, error) {
+	return []string{"android-tools"}, nil
+}
+
+func (p *AndroidSparse) Export() *artifact.Artifact {
+	p.Base.export = true
+	mimeType := "application/octet-stream"
+	return artifact.New(p.Name(), p.Filename(), &mimeType)
+}
@@ -0,0 +1,37 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/pkg/manifest"
+	"github.com/osbuild/image-builder/pkg/osbuild"
+	"github.com/osbuild/image-builder/pkg/runner"
+)
+
+func TestAndroidSparseSerialize(t *testing.T) {
+	mani := manifest.New()
+	runner := &runner.Linux{}
+	build := manifest.NewBuild(&mani, runner, nil, nil)
+
+	// setup
+	rawImage := manifest.NewRawImage(build, nil, manifest.DiskCustomizations{})
+	sparsePipeline := manifest.NewAndroidSparse(build, rawImage)
+	sparsePipeline.SetFilename("filename.simg")
+	sparsePipeline.BlockSize = 8192
+
+	// run
+	osbuildPipeline, err := manifest.Serialize(sparsePipeline)
+	assert.NoError(t, err)
+
+	// assert
+	assert.Equal(t, "android-sparse", osbuildPipeline.Name)
+	assert.Equal(t, 1, len(osbuildPipeline.Stages))
+	sparseStage := osbuildPipeline.Stages[0]
+	assert.Equal(t, "org.osbuild.img2simg", sparseStage.Type)
+	assert.Equal(t, &osbuild.Img2simgStageOptions{
+		Filename:  "filename.simg",
+		BlockSize: 8192,
+	}, sparseStage.Options.(*osbuild.Img2simgStageOptions))
+}
@@ -0,0 +1,22 @@
+package manifest
+
+import (
+	"github.com/osbuild/image-builder/pkg/osbuild"
+)
+
+// BmapFilename returns the name of the block map (bmap) that is written next
+// to the image file filename.
+func BmapFilename(filename string) string {
+	return filename + ".bmap"
+}
+
+// newBmapStage returns the stage that writes the block map of the raw image
+// produced by rawPipeline into the tree, next to the (compressed) image file
+// filename. bmaptool can then write the compressed image without copying the
+// unused blocks of the raw image.
+func newBmapStage(filename string, rawPipeline FilePipeline) *osbuild.Stage {
+	return osbuild.NewBmaptoolStage(
+		osbuild.NewBmaptoolStageOptions(BmapFilename(filename)),
+		osbuild.NewBmaptoolStageInputs(osbuild.NewFilesInputPipelineObjectRef(rawPipeline.Name(), rawPipeline.Filename(), nil)),
+	)
+}
@@ -0,0 +1,48 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/manifest"
+	"github.com/osbuild/image-builder/pkg/osbuild"
+	"github.com/osbuild/image-builder/pkg/runner"
+)
+
+func TestBmapSerialize(t *testing.T) {
+	mani := manifest.New()
+	runner := &runner.Linux{}
+	build := manifest.NewBuild(&mani, runner, nil, nil)
+
+	// setup
+	rawImage := manifest.NewRawImage(build, nil, manifest.DiskCustomizations{})
+	rawImage.SetFilename("disk.raw")
+	zstdPipeline := manifest.NewZstd(build, rawImage)
+	zstdPipeline.SetFilename("disk.raw.zst")
+	zstdPipeline.Bmap = true
+
+	// run
+	osbuildPipeline, err := manifest.Serialize(zstdPipeline)
+	require.NoError(t, err)
+
+	// assert: the block map is created in the zstd pipeline, from the raw
+	// image, without copying the image
+	assert.Equal(t, "zstd", osbuildPipeline.Name)
+	assert.Equal(t, "disk.raw.zst.bmap", manifest.BmapFilename(zstdPipeline.Filename()))
+	require.Equal(t, 2, len(osbuildPipeline.Stages))
+	assert.Equal(t, "org.osbuild.zstd", osbuildPipeline.Stages[0].Type)
+
+	bmapStage := osbuildPipeline.Stages[1]
+	assert.Equal(t, "org.osbuild.bmaptool", bmapStage.Type)
+	assert.Equal(t, &osbuild.BmaptoolStageOptions{
+		Filename: "disk.raw.zst.bmap",
+	}, bmapStage.Options)
+	inputs := bmapStage.Inputs.(*osbuild.BmaptoolStageInputs)
+	assert.Equal(t, osbuild.NewFilesInput(osbuild.NewFilesInputPipelineObjectRef("image", "disk.raw", nil)), inputs.File)
+
+	artifact := zstdPipeline.Export()
+	assert.Equal(t, "zstd", artifact.Export())
+	assert.Equal(t, "disk.raw.zst", artifact.Filename())
+}
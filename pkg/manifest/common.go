@@ -3,6 +3,7 @@ package manifest
 import (
 	"fmt"
 
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
 	"github.com/osbuild/image-builder/pkg/disk"
 	"github.com/osbuild/image-builder/pkg/osbuild"
 )
@@ -10,16 +11,27 @@ import (
 // filesystemConfigStages generates either an org.osbuild.fstab stage or a
 // collection of org.osbuild.systemd.unit.create stages for .mount and .swap
 // units (and an org.osbuild.systemd stage to enable them) depending on the
-// pipeline configuration.
-func filesystemConfigStages(pt *disk.PartitionTable, mountConfiguration osbuild.MountConfiguration) ([]*osbuild.Stage, error) {
+// pipeline configuration. The optional swapFile is added as an fstab entry or
+// as a .swap unit respectively.
+func filesystemConfigStages(pt *disk.PartitionTable, mountConfiguration osbuild.MountConfiguration, swapFile *swap.File) ([]*osbuild.Stage, error) {
 	switch mountConfiguration {
 	case osbuild.MOUNT_CONFIGURATION_UNITS:
-		return osbuild.GenSystemdMountStages(pt)
+		stages, err := osbuild.GenSystemdMountStages(pt)
+		if err != nil {
+			return nil, err
+		}
+		if swapFile != nil {
+			stages = append(stages, osbuild.GenSwapFileUnitStages(swapFile)...)
+		}
+		return stages, nil
 	case osbuild.MOUNT_CONFIGURATION_FSTAB:
 		opts, err := osbuild.NewFSTabStageOptions(pt)
 		if err != nil {
 			return nil, err
 		}
+		if swapFile != nil {
+			opts.AddSwapFile(swapFile.Path)
+		}
 		return []*osbuild.Stage{osbuild.NewFSTabStage(opts)}, nil
 	case osbuild.MOUNT_CONFIGURATION_NONE:
 		return []*osbuild.Stage{}, nil
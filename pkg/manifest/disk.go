@@ -11,6 +11,10 @@ type DiskCustomizations struct {
 
 	// Which partitioning tooling is used to create the disk image(s)
 	PartitioningTool osbuild.PartTool
+
+	// How partitions that are marked to grow are grown to fill the disk on
+	// first boot
+	GrowMethod osbuild.GrowMethod
 }
 
 func NewDiskCustomizations() DiskCustomizations {
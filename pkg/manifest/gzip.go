@@ -10,6 +10,9 @@ type Gzip struct {
 	Base
 	filename string
 
+	// Write a block map of the raw image next to the compressed image
+	Bmap bool
+
 	imgPipeline FilePipeline
 }
 
@@ -43,12 +46,19 @@ func (p *Gzip) serialize() (osbuild.Pipeline, error) {
 		osbuild.NewGzipStageOptions(p.Filename()),
 		osbuild.NewGzipStageInputs(osbuild.NewFilesInputPipelineObjectRef(p.imgPipeline.Name(), p.imgPipeline.Export().Filename(), nil)),
 	))
+	if p.Bmap {
+		pipeline.AddStage(newBmapStage(p.Filename(), p.imgPipeline))
+	}
 
 	return pipeline, nil
 }
 
 func (p *Gzip) getBuildPackages(Distro) ([]string, error) {
-	return []string{"gzip"}, nil
+	pkgs := []string{"gzip"}
+	if p.Bmap {
+		pkgs = append(pkgs, "bmap-tools")
+	}
+	return pkgs, nil
 }
 
 func (p *Gzip) Export() *artifact.Artifact {
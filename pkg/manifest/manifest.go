@@ -19,6 +19,7 @@ import (
 	"github.com/osbuild/image-builder/internal/common"
 	"github.com/osbuild/image-builder/pkg/container"
 	"github.com/osbuild/image-builder/pkg/depsolvednf"
+	"github.com/osbuild/image-builder/pkg/disk"
 	"github.com/osbuild/image-builder/pkg/flatpak"
 	"github.com/osbuild/image-builder/pkg/osbuild"
 	"github.com/osbuild/image-builder/pkg/ostree"
@@ -254,6 +255,20 @@ func (m Manifest) Serialize(depsolvedSets map[string]depsolvednf.DepsolveResult,
 	)
 }
 
+// GetPartitionTables returns the partition tables of the pipelines that
+// install packages into a partitioned filesystem tree, keyed by pipeline
+// name. The partition tables can be modified before serializing, e.g. to
+// make room for the depsolved packages.
+func (m Manifest) GetPartitionTables() map[string]*disk.PartitionTable {
+	pts := make(map[string]*disk.PartitionTable)
+	for _, pipeline := range m.pipelines {
+		if os, ok := pipeline.(*OS); ok && os.PartitionTable != nil {
+			pts[pipeline.Name()] = os.PartitionTable
+		}
+	}
+	return pts
+}
+
 func (m Manifest) GetCheckpoints() []string {
 	checkpoints := []string{}
 	for _, p := range m.pipelines {
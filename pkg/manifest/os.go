@@ -15,12 +15,16 @@ import (
 	"github.com/osbuild/image-builder/pkg/arch"
 	"github.com/osbuild/image-builder/pkg/container"
 	"github.com/osbuild/image-builder/pkg/customizations/bootc"
+	"github.com/osbuild/image-builder/pkg/customizations/cryptopolicy"
 	"github.com/osbuild/image-builder/pkg/customizations/firstboot"
 	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
 	"github.com/osbuild/image-builder/pkg/customizations/ignition"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
 	"github.com/osbuild/image-builder/pkg/customizations/oscap"
+	"github.com/osbuild/image-builder/pkg/customizations/selinux"
 	"github.com/osbuild/image-builder/pkg/customizations/shell"
 	"github.com/osbuild/image-builder/pkg/customizations/subscription"
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
 	"github.com/osbuild/image-builder/pkg/customizations/users"
 	"github.com/osbuild/image-builder/pkg/depsolvednf"
 	"github.com/osbuild/image-builder/pkg/disk"
@@ -108,6 +112,8 @@ type OSCustomizations struct {
 	BuildSELinux string
 
 	SELinuxForceRelabel *bool
+	// Local modifications of the SELinux policy, requires SELinux
+	SELinuxOptions *selinux.Options
 
 	// Do not install documentation
 	ExcludeDocs bool
@@ -123,6 +129,7 @@ type OSCustomizations struct {
 	Grub2Config           *osbuild.GRUB2Config
 	Sysconfig             []*osbuild.SysconfigStageOptions
 	SystemdLogind         []*osbuild.SystemdLogindStageOptions
+	SystemdJournald       []*osbuild.SystemdJournaldStageOptions
 	CloudInit             []*osbuild.CloudInitStageOptions
 	Modprobe              []*osbuild.ModprobeStageOptions
 	DracutConf            []*osbuild.DracutConfStageOptions
@@ -144,7 +151,7 @@ type OSCustomizations struct {
 	PwQuality             *osbuild.PwqualityConfStageOptions
 	ChronyConfig          *osbuild.ChronyStageOptions
 	WAAgentConfig         *osbuild.WAAgentConfStageOptions
-	UdevRules             *osbuild.UdevRulesStageOptions
+	UdevRules             []*osbuild.UdevRulesStageOptions
 	WSLConfig             *osbuild.WSLConfStageOptions
 	WSLDistributionConfig *osbuild.WSLDistributionConfStageOptions
 	InsightsClientConfig  *osbuild.InsightsClientConfigStageOptions
@@ -153,6 +160,16 @@ type OSCustomizations struct {
 	ContainersStorage     *string
 	Ignition              *ignition.FirstBootOptions
 
+	// NetworkManager connection profiles, written as keyfiles
+	NetworkConnections []network.Connection
+
+	// System-wide crypto policy, applied after the FIPS configuration
+	CryptoPolicy *cryptopolicy.Options
+
+	// Swap file and zram configuration; a swap partition is part of the
+	// partition table
+	Swap *swap.Options
+
 	// OpenSCAP config
 	OpenSCAPRemediationConfig *oscap.RemediationConfig
 
@@ -296,10 +313,19 @@ func (p *OS) getPackageSetChain(Distro) ([]rpmmd.PackageSet, error) {
 		customizationPackages = append(customizationPackages, fmt.Sprintf("selinux-policy-%s", p.OSCustomizations.SELinux))
 	}
 
+	if p.OSCustomizations.SELinuxOptions != nil {
+		// semanage is needed in the tree to modify the policy store
+		customizationPackages = append(customizationPackages, "policycoreutils-python-utils")
+	}
+
 	if p.OSCustomizations.OpenSCAPRemediationConfig != nil {
 		customizationPackages = append(customizationPackages, "openscap-scanner", "scap-security-guide", "xz")
 	}
 
+	if p.OSCustomizations.Swap != nil && p.OSCustomizations.Swap.Zram != nil {
+		customizationPackages = append(customizationPackages, "zram-generator")
+	}
+
 	// Make sure the right packages are included for subscriptions
 	// rhc always uses insights, and depends on subscription-manager
 	// non-rhc uses subscription-manager and optionally includes Insights
@@ -321,6 +347,11 @@ func (p *OS) getPackageSetChain(Distro) ([]rpmmd.PackageSet, error) {
 		customizationPackages = append(customizationPackages, "shadow-utils", "pam", "passwd")
 	}
 
+	if p.PartitionTable != nil && p.PartitionTable.GrowsOnBoot() && p.DiskCustomizations.GrowMethod == osbuild.GrowMethodGrowpart {
+		// the cloud-init growpart module calls growpart from cloud-utils
+		customizationPackages = append(customizationPackages, "cloud-utils-growpart")
+	}
+
 	if p.OSCustomizations.Firewall != nil {
 		// Make sure firewalld is available in the image.
 		// org.osbuild.firewall runs 'firewall-offline-cmd' in the os tree
@@ -713,6 +744,10 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 		pipeline.AddStage(osbuild.NewSystemdLogindStage(systemdLogindConfig))
 	}
 
+	for _, systemdJournaldConfig := range p.OSCustomizations.SystemdJournald {
+		pipeline.AddStage(osbuild.NewSystemdJournaldStage(systemdJournaldConfig))
+	}
+
 	for _, cloudInitConfig := range p.OSCustomizations.CloudInit {
 		pipeline.AddStage(osbuild.NewCloudInitStage(cloudInitConfig))
 	}
@@ -749,6 +784,17 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 		pipeline.AddStage(osbuild.NewSystemdUnitCreateStage(fbUnit))
 	}
 
+	tpm2Dirs, tpm2Units, err := osbuild.GenTPM2EnrollFromPartitionTable(p.PartitionTable)
+	if err != nil {
+		return osbuild.Pipeline{}, err
+	}
+	if len(tpm2Dirs) > 0 {
+		pipeline.AddStages(osbuild.GenDirectoryNodesStages(tpm2Dirs)...)
+	}
+	for _, tpm2Unit := range tpm2Units {
+		pipeline.AddStage(osbuild.NewSystemdUnitCreateStage(tpm2Unit))
+	}
+
 	if p.OSCustomizations.Authselect != nil {
 		pipeline.AddStage(osbuild.NewAuthselectStage(p.OSCustomizations.Authselect))
 	}
@@ -805,6 +851,17 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 		pipeline.AddStage(osbuild.NewNMConfStage(p.OSCustomizations.NetworkManager))
 	}
 
+	nmDirs, nmFiles, err := osbuild.GenNMKeyfilesFromConnections(p.OSCustomizations.NetworkConnections)
+	if err != nil {
+		return osbuild.Pipeline{}, err
+	}
+	if len(nmDirs) > 0 {
+		pipeline.AddStages(osbuild.GenDirectoryNodesStages(nmDirs)...)
+	}
+	if len(nmFiles) > 0 {
+		p.addStagesForAllFilesAndInlineData(&pipeline, nmFiles)
+	}
+
 	if p.OSCustomizations.AuthConfig != nil {
 		pipeline.AddStage(osbuild.NewAuthconfigStage(p.OSCustomizations.AuthConfig))
 	}
@@ -838,10 +895,20 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 		pipeline.AddStage(osbuild.NewWAAgentConfStage(p.OSCustomizations.WAAgentConfig))
 	}
 
-	if p.OSCustomizations.UdevRules != nil {
-		pipeline.AddStage(osbuild.NewUdevRulesStage(p.OSCustomizations.UdevRules))
+	for _, udevRules := range p.OSCustomizations.UdevRules {
+		pipeline.AddStage(osbuild.NewUdevRulesStage(udevRules))
 	}
 
+	if swapOptions := p.OSCustomizations.Swap; swapOptions != nil && swapOptions.Zram != nil {
+		zramConfig, err := swapOptions.Zram.ConfigFile()
+		if err != nil {
+			return osbuild.Pipeline{}, err
+		}
+		p.addStagesForAllFilesAndInlineData(&pipeline, []*fsnode.File{zramConfig})
+	}
+
+	var growUnits []*osbuild.SystemdUnitCreateStageOptions
+	var swapFileUnit *osbuild.SystemdUnitCreateStageOptions
 	if pt := p.PartitionTable; pt != nil {
 		rootUUID, kernelOptions, err := osbuild.GenImageKernelOptions(p.PartitionTable, p.DiskCustomizations.MountConfiguration)
 		if err != nil {
@@ -869,11 +936,35 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 			pipeline.AddStage(osbuild.NewDracutStage(dracutOptions))
 		}
 
-		fsCfgStages, err := filesystemConfigStages(pt, p.DiskCustomizations.MountConfiguration)
+		var swapFile *swap.File
+		if p.OSCustomizations.Swap != nil {
+			swapFile = p.OSCustomizations.Swap.File
+		}
+		fsCfgStages, err := filesystemConfigStages(pt, p.DiskCustomizations.MountConfiguration, swapFile)
 		if err != nil {
 			return osbuild.Pipeline{}, err
 		}
 		pipeline.AddStages(fsCfgStages...)
+		if swapFile != nil {
+			swapFileUnit = osbuild.GenSwapFileCreateStageOptions(swapFile)
+			pipeline.AddStage(osbuild.NewSystemdUnitCreateStage(swapFileUnit))
+		}
+
+		var growDirs []*fsnode.Directory
+		var growFiles []*fsnode.File
+		growDirs, growFiles, growUnits, err = osbuild.GenGrowFromPartitionTable(pt, p.DiskCustomizations.GrowMethod)
+		if err != nil {
+			return osbuild.Pipeline{}, err
+		}
+		if len(growDirs) > 0 {
+			pipeline.AddStages(osbuild.GenDirectoryNodesStages(growDirs)...)
+		}
+		if len(growFiles) > 0 {
+			p.addStagesForAllFilesAndInlineData(&pipeline, growFiles)
+		}
+		for _, growUnit := range growUnits {
+			pipeline.AddStage(osbuild.NewSystemdUnitCreateStage(growUnit))
+		}
 
 		switch p.platform.GetBootloader() {
 		case platform.BOOTLOADER_GRUB2:
@@ -983,6 +1074,15 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 	for _, fbUnit := range fbUnits {
 		enabledServices = append(enabledServices, fbUnit.Filename)
 	}
+	for _, tpm2Unit := range tpm2Units {
+		enabledServices = append(enabledServices, tpm2Unit.Filename)
+	}
+	for _, growUnit := range growUnits {
+		enabledServices = append(enabledServices, growUnit.Filename)
+	}
+	if swapFileUnit != nil {
+		enabledServices = append(enabledServices, swapFileUnit.Filename)
+	}
 	enabledServices = append(enabledServices, subscriptionEnabledServices...)
 	disabledServices = append(disabledServices, p.OSCustomizations.DisabledServices...)
 	maskedServices = append(maskedServices, p.OSCustomizations.MaskedServices...)
@@ -1023,6 +1123,16 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 		p.addStagesForAllFilesAndInlineData(&pipeline, osbuild.GenFIPSFiles())
 	}
 
+	if cryptoPolicy := p.OSCustomizations.CryptoPolicy; cryptoPolicy != nil {
+		dirs, files, cryptoPolicyOptions, err := osbuild.GenCryptoPolicyFromOptions(cryptoPolicy)
+		if err != nil {
+			return osbuild.Pipeline{}, err
+		}
+		pipeline.AddStages(osbuild.GenDirectoryNodesStages(dirs)...)
+		p.addStagesForAllFilesAndInlineData(&pipeline, files)
+		pipeline.AddStage(osbuild.NewUpdateCryptoPoliciesStage(cryptoPolicyOptions))
+	}
+
 	// NOTE: We need to run the OpenSCAP stages as the last stage before SELinux
 	// since the remediation may change file permissions and other aspects of the
 	// hardened image
@@ -1074,6 +1184,22 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 		}))
 	}
 
+	if selinuxOptions := p.OSCustomizations.SELinuxOptions; selinuxOptions != nil {
+		if p.OSCustomizations.SELinux == "" {
+			return osbuild.Pipeline{}, fmt.Errorf("SELinux customizations require an SELinux policy")
+		}
+		dirs, files, semanageOptions, err := osbuild.GenSemanageFromOptions(p.OSCustomizations.SELinux, selinuxOptions)
+		if err != nil {
+			return osbuild.Pipeline{}, err
+		}
+		if semanageOptions != nil {
+			pipeline.AddStages(osbuild.GenDirectoryNodesStages(dirs)...)
+			p.addStagesForAllFilesAndInlineData(&pipeline, files)
+			// the local file contexts are picked up by the relabelling below
+			pipeline.AddStage(osbuild.NewSemanageStage(semanageOptions))
+		}
+	}
+
 	if p.OSCustomizations.SELinux != "" {
 		pipeline.AddStage(osbuild.NewSELinuxStage(&osbuild.SELinuxStageOptions{
 			FileContexts:     fmt.Sprintf("etc/selinux/%s/contexts/files/file_contexts", p.OSCustomizations.SELinux),
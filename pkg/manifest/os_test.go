@@ -3,6 +3,7 @@ package manifest_test
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,8 +14,13 @@ import (
 	"github.com/osbuild/image-builder/pkg/arch"
 	"github.com/osbuild/image-builder/pkg/container"
 	"github.com/osbuild/image-builder/pkg/customizations/bootc"
+	"github.com/osbuild/image-builder/pkg/customizations/cryptopolicy"
 	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
+	"github.com/osbuild/image-builder/pkg/customizations/selinux"
 	"github.com/osbuild/image-builder/pkg/customizations/subscription"
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
+	"github.com/osbuild/image-builder/pkg/datasizes"
 	"github.com/osbuild/image-builder/pkg/depsolvednf"
 	"github.com/osbuild/image-builder/pkg/disk"
 	"github.com/osbuild/image-builder/pkg/manifest"
@@ -374,6 +380,67 @@ func TestOSPipelineMountNoneStages(t *testing.T) {
 	checkStagesForNoMounts(t, common.Must(os.Serialize()).Stages)
 }
 
+func TestOSPipelineSwapFile(t *testing.T) {
+	swapOptions := &swap.Options{
+		File: &swap.File{Path: "/var/swapfile", Size: 2 * datasizes.GiB},
+	}
+
+	t.Run("fstab", func(t *testing.T) {
+		os := manifest.NewTestOS()
+		os.PartitionTable = testdisk.MakeFakePartitionTable("/")
+		os.DiskCustomizations.MountConfiguration = osbuild.MOUNT_CONFIGURATION_FSTAB
+		os.OSCustomizations.Swap = swapOptions
+
+		stages := common.Must(os.Serialize()).Stages
+		fstab := findStage("org.osbuild.fstab", stages)
+		require.NotNil(t, fstab)
+		filesystems := fstab.Options.(*osbuild.FSTabStageOptions).FileSystems
+		assert.Equal(t, &osbuild.FSTabEntry{Device: "/var/swapfile", VFSType: "swap", Path: "none", Options: "defaults,nofail"}, filesystems[len(filesystems)-1])
+
+		var unitFilenames []string
+		for _, st := range findStages("org.osbuild.systemd.unit.create", stages) {
+			unitFilenames = append(unitFilenames, st.Options.(*osbuild.SystemdUnitCreateStageOptions).Filename)
+		}
+		assert.Contains(t, unitFilenames, "create-var-swapfile.service")
+
+		systemd := findStage("org.osbuild.systemd", stages)
+		require.NotNil(t, systemd)
+		assert.Contains(t, systemd.Options.(*osbuild.SystemdStageOptions).EnabledServices, "create-var-swapfile.service")
+	})
+
+	t.Run("units", func(t *testing.T) {
+		os := manifest.NewTestOS()
+		os.PartitionTable = testdisk.MakeFakePartitionTable("/")
+		os.DiskCustomizations.MountConfiguration = osbuild.MOUNT_CONFIGURATION_UNITS
+		os.OSCustomizations.Swap = swapOptions
+
+		stages := common.Must(os.Serialize()).Stages
+		assert.Nil(t, findStage("org.osbuild.fstab", stages))
+
+		var unitFilenames []string
+		for _, st := range findStages("org.osbuild.systemd.unit.create", stages) {
+			unitFilenames = append(unitFilenames, st.Options.(*osbuild.SystemdUnitCreateStageOptions).Filename)
+		}
+		assert.Contains(t, unitFilenames, "var-swapfile.swap")
+		assert.Contains(t, unitFilenames, "create-var-swapfile.service")
+	})
+}
+
+func TestOSPipelineZram(t *testing.T) {
+	os := manifest.NewTestOS()
+	os.OSCustomizations.Swap = &swap.Options{
+		Zram: &swap.Zram{Size: "ram / 2", CompressionAlgorithm: "zstd"},
+	}
+
+	pkgSetChain, err := os.GetPackageSetChain(manifest.DISTRO_NULL)
+	assert.NoError(t, err)
+	CheckPkgSetInclude(t, pkgSetChain, []string{"zram-generator"})
+
+	_, err = os.Serialize()
+	require.NoError(t, err)
+	assert.Contains(t, manifest.GetInline(os), "[zram0]\nzram-size = ram / 2\ncompression-algorithm = zstd\n")
+}
+
 func TestLanguageIncludesLocaleStage(t *testing.T) {
 	os := manifest.NewTestOS()
 
@@ -440,6 +507,114 @@ func TestHostnameDoesNotIncludeHostnameStage(t *testing.T) {
 	require.Nil(t, st)
 }
 
+func TestNetworkConnectionsIncludeKeyfiles(t *testing.T) {
+	os := manifest.NewTestOS()
+
+	os.OSCustomizations.NetworkConnections = []network.Connection{
+		{
+			Name:      "eth0",
+			Type:      network.ConnectionTypeEthernet,
+			Interface: "eth0",
+			IPv4:      &network.IPConfig{Method: network.IPMethodManual, Addresses: []string{"192.168.1.10/24"}},
+		},
+	}
+
+	pipeline, err := os.Serialize()
+	require.NoError(t, err)
+
+	copySt := findStage("org.osbuild.copy", pipeline.Stages)
+	require.NotNil(t, copySt)
+	assert.Equal(t, "tree:///etc/NetworkManager/system-connections/eth0.nmconnection", copySt.Options.(*osbuild.CopyStageOptions).Paths[0].To)
+
+	chmodSt := findStage("org.osbuild.chmod", pipeline.Stages)
+	require.NotNil(t, chmodSt)
+	assert.Equal(t, "0600", chmodSt.Options.(*osbuild.ChmodStageOptions).Items["/etc/NetworkManager/system-connections/eth0.nmconnection"].Mode)
+	assert.Len(t, manifest.GetInline(os), 1)
+}
+
+func TestNetworkConnectionsInvalid(t *testing.T) {
+	os := manifest.NewTestOS()
+
+	os.OSCustomizations.NetworkConnections = []network.Connection{
+		{Name: "eth0", Type: "wifi"},
+	}
+
+	_, err := os.Serialize()
+	assert.EqualError(t, err, `connection "eth0": unknown connection type "wifi"`)
+}
+
+func TestSELinuxOptionsStages(t *testing.T) {
+	os := manifest.NewTestOS()
+
+	os.OSCustomizations.SELinux = "targeted"
+	os.OSCustomizations.SELinuxOptions = &selinux.Options{
+		Booleans: map[string]bool{"httpd_can_network_connect": true},
+		Modules: []selinux.Module{
+			{Name: "mywebapp", Type: selinux.ModuleTypeCIL, Contents: []byte("(allow httpd_t var_t (file (read)))")},
+		},
+	}
+
+	pipeline, err := os.Serialize()
+	require.NoError(t, err)
+
+	var stageTypes []string
+	for _, st := range pipeline.Stages {
+		stageTypes = append(stageTypes, st.Type)
+	}
+	semanageIdx := slices.Index(stageTypes, "org.osbuild.semanage")
+	require.NotEqual(t, -1, semanageIdx)
+	// the policy needs to be modified before the tree is labelled
+	assert.Equal(t, "org.osbuild.selinux", stageTypes[semanageIdx+1])
+
+	semanageOptions := pipeline.Stages[semanageIdx].Options.(*osbuild.SemanageStageOptions)
+	assert.Equal(t, "targeted", semanageOptions.Policy)
+	assert.Equal(t, []osbuild.SemanageModule{{Path: "/usr/share/selinux/packages/targeted/mywebapp.cil"}}, semanageOptions.Modules)
+	assert.Len(t, manifest.GetInline(os), 1)
+}
+
+func TestSELinuxOptionsRequirePolicy(t *testing.T) {
+	os := manifest.NewTestOS()
+
+	os.OSCustomizations.SELinuxOptions = &selinux.Options{
+		Booleans: map[string]bool{"httpd_can_network_connect": true},
+	}
+
+	_, err := os.Serialize()
+	assert.EqualError(t, err, "SELinux customizations require an SELinux policy")
+}
+
+func TestCryptoPolicyStages(t *testing.T) {
+	os := manifest.NewTestOS()
+
+	os.OSCustomizations.FIPS = true
+	os.OSCustomizations.CryptoPolicy = &cryptopolicy.Options{
+		Policy:  "FIPS:NO-CBC",
+		Modules: []cryptopolicy.Module{{Name: "NO-CBC", Contents: "cipher = -*-CBC\n"}},
+	}
+
+	pipeline, err := os.Serialize()
+	require.NoError(t, err)
+
+	var policies []string
+	for _, st := range pipeline.Stages {
+		if st.Type == "org.osbuild.update-crypto-policies" {
+			policies = append(policies, st.Options.(*osbuild.UpdateCryptoPoliciesStageOptions).Policy)
+		}
+	}
+	// the custom policy is applied after the FIPS policy
+	assert.Equal(t, []string{"FIPS", "FIPS:NO-CBC"}, policies)
+
+	var copied []string
+	for _, st := range pipeline.Stages {
+		if st.Type == "org.osbuild.copy" {
+			for _, p := range st.Options.(*osbuild.CopyStageOptions).Paths {
+				copied = append(copied, p.To)
+			}
+		}
+	}
+	assert.Contains(t, copied, "tree:///etc/crypto-policies/policies/modules/NO-CBC.pmod")
+}
+
 func TestRpmlang(t *testing.T) {
 	os := manifest.NewTestOS()
 	os.OSCustomizations.InstallLangs = []string{"nl"}
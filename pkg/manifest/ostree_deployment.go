@@ -351,7 +351,7 @@ func (p *OSTreeDeployment) serialize() (osbuild.Pipeline, error) {
 	configStage.MountOSTree(p.osName, ref, 0)
 	pipeline.AddStage(configStage)
 
-	fsCfgStages, err := filesystemConfigStages(p.PartitionTable, p.MountConfiguration)
+	fsCfgStages, err := filesystemConfigStages(p.PartitionTable, p.MountConfiguration, nil)
 	if err != nil {
 		return osbuild.Pipeline{}, err
 	}
@@ -89,6 +89,15 @@ func (p *RawImage) serialize() (osbuild.Pipeline, error) {
 		pipeline.AddStage(stage)
 	}
 
+	// the TPM2 enrollment keys are written into the root filesystem, this
+	// needs to happen before the finish stages rename volume groups and
+	// remove passphrases
+	tpm2Stages, err := osbuild.GenTPM2EnrollKeyStages(pt, p.Filename())
+	if err != nil {
+		return osbuild.Pipeline{}, err
+	}
+	pipeline.AddStages(tpm2Stages...)
+
 	for _, stage := range osbuild.GenImageFinishStages(pt, p.Filename()) {
 		pipeline.AddStage(stage)
 	}
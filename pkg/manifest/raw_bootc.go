@@ -193,7 +193,7 @@ func (p *RawBootcImage) serialize() (osbuild.Pipeline, error) {
 
 		postStages := []*osbuild.Stage{}
 
-		fsCfgStages, err := filesystemConfigStages(pt, p.DiskCustomizations.MountConfiguration)
+		fsCfgStages, err := filesystemConfigStages(pt, p.DiskCustomizations.MountConfiguration, nil)
 		if err != nil {
 			return osbuild.Pipeline{}, err
 		}
@@ -318,6 +318,26 @@ func (p *RawBootcImage) serialize() (osbuild.Pipeline, error) {
 			postStages = append(postStages, stages...)
 		}
 
+		// units are created in /etc, they are part of the deployment
+		// configuration and not of the image
+		var unitStages []*osbuild.Stage
+		for _, unit := range p.OSCustomizations.SystemdUnit {
+			if unit.UnitPath != osbuild.EtcUnitPath {
+				return osbuild.Pipeline{}, fmt.Errorf("unit %q must be created in /etc on bootc images", unit.Filename)
+			}
+			unitStages = append(unitStages, osbuild.NewSystemdUnitCreateStage(unit))
+		}
+		if len(p.OSCustomizations.EnabledServices) > 0 {
+			unitStages = append(unitStages, osbuild.NewSystemdStage(&osbuild.SystemdStageOptions{
+				EnabledServices: p.OSCustomizations.EnabledServices,
+			}))
+		}
+		for _, stage := range unitStages {
+			stage.Mounts = mounts
+			stage.Devices = devices
+		}
+		postStages = append(postStages, unitStages...)
+
 		// The ignition stamp must be created after bootc install, otherwise bootc will error out
 		// because the boot partition is not empty.
 		// That's why we have to pass `mount://boot/` and can't write to `tree://boot/`.
@@ -631,3 +631,37 @@ func collectMkdirPaths(stages []*osbuild.Stage) []string {
 	}
 	return mkdirPaths
 }
+
+func TestRawBootcImageSerializeSystemdUnits(t *testing.T) {
+	rawBootcPipeline := makeFakeRawBootcPipeline()
+	unit := &osbuild.SystemdUnitCreateStageOptions{
+		Filename: "app.service",
+		UnitType: osbuild.SystemUnitType,
+		UnitPath: osbuild.EtcUnitPath,
+		Config: osbuild.SystemdUnit{
+			Unit:    &osbuild.UnitSection{Description: "app"},
+			Service: &osbuild.ServiceSection{ExecStart: []string{"/usr/bin/app"}},
+			Install: &osbuild.InstallSection{WantedBy: []string{"multi-user.target"}},
+		},
+	}
+	rawBootcPipeline.OSCustomizations.SystemdUnit = []*osbuild.SystemdUnitCreateStageOptions{unit}
+	rawBootcPipeline.OSCustomizations.EnabledServices = []string{"app.service"}
+
+	pipeline, err := rawBootcPipeline.Serialize()
+	require.NoError(t, err)
+
+	stage := findStage("org.osbuild.systemd.unit.create", pipeline.Stages)
+	require.NotNil(t, stage)
+	assert.Equal(t, unit, stage.Options)
+	assertBootcDeploymentAndBindMount(t, stage)
+
+	stage = findStage("org.osbuild.systemd", pipeline.Stages)
+	require.NotNil(t, stage)
+	assert.Equal(t, []string{"app.service"}, stage.Options.(*osbuild.SystemdStageOptions).EnabledServices)
+	assertBootcDeploymentAndBindMount(t, stage)
+
+	// units in /usr would modify the image
+	unit.UnitPath = osbuild.UsrUnitPath
+	_, err = rawBootcPipeline.Serialize()
+	assert.EqualError(t, err, `unit "app.service" must be created in /etc on bootc images`)
+}
@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"github.com/osbuild/image-builder/pkg/artifact"
+	"github.com/osbuild/image-builder/pkg/osbuild"
+)
+
+// A VHDX turns a raw image file into a vhdx image, as used by Hyper-V.
+type VHDX struct {
+	Base
+	filename string
+
+	imgPipeline FilePipeline
+}
+
+func (p VHDX) Filename() string {
+	return p.filename
+}
+
+func (p *VHDX) SetFilename(filename string) {
+	p.filename = filename
+}
+
+// NewVHDX creates a new VHDX pipeline. imgPipeline is the pipeline producing
+// the raw image.
+func NewVHDX(buildPipeline Build, imgPipeline FilePipeline) *VHDX {
+	p := &VHDX{
+		Base:        NewBase("vhdx", buildPipeline),
+		imgPipeline: imgPipeline,
+		filename:    "image.vhdx",
+	}
+	buildPipeline.addDependent(p)
+	return p
+}
+
+func (p *VHDX) serialize() (osbuild.Pipeline, error) {
+	pipeline, err := p.Base.serialize()
+	if err != nil {
+		return osbuild.Pipeline{}, err
+	}
+
+	pipeline.AddStage(osbuild.NewQEMUStage(
+		osbuild.NewQEMUStageOptions(p.Filename(), osbuild.QEMUFormatVHDX, nil),
+		osbuild.NewQemuStagePipelineFilesInputs(p.imgPipeline.Name(), p.imgPipeline.Filename()),
+	))
+
+	return pipeline, nil
+}
+
+func (p *VHDX) getBuildPackages(Distro) ([]string, error) {
+	return []string{"qemu-img"}, nil
+}
+
+func (p *VHDX) Export() *artifact.Artifact {
+	p.Base.export = true
+	mimeType := "application/x-vhdx"
+	return artifact.New(p.Name(), p.Filename(), &mimeType)
+}
@@ -10,6 +10,9 @@ type XZ struct {
 	Base
 	filename string
 
+	// Write a block map of the raw image next to the compressed image
+	Bmap bool
+
 	imgPipeline FilePipeline
 }
 
@@ -43,12 +46,19 @@ func (p *XZ) serialize() (osbuild.Pipeline, error) {
 		osbuild.NewXzStageOptions(p.Filename()),
 		osbuild.NewXzStageInputs(osbuild.NewFilesInputPipelineObjectRef(p.imgPipeline.Name(), p.imgPipeline.Export().Filename(), nil)),
 	))
+	if p.Bmap {
+		pipeline.AddStage(newBmapStage(p.Filename(), p.imgPipeline))
+	}
 
 	return pipeline, nil
 }
 
 func (p *XZ) getBuildPackages(Distro) ([]string, error) {
-	return []string{"xz"}, nil
+	pkgs := []string{"xz"}
+	if p.Bmap {
+		pkgs = append(pkgs, "bmap-tools")
+	}
+	return pkgs, nil
 }
 
 func (p *XZ) Export() *artifact.Artifact {
@@ -10,6 +10,12 @@ type Zstd struct {
 	Base
 	filename string
 
+	// Compress the image in the zstd seekable format
+	Seekable bool
+
+	// Write a block map of the raw image next to the compressed image
+	Bmap bool
+
 	imgPipeline FilePipeline
 }
 
@@ -39,16 +45,25 @@ func (p *Zstd) serialize() (osbuild.Pipeline, error) {
 		return osbuild.Pipeline{}, err
 	}
 
+	options := osbuild.NewZstdStageOptions(p.Filename())
+	options.Seekable = p.Seekable
 	pipeline.AddStage(osbuild.NewZstdStage(
-		osbuild.NewZstdStageOptions(p.Filename()),
+		options,
 		osbuild.NewZstdStageInputs(osbuild.NewFilesInputPipelineObjectRef(p.imgPipeline.Name(), p.imgPipeline.Export().Filename(), nil)),
 	))
+	if p.Bmap {
+		pipeline.AddStage(newBmapStage(p.Filename(), p.imgPipeline))
+	}
 
 	return pipeline, nil
 }
 
 func (p *Zstd) getBuildPackages(Distro) ([]string, error) {
-	return []string{"zstd"}, nil
+	pkgs := []string{"zstd"}
+	if p.Bmap {
+		pkgs = append(pkgs, "bmap-tools")
+	}
+	return pkgs, nil
 }
 
 func (p *Zstd) Export() *artifact.Artifact {
@@ -32,3 +32,21 @@ func TestZstdSerialize(t *testing.T) {
 		Filename: "filename.zst",
 	}, zstdStage.Options.(*osbuild.ZstdStageOptions))
 }
+
+func TestZstdSerializeSeekable(t *testing.T) {
+	mani := manifest.New()
+	runner := &runner.Linux{}
+	build := manifest.NewBuild(&mani, runner, nil, nil)
+
+	rawImage := manifest.NewRawImage(build, nil, manifest.DiskCustomizations{})
+	zstdPipeline := manifest.NewZstd(build, rawImage)
+	zstdPipeline.Seekable = true
+
+	osbuildPipeline, err := manifest.Serialize(zstdPipeline)
+	assert.NoError(t, err)
+
+	assert.Equal(t, &osbuild.ZstdStageOptions{
+		Filename: "image.zst",
+		Seekable: true,
+	}, osbuildPipeline.Stages[0].Options.(*osbuild.ZstdStageOptions))
+}
@@ -0,0 +1,81 @@
+package manifestgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/osbuild/image-builder/pkg/depsolvednf"
+	"github.com/osbuild/image-builder/pkg/distro"
+	"github.com/osbuild/image-builder/pkg/rpmmd"
+)
+
+// DepsolveCache keeps the results of depsolving package set chains so
+// that manifests of several image types can be generated with a single
+// depsolve of the package sets they share (e.g. the buildroot or, when
+// the package sets match, the operating system tree).
+type DepsolveCache struct {
+	mu      sync.Mutex
+	results map[string]depsolvednf.DepsolveResult
+}
+
+// NewDepsolveCache creates a new, empty, DepsolveCache
+func NewDepsolveCache() *DepsolveCache {
+	return &DepsolveCache{
+		results: make(map[string]depsolvednf.DepsolveResult),
+	}
+}
+
+// Wrap returns a DepsolveFunc that only passes the package set chains to
+// depsolve that were not depsolved before and takes the results of all
+// other chains from the cache.
+func (c *DepsolveCache) Wrap(depsolve DepsolveFunc) DepsolveFunc {
+	return func(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		keys := make(map[string]string, len(packageSets))
+		missing := make(map[string][]rpmmd.PackageSet)
+		for plName, chain := range packageSets {
+			key, err := depsolveCacheKey(chain, d, arch)
+			if err != nil {
+				return nil, err
+			}
+			keys[plName] = key
+			if _, ok := c.results[key]; !ok {
+				missing[plName] = chain
+			}
+		}
+		if len(missing) > 0 {
+			depsolved, err := depsolve(solver, cacheDir, depsolveWarningsOutput, missing, d, arch)
+			if err != nil {
+				return nil, err
+			}
+			for plName := range missing {
+				res, ok := depsolved[plName]
+				if !ok {
+					return nil, fmt.Errorf("no depsolve result for pipeline %q", plName)
+				}
+				c.results[keys[plName]] = res
+			}
+		}
+
+		results := make(map[string]depsolvednf.DepsolveResult, len(packageSets))
+		for plName, key := range keys {
+			results[plName] = c.results[key]
+		}
+		return results, nil
+	}
+}
+
+// depsolveCacheKey identifies a package set chain, the chain includes the
+// repositories so the distro and architecture are only needed to tell
+// apart chains that are depsolved with a different solver
+func depsolveCacheKey(chain []rpmmd.PackageSet, d distro.Distro, arch string) (string, error) {
+	data, err := json.Marshal(chain)
+	if err != nil {
+		return "", fmt.Errorf("cannot create depsolve cache key: %w", err)
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", d.Name(), d.Releasever(), arch, data), nil
+}
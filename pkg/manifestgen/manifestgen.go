@@ -2,6 +2,7 @@ package manifestgen
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,7 +16,9 @@ import (
 	"github.com/osbuild/image-builder/internal/common"
 	"github.com/osbuild/image-builder/pkg/arch"
 	"github.com/osbuild/image-builder/pkg/container"
+	"github.com/osbuild/image-builder/pkg/datasizes"
 	"github.com/osbuild/image-builder/pkg/depsolvednf"
+	"github.com/osbuild/image-builder/pkg/disk"
 	"github.com/osbuild/image-builder/pkg/distro"
 	"github.com/osbuild/image-builder/pkg/flatpak"
 	"github.com/osbuild/image-builder/pkg/manifest"
@@ -32,6 +35,12 @@ const (
 	defaultSBOMExt           = "spdx.json"
 
 	defaultDepsolveCacheDir = "osbuild-depsolve-dnf"
+
+	// payloadSizeHeadroom is the extra space (in percent of the
+	// installed size of the depsolved packages) that is reserved for
+	// files created when installing and configuring the packages
+	// (e.g. rpm database, caches, initrd)
+	payloadSizeHeadroom = 30
 )
 
 var (
@@ -136,9 +145,7 @@ func New(reporegistry *reporegistry.RepoRegistry, opts *Options) (*Generator, er
 		mg.depsolve = DefaultDepsolve
 	}
 	if mg.containerResolver == nil {
-		mg.containerResolver = func(containerSources map[string][]container.SourceSpec, archName string) (map[string][]container.Spec, error) {
-			return container.NewBlockingResolver(archName).ResolveAll(containerSources)
-		}
+		mg.containerResolver = DefaultContainerResolver
 	}
 	if mg.commitResolver == nil {
 		mg.commitResolver = ostree.ResolveAll
@@ -147,11 +154,11 @@ func New(reporegistry *reporegistry.RepoRegistry, opts *Options) (*Generator, er
 		mg.flatpakResolver = flatpak.ResolveAll
 	}
 	if mg.cacheDir == "" {
-		xdgCacheHomeDir, err := xdgCacheHome()
+		cacheDir, err := DefaultCacheDir()
 		if err != nil {
 			return nil, err
 		}
-		mg.cacheDir = filepath.Join(xdgCacheHomeDir, defaultDepsolveCacheDir)
+		mg.cacheDir = cacheDir
 	}
 
 	return mg, nil
@@ -219,6 +226,9 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 	if err != nil {
 		return nil, err
 	}
+	if err := mg.ensurePayloadSizes(bp, preManifest, depsolved); err != nil {
+		return nil, err
+	}
 	containerSpecs, err := mg.containerResolver(preManifest.GetContainerSourceSpecs(), a.Name())
 	if err != nil {
 		return nil, err
@@ -292,6 +302,148 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 	return mf, nil
 }
 
+// ensurePayloadSizes grows the partition tables of the manifest so that the
+// filesystems can hold the depsolved packages. The installed size of every
+// package is split between the mountpoints that hold its files. Packages
+// without a file list are accounted to /usr, see payloadSizesByMountpoint,
+// and result in a warning. Growing a filesystem that was sized in the
+// blueprint results in a warning too.
+func (mg *Generator) ensurePayloadSizes(bp *blueprint.Blueprint, preManifest *manifest.Manifest, depsolved map[string]depsolvednf.DepsolveResult) error {
+	var warnings []string
+	for plName, pt := range preManifest.GetPartitionTables() {
+		payloadSizes, withoutFiles, err := payloadSizesByMountpoint(pt, depsolved[plName].Transactions.AllPackages())
+		if err != nil {
+			return fmt.Errorf("cannot calculate the payload size of pipeline %q: %w", plName, err)
+		}
+		if withoutFiles > 0 {
+			warnings = append(warnings, fmt.Sprintf("the depsolver returned no file list for %d packages of pipeline %q, their size is accounted to the filesystem of /usr", withoutFiles, plName))
+		}
+		if len(payloadSizes) == 0 {
+			continue
+		}
+		minSizes := make(map[string]datasizes.Size, len(payloadSizes))
+		for mountpoint, size := range payloadSizes {
+			minSizes[mountpoint] = datasizes.Size(size + size*payloadSizeHeadroom/100)
+		}
+
+		grown, err := pt.GrowToDirectorySizes(minSizes)
+		if err != nil {
+			return fmt.Errorf("cannot grow the partition table of pipeline %q: %w", plName, err)
+		}
+		for mountpoint, prevSize := range grown {
+			if !hasCustomSize(bp, mountpoint) {
+				continue
+			}
+			newSize, err := pt.GetMountpointSize(mountpoint)
+			if err != nil {
+				return err
+			}
+			warnings = append(warnings, fmt.Sprintf("the size of %q (%d bytes) is too small for the packages of pipeline %q, growing it to %d bytes", mountpoint, prevSize, plName, newSize))
+		}
+	}
+	if len(warnings) == 0 || mg.warningsOutput == nil {
+		return nil
+	}
+
+	slices.Sort(warnings)
+	fmt.Fprintln(mg.warningsOutput, strings.Join(warnings, "\n"))
+	return nil
+}
+
+// payloadSizesByMountpoint returns the installed size of the packages per
+// mountpoint of the partition table. The rpm metadata only has the installed
+// size of the whole package, so it is split by the number of files of the
+// package on each mountpoint.
+//
+// The file lists are only returned by the v2 API of the depsolver, custom
+// depsolvers may not return them either. Packages without a file list are
+// accounted to the filesystem that holds /usr, where packages install most
+// of their files, and their number is returned so that the caller can warn
+// about the estimate.
+func payloadSizesByMountpoint(pt *disk.PartitionTable, pkgs rpmmd.PackageList) (map[string]uint64, int, error) {
+	mountpoints := make(map[string]string)
+	mountpointOf := func(dir string) (string, error) {
+		if mountpoint, ok := mountpoints[dir]; ok {
+			return mountpoint, nil
+		}
+		mountpoint, err := pt.GetMountpointOf(dir)
+		if err != nil {
+			return "", err
+		}
+		mountpoints[dir] = mountpoint
+		return mountpoint, nil
+	}
+
+	sizes := make(map[string]uint64)
+	var withoutFiles int
+	for _, pkg := range pkgs {
+		if pkg.InstallSize == 0 {
+			continue
+		}
+		files := make(map[string]uint64)
+		for _, file := range pkg.Files {
+			if !filepath.IsAbs(file) {
+				continue
+			}
+			mountpoint, err := mountpointOf(filepath.Dir(file))
+			if err != nil {
+				return nil, 0, err
+			}
+			files[mountpoint]++
+		}
+		if len(files) == 0 {
+			mountpoint, err := mountpointOf("/usr")
+			if err != nil {
+				return nil, 0, err
+			}
+			sizes[mountpoint] += pkg.InstallSize
+			withoutFiles++
+			continue
+		}
+
+		var total uint64
+		for _, count := range files {
+			total += count
+		}
+		for mountpoint, count := range files {
+			sizes[mountpoint] += pkg.InstallSize * count / total
+		}
+	}
+	return sizes, withoutFiles, nil
+}
+
+// hasCustomSize returns true if the blueprint customizes the size of the
+// filesystem mounted at the given mountpoint.
+func hasCustomSize(bp *blueprint.Blueprint, mountpoint string) bool {
+	if bp == nil || bp.Customizations == nil {
+		return false
+	}
+	for _, fs := range bp.Customizations.Filesystem {
+		if fs.Mountpoint == mountpoint {
+			return true
+		}
+	}
+	if bp.Customizations.Disk == nil {
+		return false
+	}
+	for _, part := range bp.Customizations.Disk.Partitions {
+		if part.Mountpoint == mountpoint {
+			return true
+		}
+		for _, lv := range part.LogicalVolumes {
+			if lv.Mountpoint == mountpoint {
+				return true
+			}
+		}
+		for _, subvol := range part.Subvolumes {
+			if subvol.Mountpoint == mountpoint {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func addUniquePackagesFromPipeline(unique map[string]rpmmd.Package, pipeline depsolvednf.DepsolveResult) {
 	for _, pkg := range pipeline.Transactions.AllPackages() {
 		var key string
@@ -329,6 +481,43 @@ func xdgCacheHome() (string, error) {
 	return filepath.Join(home, ".cache"), nil
 }
 
+// DefaultCacheDir returns the directory that is used to cache the
+// rpm metadata when no explicit cache directory is set.
+func DefaultCacheDir() (string, error) {
+	xdgCacheHomeDir, err := xdgCacheHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(xdgCacheHomeDir, defaultDepsolveCacheDir), nil
+}
+
+// DefaultContainerResolver provides a default implementation for
+// container resolving that resolves all containers using a blocking
+// resolver for the given architecture.
+func DefaultContainerResolver(containerSources map[string][]container.SourceSpec, archName string) (map[string][]container.Spec, error) {
+	return container.NewBlockingResolver(archName).ResolveAll(containerSources)
+}
+
+// ContainerResolverWithContext returns a container resolver like
+// DefaultContainerResolver that stops resolving when the given context
+// is cancelled.
+func ContainerResolverWithContext(ctx context.Context) ContainerResolverFunc {
+	return func(containerSources map[string][]container.SourceSpec, archName string) (map[string][]container.Spec, error) {
+		return container.NewBlockingResolverWithContext(ctx, archName).ResolveAll(containerSources)
+	}
+}
+
+// DepsolveWithContext returns a depsolve function like DefaultDepsolve
+// that stops the running depsolver when the given context is cancelled.
+func DepsolveWithContext(ctx context.Context) DepsolveFunc {
+	return func(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error) {
+		if solver != nil {
+			solver.SetContext(ctx)
+		}
+		return DefaultDepsolve(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch)
+	}
+}
+
 // DefaultDepsolve provides a default implementation for depsolving.
 // It should rarely be necessary to use it directly and will be used
 // by default by manifestgen (unless overriden)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -442,3 +443,198 @@ func TestManifestGeneratorUseBootstrapContainer(t *testing.T) {
 		})
 	}
 }
+
+func TestManifestGeneratorGrowsForPayload(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	// pretend that the packages of the os pipeline need 10 GiB
+	bigDepsolve := newBigDepsolve(nil)
+
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{
+				{Mountpoint: "/", MinSize: 2 * 1024 * 1024 * 1024},
+			},
+		},
+	}
+
+	var warningsOutput bytes.Buffer
+	opts := &manifestgen.Options{
+		Depsolve:       bigDepsolve,
+		WarningsOutput: &warningsOutput,
+	}
+	mg, err := manifestgen.New(repos, opts)
+	assert.NoError(t, err)
+	osbuildManifest, err := mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+	assert.Contains(t, warningsOutput.String(), `the size of "/" (`)
+	assert.Contains(t, warningsOutput.String(), `is too small for the packages of pipeline "os"`)
+
+	// the image is grown to 13 GiB (payload plus headroom) at least
+	var mf struct {
+		Pipelines []struct {
+			Name   string `json:"name"`
+			Stages []struct {
+				Type    string `json:"type"`
+				Options struct {
+					Size string `json:"size"`
+				} `json:"options"`
+			} `json:"stages"`
+		} `json:"pipelines"`
+	}
+	require.NoError(t, json.Unmarshal(osbuildManifest, &mf))
+	var truncateSize string
+	for _, pl := range mf.Pipelines {
+		for _, stage := range pl.Stages {
+			if pl.Name == "image" && stage.Type == "org.osbuild.truncate" {
+				truncateSize = stage.Options.Size
+			}
+		}
+	}
+	size, err := strconv.ParseUint(truncateSize, 10, 64)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, size, uint64(13*1024*1024*1024))
+
+	// without a warnings output, the image is grown all the same
+	mg, err = manifestgen.New(repos, &manifestgen.Options{Depsolve: bigDepsolve})
+	assert.NoError(t, err)
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	assert.NoError(t, err)
+}
+
+// newBigDepsolve returns a depsolver that makes the first package of the os
+// pipeline take up 10 GiB with the given files
+func newBigDepsolve(files []string) manifestgen.DepsolveFunc {
+	return func(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error) {
+		depsolved, err := fakeDepsolve(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch)
+		if err != nil {
+			return nil, err
+		}
+		transactions := depsolved["os"].Transactions
+		transactions[0][0].InstallSize = 10 * 1024 * 1024 * 1024
+		transactions[0][0].Files = files
+		return depsolved, nil
+	}
+}
+
+func TestManifestGeneratorGrowsPayloadMountpoints(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{
+				{Mountpoint: "/", MinSize: 2 * 1024 * 1024 * 1024},
+				{Mountpoint: "/var", MinSize: 1024 * 1024 * 1024},
+			},
+		},
+	}
+
+	// the files of the package are on /var, only /var is grown
+	var warningsOutput bytes.Buffer
+	mg, err := manifestgen.New(repos, &manifestgen.Options{
+		Depsolve:       newBigDepsolve([]string{"/var/lib/big/data", "/var/lib/big/more-data"}),
+		WarningsOutput: &warningsOutput,
+	})
+	assert.NoError(t, err)
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+	assert.Contains(t, warningsOutput.String(), `the size of "/var" (1073741824 bytes) is too small for the packages of pipeline "os"`)
+	assert.NotContains(t, warningsOutput.String(), `the size of "/" (`)
+}
+
+func TestManifestGeneratorGrowsPayloadWithoutFileLists(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{
+				{Mountpoint: "/", MinSize: 2 * 1024 * 1024 * 1024},
+				{Mountpoint: "/var", MinSize: 1024 * 1024 * 1024},
+			},
+		},
+	}
+
+	// without a file list, the package is accounted to the filesystem of
+	// /usr, which is the root filesystem here
+	var warningsOutput bytes.Buffer
+	mg, err := manifestgen.New(repos, &manifestgen.Options{
+		Depsolve:       newBigDepsolve(nil),
+		WarningsOutput: &warningsOutput,
+	})
+	assert.NoError(t, err)
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+	assert.Contains(t, warningsOutput.String(), `the depsolver returned no file list for 1 packages of pipeline "os", their size is accounted to the filesystem of /usr`)
+	assert.Contains(t, warningsOutput.String(), `the size of "/" (`)
+	assert.NotContains(t, warningsOutput.String(), `the size of "/var" (`)
+}
+
+func TestManifestGeneratorDepsolveCache(t *testing.T) {
+	repos, err := testrepos.New()
+	require.NoError(t, err)
+	filter, err := imagefilter.New(distrofactory.NewDefault(), repos)
+	require.NoError(t, err)
+
+	var depsolved []string
+	countingDepsolve := func(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error) {
+		for plName := range packageSets {
+			depsolved = append(depsolved, plName)
+		}
+		return fakeDepsolve(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch)
+	}
+
+	cache := manifestgen.NewDepsolveCache()
+	for _, imgType := range []string{"qcow2", "openstack", "qcow2"} {
+		res, err := filter.Filter("distro:centos-9", "type:"+imgType, "arch:x86_64")
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		mg, err := manifestgen.New(repos, &manifestgen.Options{
+			Depsolve:          fakeDepsolve,
+			CommitResolver:    panicCommitResolver,
+			ContainerResolver: panicContainerResolver,
+			CustomSeed:        common.ToPtr(int64(0)),
+		})
+		require.NoError(t, err)
+		expected, err := mg.Generate(&blueprint.Blueprint{}, res[0].ImgType, nil)
+		require.NoError(t, err)
+
+		mg, err = manifestgen.New(repos, &manifestgen.Options{
+			Depsolve:          cache.Wrap(countingDepsolve),
+			CommitResolver:    panicCommitResolver,
+			ContainerResolver: panicContainerResolver,
+			CustomSeed:        common.ToPtr(int64(0)),
+		})
+		require.NoError(t, err)
+		mf, err := mg.Generate(&blueprint.Blueprint{}, res[0].ImgType, nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected, mf)
+	}
+	// the buildroot is shared between the image types and the second
+	// qcow2 is generated from the cache alone
+	assert.ElementsMatch(t, []string{"build", "os", "os"}, depsolved)
+}
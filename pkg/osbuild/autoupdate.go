@@ -0,0 +1,20 @@
+package osbuild
+
+import (
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/autoupdate"
+)
+
+// GenDNFAutomaticConfigStageOptions creates the dnf-automatic configuration
+// for an automatic update policy
+func GenDNFAutomaticConfigStageOptions(o *autoupdate.Options) *DNFAutomaticConfigStageOptions {
+	commands := &DNFAutomaticConfigCommands{
+		ApplyUpdates: common.ToPtr(o.Policy != autoupdate.PolicyDownloadOnly),
+		UpgradeType:  DNFAutomaticUpgradeTypeDefault,
+		Reboot:       DNFAutomaticRebootValue(o.DNFAutomaticReboot()),
+	}
+	if o.Policy == autoupdate.PolicySecurity {
+		commands.UpgradeType = DNFAutomaticUpgradeTypeSecurity
+	}
+	return NewDNFAutomaticConfigStageOptions(&DNFAutomaticConfig{Commands: commands})
+}
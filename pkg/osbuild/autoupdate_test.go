@@ -0,0 +1,50 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/autoupdate"
+)
+
+func TestGenDNFAutomaticConfigStageOptions(t *testing.T) {
+	tests := []struct {
+		options  autoupdate.Options
+		expected DNFAutomaticConfigCommands
+	}{
+		{
+			options: autoupdate.Options{Policy: "security"},
+			expected: DNFAutomaticConfigCommands{
+				ApplyUpdates: common.ToPtr(true),
+				UpgradeType:  DNFAutomaticUpgradeTypeSecurity,
+				Reboot:       DNFAutomaticRebootNever,
+			},
+		},
+		{
+			options: autoupdate.Options{Policy: "download-only"},
+			expected: DNFAutomaticConfigCommands{
+				ApplyUpdates: common.ToPtr(false),
+				UpgradeType:  DNFAutomaticUpgradeTypeDefault,
+				Reboot:       DNFAutomaticRebootNever,
+			},
+		},
+		{
+			options: autoupdate.Options{Policy: "apply", RebootWindow: "Sun 03:00"},
+			expected: DNFAutomaticConfigCommands{
+				ApplyUpdates: common.ToPtr(true),
+				UpgradeType:  DNFAutomaticUpgradeTypeDefault,
+				Reboot:       DNFAutomaticRebootWhenNeeded,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.options.Policy, func(t *testing.T) {
+			stageOptions := GenDNFAutomaticConfigStageOptions(&tc.options)
+			assert.Equal(t, &tc.expected, stageOptions.Config.Commands)
+			assert.NotPanics(t, func() { NewDNFAutomaticConfigStage(stageOptions) })
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package osbuild
+
+type BmaptoolStageOptions struct {
+	// Filename for the block map
+	Filename string `json:"filename"`
+}
+
+func (BmaptoolStageOptions) isStageOptions() {}
+
+func NewBmaptoolStageOptions(filename string) *BmaptoolStageOptions {
+	return &BmaptoolStageOptions{
+		Filename: filename,
+	}
+}
+
+type BmaptoolStageInputs struct {
+	File *FilesInput `json:"file"`
+}
+
+func (*BmaptoolStageInputs) isStageInputs() {}
+
+func NewBmaptoolStageInputs(references FilesInputRef) *BmaptoolStageInputs {
+	return &BmaptoolStageInputs{
+		File: NewFilesInput(references),
+	}
+}
+
+// Creates a block map (bmap) of a raw disk image that lists the blocks that
+// are in use, so that bmaptool can write the image (or a compressed version
+// of it) without copying the unused blocks.
+func NewBmaptoolStage(options *BmaptoolStageOptions, inputs *BmaptoolStageInputs) *Stage {
+	var stageInputs Inputs
+	if inputs != nil {
+		stageInputs = inputs
+	}
+
+	return &Stage{
+		Type:    "org.osbuild.bmaptool",
+		Options: options,
+		Inputs:  stageInputs,
+	}
+}
@@ -0,0 +1,23 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBmaptoolStage(t *testing.T) {
+	inputFilename := "image.raw"
+	filename := "image.raw.bmap"
+	pipeline := "image"
+
+	expectedStage := &Stage{
+		Type:    "org.osbuild.bmaptool",
+		Options: &BmaptoolStageOptions{Filename: filename},
+		Inputs:  NewBmaptoolStageInputs(NewFilesInputPipelineObjectRef(pipeline, inputFilename, nil)),
+	}
+
+	actualStage := NewBmaptoolStage(NewBmaptoolStageOptions(filename),
+		NewBmaptoolStageInputs(NewFilesInputPipelineObjectRef(pipeline, inputFilename, nil)))
+	assert.Equal(t, expectedStage, actualStage)
+}
@@ -63,6 +63,13 @@ func GenDeviceCreationStages(pt *disk.PartitionTable, filename string) []*Stage
 				}, stageDevices))
 			}
 
+			if ent.TPM2 != nil && ent.TPM2.RecoveryKey {
+				stages = append(stages, NewSystemdCryptenrollStage(&SystemdCryptenrollStageOptions{
+					Passphrase:      ent.Passphrase,
+					RecoveryKeyPath: LUKSRecoveryKeyFilename(filename, ent),
+				}, stageDevices, nil))
+			}
+
 		case *disk.LVMVolumeGroup:
 			// do not include us when getting the devices
 			stageDevices, lastName := getDevices(path[:len(path)-1], filename, true)
@@ -147,6 +154,13 @@ func GenDeviceFinishStages(pt *disk.PartitionTable, filename string) []*Stage {
 	return stages
 }
 
+// LUKSRecoveryKeyFilename returns the name of the file the recovery key of
+// the given LUKS container is written to. The file is placed next to the disk
+// image filename in the tree of the pipeline creating the image.
+func LUKSRecoveryKeyFilename(filename string, lc *disk.LUKSContainer) string {
+	return fmt.Sprintf("%s.%s.recovery-key", filename, deviceName(lc))
+}
+
 func deviceName(p disk.Entity) string {
 	if p == nil {
 		panic("device is nil; this is a programming error")
@@ -146,6 +146,41 @@ func TestGenDeviceFinishStagesOrderWithLVMClevisBind(t *testing.T) {
 	assert.Equal("org.osbuild.luks2.remove-key", luks.Type)
 }
 
+func TestGenDeviceCreationStagesTPM2RecoveryKey(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(13))
+
+	luks_lvm := testPartitionTables["luks+lvm"]
+
+	pt, err := disk.NewPartitionTable(&luks_lvm, []blueprint.FilesystemCustomization{}, 0, partition.AutoLVMPartitioningMode, arch.ARCH_X86_64, make(map[string]datasizes.Size), "", rng)
+	require.NoError(t, err)
+
+	var luks *disk.LUKSContainer
+	_ = pt.ForEachEntity(func(e disk.Entity, path []disk.Entity) error {
+		if lc, ok := e.(*disk.LUKSContainer); ok {
+			lc.TPM2 = &disk.TPM2Enroll{RecoveryKey: true}
+			luks = lc
+		}
+		return nil
+	})
+	require.NotNil(t, luks)
+
+	stages := GenDeviceCreationStages(pt, "image.raw")
+	require.Len(t, stages, 3)
+
+	// the recovery key is enrolled right after the container is created
+	assert.Equal(t, "org.osbuild.luks2.format", stages[0].Type)
+	enroll := stages[1]
+	assert.Equal(t, "org.osbuild.systemd-cryptenroll", enroll.Type)
+	assert.Equal(t, &SystemdCryptenrollStageOptions{
+		Passphrase:      "osbuild",
+		RecoveryKeyPath: "image.raw.luks-" + luks.UUID[:4] + ".recovery-key",
+	}, enroll.Options)
+	assert.Contains(t, enroll.Devices, "device")
+	assert.Equal(t, "org.osbuild.lvm2.create", stages[2].Type)
+}
+
 func TestPathEscape(t *testing.T) {
 	testCases := []struct {
 		path     string
@@ -10,12 +10,23 @@ const (
 	DNFAutomaticUpgradeTypeSecurity DNFAutomaticUpgradeTypeValue = "security"
 )
 
+type DNFAutomaticRebootValue string
+
+// Valid values of the 'reboot' option
+const (
+	DNFAutomaticRebootNever       DNFAutomaticRebootValue = "never"
+	DNFAutomaticRebootWhenChanged DNFAutomaticRebootValue = "when-changed"
+	DNFAutomaticRebootWhenNeeded  DNFAutomaticRebootValue = "when-needed"
+)
+
 // DNFAutomaticConfigCommands represents the 'commands' configuration section.
 type DNFAutomaticConfigCommands struct {
 	// Whether packages comprising the available updates should be installed
 	ApplyUpdates *bool `json:"apply_updates,omitempty" yaml:"apply_updates,omitempty"`
 	// What kind of upgrades to look at
 	UpgradeType DNFAutomaticUpgradeTypeValue `json:"upgrade_type,omitempty" yaml:"upgrade_type,omitempty"`
+	// When to reboot the system after the updates were installed
+	Reboot DNFAutomaticRebootValue `json:"reboot,omitempty" yaml:"reboot,omitempty"`
 }
 
 // DNFAutomaticConfig represents DNF Automatic configuration.
@@ -53,6 +64,12 @@ func (o DNFAutomaticConfigStageOptions) validate() error {
 		if !valid {
 			return fmt.Errorf("'upgrade_type' option does not allow %q as a value", o.Config.Commands.UpgradeType)
 		}
+
+		switch o.Config.Commands.Reboot {
+		case "", DNFAutomaticRebootNever, DNFAutomaticRebootWhenChanged, DNFAutomaticRebootWhenNeeded:
+		default:
+			return fmt.Errorf("'reboot' option does not allow %q as a value", o.Config.Commands.Reboot)
+		}
 	}
 
 	return nil
@@ -41,6 +41,18 @@ func TestDNFAutomaticConfigStageOptionsValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "invalid-reboot",
+			options: DNFAutomaticConfigStageOptions{
+				Config: &DNFAutomaticConfig{
+					Commands: &DNFAutomaticConfigCommands{
+						ApplyUpdates: common.ToPtr(true),
+						Reboot:       "always",
+					},
+				},
+			},
+			err: true,
+		},
 		{
 			name: "valid-data-1",
 			options: DNFAutomaticConfigStageOptions{
@@ -60,6 +72,7 @@ func TestDNFAutomaticConfigStageOptionsValidate(t *testing.T) {
 					Commands: &DNFAutomaticConfigCommands{
 						ApplyUpdates: common.ToPtr(false),
 						UpgradeType:  DNFAutomaticUpgradeTypeSecurity,
+						Reboot:       DNFAutomaticRebootWhenNeeded,
 					},
 				},
 			},
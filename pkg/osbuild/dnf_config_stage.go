@@ -65,6 +65,7 @@ type DNFConfig struct {
 
 type DNFConfigMain struct {
 	IPResolve string `json:"ip_resolve,omitempty"`
+	Proxy     string `json:"proxy,omitempty"`
 }
 
 // UpdateVar inserts or updates a dnf variable. If a variable with the same
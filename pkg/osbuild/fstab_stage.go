@@ -38,6 +38,7 @@ func NewFSTabStage(options *FSTabStageOptions) *Stage {
 type FSTabEntry struct {
 	UUID    string `json:"uuid,omitempty"`
 	Label   string `json:"label,omitempty"`
+	Device  string `json:"device,omitempty"`
 	VFSType string `json:"vfs_type"`
 	Path    string `json:"path,omitempty"`
 	Options string `json:"options,omitempty"`
@@ -57,6 +58,17 @@ func (options *FSTabStageOptions) AddFilesystem(id string, vfsType string, path
 	})
 }
 
+// AddSwapFile adds an entry for a swap file. The entry is marked nofail
+// since the file is only created on first boot.
+func (options *FSTabStageOptions) AddSwapFile(path string) {
+	options.FileSystems = append(options.FileSystems, &FSTabEntry{
+		Device:  path,
+		VFSType: "swap",
+		Path:    "none",
+		Options: "defaults,nofail",
+	})
+}
+
 func NewFSTabStageOptions(pt *disk.PartitionTable) (*FSTabStageOptions, error) {
 	var options FSTabStageOptions
 	genOption := func(mnt disk.FSTabEntity, path []disk.Entity) error {
@@ -65,7 +77,11 @@ func NewFSTabStageOptions(pt *disk.PartitionTable) (*FSTabStageOptions, error) {
 		if err != nil {
 			return err
 		}
-		options.AddFilesystem(fsSpec.UUID, mnt.GetFSType(), mnt.GetFSFile(), fsOptions.MntOps, fsOptions.Freq, fsOptions.PassNo)
+		mntOps := fsOptions.MntOps
+		if mnt.GetFSType() != "swap" && growFS(path) {
+			mntOps += ",x-systemd.growfs"
+		}
+		options.AddFilesystem(fsSpec.UUID, mnt.GetFSType(), mnt.GetFSFile(), mntOps, fsOptions.Freq, fsOptions.PassNo)
 		return nil
 	}
 
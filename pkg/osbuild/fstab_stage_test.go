@@ -54,6 +54,19 @@ func TestAddFilesystem(t *testing.T) {
 	assert.Equal(t, len(filesystems), len(options.FileSystems))
 }
 
+func TestAddSwapFile(t *testing.T) {
+	options := &FSTabStageOptions{}
+	options.AddSwapFile("/var/swapfile")
+	assert.Equal(t, []*FSTabEntry{
+		{
+			Device:  "/var/swapfile",
+			VFSType: "swap",
+			Path:    "none",
+			Options: "defaults,nofail",
+		},
+	}, options.FileSystems)
+}
+
 func TestNewFSTabStageOptions(t *testing.T) {
 	expectedOptions := map[string]FSTabStageOptions{
 		// The names must match the ones in testdisk.TestPartitionTables
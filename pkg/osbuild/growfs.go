@@ -0,0 +1,335 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+	"github.com/osbuild/image-builder/pkg/disk"
+)
+
+// GrowMethod determines how the partitions that are marked to grow are
+// resized to fill the disk on first boot.
+type GrowMethod string
+
+const (
+	// No partition is grown on boot
+	GrowMethodNone GrowMethod = ""
+	// Partitions are grown by systemd-repart using drop-ins in repart.d
+	GrowMethodRepart GrowMethod = "repart"
+	// Partitions are grown by the growpart module of cloud-init (using
+	// growpart from cloud-utils)
+	GrowMethodGrowpart GrowMethod = "growpart"
+)
+
+func (m *GrowMethod) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	switch GrowMethod(s) {
+	case GrowMethodNone, GrowMethodRepart, GrowMethodGrowpart:
+		*m = GrowMethod(s)
+		return nil
+	default:
+		return fmt.Errorf("unknown grow method %q", s)
+	}
+}
+
+func (m *GrowMethod) UnmarshalYAML(unmarshal func(any) error) error {
+	return common.UnmarshalYAMLviaJSON(m, unmarshal)
+}
+
+const (
+	repartDropinDir   = "/usr/lib/repart.d"
+	cloudInitConfDir  = "/etc/cloud/cloud.cfg.d"
+	growpartConfFile  = "10-osbuild-growpart.cfg"
+	growLVMUnitPrefix = "osbuild-grow-lvm-"
+	growLVMStampDir   = "/var/lib/osbuild-grow-lvm"
+)
+
+// dosDiskIDRegex matches the disk identifier of a dos partition table
+var dosDiskIDRegex = regexp.MustCompile(`^0x[0-9a-fA-F]{8}$`)
+
+// growFS returns true if the filesystem at the end of the path lives directly
+// on a partition that is grown on boot and thus needs to be grown by
+// systemd-growfs. Filesystems on logical volumes are grown together with the
+// volume by lvextend.
+func growFS(path []disk.Entity) bool {
+	for idx := len(path) - 2; idx >= 0; idx-- {
+		switch ent := path[idx].(type) {
+		case *disk.Btrfs, *disk.BtrfsSubvolume:
+			continue
+		case *disk.Partition:
+			return ent.Grow
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// growFSUnit returns the name of the systemd-growfs service for the given
+// mountpoint.
+func growFSUnit(mountpoint string) string {
+	if mountpoint == "/" {
+		return "systemd-growfs-root.service"
+	}
+	return fmt.Sprintf("systemd-growfs@%s.service", pathEscape(mountpoint))
+}
+
+// partitionDevice returns a stable device path for the partition at the
+// given index of the partition table.
+func partitionDevice(pt *disk.PartitionTable, idx int) (string, error) {
+	switch pt.Type {
+	case disk.PT_GPT:
+		part := pt.Partitions[idx]
+		if part.UUID == "" {
+			return "", fmt.Errorf("partition %d has no UUID", idx+1)
+		}
+		return "/dev/disk/by-partuuid/" + strings.ToLower(part.UUID), nil
+	case disk.PT_DOS:
+		// the PARTUUID of dos partitions is the disk identifier followed
+		// by the partition number
+		if !dosDiskIDRegex.MatchString(pt.UUID) {
+			return "", fmt.Errorf("partition %d has no stable device path, the disk identifier %q is not a dos disk identifier", idx+1, pt.UUID)
+		}
+		return fmt.Sprintf("/dev/disk/by-partuuid/%s-%02d", strings.ToLower(strings.TrimPrefix(pt.UUID, "0x")), idx+1), nil
+	default:
+		return "", fmt.Errorf("unsupported partition table type %q", pt.Type)
+	}
+}
+
+// GenGrowFromPartitionTable returns the directory and file nodes and the
+// systemd units needed to grow the partitions and logical volumes of the
+// partition table that are marked to grow, using the given method for the
+// partitions. Filesystems are grown via the x-systemd.growfs mount option
+// (see NewFSTabStageOptions and GenSystemdMountStages).
+func GenGrowFromPartitionTable(pt *disk.PartitionTable, method GrowMethod) ([]*fsnode.Directory, []*fsnode.File, []*SystemdUnitCreateStageOptions, error) {
+	if pt == nil {
+		return nil, nil, nil, nil
+	}
+
+	// the partitions are not necessarily in the order of their position on
+	// disk, the root partition is always laid out last
+	lastIdx := -1
+	for idx, part := range pt.Partitions {
+		if lastIdx == -1 || part.Start >= pt.Partitions[lastIdx].Start {
+			lastIdx = idx
+		}
+	}
+
+	growIdx := -1
+	for idx, part := range pt.Partitions {
+		if !part.Grow {
+			continue
+		}
+		if idx != lastIdx {
+			return nil, nil, nil, fmt.Errorf("only the last partition can be grown, partition %d is marked to grow", idx+1)
+		}
+		growIdx = idx
+	}
+
+	var units []*SystemdUnitCreateStageOptions
+	var growLVM bool
+	for idx, part := range pt.Partitions {
+		vg, ok := part.Payload.(*disk.LVMVolumeGroup)
+		if !ok {
+			continue
+		}
+		unit, err := genGrowLVMUnit(pt, idx, vg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if unit != nil {
+			units = append(units, unit)
+			growLVM = true
+		}
+	}
+
+	if growIdx == -1 {
+		if growLVM {
+			return nil, nil, nil, fmt.Errorf("logical volumes can only be grown on a partition that is grown")
+		}
+		return nil, nil, nil, nil
+	}
+
+	switch pt.Partitions[growIdx].Payload.(type) {
+	case *disk.LUKSContainer:
+		return nil, nil, nil, fmt.Errorf("growing partitions with LUKS containers is not supported")
+	}
+
+	var dirs []*fsnode.Directory
+	var files []*fsnode.File
+	var err error
+	switch method {
+	case GrowMethodRepart:
+		dirs, files, err = genRepartGrowDropins(pt, growIdx)
+	case GrowMethodGrowpart:
+		dirs, files, err = genGrowpartConfig(pt, growIdx)
+	case GrowMethodNone:
+		err = fmt.Errorf("partition %d is marked to grow but no grow method is set", growIdx+1)
+	default:
+		err = fmt.Errorf("unknown grow method %q", method)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return dirs, files, units, nil
+}
+
+// genRepartGrowDropins generates repart.d drop-ins that grow the partition at
+// the given index. systemd-repart matches existing partitions to definitions
+// of the same type in order, so every partition of the same type is described
+// (with a fixed size for all but the grown one).
+func genRepartGrowDropins(pt *disk.PartitionTable, growIdx int) ([]*fsnode.Directory, []*fsnode.File, error) {
+	if pt.Type != disk.PT_GPT {
+		return nil, nil, fmt.Errorf("growing partitions with systemd-repart requires a GPT partition table")
+	}
+
+	growType := pt.Partitions[growIdx].Type
+	if growType == "" {
+		return nil, nil, fmt.Errorf("partition %d has no type", growIdx+1)
+	}
+
+	var files []*fsnode.File
+	for idx, part := range pt.Partitions {
+		if !strings.EqualFold(part.Type, growType) {
+			continue
+		}
+
+		var conf strings.Builder
+		fmt.Fprintf(&conf, "[Partition]\nType=%s\n", strings.ToLower(part.Type))
+		fmt.Fprintf(&conf, "SizeMinBytes=%d\n", part.Size.Uint64())
+		if idx != growIdx {
+			fmt.Fprintf(&conf, "SizeMaxBytes=%d\n", part.Size.Uint64())
+		}
+
+		path := filepath.Join(repartDropinDir, fmt.Sprintf("%02d-partition.conf", idx+1))
+		f, err := fsnode.NewFile(path, common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(conf.String()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating repart drop-in %q: %w", path, err)
+		}
+		files = append(files, f)
+	}
+
+	d, err := fsnode.NewDirectory(repartDropinDir, common.ToPtr(fs.FileMode(0755)), "root", "root", true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating repart drop-in directory node: %w", err)
+	}
+
+	return []*fsnode.Directory{d}, files, nil
+}
+
+// growpartDevice returns the device the growpart module of cloud-init grows
+// for the partition at the given index. The module resolves mountpoints to
+// their partition, which also works for partition tables without a stable
+// disk identifier. Partitions without a filesystem are passed by PARTUUID.
+func growpartDevice(pt *disk.PartitionTable, idx int) (string, error) {
+	switch payload := pt.Partitions[idx].Payload.(type) {
+	case *disk.Filesystem:
+		return payload.Mountpoint, nil
+	case *disk.Btrfs:
+		for _, subvol := range payload.Subvolumes {
+			if subvol.Mountpoint != "" {
+				return subvol.Mountpoint, nil
+			}
+		}
+	}
+	return partitionDevice(pt, idx)
+}
+
+// genGrowpartConfig generates a cloud-init configuration file that makes the
+// growpart module grow the partition at the given index.
+func genGrowpartConfig(pt *disk.PartitionTable, growIdx int) ([]*fsnode.Directory, []*fsnode.File, error) {
+	device, err := growpartDevice(pt, growIdx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conf := fmt.Sprintf("growpart:\n  mode: auto\n  devices:\n    - %s\n", device)
+	path := filepath.Join(cloudInitConfDir, growpartConfFile)
+	f, err := fsnode.NewFile(path, common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(conf))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating growpart configuration %q: %w", path, err)
+	}
+
+	d, err := fsnode.NewDirectory(cloudInitConfDir, common.ToPtr(fs.FileMode(0755)), "root", "root", true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating cloud-init configuration directory node: %w", err)
+	}
+
+	return []*fsnode.Directory{d}, []*fsnode.File{f}, nil
+}
+
+// genGrowLVMUnit generates a unit that resizes the physical volume of the
+// volume group on the partition at the given index (after the partition has
+// been grown) and extends the logical volume that is marked to grow, along
+// with its filesystem. Returns nil if neither needs to happen.
+func genGrowLVMUnit(pt *disk.PartitionTable, idx int, vg *disk.LVMVolumeGroup) (*SystemdUnitCreateStageOptions, error) {
+	var growLV *disk.LVMLogicalVolume
+	for lvIdx := range vg.LogicalVolumes {
+		lv := &vg.LogicalVolumes[lvIdx]
+		if !lv.Grow {
+			continue
+		}
+		if growLV != nil {
+			return nil, fmt.Errorf("only one logical volume of volume group %q can be grown", vg.Name)
+		}
+		growLV = lv
+	}
+
+	if !pt.Partitions[idx].Grow {
+		if growLV != nil {
+			return nil, fmt.Errorf("logical volume %q can only be grown if partition %d is grown", growLV.Name, idx+1)
+		}
+		return nil, nil
+	}
+
+	device, err := partitionDevice(pt, idx)
+	if err != nil {
+		return nil, err
+	}
+
+	// lvextend fails once there are no free extents left, so the volumes
+	// are only grown once, on first boot
+	stamp := filepath.Join(growLVMStampDir, vg.Name)
+	execStart := []string{"/usr/sbin/pvresize " + device}
+	if growLV != nil {
+		execStart = append(execStart, fmt.Sprintf("/usr/sbin/lvextend --resizefs --extents +100%%FREE %s/%s", vg.Name, growLV.Name))
+	}
+	execStart = append(execStart,
+		"/usr/bin/mkdir -p "+growLVMStampDir,
+		"/usr/bin/touch "+stamp,
+	)
+
+	return &SystemdUnitCreateStageOptions{
+		Filename: growLVMUnitPrefix + vg.Name + ".service",
+		UnitType: SystemUnitType,
+		UnitPath: UsrUnitPath,
+		Config: SystemdUnit{
+			Unit: &UnitSection{
+				Description:         fmt.Sprintf("Grow LVM volume group %s to fill the disk", vg.Name),
+				ConditionPathExists: []string{"!" + stamp},
+				// the partition is grown by either of these, depending on
+				// the grow method
+				After: []string{"systemd-repart.service", "cloud-init.service"},
+			},
+			Service: &ServiceSection{
+				Type:            OneshotServiceType,
+				ExecStart:       execStart,
+				RemainAfterExit: true,
+			},
+			Install: &InstallSection{
+				WantedBy: []string{"multi-user.target"},
+			},
+		},
+	}, nil
+}
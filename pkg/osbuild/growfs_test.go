@@ -0,0 +1,236 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/disk"
+)
+
+func newGrowTestPartitionTable(t *testing.T, name string) *disk.PartitionTable {
+	base := testPartitionTables[name]
+	pt := base.Clone().(*disk.PartitionTable)
+	pt.Partitions[len(pt.Partitions)-1].Grow = true
+	return pt
+}
+
+func newGrowTestLVMPartitionTable() *disk.PartitionTable {
+	return &disk.PartitionTable{
+		UUID: "D209C89E-EA5E-4FBD-B161-B461CCE297E0",
+		Type: disk.PT_GPT,
+		Partitions: []disk.Partition{
+			{
+				Size: 1024 * 1024 * 1024,
+				Type: disk.FilesystemDataGUID,
+				UUID: disk.DataPartitionUUID,
+				Payload: &disk.Filesystem{
+					Type:       "xfs",
+					Mountpoint: "/boot",
+				},
+			},
+			{
+				Size: 5 * 1024 * 1024 * 1024,
+				Type: disk.LVMPartitionGUID,
+				UUID: disk.RootPartitionUUID,
+				Grow: true,
+				Payload: &disk.LVMVolumeGroup{
+					Name: "rootvg",
+					LogicalVolumes: []disk.LVMLogicalVolume{
+						{
+							Name: "rootlv",
+							Size: 2 * 1024 * 1024 * 1024,
+							Grow: true,
+							Payload: &disk.Filesystem{
+								Type:       "xfs",
+								Mountpoint: "/",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGenGrowFromPartitionTableNothingToGrow(t *testing.T) {
+	pt := testPartitionTables["plain"]
+
+	dirs, files, units, err := GenGrowFromPartitionTable(&pt, GrowMethodRepart)
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Empty(t, files)
+	assert.Empty(t, units)
+}
+
+func TestGenGrowFromPartitionTableRepart(t *testing.T) {
+	pt := newGrowTestPartitionTable(t, "plain")
+
+	dirs, files, units, err := GenGrowFromPartitionTable(pt, GrowMethodRepart)
+	require.NoError(t, err)
+	assert.Empty(t, units)
+
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "/usr/lib/repart.d", dirs[0].Path())
+
+	// /boot has the same partition type as / and needs to be described
+	// as well to keep systemd-repart from growing it instead
+	require.Len(t, files, 2)
+	assert.Equal(t, "/usr/lib/repart.d/03-partition.conf", files[0].Path())
+	assert.Equal(t, "[Partition]\nType="+strings.ToLower(disk.FilesystemDataGUID)+"\nSizeMinBytes=1024000\nSizeMaxBytes=1024000\n", string(files[0].Data()))
+	assert.Equal(t, "/usr/lib/repart.d/04-partition.conf", files[1].Path())
+	assert.Equal(t, "[Partition]\nType="+strings.ToLower(disk.FilesystemDataGUID)+"\nSizeMinBytes=0\n", string(files[1].Data()))
+}
+
+func TestGenGrowFromPartitionTableGrowpart(t *testing.T) {
+	pt := newGrowTestPartitionTable(t, "plain")
+
+	dirs, files, units, err := GenGrowFromPartitionTable(pt, GrowMethodGrowpart)
+	require.NoError(t, err)
+	assert.Empty(t, units)
+
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "/etc/cloud/cloud.cfg.d", dirs[0].Path())
+	require.Len(t, files, 1)
+	assert.Equal(t, "/etc/cloud/cloud.cfg.d/10-osbuild-growpart.cfg", files[0].Path())
+	// the module resolves the mountpoint to the partition
+	assert.Equal(t, "growpart:\n  mode: auto\n  devices:\n    - /\n", string(files[0].Data()))
+}
+
+func TestGenGrowFromPartitionTableLayoutOrder(t *testing.T) {
+	// partitions added by customizations come after the root partition
+	// in the partition table, but the root partition is laid out last
+	pt := newGrowTestPartitionTable(t, "plain")
+	for idx := range pt.Partitions {
+		pt.Partitions[idx].Start = uint64(idx+1) * 1024 * 1024
+	}
+	pt.Partitions[2], pt.Partitions[3] = pt.Partitions[3], pt.Partitions[2]
+
+	_, files, _, err := GenGrowFromPartitionTable(pt, GrowMethodGrowpart)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Contains(t, string(files[0].Data()), "    - /\n")
+}
+
+func TestGenGrowFromPartitionTableLVM(t *testing.T) {
+	pt := newGrowTestLVMPartitionTable()
+
+	_, _, units, err := GenGrowFromPartitionTable(pt, GrowMethodGrowpart)
+	require.NoError(t, err)
+
+	require.Len(t, units, 1)
+	assert.Equal(t, "osbuild-grow-lvm-rootvg.service", units[0].Filename)
+	assert.Equal(t, []string{
+		"/usr/sbin/pvresize /dev/disk/by-partuuid/" + strings.ToLower(disk.RootPartitionUUID),
+		"/usr/sbin/lvextend --resizefs --extents +100%FREE rootvg/rootlv",
+		"/usr/bin/mkdir -p /var/lib/osbuild-grow-lvm",
+		"/usr/bin/touch /var/lib/osbuild-grow-lvm/rootvg",
+	}, units[0].Config.Service.ExecStart)
+	// the volumes are only grown once
+	assert.Equal(t, []string{"!/var/lib/osbuild-grow-lvm/rootvg"}, units[0].Config.Unit.ConditionPathExists)
+
+	dos := newGrowTestLVMPartitionTable()
+	dos.Type = disk.PT_DOS
+	dos.UUID = "0x14FC63D2"
+	_, _, units, err = GenGrowFromPartitionTable(dos, GrowMethodGrowpart)
+	require.NoError(t, err)
+	require.Len(t, units, 1)
+	assert.Equal(t, "/usr/sbin/pvresize /dev/disk/by-partuuid/14fc63d2-02", units[0].Config.Service.ExecStart[0])
+}
+
+func TestGenGrowFromPartitionTableErrors(t *testing.T) {
+	notLast := newGrowTestPartitionTable(t, "plain")
+	notLast.Partitions[3].Grow = false
+	notLast.Partitions[2].Grow = true
+
+	lvWithoutPartition := newGrowTestLVMPartitionTable()
+	lvWithoutPartition.Partitions[1].Grow = false
+
+	dos := newGrowTestPartitionTable(t, "plain")
+	dos.Type = disk.PT_DOS
+
+	lvmDOS := newGrowTestLVMPartitionTable()
+	lvmDOS.Type = disk.PT_DOS
+
+	testCases := map[string]struct {
+		pt     *disk.PartitionTable
+		method GrowMethod
+		expErr string
+	}{
+		"not-last": {
+			pt:     notLast,
+			method: GrowMethodRepart,
+			expErr: "only the last partition can be grown, partition 3 is marked to grow",
+		},
+		"lv-without-partition": {
+			pt:     lvWithoutPartition,
+			method: GrowMethodRepart,
+			expErr: `logical volume "rootlv" can only be grown if partition 2 is grown`,
+		},
+		"luks": {
+			pt:     newGrowTestPartitionTable(t, "luks"),
+			method: GrowMethodRepart,
+			expErr: "growing partitions with LUKS containers is not supported",
+		},
+		"no-method": {
+			pt:     newGrowTestPartitionTable(t, "plain"),
+			method: GrowMethodNone,
+			expErr: "partition 4 is marked to grow but no grow method is set",
+		},
+		"lvm-dos-no-disk-id": {
+			pt:     lvmDOS,
+			method: GrowMethodGrowpart,
+			expErr: `partition 2 has no stable device path, the disk identifier "D209C89E-EA5E-4FBD-B161-B461CCE297E0" is not a dos disk identifier`,
+		},
+		"repart-dos": {
+			pt:     dos,
+			method: GrowMethodRepart,
+			expErr: "growing partitions with systemd-repart requires a GPT partition table",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, _, _, err := GenGrowFromPartitionTable(tc.pt, tc.method)
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
+
+func TestGrowFSMountOptions(t *testing.T) {
+	pt := newGrowTestPartitionTable(t, "plain")
+
+	options, err := NewFSTabStageOptions(pt)
+	require.NoError(t, err)
+	for _, fs := range options.FileSystems {
+		if fs.Path == "/" {
+			assert.Equal(t, "defaults,x-systemd.growfs", fs.Options)
+		} else {
+			assert.NotContains(t, fs.Options, "x-systemd.growfs")
+		}
+	}
+
+	stages, err := GenSystemdMountStages(pt)
+	require.NoError(t, err)
+	var found bool
+	for _, stage := range stages {
+		opts, ok := stage.Options.(*SystemdUnitCreateStageOptions)
+		if !ok || opts.Filename != "-.mount" {
+			continue
+		}
+		found = true
+		assert.Equal(t, []string{"systemd-growfs-root.service"}, opts.Config.Unit.Wants)
+	}
+	assert.True(t, found)
+}
+
+func TestGrowMethodUnmarshal(t *testing.T) {
+	var m GrowMethod
+	require.NoError(t, json.Unmarshal([]byte(`"repart"`), &m))
+	assert.Equal(t, GrowMethodRepart, m)
+
+	assert.EqualError(t, json.Unmarshal([]byte(`"resize2fs"`), &m), `unknown grow method "resize2fs"`)
+}
@@ -0,0 +1,44 @@
+package osbuild
+
+type Img2simgStageOptions struct {
+	// Filename for the Android sparse image
+	Filename string `json:"filename"`
+
+	// Block size of the sparse image (default 4096)
+	BlockSize uint64 `json:"block_size,omitempty"`
+}
+
+func (Img2simgStageOptions) isStageOptions() {}
+
+func NewImg2simgStageOptions(filename string) *Img2simgStageOptions {
+	return &Img2simgStageOptions{
+		Filename: filename,
+	}
+}
+
+type Img2simgStageInputs struct {
+	File *FilesInput `json:"file"`
+}
+
+func (*Img2simgStageInputs) isStageInputs() {}
+
+func NewImg2simgStageInputs(references FilesInputRef) *Img2simgStageInputs {
+	return &Img2simgStageInputs{
+		File: NewFilesInput(references),
+	}
+}
+
+// Converts a raw disk image into an Android sparse image, which only contains
+// the blocks of the image that are in use.
+func NewImg2simgStage(options *Img2simgStageOptions, inputs *Img2simgStageInputs) *Stage {
+	var stageInputs Inputs
+	if inputs != nil {
+		stageInputs = inputs
+	}
+
+	return &Stage{
+		Type:    "org.osbuild.img2simg",
+		Options: options,
+		Inputs:  stageInputs,
+	}
+}
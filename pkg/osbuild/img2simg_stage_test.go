@@ -0,0 +1,23 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewImg2simgStage(t *testing.T) {
+	inputFilename := "image.raw"
+	filename := "image.simg"
+	pipeline := "image"
+
+	expectedStage := &Stage{
+		Type:    "org.osbuild.img2simg",
+		Options: &Img2simgStageOptions{Filename: filename},
+		Inputs:  NewImg2simgStageInputs(NewFilesInputPipelineObjectRef(pipeline, inputFilename, nil)),
+	}
+
+	actualStage := NewImg2simgStage(NewImg2simgStageOptions(filename),
+		NewImg2simgStageInputs(NewFilesInputPipelineObjectRef(pipeline, inputFilename, nil)))
+	assert.Equal(t, expectedStage, actualStage)
+}
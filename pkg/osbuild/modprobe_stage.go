@@ -6,6 +6,7 @@ import (
 	"regexp"
 
 	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/modprobe"
 )
 
 const modprobeCfgFilenameRegex = "^[\\w.-]{1,250}\\.conf$"
@@ -169,3 +170,23 @@ func NewModprobeConfigCmdInstall(modulename, cmdline string) *ModprobeConfigCmdI
 	}
 	return cmd
 }
+
+// GenModprobeStageOptions creates the options for the modprobe stages of
+// the given configuration files
+func GenModprobeStageOptions(o *modprobe.Options) []*ModprobeStageOptions {
+	var stageOptions []*ModprobeStageOptions
+	for _, f := range o.Files {
+		var commands ModprobeConfigCmdList
+		for _, module := range f.Blacklist {
+			commands = append(commands, NewModprobeConfigCmdBlacklist(module))
+		}
+		for _, install := range f.Install {
+			commands = append(commands, NewModprobeConfigCmdInstall(install.Module, install.Command))
+		}
+		stageOptions = append(stageOptions, &ModprobeStageOptions{
+			Filename: f.Filename(),
+			Commands: commands,
+		})
+	}
+	return stageOptions
+}
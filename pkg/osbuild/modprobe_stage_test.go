@@ -5,6 +5,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.yaml.in/yaml/v3"
+
+	"github.com/osbuild/image-builder/pkg/customizations/modprobe"
 )
 
 func TestNewModprobeStage(t *testing.T) {
@@ -170,3 +172,20 @@ commands:
 	}
 	assert.Equal(t, expected, modprobeOpts)
 }
+
+func TestGenModprobeStageOptions(t *testing.T) {
+	options := GenModprobeStageOptions(&modprobe.Options{
+		Files: []modprobe.File{{
+			Name:      "disable-usb-storage",
+			Blacklist: []string{"usb_storage"},
+			Install:   []modprobe.Install{{Module: "usb_storage", Command: "/bin/false"}},
+		}},
+	})
+	assert.Equal(t, []*ModprobeStageOptions{{
+		Filename: "disable-usb-storage.conf",
+		Commands: ModprobeConfigCmdList{
+			NewModprobeConfigCmdBlacklist("usb_storage"),
+			NewModprobeConfigCmdInstall("usb_storage", "/bin/false"),
+		},
+	}}, options)
+}
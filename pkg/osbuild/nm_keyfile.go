@@ -0,0 +1,233 @@
+package osbuild
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
+)
+
+const nmSystemConnectionsDir = "/etc/NetworkManager/system-connections"
+
+// nmConnectionNamespace is used to derive stable connection UUIDs from the
+// connection names, so that the same configuration always produces the
+// same keyfiles
+var nmConnectionNamespace = uuid.MustParse("7b8d5c1e-5b0e-4c7c-9a51-3a3f5a6e2f10")
+
+// nmKeyfile builds the contents of a NetworkManager keyfile
+type nmKeyfile struct {
+	b strings.Builder
+}
+
+func (k *nmKeyfile) section(name string) {
+	if k.b.Len() > 0 {
+		k.b.WriteString("\n")
+	}
+	fmt.Fprintf(&k.b, "[%s]\n", name)
+}
+
+func (k *nmKeyfile) set(key, value string) {
+	fmt.Fprintf(&k.b, "%s=%s\n", key, value)
+}
+
+func (k *nmKeyfile) setList(key string, values []string) {
+	if len(values) > 0 {
+		k.set(key, strings.Join(values, ";")+";")
+	}
+}
+
+func (k *nmKeyfile) connection(id, connType, iface string, autoconnect *bool) {
+	k.section("connection")
+	k.set("id", id)
+	k.set("uuid", uuid.NewSHA1(nmConnectionNamespace, []byte(id)).String())
+	k.set("type", connType)
+	if iface != "" {
+		k.set("interface-name", iface)
+	}
+	if autoconnect != nil {
+		k.set("autoconnect", strconv.FormatBool(*autoconnect))
+	}
+}
+
+func (k *nmKeyfile) ip(name string, ip *network.IPConfig) {
+	k.section(name)
+	k.set("method", string(ip.GetMethod()))
+	if ip == nil {
+		return
+	}
+	for idx, addr := range ip.Addresses {
+		k.set(fmt.Sprintf("address%d", idx+1), addr)
+	}
+	if ip.Gateway != "" {
+		k.set("gateway", ip.Gateway)
+	}
+	k.setList("dns", ip.DNS)
+	k.setList("dns-search", ip.DNSSearch)
+	for idx, route := range ip.Routes {
+		value := route.Destination
+		if route.Gateway != "" || route.Metric != nil {
+			value += "," + route.Gateway
+		}
+		if route.Metric != nil {
+			value += "," + strconv.FormatUint(uint64(*route.Metric), 10)
+		}
+		k.set(fmt.Sprintf("route%d", idx+1), value)
+	}
+}
+
+func newNMKeyfileNode(id, contents string) (*fsnode.File, error) {
+	path := filepath.Join(nmSystemConnectionsDir, id+".nmconnection")
+	// NetworkManager ignores keyfiles that are readable by other users
+	f, err := fsnode.NewFile(path, common.ToPtr(fs.FileMode(0600)), "root", "root", []byte(contents))
+	if err != nil {
+		return nil, fmt.Errorf("error creating NetworkManager keyfile node %q: %w", path, err)
+	}
+	return f, nil
+}
+
+// GenNMKeyfilesFromConnections returns the directory and file nodes for the
+// NetworkManager keyfiles of the given connections. Bond and bridge ports
+// get a connection profile of their own that attaches them to the bond or
+// bridge.
+func GenNMKeyfilesFromConnections(conns []network.Connection) ([]*fsnode.Directory, []*fsnode.File, error) {
+	if len(conns) == 0 {
+		return nil, nil, nil
+	}
+	if err := network.ValidateConnections(conns); err != nil {
+		return nil, nil, err
+	}
+
+	var files []*fsnode.File
+	for _, conn := range conns {
+		var k nmKeyfile
+		k.connection(conn.Name, string(conn.Type), conn.Interface, conn.Autoconnect)
+		switch conn.Type {
+		case network.ConnectionTypeEthernet:
+			k.section("ethernet")
+		case network.ConnectionTypeBond:
+			k.section("bond")
+			k.set("mode", conn.Bond.Mode)
+			for _, name := range slices.Sorted(maps.Keys(conn.Bond.Options)) {
+				k.set(name, conn.Bond.Options[name])
+			}
+		case network.ConnectionTypeVLAN:
+			k.section("vlan")
+			k.set("id", strconv.FormatUint(uint64(conn.VLAN.ID), 10))
+			k.set("parent", conn.VLAN.Parent)
+		case network.ConnectionTypeBridge:
+			k.section("bridge")
+			if conn.Bridge != nil && conn.Bridge.STP != nil {
+				k.set("stp", strconv.FormatBool(*conn.Bridge.STP))
+			}
+		}
+		k.ip("ipv4", conn.IPv4)
+		k.ip("ipv6", conn.IPv6)
+
+		f, err := newNMKeyfileNode(conn.Name, k.b.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+
+		for _, port := range conn.Ports() {
+			var pk nmKeyfile
+			pk.connection(conn.PortName(port), string(network.ConnectionTypeEthernet), port, nil)
+			// "master" and "slave-type" are understood by all
+			// NetworkManager versions, unlike their newer aliases
+			pk.set("master", conn.Interface)
+			pk.set("slave-type", string(conn.Type))
+			pk.section("ethernet")
+
+			f, err := newNMKeyfileNode(conn.PortName(port), pk.b.String())
+			if err != nil {
+				return nil, nil, err
+			}
+			files = append(files, f)
+		}
+	}
+
+	// the directory is part of the NetworkManager package, only make sure
+	// it exists without changing it
+	d, err := fsnode.NewDirectory(nmSystemConnectionsDir, nil, nil, nil, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating NetworkManager connections directory node: %w", err)
+	}
+	return []*fsnode.Directory{d}, files, nil
+}
+
+// KickstartNetworkFromConnections converts the connections into kickstart
+// network commands. Kickstart only supports a single address per IP
+// family and no bonds, bridges or VLANs in the stage options, so those are
+// rejected.
+func KickstartNetworkFromConnections(conns []network.Connection) ([]NetworkOptions, error) {
+	if err := network.ValidateConnections(conns); err != nil {
+		return nil, err
+	}
+
+	var options []NetworkOptions
+	for _, conn := range conns {
+		if conn.Type != network.ConnectionTypeEthernet {
+			return nil, fmt.Errorf("connection %q: kickstart only supports %s connections, not %s", conn.Name, network.ConnectionTypeEthernet, conn.Type)
+		}
+		if conn.Interface == "" {
+			return nil, fmt.Errorf("connection %q: kickstart requires an interface name", conn.Name)
+		}
+		opts := NetworkOptions{
+			Device:   conn.Interface,
+			Activate: common.ToPtr(true),
+			OnBoot:   "on",
+		}
+		if conn.Autoconnect != nil && !*conn.Autoconnect {
+			opts.OnBoot = "off"
+		}
+
+		switch conn.IPv4.GetMethod() {
+		case network.IPMethodAuto:
+			opts.BootProto = "dhcp"
+		case network.IPMethodManual:
+			if len(conn.IPv4.Addresses) > 1 {
+				return nil, fmt.Errorf("connection %q: kickstart only supports a single IPv4 address", conn.Name)
+			}
+			prefix := netip.MustParsePrefix(conn.IPv4.Addresses[0])
+			opts.BootProto = "static"
+			opts.IP = prefix.Addr().String()
+			opts.Netmask = net.IP(net.CIDRMask(prefix.Bits(), 32)).String()
+			opts.Gateway = conn.IPv4.Gateway
+		default:
+			return nil, fmt.Errorf("connection %q: kickstart does not support IPv4 method %q", conn.Name, conn.IPv4.GetMethod())
+		}
+
+		if conn.IPv4 != nil {
+			opts.Nameservers = append(opts.Nameservers, conn.IPv4.DNS...)
+		}
+
+		if conn.IPv6 != nil {
+			switch conn.IPv6.GetMethod() {
+			case network.IPMethodAuto:
+				opts.IPV6 = "auto"
+			case network.IPMethodManual:
+				if len(conn.IPv6.Addresses) > 1 {
+					return nil, fmt.Errorf("connection %q: kickstart only supports a single IPv6 address", conn.Name)
+				}
+				opts.IPV6 = conn.IPv6.Addresses[0]
+				opts.IPV6Gateway = conn.IPv6.Gateway
+			default:
+				return nil, fmt.Errorf("connection %q: kickstart does not support IPv6 method %q", conn.Name, conn.IPv6.GetMethod())
+			}
+			opts.Nameservers = append(opts.Nameservers, conn.IPv6.DNS...)
+		}
+		options = append(options, opts)
+	}
+	return options, nil
+}
@@ -0,0 +1,254 @@
+package osbuild
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/network"
+)
+
+func nmTestUUID(id string) string {
+	return uuid.NewSHA1(nmConnectionNamespace, []byte(id)).String()
+}
+
+func TestGenNMKeyfilesFromConnectionsNone(t *testing.T) {
+	dirs, files, err := GenNMKeyfilesFromConnections(nil)
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Empty(t, files)
+}
+
+func TestGenNMKeyfilesFromConnectionsEthernet(t *testing.T) {
+	dirs, files, err := GenNMKeyfilesFromConnections([]network.Connection{
+		{
+			Name:        "eth0",
+			Type:        network.ConnectionTypeEthernet,
+			Interface:   "eth0",
+			Autoconnect: common.ToPtr(true),
+			IPv4: &network.IPConfig{
+				Method:    network.IPMethodManual,
+				Addresses: []string{"192.168.1.10/24"},
+				Gateway:   "192.168.1.1",
+				DNS:       []string{"192.168.1.1", "192.168.1.2"},
+				DNSSearch: []string{"example.com"},
+				Routes: []network.Route{
+					{Destination: "10.0.0.0/8", Gateway: "192.168.1.254"},
+					{Destination: "172.16.0.0/12", Metric: common.ToPtr(uint32(50))},
+				},
+			},
+			IPv6: &network.IPConfig{Method: network.IPMethodDisabled},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "/etc/NetworkManager/system-connections", dirs[0].Path())
+	assert.Nil(t, dirs[0].Mode())
+
+	require.Len(t, files, 1)
+	assert.Equal(t, "/etc/NetworkManager/system-connections/eth0.nmconnection", files[0].Path())
+	assert.Equal(t, os.FileMode(0600), *files[0].Mode())
+	assert.Equal(t, `[connection]
+id=eth0
+uuid=`+nmTestUUID("eth0")+`
+type=ethernet
+interface-name=eth0
+autoconnect=true
+
+[ethernet]
+
+[ipv4]
+method=manual
+address1=192.168.1.10/24
+gateway=192.168.1.1
+dns=192.168.1.1;192.168.1.2;
+dns-search=example.com;
+route1=10.0.0.0/8,192.168.1.254
+route2=172.16.0.0/12,,50
+
+[ipv6]
+method=disabled
+`, string(files[0].Data()))
+}
+
+func TestGenNMKeyfilesFromConnectionsBondVLAN(t *testing.T) {
+	_, files, err := GenNMKeyfilesFromConnections([]network.Connection{
+		{
+			Name:      "bond0",
+			Type:      network.ConnectionTypeBond,
+			Interface: "bond0",
+			Bond: &network.Bond{
+				Mode:    "802.3ad",
+				Options: map[string]string{"miimon": "100", "lacp_rate": "fast"},
+				Ports:   []string{"eth0", "eth1"},
+			},
+			IPv4: &network.IPConfig{Method: network.IPMethodDisabled},
+			IPv6: &network.IPConfig{Method: network.IPMethodDisabled},
+		},
+		{
+			Name:      "vlan10",
+			Type:      network.ConnectionTypeVLAN,
+			Interface: "bond0.10",
+			VLAN:      &network.VLAN{ID: 10, Parent: "bond0"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, files, 4)
+	assert.Equal(t, "/etc/NetworkManager/system-connections/bond0.nmconnection", files[0].Path())
+	assert.Equal(t, `[connection]
+id=bond0
+uuid=`+nmTestUUID("bond0")+`
+type=bond
+interface-name=bond0
+
+[bond]
+mode=802.3ad
+lacp_rate=fast
+miimon=100
+
+[ipv4]
+method=disabled
+
+[ipv6]
+method=disabled
+`, string(files[0].Data()))
+
+	assert.Equal(t, "/etc/NetworkManager/system-connections/bond0-port-eth0.nmconnection", files[1].Path())
+	assert.Equal(t, `[connection]
+id=bond0-port-eth0
+uuid=`+nmTestUUID("bond0-port-eth0")+`
+type=ethernet
+interface-name=eth0
+master=bond0
+slave-type=bond
+
+[ethernet]
+`, string(files[1].Data()))
+	assert.Equal(t, "/etc/NetworkManager/system-connections/bond0-port-eth1.nmconnection", files[2].Path())
+
+	assert.Equal(t, "/etc/NetworkManager/system-connections/vlan10.nmconnection", files[3].Path())
+	assert.Equal(t, `[connection]
+id=vlan10
+uuid=`+nmTestUUID("vlan10")+`
+type=vlan
+interface-name=bond0.10
+
+[vlan]
+id=10
+parent=bond0
+
+[ipv4]
+method=auto
+
+[ipv6]
+method=auto
+`, string(files[3].Data()))
+}
+
+func TestGenNMKeyfilesFromConnectionsInvalid(t *testing.T) {
+	_, _, err := GenNMKeyfilesFromConnections([]network.Connection{
+		{Name: "vlan10", Type: network.ConnectionTypeVLAN, Interface: "eth0.10"},
+	})
+	assert.EqualError(t, err, `connection "vlan10": vlan connections require vlan settings`)
+}
+
+func TestKickstartNetworkFromConnections(t *testing.T) {
+	options, err := KickstartNetworkFromConnections([]network.Connection{
+		{
+			Name:      "eth0",
+			Type:      network.ConnectionTypeEthernet,
+			Interface: "eth0",
+			IPv4: &network.IPConfig{
+				Method:    network.IPMethodManual,
+				Addresses: []string{"192.168.1.10/24"},
+				Gateway:   "192.168.1.1",
+				DNS:       []string{"192.168.1.1"},
+			},
+			IPv6: &network.IPConfig{
+				Method:    network.IPMethodManual,
+				Addresses: []string{"2001:db8::10/64"},
+				Gateway:   "2001:db8::1",
+				DNS:       []string{"2001:db8::1"},
+			},
+		},
+		{
+			Name:        "eth1",
+			Type:        network.ConnectionTypeEthernet,
+			Interface:   "eth1",
+			Autoconnect: common.ToPtr(false),
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []NetworkOptions{
+		{
+			Device:      "eth0",
+			Activate:    common.ToPtr(true),
+			OnBoot:      "on",
+			BootProto:   "static",
+			IP:          "192.168.1.10",
+			Netmask:     "255.255.255.0",
+			Gateway:     "192.168.1.1",
+			IPV6:        "2001:db8::10/64",
+			IPV6Gateway: "2001:db8::1",
+			Nameservers: []string{"192.168.1.1", "2001:db8::1"},
+		},
+		{
+			Device:    "eth1",
+			Activate:  common.ToPtr(true),
+			OnBoot:    "off",
+			BootProto: "dhcp",
+		},
+	}, options)
+}
+
+func TestKickstartNetworkFromConnectionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		conn network.Connection
+		err  string
+	}{
+		{
+			name: "bond",
+			conn: network.Connection{Name: "bond0", Type: network.ConnectionTypeBond, Interface: "bond0", Bond: &network.Bond{Mode: "802.3ad"}},
+			err:  `connection "bond0": kickstart only supports ethernet connections, not bond`,
+		},
+		{
+			name: "no-interface",
+			conn: network.Connection{Name: "eth0", Type: network.ConnectionTypeEthernet},
+			err:  `connection "eth0": kickstart requires an interface name`,
+		},
+		{
+			name: "multiple-addresses",
+			conn: network.Connection{
+				Name:      "eth0",
+				Type:      network.ConnectionTypeEthernet,
+				Interface: "eth0",
+				IPv4:      &network.IPConfig{Method: network.IPMethodManual, Addresses: []string{"192.168.1.10/24", "192.168.1.11/24"}},
+			},
+			err: `connection "eth0": kickstart only supports a single IPv4 address`,
+		},
+		{
+			name: "ipv4-link-local",
+			conn: network.Connection{
+				Name:      "eth0",
+				Type:      network.ConnectionTypeEthernet,
+				Interface: "eth0",
+				IPv4:      &network.IPConfig{Method: network.IPMethodLinkLocal},
+			},
+			err: `connection "eth0": kickstart does not support IPv4 method "link-local"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := KickstartNetworkFromConnections([]network.Connection{tc.conn})
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
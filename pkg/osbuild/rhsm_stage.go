@@ -46,6 +46,7 @@ type RHSMStageOptionsDnfPlugin struct {
 type RHSMStageOptionsSubMan struct {
 	Rhsm      *SubManConfigRHSMSection      `json:"rhsm,omitempty"`
 	Rhsmcertd *SubManConfigRHSMCERTDSection `json:"rhsmcertd,omitempty"`
+	Server    *SubManConfigServerSection    `json:"server,omitempty"`
 }
 
 // RHSM configuration section of /etc/rhsm/rhsm.conf
@@ -63,6 +64,14 @@ type SubManConfigRHSMCERTDSection struct {
 	AutoRegistration *bool `json:"auto_registration,omitempty"`
 }
 
+// Server configuration section of /etc/rhsm/rhsm.conf
+type SubManConfigServerSection struct {
+	ProxyHostname *string `json:"proxy_hostname,omitempty"`
+	ProxyScheme   *string `json:"proxy_scheme,omitempty"`
+	ProxyPort     *string `json:"proxy_port,omitempty"`
+	NoProxy       *string `json:"no_proxy,omitempty"`
+}
+
 func NewRHSMStageOptions(config *subscription.RHSMConfig) *RHSMStageOptions {
 	if config == nil {
 		return nil
@@ -111,7 +120,7 @@ func NewRHSMStageOptions(config *subscription.RHSMConfig) *RHSMStageOptions {
 		subManConfRhsmAutoEnableYumPlugins,
 		subManConfRhsmcertdAutoReg,
 	}
-	if slices.ContainsFunc(subManConfValues, func(val *bool) bool { return val != nil }) {
+	if slices.ContainsFunc(subManConfValues, func(val *bool) bool { return val != nil }) || !config.SubMan.Server.IsZero() {
 		options.SubMan = &RHSMStageOptionsSubMan{}
 
 		if subManConfRhsmManageRepos != nil || subManConfRhsmAutoEnableYumPlugins != nil {
@@ -125,6 +134,15 @@ func NewRHSMStageOptions(config *subscription.RHSMConfig) *RHSMStageOptions {
 				AutoRegistration: common.ClonePtr(subManConfRhsmcertdAutoReg),
 			}
 		}
+
+		if server := config.SubMan.Server; !server.IsZero() {
+			options.SubMan.Server = &SubManConfigServerSection{
+				ProxyHostname: common.ClonePtr(server.ProxyHostname),
+				ProxyScheme:   common.ClonePtr(server.ProxyScheme),
+				ProxyPort:     common.ClonePtr(server.ProxyPort),
+				NoProxy:       common.ClonePtr(server.NoProxy),
+			}
+		}
 	}
 
 	return options
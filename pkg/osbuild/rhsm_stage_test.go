@@ -185,6 +185,28 @@ func TestNewRHSMStageOptions(t *testing.T) {
 				},
 			},
 		},
+		{
+			config: &subscription.RHSMConfig{
+				SubMan: subscription.SubManConfig{
+					Server: subscription.SubManServerConfig{
+						ProxyHostname: common.ToPtr("proxy.example.com"),
+						ProxyScheme:   common.ToPtr("http"),
+						ProxyPort:     common.ToPtr("3128"),
+						NoProxy:       common.ToPtr("localhost,.example.com"),
+					},
+				},
+			},
+			stageOptions: &RHSMStageOptions{
+				SubMan: &RHSMStageOptionsSubMan{
+					Server: &SubManConfigServerSection{
+						ProxyHostname: common.ToPtr("proxy.example.com"),
+						ProxyScheme:   common.ToPtr("http"),
+						ProxyPort:     common.ToPtr("3128"),
+						NoProxy:       common.ToPtr("localhost,.example.com"),
+					},
+				},
+			},
+		},
 	}
 
 	for idx, tc := range testCases {
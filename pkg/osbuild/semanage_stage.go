@@ -0,0 +1,124 @@
+package osbuild
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+	"github.com/osbuild/image-builder/pkg/customizations/selinux"
+)
+
+// SemanageStageOptions describe the local modifications of the SELinux
+// policy store in the tree. The stage runs semodule and semanage in the
+// tree, so it needs to run before the tree is labelled.
+//
+// NOTE: osbuild does not ship an org.osbuild.semanage stage yet, the
+// options follow the schema proposed for it. Manifests that contain the
+// stage fail to build until the stage is available in osbuild.
+type SemanageStageOptions struct {
+	// Policy is the name of the policy store, e.g. "targeted"
+	Policy       string                `json:"policy"`
+	Modules      []SemanageModule      `json:"modules,omitempty"`
+	Booleans     map[string]bool       `json:"booleans,omitempty"`
+	FileContexts []SemanageFileContext `json:"fcontexts,omitempty"`
+	Ports        []SemanagePort        `json:"ports,omitempty"`
+}
+
+type SemanageModule struct {
+	// Path of the module file in the tree
+	Path     string  `json:"path"`
+	Priority *uint16 `json:"priority,omitempty"`
+}
+
+type SemanageFileContext struct {
+	Path     string `json:"path"`
+	Type     string `json:"type"`
+	FileType string `json:"file_type,omitempty"`
+}
+
+type SemanagePort struct {
+	Protocol string `json:"protocol"`
+	Port     string `json:"port"`
+	Type     string `json:"type"`
+}
+
+func (SemanageStageOptions) isStageOptions() {}
+
+func (o SemanageStageOptions) validate() error {
+	if o.Policy == "" {
+		return fmt.Errorf("policy is required")
+	}
+	if len(o.Modules)+len(o.Booleans)+len(o.FileContexts)+len(o.Ports) == 0 {
+		return fmt.Errorf("at least one module, boolean, file context or port is required")
+	}
+	for _, m := range o.Modules {
+		if !filepath.IsAbs(m.Path) {
+			return fmt.Errorf("module path %q must be absolute", m.Path)
+		}
+	}
+	return nil
+}
+
+// NewSemanageStage creates a new org.osbuild.semanage stage, see the note
+// on SemanageStageOptions
+func NewSemanageStage(options *SemanageStageOptions) *Stage {
+	if err := options.validate(); err != nil {
+		panic(err)
+	}
+
+	return &Stage{
+		Type:    "org.osbuild.semanage",
+		Options: options,
+	}
+}
+
+// GenSemanageFromOptions returns the directory and file nodes for the policy
+// modules and the options of the semanage stage that installs them and
+// applies the booleans, file contexts and ports to the given policy. The
+// stage options are nil if there is nothing to apply.
+func GenSemanageFromOptions(policy string, options *selinux.Options) ([]*fsnode.Directory, []*fsnode.File, *SemanageStageOptions, error) {
+	if err := options.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	if options == nil || len(options.Modules)+len(options.Booleans)+len(options.FileContexts)+len(options.Ports) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	stageOptions := &SemanageStageOptions{
+		Policy:   policy,
+		Booleans: options.Booleans,
+	}
+	for _, fc := range options.FileContexts {
+		stageOptions.FileContexts = append(stageOptions.FileContexts, SemanageFileContext(fc))
+	}
+	for _, p := range options.Ports {
+		stageOptions.Ports = append(stageOptions.Ports, SemanagePort(p))
+	}
+
+	if len(options.Modules) == 0 {
+		return nil, nil, stageOptions, nil
+	}
+
+	modulesDir := filepath.Join("/usr/share/selinux/packages", policy)
+	var files []*fsnode.File
+	for _, m := range options.Modules {
+		path := filepath.Join(modulesDir, m.Filename())
+		f, err := fsnode.NewFile(path, common.ToPtr(fs.FileMode(0644)), "root", "root", m.Contents)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error creating SELinux module node %q: %w", path, err)
+		}
+		files = append(files, f)
+		stageOptions.Modules = append(stageOptions.Modules, SemanageModule{
+			Path:     path,
+			Priority: m.Priority,
+		})
+	}
+
+	d, err := fsnode.NewDirectory(modulesDir, nil, nil, nil, true)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error creating SELinux modules directory node: %w", err)
+	}
+	return []*fsnode.Directory{d}, files, stageOptions, nil
+}
@@ -0,0 +1,95 @@
+package osbuild
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/selinux"
+)
+
+func TestNewSemanageStage(t *testing.T) {
+	options := &SemanageStageOptions{
+		Policy:   "targeted",
+		Booleans: map[string]bool{"httpd_can_network_connect": true},
+	}
+	expectedStage := &Stage{
+		Type:    "org.osbuild.semanage",
+		Options: options,
+	}
+	assert.Equal(t, expectedStage, NewSemanageStage(options))
+}
+
+func TestNewSemanageStageInvalid(t *testing.T) {
+	assert.PanicsWithError(t, "policy is required", func() {
+		NewSemanageStage(&SemanageStageOptions{Booleans: map[string]bool{"deny_ptrace": true}})
+	})
+	assert.PanicsWithError(t, "at least one module, boolean, file context or port is required", func() {
+		NewSemanageStage(&SemanageStageOptions{Policy: "targeted"})
+	})
+	assert.PanicsWithError(t, `module path "mod.pp" must be absolute`, func() {
+		NewSemanageStage(&SemanageStageOptions{Policy: "targeted", Modules: []SemanageModule{{Path: "mod.pp"}}})
+	})
+}
+
+func TestGenSemanageFromOptions(t *testing.T) {
+	cil := []byte("(allow httpd_t var_t (file (read open)))\n")
+	dirs, files, stageOptions, err := GenSemanageFromOptions("targeted", &selinux.Options{
+		Booleans: map[string]bool{"httpd_can_network_connect": true},
+		Modules: []selinux.Module{
+			{Name: "mywebapp", Type: selinux.ModuleTypeCIL, Contents: cil, Priority: common.ToPtr(uint16(500))},
+		},
+		FileContexts: []selinux.FileContext{{Path: "/srv/web(/.*)?", Type: "httpd_sys_content_t"}},
+		Ports:        []selinux.Port{{Protocol: "tcp", Port: "8443", Type: "http_port_t"}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "/usr/share/selinux/packages/targeted", dirs[0].Path())
+
+	require.Len(t, files, 1)
+	assert.Equal(t, "/usr/share/selinux/packages/targeted/mywebapp.cil", files[0].Path())
+	assert.Equal(t, cil, files[0].Data())
+	assert.Equal(t, os.FileMode(0644), *files[0].Mode())
+
+	assert.Equal(t, &SemanageStageOptions{
+		Policy: "targeted",
+		Modules: []SemanageModule{
+			{Path: "/usr/share/selinux/packages/targeted/mywebapp.cil", Priority: common.ToPtr(uint16(500))},
+		},
+		Booleans:     map[string]bool{"httpd_can_network_connect": true},
+		FileContexts: []SemanageFileContext{{Path: "/srv/web(/.*)?", Type: "httpd_sys_content_t"}},
+		Ports:        []SemanagePort{{Protocol: "tcp", Port: "8443", Type: "http_port_t"}},
+	}, stageOptions)
+}
+
+func TestGenSemanageFromOptionsNoModules(t *testing.T) {
+	dirs, files, stageOptions, err := GenSemanageFromOptions("targeted", &selinux.Options{
+		Booleans: map[string]bool{"deny_ptrace": true},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Empty(t, files)
+	assert.Equal(t, &SemanageStageOptions{
+		Policy:   "targeted",
+		Booleans: map[string]bool{"deny_ptrace": true},
+	}, stageOptions)
+}
+
+func TestGenSemanageFromOptionsInvalid(t *testing.T) {
+	_, _, _, err := GenSemanageFromOptions("targeted", &selinux.Options{
+		Modules: []selinux.Module{{Name: "mywebapp", Type: selinux.ModuleTypePP, Contents: []byte("module mywebapp 1.0;")}},
+	})
+	assert.EqualError(t, err, `SELinux module "mywebapp": not a compiled policy package`)
+}
+
+func TestGenSemanageFromOptionsEmpty(t *testing.T) {
+	dirs, files, stageOptions, err := GenSemanageFromOptions("targeted", &selinux.Options{})
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Empty(t, files)
+	assert.Nil(t, stageOptions)
+}
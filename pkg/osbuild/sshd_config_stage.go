@@ -12,6 +12,10 @@ type SshdConfigConfig struct {
 	ChallengeResponseAuthentication *bool                `json:"ChallengeResponseAuthentication,omitempty" yaml:"ChallengeResponseAuthentication,omitempty"`
 	ClientAliveInterval             *int                 `json:"ClientAliveInterval,omitempty" yaml:"ClientAliveInterval,omitempty"`
 	PermitRootLogin                 PermitRootLoginValue `json:"PermitRootLogin,omitempty" yaml:"PermitRootLogin,omitempty"`
+	TrustedUserCAKeys               string               `json:"TrustedUserCAKeys,omitempty" yaml:"TrustedUserCAKeys,omitempty"`
+	AuthorizedPrincipalsFile        string               `json:"AuthorizedPrincipalsFile,omitempty" yaml:"AuthorizedPrincipalsFile,omitempty"`
+	AuthorizedKeysCommand           string               `json:"AuthorizedKeysCommand,omitempty" yaml:"AuthorizedKeysCommand,omitempty"`
+	AuthorizedKeysCommandUser       string               `json:"AuthorizedKeysCommandUser,omitempty" yaml:"AuthorizedKeysCommandUser,omitempty"`
 }
 
 // PermitRootLoginValue is defined to represent all valid types of the
@@ -49,6 +53,10 @@ type rawSshdConfigConfig struct {
 	ChallengeResponseAuthentication *bool       `json:"ChallengeResponseAuthentication,omitempty" yaml:"ChallengeResponseAuthentication,omitempty"`
 	ClientAliveInterval             *int        `json:"ClientAliveInterval,omitempty" yaml:"ClientAliveInterval,omitempty"`
 	PermitRootLogin                 interface{} `json:"PermitRootLogin,omitempty" yaml:"PermitRootLogin,omitempty"`
+	TrustedUserCAKeys               string      `json:"TrustedUserCAKeys,omitempty" yaml:"TrustedUserCAKeys,omitempty"`
+	AuthorizedPrincipalsFile        string      `json:"AuthorizedPrincipalsFile,omitempty" yaml:"AuthorizedPrincipalsFile,omitempty"`
+	AuthorizedKeysCommand           string      `json:"AuthorizedKeysCommand,omitempty" yaml:"AuthorizedKeysCommand,omitempty"`
+	AuthorizedKeysCommandUser       string      `json:"AuthorizedKeysCommandUser,omitempty" yaml:"AuthorizedKeysCommandUser,omitempty"`
 }
 
 func (c *SshdConfigConfig) UnmarshalJSON(data []byte) error {
@@ -73,6 +81,10 @@ func (c *SshdConfigConfig) UnmarshalJSON(data []byte) error {
 	c.ChallengeResponseAuthentication = rawConfig.ChallengeResponseAuthentication
 	c.ClientAliveInterval = rawConfig.ClientAliveInterval
 	c.PermitRootLogin = permitRootLogin
+	c.TrustedUserCAKeys = rawConfig.TrustedUserCAKeys
+	c.AuthorizedPrincipalsFile = rawConfig.AuthorizedPrincipalsFile
+	c.AuthorizedKeysCommand = rawConfig.AuthorizedKeysCommand
+	c.AuthorizedKeysCommandUser = rawConfig.AuthorizedKeysCommandUser
 
 	return nil
 }
@@ -108,6 +120,10 @@ func (o SshdConfigStageOptions) validate() error {
 		}
 	}
 
+	if (o.Config.AuthorizedKeysCommand == "") != (o.Config.AuthorizedKeysCommandUser == "") {
+		return fmt.Errorf("'AuthorizedKeysCommand' and 'AuthorizedKeysCommandUser' options must be set together")
+	}
+
 	return nil
 }
 
@@ -28,6 +28,8 @@ func TestJsonYamlSshdConfigStage(t *testing.T) {
 			ChallengeResponseAuthentication: common.ToPtr(false),
 			ClientAliveInterval:             common.ToPtr(180),
 			PermitRootLogin:                 PermitRootLoginValueProhibitPassword,
+			TrustedUserCAKeys:               "/etc/ssh/trusted_user_ca_keys",
+			AuthorizedPrincipalsFile:        "/etc/ssh/auth_principals/%u",
 		},
 	}
 	inputStringJSON := `{
@@ -35,7 +37,9 @@ func TestJsonYamlSshdConfigStage(t *testing.T) {
 		  "PasswordAuthentication": false,
 		  "ChallengeResponseAuthentication": false,
 		  "ClientAliveInterval": 180,
-		  "PermitRootLogin": "prohibit-password"
+		  "PermitRootLogin": "prohibit-password",
+		  "TrustedUserCAKeys": "/etc/ssh/trusted_user_ca_keys",
+		  "AuthorizedPrincipalsFile": "/etc/ssh/auth_principals/%u"
 		}
 	  }`
 	inputStringYAML := `
@@ -44,6 +48,8 @@ config:
   ChallengeResponseAuthentication: false
   ClientAliveInterval: 180
   PermitRootLogin: "prohibit-password"
+  TrustedUserCAKeys: "/etc/ssh/trusted_user_ca_keys"
+  AuthorizedPrincipalsFile: "/etc/ssh/auth_principals/%u"
 `
 	var inputOptions SshdConfigStageOptions
 	err := json.Unmarshal([]byte(inputStringJSON), &inputOptions)
@@ -105,6 +111,25 @@ func TestSshdConfigStageOptionsValidate(t *testing.T) {
 			},
 			err: false,
 		},
+		{
+			name: "valid-authorized-keys-command",
+			options: SshdConfigStageOptions{
+				Config: SshdConfigConfig{
+					AuthorizedKeysCommand:     "/usr/bin/sss_ssh_authorizedkeys",
+					AuthorizedKeysCommandUser: "nobody",
+				},
+			},
+			err: false,
+		},
+		{
+			name: "authorized-keys-command-without-user",
+			options: SshdConfigStageOptions{
+				Config: SshdConfigConfig{
+					AuthorizedKeysCommand: "/usr/bin/sss_ssh_authorizedkeys",
+				},
+			},
+			err: true,
+		},
 	}
 
 	for idx := range tests {
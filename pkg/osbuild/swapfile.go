@@ -0,0 +1,86 @@
+package osbuild
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
+)
+
+// swapFileUnitName returns the name of the swap unit for the swap file at
+// path, as generated by systemd-fstab-generator from the fstab entry
+func swapFileUnitName(path string) string {
+	return fmt.Sprintf("%s.swap", pathEscape(path))
+}
+
+// GenSwapFileCreateStageOptions creates the options for a systemd service
+// that allocates and formats the swap file on first boot, before the swap
+// unit activates it. Creating the file at build time would store it in
+// the image.
+func GenSwapFileCreateStageOptions(file *swap.File) *SystemdUnitCreateStageOptions {
+	swapUnit := swapFileUnitName(file.Path)
+
+	var execStart []string
+	if dir := filepath.Dir(file.Path); dir != "/" {
+		execStart = append(execStart, fmt.Sprintf("/usr/bin/mkdir -p %s", dir))
+	}
+	execStart = append(execStart,
+		fmt.Sprintf("/usr/bin/fallocate -l %d %s", file.Size.Uint64(), file.Path),
+		fmt.Sprintf("/usr/bin/chmod 0600 %s", file.Path),
+		fmt.Sprintf("/usr/sbin/mkswap %s", file.Path),
+	)
+
+	return &SystemdUnitCreateStageOptions{
+		Filename: fmt.Sprintf("create-%s.service", pathEscape(file.Path)),
+		UnitType: SystemUnitType,
+		UnitPath: EtcUnitPath,
+		Config: SystemdUnit{
+			Unit: &UnitSection{
+				Description: fmt.Sprintf("Create swap file %s", file.Path),
+				// swap units are pulled in by sysinit.target, the default
+				// dependencies would create an ordering cycle
+				DefaultDependencies: common.ToPtr(false),
+				ConditionPathExists: []string{"!" + file.Path},
+				After:               []string{"local-fs.target"},
+				Before:              []string{swapUnit},
+			},
+			Service: &ServiceSection{
+				Type:            OneshotServiceType,
+				RemainAfterExit: true,
+				ExecStart:       execStart,
+			},
+			Install: &InstallSection{
+				RequiredBy: []string{swapUnit},
+			},
+		},
+	}
+}
+
+// GenSwapFileUnitStages creates a swap unit for the swap file and enables
+// it, for images that use units instead of fstab entries
+func GenSwapFileUnitStages(file *swap.File) []*Stage {
+	swapUnit := swapFileUnitName(file.Path)
+	options := &SystemdUnitCreateStageOptions{
+		Filename: swapUnit,
+		UnitPath: EtcUnitPath,
+		Config: SystemdUnit{
+			Unit: &UnitSection{
+				DefaultDependencies: common.ToPtr(true),
+			},
+			Swap: &SwapSection{
+				What:    file.Path,
+				Options: "nofail",
+			},
+			Install: &InstallSection{
+				WantedBy: []string{"swap.target"},
+			},
+		},
+	}
+	return []*Stage{
+		NewSystemdUnitCreateStage(options),
+		NewSystemdStage(&SystemdStageOptions{
+			EnabledServices: []string{swapUnit},
+		}),
+	}
+}
@@ -0,0 +1,62 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/swap"
+	"github.com/osbuild/image-builder/pkg/datasizes"
+)
+
+func TestGenSwapFileCreateStageOptions(t *testing.T) {
+	options := GenSwapFileCreateStageOptions(&swap.File{Path: "/var/swap-file", Size: 2 * datasizes.GiB})
+	assert.Equal(t, &SystemdUnitCreateStageOptions{
+		Filename: `create-var-swap\x2dfile.service`,
+		UnitType: SystemUnitType,
+		UnitPath: EtcUnitPath,
+		Config: SystemdUnit{
+			Unit: &UnitSection{
+				Description:         "Create swap file /var/swap-file",
+				DefaultDependencies: common.ToPtr(false),
+				ConditionPathExists: []string{"!/var/swap-file"},
+				After:               []string{"local-fs.target"},
+				Before:              []string{`var-swap\x2dfile.swap`},
+			},
+			Service: &ServiceSection{
+				Type:            OneshotServiceType,
+				RemainAfterExit: true,
+				ExecStart: []string{
+					"/usr/bin/mkdir -p /var",
+					"/usr/bin/fallocate -l 2147483648 /var/swap-file",
+					"/usr/bin/chmod 0600 /var/swap-file",
+					"/usr/sbin/mkswap /var/swap-file",
+				},
+			},
+			Install: &InstallSection{
+				RequiredBy: []string{`var-swap\x2dfile.swap`},
+			},
+		},
+	}, options)
+
+	// the stage validates
+	assert.NotPanics(t, func() { NewSystemdUnitCreateStage(options) })
+
+	// no directory is created for files in /
+	options = GenSwapFileCreateStageOptions(&swap.File{Path: "/swapfile", Size: datasizes.GiB})
+	assert.Equal(t, "create-swapfile.service", options.Filename)
+	assert.Equal(t, "/usr/bin/fallocate -l 1073741824 /swapfile", options.Config.Service.ExecStart[0])
+}
+
+func TestGenSwapFileUnitStages(t *testing.T) {
+	stages := GenSwapFileUnitStages(&swap.File{Path: "/swapfile", Size: datasizes.GiB})
+	assert.Len(t, stages, 2)
+
+	unit := stages[0].Options.(*SystemdUnitCreateStageOptions)
+	assert.Equal(t, "swapfile.swap", unit.Filename)
+	assert.Equal(t, &SwapSection{What: "/swapfile", Options: "nofail"}, unit.Config.Swap)
+	assert.Equal(t, []string{"swap.target"}, unit.Config.Install.WantedBy)
+
+	assert.Equal(t, &SystemdStageOptions{EnabledServices: []string{"swapfile.swap"}}, stages[1].Options)
+}
@@ -3,9 +3,18 @@ package osbuild
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
+
+	"github.com/osbuild/image-builder/pkg/customizations/sysctl"
 )
 
+const sysctldFilenameRegex = `^[\w.-]{1,250}\.conf$`
+
+// sysctldKeyRegex matches kernel parameter names in the dotted or the
+// slash separated form, including globs and the "-" exclude prefix
+const sysctldKeyRegex = `^-?[\w*?\[\]-]+([./][\w*?\[\]:-]+)*$`
+
 // SysctldStageOptions represents a single sysctl.d configuration file.
 type SysctldStageOptions struct {
 	// Filename of the configuration file to be created. Must end with '.conf'.
@@ -31,6 +40,10 @@ func (o SysctldStageOptions) MarshalJSON() ([]byte, error) {
 	if len(o.Config) == 0 {
 		return nil, fmt.Errorf("the 'Config' list must contain at least one item")
 	}
+	nameRegex := regexp.MustCompile(sysctldFilenameRegex)
+	if !nameRegex.MatchString(o.Filename) {
+		return nil, fmt.Errorf("sysctl.d configuration filename %q doesn't conform to schema (%s)", o.Filename, nameRegex.String())
+	}
 	options := sysctldStageOptions(o)
 	return json.Marshal(options)
 }
@@ -58,9 +71,30 @@ type SysctldConfigLine struct {
 type sysctldConfigLine SysctldConfigLine
 
 func (l SysctldConfigLine) MarshalJSON() ([]byte, error) {
+	keyRegex := regexp.MustCompile(sysctldKeyRegex)
+	if !keyRegex.MatchString(l.Key) {
+		return nil, fmt.Errorf("sysctl key %q doesn't conform to schema (%s)", l.Key, keyRegex.String())
+	}
 	if l.Value == "" && !strings.HasPrefix(l.Key, "-") {
 		return nil, fmt.Errorf("only Keys starting with '-' can have an empty Value")
 	}
+	if strings.ContainsAny(l.Value, "\n\r") {
+		return nil, fmt.Errorf("sysctl value for %q must not contain newlines", l.Key)
+	}
 	line := sysctldConfigLine(l)
 	return json.Marshal(line)
 }
+
+// GenSysctldStageOptions creates the options for the sysctld stages of the
+// given configuration files
+func GenSysctldStageOptions(o *sysctl.Options) []*SysctldStageOptions {
+	var stageOptions []*SysctldStageOptions
+	for _, f := range o.Files {
+		config := make([]SysctldConfigLine, 0, len(f.Settings))
+		for _, s := range f.Settings {
+			config = append(config, SysctldConfigLine{Key: s.Key, Value: s.Value})
+		}
+		stageOptions = append(stageOptions, NewSysctldStageOptions(f.Filename(), config))
+	}
+	return stageOptions
+}
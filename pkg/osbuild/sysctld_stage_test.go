@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/pkg/customizations/sysctl"
 )
 
 func TestNewSysctldStageOptions(t *testing.T) {
@@ -40,6 +42,20 @@ func TestSysctldStageOptions_MarshalJSON_Invalid(t *testing.T) {
 			name:    "empty-options",
 			options: SysctldStageOptions{},
 		},
+		{
+			name: "bad-filename-suffix",
+			options: SysctldStageOptions{
+				Filename: "example.cfg",
+				Config:   []SysctldConfigLine{{Key: "vm.swappiness", Value: "10"}},
+			},
+		},
+		{
+			name: "filename-with-path",
+			options: SysctldStageOptions{
+				Filename: "../example.conf",
+				Config:   []SysctldConfigLine{{Key: "vm.swappiness", Value: "10"}},
+			},
+		},
 	}
 	for idx, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -60,6 +76,26 @@ func TestSysctldConfigLine_MarshalJSON_Invalid(t *testing.T) {
 				Key: "key-without-prefix",
 			},
 		},
+		{
+			name: "empty-key",
+			options: SysctldConfigLine{
+				Value: "1",
+			},
+		},
+		{
+			name: "key-with-whitespace",
+			options: SysctldConfigLine{
+				Key:   "vm.swappiness = 10",
+				Value: "10",
+			},
+		},
+		{
+			name: "value-with-newline",
+			options: SysctldConfigLine{
+				Key:   "vm.swappiness",
+				Value: "10\nkernel.sysrq=1",
+			},
+		},
 	}
 	for idx, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -68,3 +104,36 @@ func TestSysctldConfigLine_MarshalJSON_Invalid(t *testing.T) {
 		})
 	}
 }
+
+func TestSysctldStageOptions_MarshalJSON(t *testing.T) {
+	options := NewSysctldStageOptions("99-tuning.conf", []SysctldConfigLine{
+		{Key: "net.ipv4.conf.*.rp_filter", Value: "2"},
+		{Key: "-net.ipv4.conf.eth0.rp_filter"},
+		{Key: "kernel/sysrq", Value: "1"},
+		{Key: "net.ipv4.ip_local_port_range", Value: "32768 60999"},
+	})
+	gotBytes, err := json.Marshal(options)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"filename": "99-tuning.conf",
+		"config": [
+			{"key": "net.ipv4.conf.*.rp_filter", "value": "2"},
+			{"key": "-net.ipv4.conf.eth0.rp_filter"},
+			{"key": "kernel/sysrq", "value": "1"},
+			{"key": "net.ipv4.ip_local_port_range", "value": "32768 60999"}
+		]
+	}`, string(gotBytes))
+}
+
+func TestGenSysctldStageOptions(t *testing.T) {
+	options := GenSysctldStageOptions(&sysctl.Options{
+		Files: []sysctl.File{
+			{Name: "90-network", Settings: []sysctl.Setting{{Key: "net.ipv4.ip_forward", Value: "1"}, {Key: "-net.ipv4.conf.lo.rp_filter"}}},
+			{Name: "91-vm", Settings: []sysctl.Setting{{Key: "vm.swappiness", Value: "10"}}},
+		},
+	})
+	assert.Equal(t, []*SysctldStageOptions{
+		{Filename: "90-network.conf", Config: []SysctldConfigLine{{Key: "net.ipv4.ip_forward", Value: "1"}, {Key: "-net.ipv4.conf.lo.rp_filter"}}},
+		{Filename: "91-vm.conf", Config: []SysctldConfigLine{{Key: "vm.swappiness", Value: "10"}}},
+	}, options)
+}
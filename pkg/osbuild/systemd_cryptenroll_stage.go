@@ -0,0 +1,27 @@
+package osbuild
+
+// Enroll additional keys in a LUKS2 container with systemd-cryptenroll
+
+type SystemdCryptenrollStageOptions struct {
+	// Existing passphrase used to unlock the container
+	Passphrase string `json:"passphrase"`
+
+	// Generate a recovery key and write it to the given path (relative to
+	// the tree of the pipeline) with mode 0600
+	RecoveryKeyPath string `json:"recovery-key-path,omitempty"`
+
+	// Generate a random key, enroll it in a new key slot and write it to
+	// the given location with mode 0400, e.g. "mount://-/etc/key"
+	KeyFile string `json:"key-file,omitempty"`
+}
+
+func (SystemdCryptenrollStageOptions) isStageOptions() {}
+
+func NewSystemdCryptenrollStage(options *SystemdCryptenrollStageOptions, devices map[string]Device, mounts []Mount) *Stage {
+	return &Stage{
+		Type:    "org.osbuild.systemd-cryptenroll",
+		Options: options,
+		Devices: devices,
+		Mounts:  mounts,
+	}
+}
@@ -89,4 +89,18 @@ type SystemdJournaldConfigJournalSection struct {
 	// Enables/Disables kernel auditing on start-up, leaves it as is if
 	// unspecified.
 	Audit ConfigAudit `json:"Audit,omitempty"`
+
+	// Disk space the persistent journal may use at most and has to leave
+	// free, e.g. "500M" or "2G".
+	SystemMaxUse   string `json:"SystemMaxUse,omitempty"`
+	SystemKeepFree string `json:"SystemKeepFree,omitempty"`
+
+	// Maximum size of a single persistent journal file.
+	SystemMaxFileSize string `json:"SystemMaxFileSize,omitempty"`
+
+	// Memory the volatile journal in /run may use at most.
+	RuntimeMaxUse string `json:"RuntimeMaxUse,omitempty"`
+
+	// Forwards the log messages to a traditional syslog daemon.
+	ForwardToSyslog *bool `json:"ForwardToSyslog,omitempty"`
 }
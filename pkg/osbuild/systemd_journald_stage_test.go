@@ -1,9 +1,12 @@
 package osbuild
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/internal/common"
 )
 
 func TestNewSystemdJournalStage(t *testing.T) {
@@ -69,3 +72,21 @@ func TestInvalidFilename(t *testing.T) {
 	assert.Errorf(t, options.validate(), "test didn't return any error ")
 	assert.Panics(t, func() { NewSystemdJournaldStage(options) })
 }
+
+func TestSystemdJournaldStageSizeAndForwarding(t *testing.T) {
+	options := SystemdJournaldStageOptions{
+		Filename: "50-customizations.conf",
+		Config: SystemdJournaldConfigDropin{
+			Journal: SystemdJournaldConfigJournalSection{
+				ForwardToSyslog: common.ToPtr(false),
+			},
+		},
+	}
+	assert.NoError(t, options.validate())
+
+	options.Config.Journal.SystemMaxUse = "1G"
+	options.Config.Journal.SystemKeepFree = "10%"
+	data, err := json.Marshal(options)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"filename":"50-customizations.conf","config":{"Journal":{"SystemMaxUse":"1G","SystemKeepFree":"10%","ForwardToSyslog":false}}}`, string(data))
+}
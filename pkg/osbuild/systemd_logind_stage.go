@@ -31,13 +31,22 @@ type SystemdLogindConfigLoginSection struct {
 	NAutoVTs *int `json:"NAutoVTs,omitempty"`
 	// Configures how many virtual terminals (VTs) to reserve for the seat
 	ReserveVT *int `json:"ReserveVT,omitempty"`
+	// Configures whether the processes of a user are killed when the user
+	// logs out
+	KillUserProcesses *bool `json:"KillUserProcesses,omitempty"`
+	// Configures the action taken when the system is idle, e.g. "suspend"
+	IdleAction string `json:"IdleAction,omitempty"`
+	// Configures the delay after which IdleAction is taken
+	IdleActionSec string `json:"IdleActionSec,omitempty"`
+	// Configures the timeout after which idle sessions are stopped
+	StopIdleSessionSec string `json:"StopIdleSessionSec,omitempty"`
 }
 
 // Unexported alias for use in SystemdLogindConfigLoginSection's MarshalJSON() to prevent recursion
 type systemdLogindConfigLoginSection SystemdLogindConfigLoginSection
 
 func (s SystemdLogindConfigLoginSection) MarshalJSON() ([]byte, error) {
-	if s.NAutoVTs == nil && s.ReserveVT == nil {
+	if s == (SystemdLogindConfigLoginSection{}) {
 		return nil, fmt.Errorf("at least one 'Login' section option must be specified")
 	}
 	loginSection := systemdLogindConfigLoginSection(s)
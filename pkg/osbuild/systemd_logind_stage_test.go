@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/image-builder/internal/common"
 )
 
 func TestNewSystemdLogindStage(t *testing.T) {
@@ -42,3 +44,19 @@ func TestSystemdLogindStage_MarshalJSON_Invalid(t *testing.T) {
 		})
 	}
 }
+
+func TestSystemdLogindStage_MarshalJSON_IdleOptions(t *testing.T) {
+	options := SystemdLogindStageOptions{
+		Filename: "50-customizations.conf",
+		Config: SystemdLogindConfigDropin{
+			Login: SystemdLogindConfigLoginSection{
+				KillUserProcesses: common.ToPtr(true),
+				IdleAction:        "lock",
+				IdleActionSec:     "15min",
+			},
+		},
+	}
+	data, err := json.Marshal(options)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"filename":"50-customizations.conf","config":{"Login":{"KillUserProcesses":true,"IdleAction":"lock","IdleActionSec":"15min"}}}`, string(data))
+}
@@ -12,7 +12,7 @@ import (
 )
 
 const (
-	unitFilenameRegex = "^[\\w:.\\\\-]+[@]{0,1}[\\w:.\\\\-]*\\.(service|mount|socket|swap)$"
+	unitFilenameRegex = "^[\\w:.\\\\-]+[@]{0,1}[\\w:.\\\\-]*\\.(service|mount|socket|swap|timer|path)$"
 
 	// This is less strict than the corresponding regex in osbuild. In osbuild,
 	// we use lookaheads to validate paths, whereas in images we use an invalid
@@ -91,6 +91,29 @@ type SocketSection struct {
 	RemoveOnStop           string `json:"RemoveOnStop,omitempty" yaml:"RemoveOnStop,omitempty"`
 }
 
+type TimerSection struct {
+	OnActiveSec        string   `json:"OnActiveSec,omitempty" yaml:"OnActiveSec,omitempty"`
+	OnBootSec          string   `json:"OnBootSec,omitempty" yaml:"OnBootSec,omitempty"`
+	OnStartupSec       string   `json:"OnStartupSec,omitempty" yaml:"OnStartupSec,omitempty"`
+	OnUnitActiveSec    string   `json:"OnUnitActiveSec,omitempty" yaml:"OnUnitActiveSec,omitempty"`
+	OnUnitInactiveSec  string   `json:"OnUnitInactiveSec,omitempty" yaml:"OnUnitInactiveSec,omitempty"`
+	OnCalendar         []string `json:"OnCalendar,omitempty" yaml:"OnCalendar,omitempty"`
+	AccuracySec        string   `json:"AccuracySec,omitempty" yaml:"AccuracySec,omitempty"`
+	RandomizedDelaySec string   `json:"RandomizedDelaySec,omitempty" yaml:"RandomizedDelaySec,omitempty"`
+	Persistent         bool     `json:"Persistent,omitempty" yaml:"Persistent,omitempty"`
+	Unit               string   `json:"Unit,omitempty" yaml:"Unit,omitempty"`
+}
+
+type PathSection struct {
+	PathExists        []string `json:"PathExists,omitempty" yaml:"PathExists,omitempty"`
+	PathExistsGlob    []string `json:"PathExistsGlob,omitempty" yaml:"PathExistsGlob,omitempty"`
+	PathChanged       []string `json:"PathChanged,omitempty" yaml:"PathChanged,omitempty"`
+	PathModified      []string `json:"PathModified,omitempty" yaml:"PathModified,omitempty"`
+	DirectoryNotEmpty []string `json:"DirectoryNotEmpty,omitempty" yaml:"DirectoryNotEmpty,omitempty"`
+	MakeDirectory     bool     `json:"MakeDirectory,omitempty" yaml:"MakeDirectory,omitempty"`
+	Unit              string   `json:"Unit,omitempty" yaml:"Unit,omitempty"`
+}
+
 type InstallSection struct {
 	RequiredBy []string `json:"RequiredBy,omitempty" yaml:"RequiredBy,omitempty"`
 	WantedBy   []string `json:"WantedBy,omitempty" yaml:"WantedBy,omitempty"`
@@ -102,6 +125,8 @@ type SystemdUnit struct {
 	Mount   *MountSection   `json:"Mount,omitempty" yaml:"Mount,omitempty"`
 	Socket  *SocketSection  `json:"Socket,omitempty" yaml:"Socket,omitempty"`
 	Swap    *SwapSection    `json:"Swap,omitempty" yaml:"Swap,omitempty"`
+	Timer   *TimerSection   `json:"Timer,omitempty" yaml:"Timer,omitempty"`
+	Path    *PathSection    `json:"Path,omitempty" yaml:"Path,omitempty"`
 	Install *InstallSection `json:"Install,omitempty" yaml:"Install,omitempty"`
 }
 
@@ -213,13 +238,49 @@ func (o *SystemdUnitCreateStageOptions) validateSocket() error {
 	return nil
 }
 
+func (o *SystemdUnitCreateStageOptions) validateTimer() error {
+	timer := o.Config.Timer
+	if timer == nil {
+		return fmt.Errorf("systemd timer unit %q requires a Timer section", o.Filename)
+	}
+	if o.Config.Service != nil || o.Config.Mount != nil || o.Config.Socket != nil || o.Config.Swap != nil || o.Config.Path != nil {
+		return fmt.Errorf("systemd timer unit %q contains sections of another unit type", o.Filename)
+	}
+	if timer.OnActiveSec == "" && timer.OnBootSec == "" && timer.OnStartupSec == "" && timer.OnUnitActiveSec == "" && timer.OnUnitInactiveSec == "" && len(timer.OnCalendar) == 0 {
+		return fmt.Errorf("Timer section of systemd unit %q requires at least one On* trigger", o.Filename)
+	}
+	return nil
+}
+
+func (o *SystemdUnitCreateStageOptions) validatePath() error {
+	path := o.Config.Path
+	if path == nil {
+		return fmt.Errorf("systemd path unit %q requires a Path section", o.Filename)
+	}
+	if o.Config.Service != nil || o.Config.Mount != nil || o.Config.Socket != nil || o.Config.Swap != nil || o.Config.Timer != nil {
+		return fmt.Errorf("systemd path unit %q contains sections of another unit type", o.Filename)
+	}
+	if len(path.PathExists)+len(path.PathExistsGlob)+len(path.PathChanged)+len(path.PathModified)+len(path.DirectoryNotEmpty) == 0 {
+		return fmt.Errorf("Path section of systemd unit %q requires at least one path to watch", o.Filename)
+	}
+	return nil
+}
+
 func (o *SystemdUnitCreateStageOptions) validate() error {
 	fre := regexp.MustCompile(unitFilenameRegex)
 	if !fre.MatchString(o.Filename) {
 		return fmt.Errorf("invalid filename %q for systemd unit: does not conform to schema (%s)", o.Filename, unitFilenameRegex)
 	}
 
-	switch filepath.Ext(o.Filename) {
+	ext := filepath.Ext(o.Filename)
+	if o.Config.Timer != nil && ext != ".timer" {
+		return fmt.Errorf("systemd unit %q contains invalid section Timer", o.Filename)
+	}
+	if o.Config.Path != nil && ext != ".path" {
+		return fmt.Errorf("systemd unit %q contains invalid section Path", o.Filename)
+	}
+
+	switch ext {
 	case ".service":
 		return o.validateService()
 	case ".mount":
@@ -228,9 +289,13 @@ func (o *SystemdUnitCreateStageOptions) validate() error {
 		return o.validateSwap()
 	case ".socket":
 		return o.validateSocket()
+	case ".timer":
+		return o.validateTimer()
+	case ".path":
+		return o.validatePath()
 	default:
 		// this should be caught by the regex
-		return fmt.Errorf("invalid filename %q for systemd unit: extension must be one of .service, .mount, .swap, or .socket", o.Filename)
+		return fmt.Errorf("invalid filename %q for systemd unit: extension must be one of .service, .mount, .swap, .socket, .timer or .path", o.Filename)
 	}
 }
 
@@ -299,6 +364,11 @@ func GenSystemdMountStages(pt *disk.PartitionTable) ([]*Stage, error) {
 				Type:    ent.GetFSType(),
 				Options: fsOptions.MntOps,
 			}
+			// x-systemd.growfs is only handled by the fstab generator,
+			// so pull in the growfs service explicitly
+			if growFS(path) {
+				options.Config.Unit.Wants = append(options.Config.Unit.Wants, growFSUnit(ent.GetFSFile()))
+			}
 		}
 
 		mountStages = append(mountStages, NewSystemdUnitCreateStage(options))
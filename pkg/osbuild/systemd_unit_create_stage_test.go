@@ -340,6 +340,74 @@ func TestSystemdUnitStageOptionsValidation(t *testing.T) {
 			expected: fmt.Errorf("invalid filename \"test.whatever\" for systemd unit: does not conform to schema (%s)", unitFilenameRegex),
 		},
 
+		"timer-ok": {
+			options: SystemdUnitCreateStageOptions{
+				Filename: "test.timer",
+				UnitPath: EtcUnitPath,
+				Config: SystemdUnit{
+					Unit:    unitSection,
+					Timer:   &TimerSection{OnCalendar: []string{"daily"}, Persistent: true},
+					Install: &InstallSection{WantedBy: []string{"timers.target"}},
+				},
+			},
+			expected: nil,
+		},
+		"path-ok": {
+			options: SystemdUnitCreateStageOptions{
+				Filename: "test.path",
+				UnitPath: EtcUnitPath,
+				Config: SystemdUnit{
+					Unit:    unitSection,
+					Path:    &PathSection{PathChanged: []string{"/etc/test.conf"}},
+					Install: installSection,
+				},
+			},
+			expected: nil,
+		},
+		"timer-no-trigger": {
+			options: SystemdUnitCreateStageOptions{
+				Filename: "test.timer",
+				Config: SystemdUnit{
+					Unit:  unitSection,
+					Timer: &TimerSection{Persistent: true},
+				},
+			},
+			expected: fmt.Errorf(`Timer section of systemd unit "test.timer" requires at least one On* trigger`),
+		},
+		"timer-with-service": {
+			options: SystemdUnitCreateStageOptions{
+				Filename: "test.timer",
+				Config: SystemdUnit{
+					Unit:    unitSection,
+					Timer:   &TimerSection{OnBootSec: "5min"},
+					Service: serviceSection,
+				},
+			},
+			expected: fmt.Errorf(`systemd timer unit "test.timer" contains sections of another unit type`),
+		},
+		"path-no-watch": {
+			options: SystemdUnitCreateStageOptions{
+				Filename: "test.path",
+				Config: SystemdUnit{
+					Unit: unitSection,
+					Path: &PathSection{MakeDirectory: true},
+				},
+			},
+			expected: fmt.Errorf(`Path section of systemd unit "test.path" requires at least one path to watch`),
+		},
+		"service-with-timer": {
+			options: SystemdUnitCreateStageOptions{
+				Filename: "test.service",
+				Config: SystemdUnit{
+					Unit:    unitSection,
+					Service: serviceSection,
+					Timer:   &TimerSection{OnBootSec: "5min"},
+					Install: installSection,
+				},
+			},
+			expected: fmt.Errorf(`systemd unit "test.service" contains invalid section Timer`),
+		},
+
 		// missing required options
 		"mount-no-what": {
 			options: SystemdUnitCreateStageOptions{
@@ -0,0 +1,160 @@
+package osbuild
+
+import (
+	"github.com/osbuild/image-builder/pkg/customizations/systemd"
+)
+
+func envFromCustomization(env []systemd.EnvironmentVariable) []EnvironmentVariable {
+	var vars []EnvironmentVariable
+	for _, v := range env {
+		vars = append(vars, EnvironmentVariable{Key: v.Key, Value: v.Value})
+	}
+	return vars
+}
+
+// GenSystemdUnitCreateStageOptions creates the options for the stages that
+// write the units to /etc/systemd/system
+func GenSystemdUnitCreateStageOptions(units []systemd.Unit) []*SystemdUnitCreateStageOptions {
+	var options []*SystemdUnitCreateStageOptions
+	for _, u := range units {
+		config := SystemdUnit{
+			Unit: &UnitSection{},
+		}
+		if s := u.Unit; s != nil {
+			config.Unit = &UnitSection{
+				Description:         s.Description,
+				ConditionPathExists: s.ConditionPathExists,
+				Requires:            s.Requires,
+				Wants:               s.Wants,
+				After:               s.After,
+				Before:              s.Before,
+			}
+		}
+		if s := u.Service; s != nil {
+			config.Service = &ServiceSection{
+				Type:            SystemdServiceType(s.Type),
+				RemainAfterExit: s.RemainAfterExit,
+				ExecStartPre:    s.ExecStartPre,
+				ExecStopPost:    s.ExecStopPost,
+				ExecStart:       s.ExecStart,
+				Environment:     envFromCustomization(s.Environment),
+				EnvironmentFile: s.EnvironmentFile,
+				StandardOutput:  s.StandardOutput,
+			}
+		}
+		if s := u.Timer; s != nil {
+			config.Timer = &TimerSection{
+				OnActiveSec:        s.OnActiveSec,
+				OnBootSec:          s.OnBootSec,
+				OnStartupSec:       s.OnStartupSec,
+				OnUnitActiveSec:    s.OnUnitActiveSec,
+				OnUnitInactiveSec:  s.OnUnitInactiveSec,
+				OnCalendar:         s.OnCalendar,
+				AccuracySec:        s.AccuracySec,
+				RandomizedDelaySec: s.RandomizedDelaySec,
+				Persistent:         s.Persistent,
+				Unit:               s.Unit,
+			}
+		}
+		if s := u.Path; s != nil {
+			config.Path = &PathSection{
+				PathExists:        s.PathExists,
+				PathExistsGlob:    s.PathExistsGlob,
+				PathChanged:       s.PathChanged,
+				PathModified:      s.PathModified,
+				DirectoryNotEmpty: s.DirectoryNotEmpty,
+				MakeDirectory:     s.MakeDirectory,
+				Unit:              s.Unit,
+			}
+		}
+		if s := u.Mount; s != nil {
+			config.Mount = &MountSection{
+				What:    s.What,
+				Where:   s.Where,
+				Type:    s.Type,
+				Options: s.Options,
+			}
+		}
+		if s := u.Install; s != nil {
+			config.Install = &InstallSection{
+				WantedBy:   s.WantedBy,
+				RequiredBy: s.RequiredBy,
+			}
+		}
+
+		options = append(options, &SystemdUnitCreateStageOptions{
+			Filename: u.Name,
+			UnitType: SystemUnitType,
+			UnitPath: EtcUnitPath,
+			Config:   config,
+		})
+	}
+	return options
+}
+
+// GenSystemdUnitStageOptions creates the options for the stages that write
+// the drop-ins of service units
+func GenSystemdUnitStageOptions(dropIns []systemd.DropIn) []*SystemdUnitStageOptions {
+	var options []*SystemdUnitStageOptions
+	for _, d := range dropIns {
+		config := SystemdServiceUnitDropin{}
+		if len(d.Environment) > 0 || len(d.EnvironmentFile) > 0 {
+			config.Service = &SystemdUnitServiceSection{
+				Environment:     envFromCustomization(d.Environment),
+				EnvironmentFile: d.EnvironmentFile,
+			}
+		}
+		if d.ConditionPathExists != "" {
+			config.Unit = &SystemdUnitSection{
+				FileExists: d.ConditionPathExists,
+			}
+		}
+		options = append(options, &SystemdUnitStageOptions{
+			Unit:     d.Unit,
+			Dropin:   d.Name,
+			UnitType: SystemUnitType,
+			Config:   config,
+		})
+	}
+	return options
+}
+
+// customizationsDropinFilename is the name of the journald and logind
+// drop-ins with the settings from the image options, it is ordered after
+// the drop-ins from the distro definitions
+const customizationsDropinFilename = "50-customizations.conf"
+
+// GenSystemdJournaldStageOptions creates the options for the journald
+// drop-in stage
+func GenSystemdJournaldStageOptions(j *systemd.Journald) *SystemdJournaldStageOptions {
+	return &SystemdJournaldStageOptions{
+		Filename: customizationsDropinFilename,
+		Config: SystemdJournaldConfigDropin{
+			Journal: SystemdJournaldConfigJournalSection{
+				Storage:           ConfigStorage(j.Storage),
+				MaxRetentionSec:   j.MaxRetentionSec,
+				SystemMaxUse:      j.SystemMaxUse,
+				SystemKeepFree:    j.SystemKeepFree,
+				SystemMaxFileSize: j.SystemMaxFileSize,
+				RuntimeMaxUse:     j.RuntimeMaxUse,
+				ForwardToSyslog:   j.ForwardToSyslog,
+			},
+		},
+	}
+}
+
+// GenSystemdLogindStageOptions creates the options for the logind drop-in
+// stage
+func GenSystemdLogindStageOptions(l *systemd.Logind) *SystemdLogindStageOptions {
+	return &SystemdLogindStageOptions{
+		Filename: customizationsDropinFilename,
+		Config: SystemdLogindConfigDropin{
+			Login: SystemdLogindConfigLoginSection{
+				KillUserProcesses:  l.KillUserProcesses,
+				IdleAction:         l.IdleAction,
+				IdleActionSec:      l.IdleActionSec,
+				StopIdleSessionSec: l.StopIdleSessionSec,
+			},
+		},
+	}
+}
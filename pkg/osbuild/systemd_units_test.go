@@ -0,0 +1,132 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/systemd"
+)
+
+func TestGenSystemdUnitCreateStageOptions(t *testing.T) {
+	units := []systemd.Unit{
+		{
+			Name: "cleanup.service",
+			Unit: &systemd.UnitSection{Description: "Clean up the spool", After: []string{"network-online.target"}},
+			Service: &systemd.ServiceSection{
+				Type:        "oneshot",
+				ExecStart:   []string{"/usr/local/bin/cleanup"},
+				Environment: []systemd.EnvironmentVariable{{Key: "SPOOL", Value: "/var/spool/in"}},
+			},
+			Install: &systemd.InstallSection{WantedBy: []string{"multi-user.target"}},
+		},
+		{
+			Name:    "cleanup.timer",
+			Enabled: true,
+			Timer:   &systemd.TimerSection{OnCalendar: []string{"daily"}, Persistent: true},
+			Install: &systemd.InstallSection{WantedBy: []string{"timers.target"}},
+		},
+	}
+
+	options := GenSystemdUnitCreateStageOptions(units)
+	require.Len(t, options, 2)
+	assert.Equal(t, &SystemdUnitCreateStageOptions{
+		Filename: "cleanup.service",
+		UnitType: SystemUnitType,
+		UnitPath: EtcUnitPath,
+		Config: SystemdUnit{
+			Unit: &UnitSection{Description: "Clean up the spool", After: []string{"network-online.target"}},
+			Service: &ServiceSection{
+				Type:        OneshotServiceType,
+				ExecStart:   []string{"/usr/local/bin/cleanup"},
+				Environment: []EnvironmentVariable{{Key: "SPOOL", Value: "/var/spool/in"}},
+			},
+			Install: &InstallSection{WantedBy: []string{"multi-user.target"}},
+		},
+	}, options[0])
+	assert.Equal(t, &SystemdUnitCreateStageOptions{
+		Filename: "cleanup.timer",
+		UnitType: SystemUnitType,
+		UnitPath: EtcUnitPath,
+		Config: SystemdUnit{
+			Unit:    &UnitSection{},
+			Timer:   &TimerSection{OnCalendar: []string{"daily"}, Persistent: true},
+			Install: &InstallSection{WantedBy: []string{"timers.target"}},
+		},
+	}, options[1])
+
+	for _, o := range options {
+		assert.NotPanics(t, func() { NewSystemdUnitCreateStage(o) })
+	}
+}
+
+func TestGenSystemdUnitStageOptions(t *testing.T) {
+	dropIns := []systemd.DropIn{
+		{
+			Unit:        "httpd.service",
+			Name:        "10-env.conf",
+			Environment: []systemd.EnvironmentVariable{{Key: "OPTIONS", Value: "-DFOREGROUND"}},
+		},
+		{
+			Unit:                "cleanup.service",
+			Name:                "10-condition.conf",
+			ConditionPathExists: "/etc/cleanup.conf",
+		},
+	}
+
+	assert.Equal(t, []*SystemdUnitStageOptions{
+		{
+			Unit:     "httpd.service",
+			Dropin:   "10-env.conf",
+			UnitType: SystemUnitType,
+			Config: SystemdServiceUnitDropin{
+				Service: &SystemdUnitServiceSection{
+					Environment: []EnvironmentVariable{{Key: "OPTIONS", Value: "-DFOREGROUND"}},
+				},
+			},
+		},
+		{
+			Unit:     "cleanup.service",
+			Dropin:   "10-condition.conf",
+			UnitType: SystemUnitType,
+			Config: SystemdServiceUnitDropin{
+				Unit: &SystemdUnitSection{FileExists: "/etc/cleanup.conf"},
+			},
+		},
+	}, GenSystemdUnitStageOptions(dropIns))
+}
+
+func TestGenSystemdJournaldLogindStageOptions(t *testing.T) {
+	journald := GenSystemdJournaldStageOptions(&systemd.Journald{
+		Storage:         "persistent",
+		SystemMaxUse:    "1G",
+		ForwardToSyslog: common.ToPtr(true),
+	})
+	assert.Equal(t, &SystemdJournaldStageOptions{
+		Filename: "50-customizations.conf",
+		Config: SystemdJournaldConfigDropin{
+			Journal: SystemdJournaldConfigJournalSection{
+				Storage:         StoragePresistent,
+				SystemMaxUse:    "1G",
+				ForwardToSyslog: common.ToPtr(true),
+			},
+		},
+	}, journald)
+	assert.NotPanics(t, func() { NewSystemdJournaldStage(journald) })
+
+	logind := GenSystemdLogindStageOptions(&systemd.Logind{
+		IdleAction:    "lock",
+		IdleActionSec: "15min",
+	})
+	assert.Equal(t, &SystemdLogindStageOptions{
+		Filename: "50-customizations.conf",
+		Config: SystemdLogindConfigDropin{
+			Login: SystemdLogindConfigLoginSection{
+				IdleAction:    "lock",
+				IdleActionSec: "15min",
+			},
+		},
+	}, logind)
+}
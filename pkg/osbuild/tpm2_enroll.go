@@ -0,0 +1,150 @@
+package osbuild
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+	"github.com/osbuild/image-builder/pkg/disk"
+)
+
+const tpm2EnrollKeyDir = "/etc/cryptenroll"
+
+func tpm2EnrollKeyFile(lc *disk.LUKSContainer) string {
+	return filepath.Join(tpm2EnrollKeyDir, deviceName(lc)+".key")
+}
+
+// GenTPM2EnrollFromPartitionTable returns the directory nodes and the systemd
+// units needed to enroll the TPM2 of the machine on first boot, for every
+// LUKS container of the partition table that requests it.
+//
+// The units unlock the container with the enrollment key generated by the
+// stages of GenTPM2EnrollKeyStages and remove the key slot and the key file
+// afterwards, so the key is only valid until first boot.
+func GenTPM2EnrollFromPartitionTable(pt *disk.PartitionTable) ([]*fsnode.Directory, []*SystemdUnitCreateStageOptions, error) {
+	if pt == nil {
+		return nil, nil, nil
+	}
+
+	var units []*SystemdUnitCreateStageOptions
+
+	genUnits := func(e disk.Entity, path []disk.Entity) error {
+		lc, ok := e.(*disk.LUKSContainer)
+		if !ok || lc.TPM2 == nil {
+			return nil
+		}
+		if lc.UUID == "" {
+			return fmt.Errorf("cannot enroll TPM2 for LUKS container without UUID")
+		}
+
+		name := deviceName(lc)
+		keyFile := tpm2EnrollKeyFile(lc)
+
+		pcrs := make([]string, 0, len(lc.TPM2.GetPCRs()))
+		for _, pcr := range lc.TPM2.GetPCRs() {
+			pcrs = append(pcrs, strconv.FormatUint(uint64(pcr), 10))
+		}
+		device := "/dev/disk/by-uuid/" + lc.UUID
+		cryptenroll := "/usr/bin/systemd-cryptenroll --unlock-key-file=" + keyFile
+
+		execStart := []string{
+			fmt.Sprintf("%s --tpm2-device=auto --tpm2-pcrs=%s %s", cryptenroll, strings.Join(pcrs, "+"), device),
+		}
+		if lc.TPM2.RemovePassphrase {
+			// the enrollment key is a password slot too, so this wipes
+			// both the build-time passphrase and the enrollment key
+			execStart = append(execStart, fmt.Sprintf("%s --wipe-slot=password %s", cryptenroll, device))
+		} else {
+			execStart = append(execStart, fmt.Sprintf("/usr/sbin/cryptsetup luksRemoveKey %s %s", device, keyFile))
+		}
+		execStart = append(execStart, "/usr/bin/rm "+keyFile)
+
+		units = append(units, &SystemdUnitCreateStageOptions{
+			Filename: fmt.Sprintf("tpm2-enroll-%s.service", name),
+			UnitType: SystemUnitType,
+			UnitPath: UsrUnitPath,
+			Config: SystemdUnit{
+				Unit: &UnitSection{
+					Description:         fmt.Sprintf("Enroll TPM2 for LUKS device %s", lc.UUID),
+					ConditionPathExists: []string{keyFile},
+					After:               []string{"cryptsetup.target", "local-fs.target"},
+				},
+				Service: &ServiceSection{
+					Type:            OneshotServiceType,
+					ExecStart:       execStart,
+					RemainAfterExit: true,
+				},
+				Install: &InstallSection{
+					WantedBy: []string{"basic.target"},
+				},
+			},
+		})
+		return nil
+	}
+	if err := pt.ForEachEntity(genUnits); err != nil {
+		return nil, nil, err
+	}
+
+	var dirs []*fsnode.Directory
+	if len(units) > 0 {
+		d, err := fsnode.NewDirectory(tpm2EnrollKeyDir, common.ToPtr(fs.FileMode(0700)), "root", "root", true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating TPM2 enrollment key directory node: %w", err)
+		}
+		dirs = append(dirs, d)
+	}
+
+	return dirs, units, nil
+}
+
+// GenTPM2EnrollKeyStages returns the stages that generate a random enrollment
+// key for every LUKS container of the partition table that enrolls the TPM2 on
+// first boot. The key is generated while building the image and written into
+// the root filesystem, it never appears in the manifest. The stages need to
+// run after the tree has been copied into the image.
+func GenTPM2EnrollKeyStages(pt *disk.PartitionTable, filename string) ([]*Stage, error) {
+	var stages []*Stage
+
+	genStages := func(e disk.Entity, path []disk.Entity) error {
+		lc, ok := e.(*disk.LUKSContainer)
+		if !ok || lc.TPM2 == nil {
+			return nil
+		}
+
+		fsRootMntName, mounts, devices, err := GenMountsDevicesFromPT(filename, pt)
+		if err != nil {
+			return err
+		}
+
+		// the stage expects the container in "device", which is the
+		// loopback device named after the container
+		name := deviceName(lc)
+		container, ok := devices[name]
+		if !ok {
+			return fmt.Errorf("no device found for LUKS container %q", name)
+		}
+		delete(devices, name)
+		devices["device"] = container
+		for devName, dev := range devices {
+			if dev.Parent == name {
+				dev.Parent = "device"
+				devices[devName] = dev
+			}
+		}
+
+		stages = append(stages, NewSystemdCryptenrollStage(&SystemdCryptenrollStageOptions{
+			Passphrase: lc.Passphrase,
+			KeyFile:    fmt.Sprintf("mount://%s%s", fsRootMntName, tpm2EnrollKeyFile(lc)),
+		}, devices, mounts))
+		return nil
+	}
+	if err := pt.ForEachEntity(genStages); err != nil {
+		return nil, err
+	}
+
+	return stages, nil
+}
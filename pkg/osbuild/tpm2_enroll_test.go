@@ -0,0 +1,125 @@
+package osbuild
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	"github.com/osbuild/image-builder/pkg/arch"
+	"github.com/osbuild/image-builder/pkg/datasizes"
+	"github.com/osbuild/image-builder/pkg/disk"
+	"github.com/osbuild/image-builder/pkg/disk/partition"
+)
+
+func newTPM2TestPartitionTable(t *testing.T, tpm2 *disk.TPM2Enroll) (*disk.PartitionTable, *disk.LUKSContainer) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(13))
+
+	luksLVM := testPartitionTables["luks+lvm"]
+	pt, err := disk.NewPartitionTable(&luksLVM, []blueprint.FilesystemCustomization{}, 0, partition.AutoLVMPartitioningMode, arch.ARCH_X86_64, make(map[string]datasizes.Size), "", rng)
+	require.NoError(t, err)
+
+	var luks *disk.LUKSContainer
+	_ = pt.ForEachEntity(func(e disk.Entity, path []disk.Entity) error {
+		if lc, ok := e.(*disk.LUKSContainer); ok {
+			lc.TPM2 = tpm2
+			luks = lc
+		}
+		return nil
+	})
+	require.NotNil(t, luks)
+	return pt, luks
+}
+
+func TestGenTPM2EnrollFromPartitionTableNone(t *testing.T) {
+	pt, _ := newTPM2TestPartitionTable(t, nil)
+
+	dirs, units, err := GenTPM2EnrollFromPartitionTable(pt)
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Empty(t, units)
+
+	stages, err := GenTPM2EnrollKeyStages(pt, "image.raw")
+	require.NoError(t, err)
+	assert.Empty(t, stages)
+}
+
+func TestGenTPM2EnrollFromPartitionTable(t *testing.T) {
+	pt, luks := newTPM2TestPartitionTable(t, &disk.TPM2Enroll{
+		PCRs:             []uint{0, 7},
+		RemovePassphrase: true,
+	})
+
+	dirs, units, err := GenTPM2EnrollFromPartitionTable(pt)
+	require.NoError(t, err)
+
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "/etc/cryptenroll", dirs[0].Path())
+	assert.Equal(t, os.FileMode(0700), *dirs[0].Mode())
+
+	keyFile := "/etc/cryptenroll/luks-" + luks.UUID[:4] + ".key"
+
+	require.Len(t, units, 1)
+	unit := units[0]
+	assert.Equal(t, "tpm2-enroll-luks-"+luks.UUID[:4]+".service", unit.Filename)
+	assert.Equal(t, []string{keyFile}, unit.Config.Unit.ConditionPathExists)
+	device := "/dev/disk/by-uuid/" + luks.UUID
+	assert.Equal(t, []string{
+		"/usr/bin/systemd-cryptenroll --unlock-key-file=" + keyFile + " --tpm2-device=auto --tpm2-pcrs=0+7 " + device,
+		"/usr/bin/systemd-cryptenroll --unlock-key-file=" + keyFile + " --wipe-slot=password " + device,
+		"/usr/bin/rm " + keyFile,
+	}, unit.Config.Service.ExecStart)
+}
+
+func TestGenTPM2EnrollFromPartitionTableDefaultPCRs(t *testing.T) {
+	pt, luks := newTPM2TestPartitionTable(t, &disk.TPM2Enroll{})
+
+	_, units, err := GenTPM2EnrollFromPartitionTable(pt)
+	require.NoError(t, err)
+
+	keyFile := "/etc/cryptenroll/luks-" + luks.UUID[:4] + ".key"
+	device := "/dev/disk/by-uuid/" + luks.UUID
+	require.Len(t, units, 1)
+	assert.Equal(t, []string{
+		"/usr/bin/systemd-cryptenroll --unlock-key-file=" + keyFile + " --tpm2-device=auto --tpm2-pcrs=7 " + device,
+		"/usr/sbin/cryptsetup luksRemoveKey " + device + " " + keyFile,
+		"/usr/bin/rm " + keyFile,
+	}, units[0].Config.Service.ExecStart)
+}
+
+func TestGenTPM2EnrollKeyStages(t *testing.T) {
+	pt, luks := newTPM2TestPartitionTable(t, &disk.TPM2Enroll{})
+
+	stages, err := GenTPM2EnrollKeyStages(pt, "image.raw")
+	require.NoError(t, err)
+	require.Len(t, stages, 1)
+
+	stage := stages[0]
+	assert.Equal(t, "org.osbuild.systemd-cryptenroll", stage.Type)
+	// the key is generated by the stage, the manifest only has its location
+	assert.Equal(t, &SystemdCryptenrollStageOptions{
+		Passphrase: "osbuild",
+		KeyFile:    "mount://-/etc/cryptenroll/luks-" + luks.UUID[:4] + ".key",
+	}, stage.Options)
+
+	// the loopback device of the container is passed as "device" and the
+	// opened container refers to it
+	name := "luks-" + luks.UUID[:4]
+	require.Contains(t, stage.Devices, "device")
+	assert.NotContains(t, stage.Devices, name)
+	assert.Equal(t, "org.osbuild.loopback", stage.Devices["device"].Type)
+	var children int
+	for _, dev := range stage.Devices {
+		if dev.Type == "org.osbuild.luks2" {
+			assert.Equal(t, "device", dev.Parent)
+			children++
+		}
+	}
+	assert.Equal(t, 1, children)
+	assert.NotEmpty(t, stage.Mounts)
+}
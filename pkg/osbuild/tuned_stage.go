@@ -3,8 +3,13 @@ package osbuild
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 )
 
+// tunedProfileRegex matches the names of TuneD profiles, which are the
+// names of the directories in /usr/lib/tuned and /etc/tuned
+const tunedProfileRegex = `^[\w.+-]{1,250}$`
+
 // TunedStageOptions represents manually set TuneD profiles.
 type TunedStageOptions struct {
 	// List of TuneD profiles to apply.
@@ -27,6 +32,12 @@ func (o TunedStageOptions) MarshalJSON() ([]byte, error) {
 	if len(o.Profiles) == 0 {
 		return nil, fmt.Errorf("at least one Profile must be provided")
 	}
+	nameRegex := regexp.MustCompile(tunedProfileRegex)
+	for _, profile := range o.Profiles {
+		if !nameRegex.MatchString(profile) {
+			return nil, fmt.Errorf("TuneD profile name %q doesn't conform to schema (%s)", profile, nameRegex.String())
+		}
+	}
 	options := tunedStageOptions(o)
 	return json.Marshal(options)
 }
@@ -49,6 +49,14 @@ func TestTunedStageOptions_MarshalJSON_Invalid(t *testing.T) {
 			name:    "empty-options",
 			options: TunedStageOptions{},
 		},
+		{
+			name:    "profile-with-path",
+			options: TunedStageOptions{Profiles: []string{"../balanced"}},
+		},
+		{
+			name:    "profile-with-whitespace",
+			options: TunedStageOptions{Profiles: []string{"balanced powersave"}},
+		},
 	}
 	for idx, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
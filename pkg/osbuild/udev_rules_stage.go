@@ -6,100 +6,9 @@ import (
 	"regexp"
 
 	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/udev"
 )
 
-type udevOpType int
-
-const (
-	udevOpMatch  udevOpType = 0
-	udevOpAssign udevOpType = 1
-)
-
-var ops = map[string]udevOpType{
-	"=":  udevOpAssign,
-	"+=": udevOpAssign,
-	"-=": udevOpAssign,
-	":=": udevOpAssign,
-	"==": udevOpMatch,
-	"!=": udevOpMatch,
-}
-
-type udevKeyType struct {
-	Arg    bool
-	Assign bool
-	Match  bool
-}
-
-var keys = map[string]udevKeyType{
-	"ACTION":     {Match: true},
-	"DEVPATH":    {Match: true},
-	"KERNEL":     {Match: true},
-	"KERNELS":    {Match: true},
-	"NAME":       {Match: true, Assign: true},
-	"SYMLINK":    {Match: true, Assign: true},
-	"SUBSYSTEM":  {Match: true},
-	"SUBSYSTEMS": {Match: true},
-	"DRIVER":     {Match: true},
-	"DRIVERS":    {Match: true},
-	"TAG":        {Match: true, Assign: true},
-	"TAGS":       {Match: true},
-	"PROGRAM":    {Match: true},
-	"RESULT":     {Match: true},
-
-	"ATTR":   {Arg: true, Match: true, Assign: true},
-	"ATTRS":  {Arg: true, Match: true},
-	"SYSCTL": {Arg: true, Match: true, Assign: true},
-	"ENV":    {Arg: true, Match: true, Assign: true},
-	"CONST":  {Arg: true, Match: true},
-	"TEST":   {Arg: true, Match: true},
-
-	"OWNER":   {Assign: true},
-	"GROUP":   {Assign: true},
-	"MODE":    {Assign: true},
-	"LABEL":   {Assign: true},
-	"GOTO":    {Assign: true},
-	"OPTIONS": {Assign: true},
-
-	"SECLABEL": {Arg: true, Assign: true},
-	"RUN":      {Arg: true, Assign: true},
-	"IMPORT":   {Arg: true, Assign: true},
-}
-
-func udevOpValidate(key, op, val, arg string) error {
-	if key == "" {
-		return fmt.Errorf("key is required")
-	}
-	if op == "" {
-		return fmt.Errorf("operator is required")
-	}
-	if val == "" {
-		return fmt.Errorf("value is required")
-	}
-
-	keyInfo, ok := keys[key]
-
-	if !ok {
-		return fmt.Errorf("key '%s' is unknown", key)
-	}
-
-	if keyInfo.Arg && arg == "" {
-		return fmt.Errorf("arg is required for key '%s'", key)
-	}
-
-	opType, ok := ops[op]
-
-	if !ok {
-		return fmt.Errorf("'%s' operator is not supported", op)
-	}
-
-	if (opType == udevOpMatch && !keyInfo.Match) ||
-		(opType == udevOpAssign && !keyInfo.Assign) {
-		return fmt.Errorf("key '%s' does not support '%s'", key, op)
-	}
-
-	return nil
-}
-
 type UdevRulesStageOptions struct {
 	Filename string    `json:"filename"`
 	Rules    UdevRules `json:"rules"`
@@ -229,7 +138,7 @@ type UdevOpSimple struct {
 }
 
 func (o UdevOpSimple) validate() error {
-	err := udevOpValidate(o.Key, o.Op, o.Value, "")
+	err := udev.ValidateOp(o.Key, o.Op, o.Value, "")
 	if err != nil {
 		err = fmt.Errorf("invalid op: %v", err)
 	}
@@ -248,7 +157,7 @@ type UdevOpArg struct {
 func (UdevOpArg) isUdevOp() {}
 
 func (o UdevOpArg) validate() error {
-	err := udevOpValidate(o.Key.Name, o.Op, o.Value, o.Key.Arg)
+	err := udev.ValidateOp(o.Key.Name, o.Op, o.Value, o.Key.Arg)
 	if err != nil {
 		err = fmt.Errorf("invalid op: %v", err)
 	}
@@ -302,3 +211,24 @@ func NewUdevRule(ops []UdevKV) UdevRule {
 
 	return res
 }
+
+// GenUdevRulesStageOptions creates the options for the udev rules stages of
+// the given rules files
+func GenUdevRulesStageOptions(o *udev.Options) []*UdevRulesStageOptions {
+	var stageOptions []*UdevRulesStageOptions
+	for _, f := range o.Files {
+		rules := make(UdevRules, 0, len(f.Rules))
+		for _, rule := range f.Rules {
+			kvs := make([]UdevKV, 0, len(rule))
+			for _, op := range rule {
+				kvs = append(kvs, UdevKV{K: op.Key, A: op.Arg, O: op.Op, V: op.Value})
+			}
+			rules = append(rules, NewUdevRule(kvs))
+		}
+		stageOptions = append(stageOptions, &UdevRulesStageOptions{
+			Filename: f.Path(),
+			Rules:    rules,
+		})
+	}
+	return stageOptions
+}
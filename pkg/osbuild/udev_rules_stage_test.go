@@ -7,6 +7,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/udev"
 )
 
 func TestNewUdevRulesStage(t *testing.T) {
@@ -246,3 +248,27 @@ rules:
 	}
 	assert.Equal(t, expected, options)
 }
+
+func TestGenUdevRulesStageOptions(t *testing.T) {
+	options := GenUdevRulesStageOptions(&udev.Options{
+		Files: []udev.File{{
+			Name: "70-mtu",
+			Rules: []udev.Rule{{
+				{Key: "SUBSYSTEM", Op: "==", Value: "net"},
+				{Key: "ATTR", Arg: "mtu", Op: "=", Value: "9000"},
+			}},
+		}},
+	})
+	require.Len(t, options, 1)
+	assert.Equal(t, &UdevRulesStageOptions{
+		Filename: "/etc/udev/rules.d/70-mtu.rules",
+		Rules: UdevRules{
+			NewUdevRule([]UdevKV{
+				{K: "SUBSYSTEM", O: "==", V: "net"},
+				{K: "ATTR", A: "mtu", O: "=", V: "9000"},
+			}),
+		},
+	}, options[0])
+	// the options must pass the stage validation
+	assert.NotPanics(t, func() { NewUdevRulesStage(options[0]) })
+}
@@ -1,14 +1,74 @@
 package osbuild
 
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+
+	"github.com/osbuild/image-builder/internal/common"
+	"github.com/osbuild/image-builder/pkg/customizations/cryptopolicy"
+	"github.com/osbuild/image-builder/pkg/customizations/fsnode"
+)
+
+const cryptoPolicyModulesDir = "/etc/crypto-policies/policies/modules"
+
+var cryptoPolicyRegex = regexp.MustCompile(`^[A-Z0-9_-]+(:[A-Z0-9_-]+)*$`)
+
 type UpdateCryptoPoliciesStageOptions struct {
 	Policy string `json:"policy"`
 }
 
 func (UpdateCryptoPoliciesStageOptions) isStageOptions() {}
 
+func (o UpdateCryptoPoliciesStageOptions) validate() error {
+	if !cryptoPolicyRegex.MatchString(o.Policy) {
+		return fmt.Errorf("crypto policy %q doesn't conform to schema (%s)", o.Policy, cryptoPolicyRegex.String())
+	}
+	return nil
+}
+
 func NewUpdateCryptoPoliciesStage(options *UpdateCryptoPoliciesStageOptions) *Stage {
+	if err := options.validate(); err != nil {
+		panic(err)
+	}
+
 	return &Stage{
 		Type:    "org.osbuild.update-crypto-policies",
 		Options: options,
 	}
 }
+
+// GenCryptoPolicyFromOptions returns the directory and file nodes for the
+// custom subpolicy modules and the options of the stage that applies the
+// policy. The modules need to be written before the stage runs.
+func GenCryptoPolicyFromOptions(options *cryptopolicy.Options) ([]*fsnode.Directory, []*fsnode.File, *UpdateCryptoPoliciesStageOptions, error) {
+	if err := options.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	stageOptions := &UpdateCryptoPoliciesStageOptions{
+		Policy: options.Policy,
+	}
+	if len(options.Modules) == 0 {
+		return nil, nil, stageOptions, nil
+	}
+
+	var files []*fsnode.File
+	for _, m := range options.Modules {
+		path := filepath.Join(cryptoPolicyModulesDir, m.Name+".pmod")
+		f, err := fsnode.NewFile(path, common.ToPtr(fs.FileMode(0644)), "root", "root", []byte(m.Contents))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error creating crypto policy module node %q: %w", path, err)
+		}
+		files = append(files, f)
+	}
+
+	// the directory is part of the crypto-policies package, only make sure
+	// it exists without changing it
+	d, err := fsnode.NewDirectory(cryptoPolicyModulesDir, nil, nil, nil, true)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error creating crypto policy modules directory node: %w", err)
+	}
+	return []*fsnode.Directory{d}, files, stageOptions, nil
+}
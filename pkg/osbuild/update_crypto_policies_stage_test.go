@@ -0,0 +1,58 @@
+package osbuild
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/customizations/cryptopolicy"
+)
+
+func TestNewUpdateCryptoPoliciesStage(t *testing.T) {
+	options := &UpdateCryptoPoliciesStageOptions{Policy: "FIPS:OSPP"}
+	expectedStage := &Stage{
+		Type:    "org.osbuild.update-crypto-policies",
+		Options: options,
+	}
+	assert.Equal(t, expectedStage, NewUpdateCryptoPoliciesStage(options))
+
+	assert.PanicsWithError(t, `crypto policy "fips" doesn't conform to schema (^[A-Z0-9_-]+(:[A-Z0-9_-]+)*$)`, func() {
+		NewUpdateCryptoPoliciesStage(&UpdateCryptoPoliciesStageOptions{Policy: "fips"})
+	})
+}
+
+func TestGenCryptoPolicyFromOptions(t *testing.T) {
+	dirs, files, stageOptions, err := GenCryptoPolicyFromOptions(&cryptopolicy.Options{
+		Policy: "DEFAULT:NO-CBC",
+		Modules: []cryptopolicy.Module{
+			{Name: "NO-CBC", Contents: "cipher = -*-CBC\n"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "/etc/crypto-policies/policies/modules", dirs[0].Path())
+	assert.Nil(t, dirs[0].Mode())
+
+	require.Len(t, files, 1)
+	assert.Equal(t, "/etc/crypto-policies/policies/modules/NO-CBC.pmod", files[0].Path())
+	assert.Equal(t, []byte("cipher = -*-CBC\n"), files[0].Data())
+	assert.Equal(t, os.FileMode(0644), *files[0].Mode())
+
+	assert.Equal(t, &UpdateCryptoPoliciesStageOptions{Policy: "DEFAULT:NO-CBC"}, stageOptions)
+}
+
+func TestGenCryptoPolicyFromOptionsNoModules(t *testing.T) {
+	dirs, files, stageOptions, err := GenCryptoPolicyFromOptions(&cryptopolicy.Options{Policy: "FUTURE"})
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	assert.Empty(t, files)
+	assert.Equal(t, &UpdateCryptoPoliciesStageOptions{Policy: "FUTURE"}, stageOptions)
+}
+
+func TestGenCryptoPolicyFromOptionsInvalid(t *testing.T) {
+	_, _, _, err := GenCryptoPolicyFromOptions(&cryptopolicy.Options{Policy: "future"})
+	assert.EqualError(t, err, `invalid crypto policy "future" (must match ^[A-Z0-9_-]+(:[A-Z0-9_-]+)*$)`)
+}
@@ -6,6 +6,7 @@ import (
 
 type YumConfigConfig struct {
 	HttpCaching *string `json:"http_caching,omitempty" yaml:"http_caching,omitempty"`
+	Proxy       *string `json:"proxy,omitempty" yaml:"proxy,omitempty"`
 }
 
 type YumConfigPlugins struct {
@@ -3,6 +3,11 @@ package osbuild
 type ZstdStageOptions struct {
 	// Filename for zstd archive
 	Filename string `json:"filename"`
+
+	// Write the archive in the zstd seekable format, which splits the
+	// data into independently compressed frames and adds a seek table so
+	// that it can be read at random offsets without decompressing all of it
+	Seekable bool `json:"seekable,omitempty"`
 }
 
 func (ZstdStageOptions) isStageOptions() {}
@@ -22,6 +22,7 @@ const ( // image format enum
 	FORMAT_OVA
 	FORMAT_VAGRANT_LIBVIRT
 	FORMAT_VAGRANT_VIRTUALBOX
+	FORMAT_VHDX
 )
 
 type Bootloader int
@@ -108,6 +109,8 @@ func (f ImageFormat) String() string {
 		return "vagrant_libvirt"
 	case FORMAT_VAGRANT_VIRTUALBOX:
 		return "vagrant_virtualbox"
+	case FORMAT_VHDX:
+		return "vhdx"
 	default:
 		panic(fmt.Errorf("unknown image format %d", f))
 	}
@@ -139,6 +142,8 @@ func (f *ImageFormat) UnmarshalJSON(data []byte) error {
 		*f = FORMAT_VAGRANT_LIBVIRT
 	case "vagrant_virtualbox":
 		*f = FORMAT_VAGRANT_VIRTUALBOX
+	case "vhdx":
+		*f = FORMAT_VHDX
 	default:
 		panic(fmt.Errorf("unknown image format %q", s))
 	}
@@ -64,6 +64,7 @@ func TestImageFormatUnmarshal(t *testing.T) {
 		platform.FORMAT_VHD,
 		platform.FORMAT_GCE,
 		platform.FORMAT_OVA,
+		platform.FORMAT_VHDX,
 	}
 	for _, ifmt := range ifmts {
 		inpJSON := fmt.Sprintf("%q", ifmt.String())
@@ -2,6 +2,7 @@ package progress
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/osbuild/image-builder/pkg/datasizes"
 	"github.com/osbuild/image-builder/pkg/osbuild"
@@ -25,14 +27,31 @@ type OSBuildOptions struct {
 
 	// BuildLog writes the osbuild output to the given writer
 	BuildLog io.Writer
+	// MonitorLog writes the raw osbuild monitor (JSONSeq) output
+	// to the given writer. Without a progress bar that uses the
+	// osbuild monitor the osbuild messages are then written by
+	// image-builder instead of the osbuild log monitor.
+	MonitorLog io.Writer
 
 	CacheMaxSize int64
 
 	Metrics bool
+	// MetricsReport writes a machine readable metrics report to
+	// the given path (JSON or CSV, see WriteMetricsReport())
+	MetricsReport string
 }
 
+// osbuildCancelWaitDelay is how long osbuild gets to clean up (e.g.
+// unmount the buildroot) after it was interrupted because the context
+// got cancelled, after that it is killed
+var osbuildCancelWaitDelay = 60 * time.Second
+
+// RunOSBuild runs osbuild for the given manifest. When the context is
+// cancelled osbuild gets interrupted (SIGINT) so that it can release
+// its resources and an error that wraps the context error is returned.
+//
 // XXX: merge variant back into images/pkg/osbuild/osbuild-exec.go
-func RunOSBuild(pb ProgressBar, manifest []byte, exports []string, opts *OSBuildOptions) error {
+func RunOSBuild(ctx context.Context, pb ProgressBar, manifest []byte, exports []string, opts *OSBuildOptions) error {
 	if opts == nil {
 		opts = &OSBuildOptions{}
 	}
@@ -45,20 +64,34 @@ func RunOSBuild(pb ProgressBar, manifest []byte, exports []string, opts *OSBuild
 	// checked with them we can remove the runOSBuildNoProgress() and
 	// just run with the new runOSBuildWithProgress() helper.
 	switch pb.(type) {
-	case *terminalProgressBar, *debugProgressBar, *fileProgressBar:
-		return runOSBuildWithProgress(pb, manifest, exports, opts)
+	case *terminalProgressBar, *debugProgressBar, *fileProgressBar, *jsonlProgressBar:
+		return runOSBuildWithProgress(ctx, pb, manifest, exports, opts)
 	default:
-		return runOSBuildNoProgress(pb, manifest, exports, opts)
+		// the metrics report needs the osbuild monitor output
+		if opts.MetricsReport != "" {
+			return runOSBuildWithProgress(ctx, pb, manifest, exports, opts)
+		}
+		return runOSBuildNoProgress(ctx, pb, manifest, exports, opts)
+	}
+}
+
+// cancelledErr returns the error for an osbuild run that was cancelled
+// via the context (or nil if the context is not cancelled)
+func cancelledErr(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
 	}
+	return fmt.Errorf("osbuild was cancelled: %w", context.Cause(ctx))
 }
 
-func newOsbuildCmd(manifest []byte, exports []string, opts *OSBuildOptions) *exec.Cmd {
+func newOsbuildCmd(ctx context.Context, manifest []byte, exports []string, opts *OSBuildOptions) *exec.Cmd {
 	cacheMaxSize := int64(20 * datasizes.GiB)
 	if opts.CacheMaxSize != 0 {
 		cacheMaxSize = opts.CacheMaxSize
 	}
 	// #nosec: G204
-	cmd := exec.Command(
+	cmd := exec.CommandContext(
+		ctx,
 		osbuildCmd,
 		"--store", opts.StoreDir,
 		"--output-directory", opts.OutputDir,
@@ -76,10 +109,16 @@ func newOsbuildCmd(manifest []byte, exports []string, opts *OSBuildOptions) *exe
 	}
 	cmd.Env = append(os.Environ(), opts.ExtraEnv...)
 	cmd.Stdin = bytes.NewBuffer(manifest)
+	// be gentle when cancelled, osbuild needs to release its
+	// resources (like mounts in the buildroot)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGINT)
+	}
+	cmd.WaitDelay = osbuildCancelWaitDelay
 	return cmd
 }
 
-func runOSBuildNoProgress(pb ProgressBar, manifest []byte, exports []string, opts *OSBuildOptions) error {
+func runOSBuildNoProgress(ctx context.Context, pb ProgressBar, manifest []byte, exports []string, opts *OSBuildOptions) error {
 	var stdout, stderr io.Writer
 
 	var writeMu sync.Mutex
@@ -108,18 +147,104 @@ func runOSBuildNoProgress(pb ProgressBar, manifest []byte, exports []string, opt
 		stderr = mw
 	}
 
-	cmd := newOsbuildCmd(manifest, exports, opts)
+	cmd := newOsbuildCmd(ctx, manifest, exports, opts)
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
-	if err := cmd.Run(); err != nil {
+
+	// To keep the monitor log (e.g. for diagnostics) osbuild has
+	// to use the JSONSeq monitor, this replaces the messages of
+	// the default log monitor so we write them ourselves, the
+	// same way as for the buildlog
+	var rp, wp *os.File
+	if opts.MonitorLog != nil {
+		var err error
+		rp, wp, err = os.Pipe()
+		if err != nil {
+			return fmt.Errorf("cannot create pipe for osbuild: %w", err)
+		}
+		defer rp.Close()
+		defer wp.Close()
+		cmd.Args = append(cmd.Args, "--monitor=JSONSeqMonitor", "--monitor-fd=3")
+		cmd.ExtraFiles = []*os.File{wp}
+		// with a buildlog stdout and stderr are synced already
+		if opts.BuildLog == nil {
+			stdout = newSyncedWriter(&writeMu, stdout)
+			cmd.Stdout = stdout
+			cmd.Stderr = newSyncedWriter(&writeMu, stderr)
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting osbuild: %w", err)
+	}
+	monitorDone := make(chan struct{})
+	if opts.MonitorLog != nil {
+		wp.Close()
+		go func() {
+			defer close(monitorDone)
+			writeMonitorMessages(io.TeeReader(rp, opts.MonitorLog), stdout)
+		}()
+	} else {
+		close(monitorDone)
+	}
+	err := cmd.Wait()
+	<-monitorDone
+	if err != nil {
+		if cErr := cancelledErr(ctx); cErr != nil {
+			return cErr
+		}
 		return fmt.Errorf("error running osbuild: %w", err)
 	}
 	return nil
 }
 
+// writeMonitorMessages writes the messages and traces of the given
+// osbuild monitor output to w until the monitor output is closed
+func writeMonitorMessages(monitor io.Reader, w io.Writer) {
+	osbuildStatus := osbuild.NewStatusScanner(monitor)
+	for {
+		st, err := osbuildStatus.Status()
+		if err != nil {
+			// keep reading so that osbuild is not blocked
+			fmt.Fprintf(w, "WARNING: cannot parse osbuild status: %v\n", err)
+			_, _ = io.Copy(io.Discard, monitor)
+			return
+		}
+		if st == nil {
+			return
+		}
+		if st.Message != "" {
+			fmt.Fprintln(w, st.Message)
+		}
+		if st.Trace != "" {
+			fmt.Fprintln(w, st.Trace)
+		}
+	}
+}
+
 var osbuildCmd = "osbuild"
 
-func runOSBuildWithProgress(pb ProgressBar, manifest []byte, exports []string, opts *OSBuildOptions) (err error) {
+// OSBuildError is returned by RunOSBuild when osbuild itself fails,
+// it contains the pipeline and stage that were running at the time
+// of the failure (if known)
+type OSBuildError struct {
+	Err error
+
+	Pipeline string
+	Stage    string
+
+	BuildLog string
+	Output   string
+}
+
+func (e *OSBuildError) Error() string {
+	return fmt.Sprintf("error running osbuild: %v\nBuildLog:\n%s\nOutput:\n%s", e.Err, e.BuildLog, e.Output)
+}
+
+func (e *OSBuildError) Unwrap() error {
+	return e.Err
+}
+
+func runOSBuildWithProgress(ctx context.Context, pb ProgressBar, manifest []byte, exports []string, opts *OSBuildOptions) (err error) {
 	rp, wp, err := os.Pipe()
 	if err != nil {
 		return fmt.Errorf("cannot create pipe for osbuild: %w", err)
@@ -127,7 +252,7 @@ func runOSBuildWithProgress(pb ProgressBar, manifest []byte, exports []string, o
 	defer rp.Close()
 	defer wp.Close()
 
-	cmd := newOsbuildCmd(manifest, exports, opts)
+	cmd := newOsbuildCmd(ctx, manifest, exports, opts)
 	cmd.Args = append(cmd.Args, "--monitor=JSONSeqMonitor")
 	cmd.Args = append(cmd.Args, "--monitor-fd=3")
 
@@ -146,7 +271,22 @@ func runOSBuildWithProgress(pb ProgressBar, manifest []byte, exports []string, o
 	cmd.Stderr = mw
 	cmd.ExtraFiles = []*os.File{wp}
 
-	osbuildStatus := osbuild.NewStatusScanner(rp)
+	var monitor io.Reader = rp
+	if opts.MonitorLog != nil {
+		monitor = io.TeeReader(rp, opts.MonitorLog)
+	}
+	osbuildStatus := osbuild.NewStatusScanner(monitor)
+	var storeSampler *storeUsageSampler
+	if opts.MetricsReport != "" && opts.StoreDir != "" {
+		storeSampler = startStoreUsageSampler(opts.StoreDir)
+		defer func() {
+			// not stopped yet if the build failed
+			if storeSampler != nil {
+				storeSampler.stop(&MetricsReport{})
+			}
+		}()
+	}
+	startTime := time.Now()
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("error starting osbuild: %v", err)
 	}
@@ -165,6 +305,9 @@ func runOSBuildWithProgress(pb ProgressBar, manifest []byte, exports []string, o
 	}()
 
 	var tracesMsgs []string
+	// the pipeline and stage that currently run, on failure
+	// this is what failed
+	var curPipeline, curStage string
 	oss := osbuildStageMetrics{}
 	traceOut := buildLog
 	// do not pollute the buildlog with non-json stuff
@@ -187,6 +330,17 @@ func runOSBuildWithProgress(pb ProgressBar, manifest []byte, exports []string, o
 		if st == nil {
 			break
 		}
+		if er, ok := pb.(eventReporter); ok {
+			er.setOSBuildStatus(st)
+		}
+		switch kind, name := parseMonitorMessage(st.Message); kind {
+		case monitorPipelineStart:
+			curPipeline, curStage = name, ""
+		case monitorStageStart:
+			curPipeline, curStage = st.Pipeline, name
+		case monitorStageFinish:
+			curStage = ""
+		}
 		i := 0
 		for p := st.Progress; p != nil; p = p.SubProgress {
 			if err := pb.SetProgress(i, p.Message, p.Done, p.Total); err != nil {
@@ -214,19 +368,38 @@ func runOSBuildWithProgress(pb ProgressBar, manifest []byte, exports []string, o
 		}
 
 		// store metrics if requested
-		if opts.Metrics {
+		if opts.Metrics || opts.MetricsReport != "" {
 			oss.Add(st)
 		}
 	}
 
 	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("error running osbuild: %w\nBuildLog:\n%s\nOutput:\n%s", err, strings.Join(tracesMsgs, "\n"), stdio.String())
+		if cErr := cancelledErr(ctx); cErr != nil {
+			return cErr
+		}
+		return &OSBuildError{
+			Err:      err,
+			Pipeline: curPipeline,
+			Stage:    curStage,
+			BuildLog: strings.Join(tracesMsgs, "\n"),
+			Output:   stdio.String(),
+		}
 	}
 
 	// append metrics to the end message
 	if opts.Metrics {
 		_, _ = pb.Write(oss.Bytes())
 	}
+	if opts.MetricsReport != "" {
+		report := oss.report(time.Since(startTime))
+		if storeSampler != nil {
+			storeSampler.stop(report)
+			storeSampler = nil
+		}
+		if err := WriteMetricsReport(opts.MetricsReport, report); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
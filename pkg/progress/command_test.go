@@ -2,10 +2,12 @@ package progress_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -36,7 +38,7 @@ exit 112
 
 	pbar, err := progress.New("debug", progress.ProgressConfig{})
 	assert.NoError(t, err)
-	err = progress.RunOSBuild(pbar, []byte(`{"fake":"manifest"}`), nil, nil)
+	err = progress.RunOSBuild(context.Background(), pbar, []byte(`{"fake":"manifest"}`), nil, nil)
 	assert.EqualError(t, err, `error running osbuild: exit status 112
 BuildLog:
 osbuild-stage-message
@@ -46,6 +48,33 @@ osbuild-stderr-output
 `)
 }
 
+func TestRunOSBuildWithProgressFailedStage(t *testing.T) {
+	restore := progress.MockOsStderr(io.Discard)
+	defer restore()
+
+	restore = progress.MockOsbuildCmd(makeFakeOsbuild(t, `
+>&3 echo '{"message": "Starting pipeline build", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "build"}}}'
+>&3 echo '{"message": "Starting module org.osbuild.rpm", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "build", "stage": {"name": "org.osbuild.rpm"}}}}'
+>&3 echo '{"message": "Finished module org.osbuild.rpm", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "build", "stage": {"name": "org.osbuild.rpm"}}}}'
+>&3 echo '{"message": "Starting module org.osbuild.selinux", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "build", "stage": {"name": "org.osbuild.selinux"}}}}'
+exit 1
+`))
+	defer restore()
+
+	var monitorLog bytes.Buffer
+	pbar, err := progress.New("debug", progress.ProgressConfig{})
+	assert.NoError(t, err)
+	err = progress.RunOSBuild(context.Background(), pbar, []byte(`{"fake":"manifest"}`), nil, &progress.OSBuildOptions{
+		MonitorLog: &monitorLog,
+	})
+	var osbuildErr *progress.OSBuildError
+	assert.ErrorAs(t, err, &osbuildErr)
+	assert.Equal(t, "build", osbuildErr.Pipeline)
+	assert.Equal(t, "org.osbuild.selinux", osbuildErr.Stage)
+	assert.Equal(t, 4, strings.Count(monitorLog.String(), "\n"))
+	assert.Contains(t, monitorLog.String(), `"Starting module org.osbuild.selinux"`)
+}
+
 func TestRunOSBuildWithProgressIncorrectJSON(t *testing.T) {
 	signalDeliveredMarkerPath := filepath.Join(t.TempDir(), "sigint-delivered")
 
@@ -63,7 +92,7 @@ done
 
 	pbar, err := progress.New("debug", progress.ProgressConfig{})
 	assert.NoError(t, err)
-	err = progress.RunOSBuild(pbar, []byte(`{"fake":"manifest"}`), nil, nil)
+	err = progress.RunOSBuild(context.Background(), pbar, []byte(`{"fake":"manifest"}`), nil, nil)
 	assert.EqualError(t, err, `error parsing osbuild status, please report a bug and try with "--progress=verbose": cannot scan line "invalid-json\n": invalid character 'i' looking for beginning of value`)
 
 	// ensure the SIGINT got delivered
@@ -80,6 +109,48 @@ done
 	assert.True(t, pathExists(signalDeliveredMarkerPath))
 }
 
+func TestRunOSBuildCancelled(t *testing.T) {
+	for _, progressType := range []string{"debug", "verbose"} {
+		t.Run(progressType, func(t *testing.T) {
+			startedMarkerPath := filepath.Join(t.TempDir(), "started")
+			signalDeliveredMarkerPath := filepath.Join(t.TempDir(), "sigint-delivered")
+
+			restore := progress.MockOsbuildCmd(makeFakeOsbuild(t, fmt.Sprintf(`
+trap 'touch "%s";exit 2' INT
+touch "%s"
+
+while true; do
+    sleep 0.1
+done
+`, signalDeliveredMarkerPath, startedMarkerPath)))
+			defer restore()
+			restore = progress.MockOsStdout(io.Discard)
+			defer restore()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				for {
+					if _, err := os.Stat(startedMarkerPath); err == nil {
+						cancel()
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			}()
+
+			pbar, err := progress.New(progressType, progress.ProgressConfig{})
+			assert.NoError(t, err)
+			err = progress.RunOSBuild(ctx, pbar, []byte(`{"fake":"manifest"}`), nil, nil)
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.EqualError(t, err, "osbuild was cancelled: context canceled")
+
+			// osbuild was given the chance to cleanup
+			_, err = os.Stat(signalDeliveredMarkerPath)
+			assert.NoError(t, err)
+		})
+	}
+}
+
 func TestRunOSBuildWithBuildlogTerm(t *testing.T) {
 	restore := progress.MockOsbuildCmd(makeFakeOsbuild(t, `
 echo osbuild-stdout-output
@@ -106,7 +177,7 @@ sleep 0.1
 	opts := &progress.OSBuildOptions{
 		BuildLog: &buildLog,
 	}
-	err = progress.RunOSBuild(pbar, []byte(`{"fake":"manifest"}`), nil, opts)
+	err = progress.RunOSBuild(context.Background(), pbar, []byte(`{"fake":"manifest"}`), nil, opts)
 	assert.NoError(t, err)
 	expectedOutput := `osbuild-stdout-output
 osbuild-stderr-output
@@ -115,6 +186,41 @@ osbuild-stage-message
 	assert.Equal(t, expectedOutput, buildLog.String())
 }
 
+func TestRunOSBuildVerboseWithMonitorLog(t *testing.T) {
+	restore := progress.MockOsbuildCmd(makeFakeOsbuild(t, `
+for arg in "$@"; do
+    if [ "$arg" = "--monitor=JSONSeqMonitor" ]; then
+        monitor=1
+    fi
+done
+[ -n "$monitor" ] || exit 1
+echo osbuild-stdout-output
+>&3 echo '{"message": "osbuild-stage-message"}'
+>&3 echo '{"message": "stage-output", "context": {"origin": "org.osbuild"}}'
+`))
+	defer restore()
+
+	var fakeStdout bytes.Buffer
+	restore = progress.MockOsStdout(&fakeStdout)
+	defer restore()
+	restore = progress.MockOsStderr(io.Discard)
+	defer restore()
+
+	pbar, err := progress.New("verbose", progress.ProgressConfig{})
+	assert.NoError(t, err)
+
+	var monitorLog bytes.Buffer
+	opts := &progress.OSBuildOptions{
+		MonitorLog: &monitorLog,
+	}
+	err = progress.RunOSBuild(context.Background(), pbar, []byte(`{"fake":"manifest"}`), nil, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, monitorLog.String(), `"osbuild-stage-message"`)
+	assert.Contains(t, fakeStdout.String(), "osbuild-stdout-output\n")
+	assert.Contains(t, fakeStdout.String(), "osbuild-stage-message\n")
+	assert.Contains(t, fakeStdout.String(), "stage-output\n")
+}
+
 func TestRunOSBuildWithBuildlogVerbose(t *testing.T) {
 	restore := progress.MockOsbuildCmd(makeFakeOsbuild(t, `
 echo osbuild-stdout-output
@@ -135,7 +241,7 @@ echo osbuild-stdout-output
 	opts := &progress.OSBuildOptions{
 		BuildLog: &buildLog,
 	}
-	err = progress.RunOSBuild(pbar, []byte(`{"fake":"manifest"}`), nil, opts)
+	err = progress.RunOSBuild(context.Background(), pbar, []byte(`{"fake":"manifest"}`), nil, opts)
 	assert.NoError(t, err)
 	expectedOutput := `osbuild-stdout-output
 osbuild-stderr-output
@@ -154,7 +260,7 @@ func TestRunOSBuildCacheMaxSize(t *testing.T) {
 	osbuildOpts := &progress.OSBuildOptions{
 		CacheMaxSize: 77,
 	}
-	err = progress.RunOSBuild(pbar, []byte(`{"fake":"manifest"}`), nil, osbuildOpts)
+	err = progress.RunOSBuild(context.Background(), pbar, []byte(`{"fake":"manifest"}`), nil, osbuildOpts)
 	assert.NoError(t, err)
 	cmdline, err := os.ReadFile(fakeOsbuildBinary + ".cmdline")
 	assert.NoError(t, err)
@@ -2,6 +2,7 @@ package progress
 
 import (
 	"io"
+	"time"
 )
 
 type (
@@ -9,10 +10,12 @@ type (
 	DebugProgressBar    = debugProgressBar
 	VerboseProgressBar  = verboseProgressBar
 	FileProgressItem    = fileProgressItem
+	JSONLProgressBar    = jsonlProgressBar
 )
 
 var (
 	NewSyncedWriter = newSyncedWriter
+	StoreDirUsage   = storeDirUsage
 )
 
 func MockOsStdout(w io.Writer) (restore func()) {
@@ -54,3 +57,17 @@ func MockGetTerminalSize(fn func() (int, int)) (restore func()) {
 		getTerminalSize = saved
 	}
 }
+
+type OSBuildStageMetrics = osbuildStageMetrics
+
+func (s *osbuildStageMetrics) Report(d time.Duration) *MetricsReport {
+	return s.report(d)
+}
+
+func MockStoreUsageSampleInterval(d time.Duration) (restore func()) {
+	saved := storeUsageSampleInterval
+	storeUsageSampleInterval = d
+	return func() {
+		storeUsageSampleInterval = saved
+	}
+}
@@ -0,0 +1,254 @@
+package progress
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/osbuild/image-builder/pkg/osbuild"
+)
+
+// JSONLSchemaVersion is the version of the events emitted by the
+// "jsonl" progress. It is increased whenever an incompatible change
+// is made to the events, adding new event types or new optional
+// fields is considered compatible.
+const JSONLSchemaVersion = 1
+
+// Phase is a high level step of building an image
+type Phase string
+
+const (
+	PhaseManifest Phase = "manifest"
+	PhaseDepsolve Phase = "depsolve"
+	PhaseResolve  Phase = "resolve"
+	PhaseBuild    Phase = "build"
+	PhaseUpload   Phase = "upload"
+)
+
+// Event types of the "jsonl" progress
+const (
+	JSONLEventStart          = "start"
+	JSONLEventStop           = "stop"
+	JSONLEventPhase          = "phase"
+	JSONLEventMessage        = "message"
+	JSONLEventProgress       = "progress"
+	JSONLEventPipelineStart  = "pipeline-start"
+	JSONLEventPipelineFinish = "pipeline-finish"
+	JSONLEventStageStart     = "stage-start"
+	JSONLEventStageFinish    = "stage-finish"
+	JSONLEventWarning        = "warning"
+	JSONLEventArtifact       = "artifact"
+	JSONLEventOutput         = "output"
+)
+
+// JSONLEvent is a single line of the "jsonl" progress stream. Every
+// event has "version", "type" and "timestamp", the other fields are
+// only set for the event types that need them.
+type JSONLEvent struct {
+	Version   int       `json:"version"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// phase
+	Phase Phase `json:"phase,omitempty"`
+	// message, phase, warning, output
+	Message string `json:"message,omitempty"`
+
+	// pipeline-*, stage-*
+	Pipeline string `json:"pipeline,omitempty"`
+	// stage-*
+	Stage string `json:"stage,omitempty"`
+	// pipeline-finish, stage-finish
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+
+	// progress
+	Level   *int    `json:"level,omitempty"`
+	Done    *int    `json:"done,omitempty"`
+	Total   *int    `json:"total,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+
+	// artifact
+	Path string `json:"path,omitempty"`
+}
+
+type jsonlProgressBar struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONLProgressBar creates a new "jsonl" progressbar that writes
+// one JSON object per event to the given writer (stdout if nil), see
+// JSONLEvent for the format.
+func NewJSONLProgressBar(w io.Writer) (ProgressBar, error) {
+	if w == nil {
+		w = osStdout()
+	}
+	return &jsonlProgressBar{enc: json.NewEncoder(w)}, nil
+}
+
+func (b *jsonlProgressBar) emit(ev *JSONLEvent) {
+	ev.Version = JSONLSchemaVersion
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now().UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// nothing sensible we can do if the consumer goes away
+	_ = b.enc.Encode(ev)
+}
+
+func (b *jsonlProgressBar) SetProgress(level int, msg string, done int, total int) error {
+	ev := &JSONLEvent{
+		Type:    JSONLEventProgress,
+		Message: msg,
+		Level:   &level,
+		Done:    &done,
+		Total:   &total,
+	}
+	if total > 0 {
+		ev.Percent = float64(done) * 100 / float64(total)
+	}
+	b.emit(ev)
+	return nil
+}
+
+func (b *jsonlProgressBar) SetPulseMsgf(msg string, args ...any) {
+	// nop: pulse messages are the human readable form of the
+	// phases which are emitted via setPhase()
+}
+
+func (b *jsonlProgressBar) SetMessagef(msg string, args ...any) {
+	b.emit(&JSONLEvent{
+		Type:    JSONLEventMessage,
+		Message: fmt.Sprintf(msg, args...),
+	})
+}
+
+func (b *jsonlProgressBar) Start() {
+	b.emit(&JSONLEvent{Type: JSONLEventStart})
+}
+
+func (b *jsonlProgressBar) Stop() {
+	b.emit(&JSONLEvent{Type: JSONLEventStop})
+}
+
+func (b *jsonlProgressBar) Write(p []byte) (n int, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(string(p)))
+	for scanner.Scan() {
+		b.emit(&JSONLEvent{
+			Type:    JSONLEventOutput,
+			Message: scanner.Text(),
+		})
+	}
+	return len(p), nil
+}
+
+func (b *jsonlProgressBar) setPhase(phase Phase) {
+	b.emit(&JSONLEvent{
+		Type:  JSONLEventPhase,
+		Phase: phase,
+	})
+}
+
+func (b *jsonlProgressBar) reportWarning(msg string) {
+	b.emit(&JSONLEvent{
+		Type:    JSONLEventWarning,
+		Message: msg,
+	})
+}
+
+func (b *jsonlProgressBar) reportArtifact(path string) {
+	b.emit(&JSONLEvent{
+		Type: JSONLEventArtifact,
+		Path: path,
+	})
+}
+
+// setOSBuildStatus turns the high level osbuild monitor messages
+// into pipeline and stage events
+func (b *jsonlProgressBar) setOSBuildStatus(st *osbuild.Status) {
+	ev := &JSONLEvent{
+		Timestamp: st.Timestamp.UTC(),
+		Pipeline:  st.Pipeline,
+	}
+	kind, name := parseMonitorMessage(st.Message)
+	switch kind {
+	case monitorPipelineStart:
+		ev.Type = JSONLEventPipelineStart
+		ev.Pipeline = name
+	case monitorPipelineFinish:
+		ev.Type = JSONLEventPipelineFinish
+		ev.Pipeline = name
+		ev.DurationSeconds = st.Duration.Seconds()
+	case monitorStageStart:
+		ev.Type = JSONLEventStageStart
+		ev.Stage = name
+	case monitorStageFinish:
+		ev.Type = JSONLEventStageFinish
+		ev.Stage = name
+		ev.DurationSeconds = st.Duration.Seconds()
+	default:
+		return
+	}
+	b.emit(ev)
+}
+
+// eventReporter is implemented by progress bars that report
+// structured events in addition to the human readable messages
+type eventReporter interface {
+	setPhase(phase Phase)
+	reportWarning(msg string)
+	reportArtifact(path string)
+	setOSBuildStatus(st *osbuild.Status)
+}
+
+// SetPhase reports that the build entered the given phase. This is
+// a no-op for progress bars that do not report structured events,
+// use SetPulseMsgf() for the human readable message.
+func SetPhase(pb ProgressBar, phase Phase) {
+	if er, ok := pb.(eventReporter); ok {
+		er.setPhase(phase)
+	}
+}
+
+// ReportWarning reports a (non-fatal) warning, this is a no-op for
+// progress bars that do not report structured events.
+func ReportWarning(pb ProgressBar, msg string) {
+	if er, ok := pb.(eventReporter); ok {
+		er.reportWarning(msg)
+	}
+}
+
+// ReportArtifact reports the path of a final artifact of the build,
+// this is a no-op for progress bars that do not report structured
+// events.
+func ReportArtifact(pb ProgressBar, path string) {
+	if er, ok := pb.(eventReporter); ok {
+		er.reportArtifact(path)
+	}
+}
+
+type warningsWriter struct {
+	pb ProgressBar
+}
+
+func (w *warningsWriter) Write(p []byte) (int, error) {
+	scanner := bufio.NewScanner(strings.NewReader(string(p)))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			ReportWarning(w.pb, line)
+		}
+	}
+	return len(p), nil
+}
+
+// NewWarningsWriter returns a writer that reports every line written
+// to it via ReportWarning()
+func NewWarningsWriter(pb ProgressBar) io.Writer {
+	return &warningsWriter{pb: pb}
+}
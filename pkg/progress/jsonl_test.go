@@ -0,0 +1,137 @@
+package progress_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/progress"
+)
+
+func parseJSONLEvents(t *testing.T, data []byte) []progress.JSONLEvent {
+	var events []progress.JSONLEvent
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var ev progress.JSONLEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &ev), scanner.Text())
+		assert.Equal(t, progress.JSONLSchemaVersion, ev.Version)
+		assert.False(t, ev.Timestamp.IsZero())
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestJSONLProgress(t *testing.T) {
+	var buf bytes.Buffer
+	pbar, err := progress.NewJSONLProgressBar(&buf)
+	require.NoError(t, err)
+
+	pbar.Start()
+	progress.SetPhase(pbar, progress.PhaseBuild)
+	pbar.SetPulseMsgf("pulse-msg")
+	pbar.SetMessagef("some-message %v", 42)
+	require.NoError(t, pbar.SetProgress(0, "progress-msg", 1, 4))
+	progress.ReportWarning(pbar, "some-warning")
+	progress.ReportArtifact(pbar, "/path/to/disk.qcow2")
+	_, err = pbar.Write([]byte("line1\nline2\n"))
+	require.NoError(t, err)
+	pbar.Stop()
+
+	events := parseJSONLEvents(t, buf.Bytes())
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	assert.Equal(t, []string{"start", "phase", "message", "progress", "warning", "artifact", "output", "output", "stop"}, types)
+	assert.Equal(t, progress.PhaseBuild, events[1].Phase)
+	assert.Equal(t, "some-message 42", events[2].Message)
+	assert.Equal(t, "progress-msg", events[3].Message)
+	assert.Equal(t, 0, *events[3].Level)
+	assert.Equal(t, 1, *events[3].Done)
+	assert.Equal(t, 4, *events[3].Total)
+	assert.Equal(t, 25.0, events[3].Percent)
+	assert.Equal(t, "some-warning", events[4].Message)
+	assert.Equal(t, "/path/to/disk.qcow2", events[5].Path)
+	assert.Equal(t, "line2", events[7].Message)
+}
+
+func TestJSONLProgressLevelZeroIsSerialized(t *testing.T) {
+	var buf bytes.Buffer
+	pbar, err := progress.NewJSONLProgressBar(&buf)
+	require.NoError(t, err)
+
+	require.NoError(t, pbar.SetProgress(0, "msg", 0, 0))
+	assert.Contains(t, buf.String(), `"level":0,"done":0,"total":0`)
+	assert.NotContains(t, buf.String(), `"percent"`)
+}
+
+func TestEventsAreNopForOtherProgress(t *testing.T) {
+	var buf bytes.Buffer
+	restore := progress.MockOsStderr(&buf)
+	defer restore()
+
+	pbar, err := progress.New("verbose", progress.ProgressConfig{})
+	require.NoError(t, err)
+	progress.SetPhase(pbar, progress.PhaseBuild)
+	progress.ReportWarning(pbar, "some-warning")
+	progress.ReportArtifact(pbar, "/path/to/disk.qcow2")
+	_, err = progress.NewWarningsWriter(pbar).Write([]byte("warning\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "", buf.String())
+}
+
+func TestWarningsWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pbar, err := progress.NewJSONLProgressBar(&buf)
+	require.NoError(t, err)
+
+	w := progress.NewWarningsWriter(pbar)
+	_, err = w.Write([]byte("warning 1\n\nwarning 2\n"))
+	require.NoError(t, err)
+
+	events := parseJSONLEvents(t, buf.Bytes())
+	require.Len(t, events, 2)
+	assert.Equal(t, "warning", events[0].Type)
+	assert.Equal(t, "warning 1", events[0].Message)
+	assert.Equal(t, "warning 2", events[1].Message)
+}
+
+func TestRunOSBuildWithJSONLProgress(t *testing.T) {
+	restore := progress.MockOsbuildCmd(makeFakeOsbuild(t, `
+>&3 echo '{"message": "Starting pipeline build", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "build"}}, "progress": {"done": 0, "total": 2}, "timestamp": 1731589338.8}'
+>&3 echo '{"message": "Starting module org.osbuild.rpm", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "build", "stage": {"name": "org.osbuild.rpm"}}}, "progress": {"done": 0, "total": 2}, "timestamp": 1731589339.8}'
+>&3 echo '{"message": "Finished module org.osbuild.rpm", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "build", "stage": {"name": "org.osbuild.rpm"}}}, "progress": {"done": 0, "total": 2}, "timestamp": 1731589349.8, "duration": 10.5}'
+>&3 echo '{"message": "Finished pipeline build", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "build"}}, "progress": {"done": 1, "total": 2}, "timestamp": 1731589350.8, "duration": 12.0}'
+`))
+	defer restore()
+
+	var buf bytes.Buffer
+	pbar, err := progress.New("jsonl", progress.ProgressConfig{Output: &buf})
+	require.NoError(t, err)
+	err = progress.RunOSBuild(context.Background(), pbar, []byte(`{"fake":"manifest"}`), nil, nil)
+	require.NoError(t, err)
+
+	var osbuildEvents []progress.JSONLEvent
+	for _, ev := range parseJSONLEvents(t, buf.Bytes()) {
+		if ev.Type == "progress" || ev.Type == "message" {
+			continue
+		}
+		osbuildEvents = append(osbuildEvents, ev)
+	}
+	require.Len(t, osbuildEvents, 4)
+	assert.Equal(t, "pipeline-start", osbuildEvents[0].Type)
+	assert.Equal(t, "build", osbuildEvents[0].Pipeline)
+	assert.Equal(t, "stage-start", osbuildEvents[1].Type)
+	assert.Equal(t, "org.osbuild.rpm", osbuildEvents[1].Stage)
+	assert.Equal(t, "stage-finish", osbuildEvents[2].Type)
+	assert.Equal(t, "build", osbuildEvents[2].Pipeline)
+	assert.Equal(t, 10.5, osbuildEvents[2].DurationSeconds)
+	assert.Equal(t, "pipeline-finish", osbuildEvents[3].Type)
+	assert.Equal(t, 12.0, osbuildEvents[3].DurationSeconds)
+	assert.Equal(t, int64(1731589350), osbuildEvents[3].Timestamp.Unix())
+}
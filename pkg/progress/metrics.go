@@ -2,14 +2,59 @@ package progress
 
 import (
 	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/osbuild/image-builder/pkg/osbuild"
 )
 
+// monitor message kinds, see parseMonitorMessage()
+const (
+	monitorPipelineStart = iota + 1
+	monitorPipelineFinish
+	monitorStageStart
+	monitorStageFinish
+)
+
+// parseMonitorMessage parses the high level osbuild monitor messages
+// like "Starting pipeline build" or "Finished module org.osbuild.rpm"
+// and returns the kind of message and the pipeline/stage name (or 0
+// if the message is not one of those)
+func parseMonitorMessage(msg string) (int, string) {
+	for _, m := range []struct {
+		prefix string
+		kind   int
+	}{
+		{"Starting pipeline ", monitorPipelineStart},
+		{"Finished pipeline ", monitorPipelineFinish},
+		{"Starting module ", monitorStageStart},
+		{"Finished module ", monitorStageFinish},
+	} {
+		if name, ok := strings.CutPrefix(msg, m.prefix); ok {
+			return m.kind, name
+		}
+	}
+	return 0, ""
+}
+
 type osbuildStageMetrics struct {
 	durations []measurement
+
+	pipelines []*PipelineMetrics
+	// stages of the current pipeline, pipelines without stages
+	// were fully reused from the store
+	stagesRun int
 }
 
 type measurement struct {
@@ -26,6 +71,34 @@ func (s *osbuildStageMetrics) Add(st *osbuild.Status) {
 			Duration: st.Duration,
 		})
 	}
+
+	kind, name := parseMonitorMessage(st.Message)
+	switch kind {
+	case monitorPipelineStart:
+		s.pipelines = append(s.pipelines, &PipelineMetrics{Name: name, Stages: []StageMetrics{}})
+		s.stagesRun = 0
+	case monitorPipelineFinish:
+		if p := s.currentPipeline(); p != nil {
+			p.DurationSeconds = st.Duration.Seconds()
+			p.CacheHit = s.stagesRun == 0
+		}
+	case monitorStageStart:
+		s.stagesRun++
+	case monitorStageFinish:
+		if p := s.currentPipeline(); p != nil {
+			p.Stages = append(p.Stages, StageMetrics{
+				Name:            name,
+				DurationSeconds: st.Duration.Seconds(),
+			})
+		}
+	}
+}
+
+func (s *osbuildStageMetrics) currentPipeline() *PipelineMetrics {
+	if len(s.pipelines) == 0 {
+		return nil
+	}
+	return s.pipelines[len(s.pipelines)-1]
 }
 
 func (s *osbuildStageMetrics) String() string {
@@ -52,3 +125,248 @@ func (s *osbuildStageMetrics) String() string {
 func (s *osbuildStageMetrics) Bytes() []byte {
 	return []byte(s.String())
 }
+
+// MetricsReport contains the machine readable metrics of a build
+type MetricsReport struct {
+	DurationSeconds float64            `json:"duration_seconds"`
+	Pipelines       []*PipelineMetrics `json:"pipelines"`
+	// DownloadedBytes contains the bytes added to the sources
+	// cache of the store during the build, keyed by the source
+	// cache (e.g. "org.osbuild.files")
+	DownloadedBytes map[string]int64 `json:"downloaded_bytes"`
+	// PeakStoreGrowthBytes is the largest growth of the disk usage
+	// of the store directory seen during the build
+	PeakStoreGrowthBytes int64 `json:"peak_store_growth_bytes"`
+}
+
+// PipelineMetrics contains the metrics of a single osbuild pipeline
+type PipelineMetrics struct {
+	Name            string         `json:"name"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	CacheHit        bool           `json:"cache_hit"`
+	Stages          []StageMetrics `json:"stages"`
+}
+
+// StageMetrics contains the metrics of a single osbuild stage
+type StageMetrics struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *MetricsReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the report as CSV with the columns
+// "metric,pipeline,stage,value"
+func (r *MetricsReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 3, 64)
+	}
+
+	records := [][]string{
+		{"metric", "pipeline", "stage", "value"},
+		{"duration_seconds", "", "", formatFloat(r.DurationSeconds)},
+	}
+	for _, p := range r.Pipelines {
+		records = append(records, []string{"pipeline_duration_seconds", p.Name, "", formatFloat(p.DurationSeconds)})
+		records = append(records, []string{"pipeline_cache_hit", p.Name, "", strconv.FormatBool(p.CacheHit)})
+		for _, st := range p.Stages {
+			records = append(records, []string{"stage_duration_seconds", p.Name, st.Name, formatFloat(st.DurationSeconds)})
+		}
+	}
+	sources := make([]string, 0, len(r.DownloadedBytes))
+	for source := range r.DownloadedBytes {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+	for _, source := range sources {
+		records = append(records, []string{"downloaded_bytes", source, "", strconv.FormatInt(r.DownloadedBytes[source], 10)})
+	}
+	records = append(records, []string{"peak_store_growth_bytes", "", "", strconv.FormatInt(r.PeakStoreGrowthBytes, 10)})
+
+	return cw.WriteAll(records)
+}
+
+// WriteMetricsReport writes the report to the given path, the format
+// is selected by the file extension (".csv" or JSON for anything else)
+func WriteMetricsReport(path string, r *MetricsReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create metrics report: %w", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = r.WriteCSV(f)
+	} else {
+		err = r.WriteJSON(f)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write metrics report: %w", err)
+	}
+	return f.Close()
+}
+
+// storeDirUsage returns the disk usage of the store directory like
+// "du -sx" does: the allocated blocks of all files, with hardlinked
+// files counted once. Filesystems mounted below the store (e.g. the
+// loop devices of the image that osbuild mounts) are skipped. Files
+// may disappear while osbuild runs, they are ignored.
+func storeDirUsage(storeDir string) (int64, error) {
+	info, err := os.Stat(storeDir)
+	if err != nil {
+		return 0, err
+	}
+	rootSt, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("cannot get the device of %q", storeDir)
+	}
+
+	var used int64
+	seen := map[uint64]bool{}
+	err = filepath.WalkDir(storeDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		if st.Dev != rootSt.Dev {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && st.Nlink > 1 {
+			if seen[st.Ino] {
+				return nil
+			}
+			seen[st.Ino] = true
+		}
+		// st_blocks is always in 512 byte units
+		used += st.Blocks * 512
+		return nil
+	})
+	return used, err
+}
+
+// downloadedSizes returns the size of the files that were added to the
+// sources caches of the store since the given time. Sources are
+// written to a temporary file and renamed into place so the change
+// time tells when a file was added (the modification time may be
+// taken from the server).
+func downloadedSizes(storeDir string, since time.Time) map[string]int64 {
+	sizes := map[string]int64{}
+	sourcesDir := filepath.Join(storeDir, "sources")
+	entries, err := os.ReadDir(sourcesDir)
+	if err != nil {
+		return sizes
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		var size int64
+		_ = filepath.WalkDir(filepath.Join(sourcesDir, entry.Name()), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			st, ok := info.Sys().(*syscall.Stat_t)
+			if !ok || time.Unix(st.Ctim.Unix()).Before(since) {
+				return nil
+			}
+			size += info.Size()
+			return nil
+		})
+		if size > 0 {
+			sizes[entry.Name()] = size
+		}
+	}
+	return sizes
+}
+
+// storeUsageSampler regularly samples the disk usage of the store
+// directory to find the peak growth during a build
+type storeUsageSampler struct {
+	storeDir string
+	interval time.Duration
+	start    time.Time
+
+	mu         sync.Mutex
+	usedBefore int64
+	peak       int64
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// the store directory is walked for every sample, so keep the interval
+// long enough for big stores
+var storeUsageSampleInterval = 30 * time.Second
+
+func startStoreUsageSampler(storeDir string) *storeUsageSampler {
+	s := &storeUsageSampler{
+		storeDir: storeDir,
+		interval: storeUsageSampleInterval,
+		start:    time.Now(),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	s.usedBefore, _ = storeDirUsage(storeDir)
+	go func() {
+		defer close(s.doneCh)
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-time.After(s.interval):
+				s.sample()
+			}
+		}
+	}()
+	return s
+}
+
+func (s *storeUsageSampler) sample() {
+	used, err := storeDirUsage(s.storeDir)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if growth := used - s.usedBefore; growth > s.peak {
+		s.peak = growth
+	}
+}
+
+// stop stops the sampling and adds the store metrics to the report
+func (s *storeUsageSampler) stop(report *MetricsReport) {
+	close(s.stopCh)
+	<-s.doneCh
+	s.sample()
+
+	report.PeakStoreGrowthBytes = s.peak
+	report.DownloadedBytes = downloadedSizes(s.storeDir, s.start)
+}
+
+func (s *osbuildStageMetrics) report(duration time.Duration) *MetricsReport {
+	return &MetricsReport{
+		DurationSeconds: duration.Seconds(),
+		Pipelines:       s.pipelines,
+		DownloadedBytes: map[string]int64{},
+	}
+}
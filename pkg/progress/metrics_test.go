@@ -0,0 +1,159 @@
+package progress_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/image-builder/pkg/osbuild"
+	"github.com/osbuild/image-builder/pkg/progress"
+)
+
+func TestStageMetricsReport(t *testing.T) {
+	var oss progress.OSBuildStageMetrics
+	for _, st := range []*osbuild.Status{
+		{Message: "Starting pipeline build", Pipeline: "build"},
+		{Message: "Finished pipeline build", Pipeline: "build", Duration: 100 * time.Millisecond},
+		{Message: "Starting pipeline os", Pipeline: "os"},
+		{Message: "Starting module org.osbuild.rpm", Pipeline: "os"},
+		{Trace: "some output", Pipeline: "os"},
+		{Message: "Finished module org.osbuild.rpm", Pipeline: "os", Duration: 10 * time.Second},
+		{Message: "Starting module org.osbuild.selinux", Pipeline: "os"},
+		{Message: "Finished module org.osbuild.selinux", Pipeline: "os", Duration: 2 * time.Second},
+		{Message: "Finished pipeline os", Pipeline: "os", Duration: 12 * time.Second},
+	} {
+		oss.Add(st)
+	}
+
+	report := oss.Report(15 * time.Second)
+	assert.Equal(t, &progress.MetricsReport{
+		DurationSeconds: 15,
+		Pipelines: []*progress.PipelineMetrics{
+			{
+				Name:            "build",
+				DurationSeconds: 0.1,
+				CacheHit:        true,
+				Stages:          []progress.StageMetrics{},
+			},
+			{
+				Name:            "os",
+				DurationSeconds: 12,
+				Stages: []progress.StageMetrics{
+					{Name: "org.osbuild.rpm", DurationSeconds: 10},
+					{Name: "org.osbuild.selinux", DurationSeconds: 2},
+				},
+			},
+		},
+		DownloadedBytes: map[string]int64{},
+	}, report)
+	// the text metrics are unchanged
+	assert.Contains(t, oss.String(), "os: Finished module org.osbuild.rpm: 10s\n")
+}
+
+func TestMetricsReportCSV(t *testing.T) {
+	report := &progress.MetricsReport{
+		DurationSeconds: 15,
+		Pipelines: []*progress.PipelineMetrics{
+			{Name: "build", DurationSeconds: 0.1, CacheHit: true},
+			{
+				Name:            "os",
+				DurationSeconds: 12,
+				Stages: []progress.StageMetrics{
+					{Name: "org.osbuild.rpm", DurationSeconds: 10},
+				},
+			},
+		},
+		DownloadedBytes: map[string]int64{
+			"org.osbuild.files":              1024,
+			"org.osbuild.containers-storage": 2048,
+		},
+		PeakStoreGrowthBytes: 4096,
+	}
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	assert.Equal(t, `metric,pipeline,stage,value
+duration_seconds,,,15.000
+pipeline_duration_seconds,build,,0.100
+pipeline_cache_hit,build,,true
+pipeline_duration_seconds,os,,12.000
+pipeline_cache_hit,os,,false
+stage_duration_seconds,os,org.osbuild.rpm,10.000
+downloaded_bytes,org.osbuild.containers-storage,,2048
+downloaded_bytes,org.osbuild.files,,1024
+peak_store_growth_bytes,,,4096
+`, buf.String())
+}
+
+func TestRunOSBuildWithMetricsReport(t *testing.T) {
+	storeDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(storeDir, "sources", "org.osbuild.files"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(storeDir, "sources", "org.osbuild.files", "sha256:old"), []byte("old"), 0644))
+	// the kernel timestamps files with a coarse clock
+	time.Sleep(50 * time.Millisecond)
+
+	restore := progress.MockStoreUsageSampleInterval(10 * time.Millisecond)
+	defer restore()
+	restore = progress.MockOsbuildCmd(makeFakeOsbuild(t, `
+>&3 echo '{"message": "Starting pipeline source org.osbuild.curl", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "source org.osbuild.curl"}}, "timestamp": 1731589338.8}'
+sleep 0.05
+printf 12345 > "`+storeDir+`/sources/org.osbuild.files/sha256:new"
+>&3 echo '{"message": "Starting module org.osbuild.curl", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "source org.osbuild.curl", "stage": {"name": "org.osbuild.curl"}}}, "timestamp": 1731589338.8}'
+>&3 echo '{"message": "Finished module org.osbuild.curl", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "source org.osbuild.curl", "stage": {"name": "org.osbuild.curl"}}}, "timestamp": 1731589340.8, "duration": 2.0}'
+>&3 echo '{"message": "Finished pipeline source org.osbuild.curl", "context": {"origin": "osbuild.monitor", "pipeline": {"name": "source org.osbuild.curl"}}, "timestamp": 1731589340.8, "duration": 2.0}'
+mkdir -p "`+storeDir+`/tmp"
+head -c 1000000 /dev/urandom > "`+storeDir+`/tmp/big"
+sync "`+storeDir+`/tmp/big"
+sleep 0.1
+rm -f "`+storeDir+`/tmp/big"
+`))
+	defer restore()
+
+	reportPath := filepath.Join(t.TempDir(), "metrics.json")
+	pbar, err := progress.New("debug", progress.ProgressConfig{})
+	require.NoError(t, err)
+	restore = progress.MockOsStderr(&bytes.Buffer{})
+	defer restore()
+	err = progress.RunOSBuild(context.Background(), pbar, []byte(`{"fake":"manifest"}`), nil, &progress.OSBuildOptions{
+		StoreDir:      storeDir,
+		MetricsReport: reportPath,
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	var report progress.MetricsReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Len(t, report.Pipelines, 1)
+	assert.Equal(t, "source org.osbuild.curl", report.Pipelines[0].Name)
+	assert.Equal(t, 2.0, report.Pipelines[0].DurationSeconds)
+	assert.False(t, report.Pipelines[0].CacheHit)
+	assert.Equal(t, map[string]int64{"org.osbuild.files": 5}, report.DownloadedBytes)
+	assert.GreaterOrEqual(t, report.PeakStoreGrowthBytes, int64(1000000))
+}
+
+func TestStoreDirUsage(t *testing.T) {
+	storeDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(storeDir, "objects"), 0755))
+	data := bytes.Repeat([]byte("x"), 64*1024)
+	require.NoError(t, os.WriteFile(filepath.Join(storeDir, "objects", "a"), data, 0644))
+
+	used, err := progress.StoreDirUsage(storeDir)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, used, int64(len(data)))
+
+	// hardlinked files are only counted once
+	require.NoError(t, os.Link(filepath.Join(storeDir, "objects", "a"), filepath.Join(storeDir, "objects", "b")))
+	usedWithLink, err := progress.StoreDirUsage(storeDir)
+	require.NoError(t, err)
+	assert.Equal(t, used, usedWithLink)
+
+	_, err = progress.StoreDirUsage(filepath.Join(storeDir, "missing"))
+	assert.Error(t, err)
+}
@@ -83,6 +83,9 @@ type ProgressBar interface {
 type ProgressConfig struct {
 	// file progress only
 	FilePath string
+
+	// jsonl progress only, defaults to stdout
+	Output io.Writer
 }
 
 // New creates a new progressbar based on the requested type
@@ -106,6 +109,8 @@ func New(typ string, config ProgressConfig) (ProgressBar, error) {
 		return NewDebugProgressBar()
 	case "file":
 		return NewFileProgressBar(config.FilePath)
+	case "jsonl":
+		return NewJSONLProgressBar(config.Output)
 	default:
 		return nil, fmt.Errorf("unknown progress type: %q", typ)
 	}